@@ -27,21 +27,24 @@ It's not an abstraction of core Ethereum accounts data type / logic -
 for that see the core processing code of blocks / txs.
 
 Currently this is pretty much a passthrough to the KeyStore2 interface,
-and accounts persistence is derived from stored keys' addresses
+and accounts persistence is derived from stored keys' addresses, which
+are cached in memory and kept up to date by watching the key directory.
+
+Accounts may also be derived from the seed of a hierarchical deterministic
+wallet stored in the key directory, see hdwallet.go.
 
 */
 package accounts
 
 import (
-	"bytes"
 	"crypto/ecdsa"
 	crand "crypto/rand"
 	"errors"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
 )
 
 var (
@@ -55,8 +58,10 @@ type Account struct {
 
 type Manager struct {
 	keyStore crypto.KeyStore2
+	cache    *addrCache
 	unlocked map[string]*unlocked
 	mutex    sync.RWMutex
+	walletMu sync.Mutex // serializes HD wallet file updates
 }
 
 type unlocked struct {
@@ -67,35 +72,59 @@ type unlocked struct {
 func NewManager(keyStore crypto.KeyStore2) *Manager {
 	return &Manager{
 		keyStore: keyStore,
+		cache:    newAddrCache(keyStore.KeyDir()),
 		unlocked: make(map[string]*unlocked),
 	}
 }
 
+// Close stops watching the key directory and ends all subscriptions.
+func (am *Manager) Close() {
+	am.cache.close()
+}
+
+// Subscribe returns a subscription to AccountAddedEvent and AccountRemovedEvent,
+// posted whenever keys are added to or removed from the key directory, also by
+// other processes. Events must be consumed, delivery blocks.
+func (am *Manager) Subscribe() event.Subscription {
+	return am.cache.mux.Subscribe(AccountAddedEvent{}, AccountRemovedEvent{})
+}
+
 func (am *Manager) HasAccount(addr []byte) bool {
-	accounts, _ := am.Accounts()
-	for _, acct := range accounts {
-		if bytes.Compare(acct.Address, addr) == 0 {
-			return true
-		}
-	}
-	return false
+	return am.cache.hasAddress(addr)
 }
 
+// Primary returns the account with the lowest address.
 func (am *Manager) Primary() (addr []byte, err error) {
-	addrs, err := am.keyStore.GetKeyAddresses()
-	if os.IsNotExist(err) {
-		return nil, ErrNoKeys
-	} else if err != nil {
-		return nil, err
-	}
-	if len(addrs) == 0 {
+	accounts := am.cache.accounts()
+	if len(accounts) == 0 {
 		return nil, ErrNoKeys
 	}
-	return addrs[0], nil
+	return accounts[0].Address, nil
 }
 
 func (am *Manager) DeleteAccount(address []byte, auth string) error {
-	return am.keyStore.DeleteKey(address, auth)
+	if _, ok := am.cache.hdPath(address); ok {
+		return ErrHDAccount
+	}
+	if err := am.keyStore.DeleteKey(address, auth); err != nil {
+		return err
+	}
+	am.cache.reload()
+	return nil
+}
+
+// Update changes the passphrase of an existing account, re-encrypting its key
+// with the current parameters of the key store.
+func (am *Manager) Update(addr []byte, authFrom, authTo string) error {
+	if _, ok := am.cache.hdPath(addr); ok {
+		return ErrHDAccount
+	}
+	key, err := am.keyStore.GetKey(addr, authFrom)
+	if err != nil {
+		return err
+	}
+	defer zeroKey(key.PrivateKey)
+	return am.keyStore.StoreKey(key, authTo)
 }
 
 func (am *Manager) Sign(a Account, toSign []byte) (signature []byte, err error) {
@@ -109,10 +138,21 @@ func (am *Manager) Sign(a Account, toSign []byte) (signature []byte, err error)
 	return signature, err
 }
 
+// SignWithPassphrase signs hash with the key of the given account, which is
+// unlocked only for this signature.
+func (am *Manager) SignWithPassphrase(a Account, keyAuth string, toSign []byte) (signature []byte, err error) {
+	key, err := am.getKey(a.Address, keyAuth)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+	return crypto.Sign(toSign, key.PrivateKey)
+}
+
 // TimedUnlock unlocks the account with the given address.
 // When timeout has passed, the account will be locked again.
 func (am *Manager) TimedUnlock(addr []byte, keyAuth string, timeout time.Duration) error {
-	key, err := am.keyStore.GetKey(addr, keyAuth)
+	key, err := am.getKey(addr, keyAuth)
 	if err != nil {
 		return err
 	}
@@ -125,7 +165,7 @@ func (am *Manager) TimedUnlock(addr []byte, keyAuth string, timeout time.Duratio
 // stays unlocked until the program exits or until a TimedUnlock
 // timeout (started after the call to Unlock) expires.
 func (am *Manager) Unlock(addr []byte, keyAuth string) error {
-	key, err := am.keyStore.GetKey(addr, keyAuth)
+	key, err := am.getKey(addr, keyAuth)
 	if err != nil {
 		return err
 	}
@@ -138,23 +178,16 @@ func (am *Manager) NewAccount(auth string) (Account, error) {
 	if err != nil {
 		return Account{}, err
 	}
+	am.cache.reload()
 	return Account{Address: key.Address}, nil
 }
 
+// Accounts returns all the accounts in the key directory, ordered by address.
 func (am *Manager) Accounts() ([]Account, error) {
-	addresses, err := am.keyStore.GetKeyAddresses()
-	if os.IsNotExist(err) {
+	if am.cache.keyDirMissing() {
 		return nil, ErrNoKeys
-	} else if err != nil {
-		return nil, err
-	}
-	accounts := make([]Account, len(addresses))
-	for i, addr := range addresses {
-		accounts[i] = Account{
-			Address: addr,
-		}
 	}
-	return accounts, err
+	return am.cache.accounts(), nil
 }
 
 func (am *Manager) addUnlocked(addr []byte, key *crypto.Key) *unlocked {
@@ -205,7 +238,7 @@ func zeroKey(k *ecdsa.PrivateKey) {
 // USE WITH CAUTION = this will save an unencrypted private key on disk
 // no cli or js interface
 func (am *Manager) Export(path string, addr []byte, keyAuth string) error {
-	key, err := am.keyStore.GetKey(addr, keyAuth)
+	key, err := am.getKey(addr, keyAuth)
 	if err != nil {
 		return err
 	}
@@ -221,6 +254,7 @@ func (am *Manager) Import(path string, keyAuth string) (Account, error) {
 	if err = am.keyStore.StoreKey(key, keyAuth); err != nil {
 		return Account{}, err
 	}
+	am.cache.reload()
 	return Account{Address: key.Address}, nil
 }
 
@@ -233,5 +267,6 @@ func (am *Manager) ImportPreSaleKey(keyJSON []byte, password string) (acc Accoun
 	if err = am.keyStore.StoreKey(key, password); err != nil {
 		return
 	}
+	am.cache.reload()
 	return Account{Address: key.Address}, nil
 }
@@ -1,8 +1,11 @@
 package accounts
 
 import (
+	"bytes"
+	"encoding/hex"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -60,6 +63,72 @@ func TestTimedUnlock(t *testing.T) {
 	}
 }
 
+func TestSignWithPassphrase(t *testing.T) {
+	dir, ks := tmpKeyStore(t, func(dir string) crypto.KeyStore2 {
+		return crypto.NewKeyStorePassphraseParams(dir, crypto.LightScryptN, crypto.LightScryptP)
+	})
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	a1, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	toSign := randentropy.GetEntropyCSPRNG(32)
+	if _, err := am.SignWithPassphrase(a1, "bar", toSign); err == nil {
+		t.Fatal("Signing should've failed with the wrong passphrase")
+	}
+	sig, err := am.SignWithPassphrase(a1, "foo", toSign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(toSign, sig)
+	if err != nil || !bytes.Equal(crypto.PubkeyToAddress(*pub), a1.Address) {
+		t.Fatalf("signer mismatch: have %x (%v), want %x", crypto.PubkeyToAddress(*pub), err, a1.Address)
+	}
+	// The account stays locked
+	if _, err := am.Sign(a1, toSign); err != ErrLocked {
+		t.Fatal("Signing should've failed with ErrLocked, got ", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir, ks := tmpKeyStore(t, func(dir string) crypto.KeyStore2 {
+		return crypto.NewKeyStorePassphraseParams(dir, crypto.LightScryptN, crypto.LightScryptP)
+	})
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	a1, err := am.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Update(a1.Address, "bar", "baz"); err == nil {
+		t.Fatal("Update should've failed with the wrong passphrase")
+	}
+	if err := am.Update(a1.Address, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(a1.Address, "foo"); err == nil {
+		t.Fatal("Unlock should've failed with the old passphrase")
+	}
+	if err := am.Unlock(a1.Address, "bar"); err != nil {
+		t.Fatal("Unlock with the new passphrase failed: ", err)
+	}
+	// The key file is replaced in place, no other files are left behind
+	files, err := ioutil.ReadDir(filepath.Join(dir, hex.EncodeToString(a1.Address)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("key directory contains %d files, want 1", len(files))
+	}
+}
+
 func tmpKeyStore(t *testing.T, new func(string) crypto.KeyStore2) (string, crypto.KeyStore2) {
 	d, err := ioutil.TempDir("", "eth-keystore-test")
 	if err != nil {
@@ -0,0 +1,367 @@
+package accounts
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/howeyc/fsnotify"
+)
+
+// reloadDelay is the time to wait for further changes of the key directory
+// before scanning it, so that bursts of changes cause a single scan.
+const reloadDelay = 100 * time.Millisecond
+
+// AccountAddedEvent is posted when a key appears in the key directory.
+type AccountAddedEvent struct{ Account Account }
+
+// AccountRemovedEvent is posted when a key disappears from the key directory.
+type AccountRemovedEvent struct{ Account Account }
+
+// keyFile is a key file found in the key directory.
+type keyFile struct {
+	addr    []byte    // Address of the key, nil if the file is malformed
+	modTime time.Time // Modification time of the file when it was parsed
+}
+
+// hdWalletFile is the parsed HD wallet file of the key directory.
+type hdWalletFile struct {
+	paths   map[string]crypto.DerivationPath // Derivation paths of the accounts by address
+	modTime time.Time                        // Modification time of the file when it was parsed
+	size    int64                            // Size of the file when it was parsed
+}
+
+// addrCache is an in-memory index of the keys in a key directory, including
+// the accounts derived from the HD wallet. Once the directory exists, it is
+// watched for changes made by other processes, so the keys don't need to be
+// listed and parsed on every access.
+type addrCache struct {
+	keydir string
+	mux    *event.TypeMux
+
+	mu      sync.Mutex
+	files   map[string]keyFile  // Parsed key files by path
+	wallet  hdWalletFile        // Parsed HD wallet file
+	byAddr  map[string][]string // Paths of the key files by address
+	all     []Account           // All accounts, sorted by address
+	missing bool                // Whether the key directory was missing on the last scan
+
+	watcher *fsnotify.Watcher // Key directory watcher, nil if not watching
+	watched map[string]bool   // Directories watched by the watcher
+	quit    chan struct{}     // Quit channel of the watcher loop
+	closed  bool
+}
+
+func newAddrCache(keydir string) *addrCache {
+	return &addrCache{
+		keydir: keydir,
+		mux:    new(event.TypeMux),
+		files:  make(map[string]keyFile),
+		byAddr: make(map[string][]string),
+	}
+}
+
+// accounts returns all the cached accounts, ordered by address.
+func (c *addrCache) accounts() []Account {
+	c.maybeReload()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	accounts := make([]Account, len(c.all))
+	copy(accounts, c.all)
+	return accounts
+}
+
+// hasAddress checks whether a key with the given address is cached.
+func (c *addrCache) hasAddress(addr []byte) bool {
+	c.maybeReload()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.byAddr[string(addr)]) > 0
+}
+
+// hdPath returns the derivation path of an account of the HD wallet, unless
+// the account also has a key file.
+func (c *addrCache) hdPath(addr []byte) (crypto.DerivationPath, bool) {
+	c.maybeReload()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths := c.byAddr[string(addr)]
+	if len(paths) != 1 || paths[0] != filepath.Join(c.keydir, hdWalletFileName) {
+		return nil, false
+	}
+	return c.wallet.paths[string(addr)], true
+}
+
+// keyDirMissing reports whether the key directory didn't exist when last scanned.
+func (c *addrCache) keyDirMissing() bool {
+	c.maybeReload()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.missing
+}
+
+// maybeReload scans the key directory unless it is being watched, in which case
+// the cache is already up to date. Watching is (re)started if possible.
+func (c *addrCache) maybeReload() {
+	c.mu.Lock()
+	if c.watcher != nil || c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.startWatching()
+	c.mu.Unlock()
+
+	c.reload()
+}
+
+// reload scans the key directory, updating the cache and posting events for
+// all added and removed accounts.
+func (c *addrCache) reload() {
+	c.mu.Lock()
+	added, removed := c.scan()
+	c.mu.Unlock()
+
+	for _, account := range removed {
+		c.mux.Post(AccountRemovedEvent{account})
+	}
+	for _, account := range added {
+		c.mux.Post(AccountAddedEvent{account})
+	}
+}
+
+// reloadWallet is like reload, but always parses the HD wallet file. It is
+// used after writing the file, as rewrites within the timestamp resolution of
+// the file system don't change its modification time.
+func (c *addrCache) reloadWallet() {
+	c.mu.Lock()
+	c.wallet.modTime = time.Time{}
+	c.mu.Unlock()
+
+	c.reload()
+}
+
+// scan lists the key directory and parses all new or modified key files. The
+// lock must be held.
+func (c *addrCache) scan() (added, removed []Account) {
+	entries, err := ioutil.ReadDir(c.keydir)
+	if err != nil && !os.IsNotExist(err) {
+		glog.V(logger.Warn).Infof("Failed to scan key directory: %v", err)
+	}
+	c.missing = err != nil
+
+	files := make(map[string]keyFile)
+	dirs := make(map[string]bool)
+	for _, fi := range entries {
+		// Keys are stored as <keydir>/<address>/<address>
+		name := fi.Name()
+		if addr, err := hex.DecodeString(name); err != nil || len(addr) != 20 || !fi.IsDir() {
+			continue
+		}
+		dir := filepath.Join(c.keydir, name)
+		dirs[dir] = true
+
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // key not written yet
+		}
+		if file, ok := c.files[path]; ok && file.modTime.Equal(info.ModTime()) {
+			files[path] = file
+			continue
+		}
+		file := keyFile{modTime: info.ModTime()}
+		switch addr, err := readKeyAddress(path); {
+		case err != nil:
+			glog.V(logger.Warn).Infof("Ignoring malformed key file %s: %v", path, err)
+		case !strings.EqualFold(hex.EncodeToString(addr), name):
+			glog.V(logger.Warn).Infof("Ignoring key file %s: contains key of address %x", path, addr)
+		default:
+			file.addr = addr
+		}
+		files[path] = file
+	}
+	c.files = files
+	c.watchDirs(dirs)
+	c.scanWallet()
+
+	// Index the keys by address, reporting duplicates
+	byAddr := make(map[string][]string)
+	for path, file := range files {
+		if file.addr != nil {
+			byAddr[string(file.addr)] = append(byAddr[string(file.addr)], path)
+		}
+	}
+	for addr := range c.wallet.paths {
+		byAddr[addr] = append(byAddr[addr], filepath.Join(c.keydir, hdWalletFileName))
+	}
+	all := make([]Account, 0, len(byAddr))
+	for addr, paths := range byAddr {
+		if len(paths) > 1 && len(c.byAddr[addr]) != len(paths) {
+			sort.Strings(paths)
+			glog.V(logger.Warn).Infof("Multiple key files for address %x: %s", addr, strings.Join(paths, ", "))
+		}
+		all = append(all, Account{Address: []byte(addr)})
+		if len(c.byAddr[addr]) == 0 {
+			added = append(added, Account{Address: []byte(addr)})
+		}
+	}
+	for addr := range c.byAddr {
+		if len(byAddr[addr]) == 0 {
+			removed = append(removed, Account{Address: []byte(addr)})
+		}
+	}
+	sort.Sort(accountsByAddress(all))
+	sort.Sort(accountsByAddress(added))
+	sort.Sort(accountsByAddress(removed))
+
+	c.byAddr, c.all = byAddr, all
+	return added, removed
+}
+
+// scanWallet parses the HD wallet file if it was modified. The lock must be
+// held.
+func (c *addrCache) scanWallet() {
+	path := filepath.Join(c.keydir, hdWalletFileName)
+	info, err := os.Stat(path)
+	if err != nil {
+		c.wallet = hdWalletFile{}
+		return
+	}
+	if c.wallet.modTime.Equal(info.ModTime()) && c.wallet.size == info.Size() {
+		return
+	}
+	c.wallet = hdWalletFile{paths: make(map[string]crypto.DerivationPath), modTime: info.ModTime(), size: info.Size()}
+
+	wallet, err := readHDWallet(path)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Ignoring malformed HD wallet file %s: %v", path, err)
+		return
+	}
+	for _, account := range wallet.Accounts {
+		c.wallet.paths[string(account.Address)], _ = crypto.ParseDerivationPath(account.Path)
+	}
+}
+
+// startWatching starts watching the key directory for changes. It fails if the
+// directory doesn't exist, in which case it is retried on the next access. The
+// lock must be held.
+func (c *addrCache) startWatching() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		glog.V(logger.Warn).Infof("Failed to watch key directory: %v", err)
+		return
+	}
+	if err := watcher.Watch(c.keydir); err != nil {
+		watcher.Close()
+		return
+	}
+	c.watcher, c.quit = watcher, make(chan struct{})
+	c.watched = make(map[string]bool)
+
+	go c.loop(watcher, c.quit)
+}
+
+// stopWatching stops watching the key directory. The lock must be held.
+func (c *addrCache) stopWatching() {
+	if c.watcher != nil {
+		close(c.quit)
+		c.watcher.Close()
+		c.watcher = nil
+	}
+}
+
+// watchDirs updates the set of watched key subdirectories, as the key files are
+// written after their directories are created. If the key directory itself is
+// gone, watching stops. The lock must be held.
+func (c *addrCache) watchDirs(dirs map[string]bool) {
+	if c.watcher == nil {
+		return
+	}
+	if c.missing {
+		c.stopWatching()
+		return
+	}
+	for dir := range dirs {
+		if !c.watched[dir] && c.watcher.Watch(dir) == nil {
+			c.watched[dir] = true
+		}
+	}
+	for dir := range c.watched {
+		if !dirs[dir] {
+			c.watcher.RemoveWatch(dir)
+			delete(c.watched, dir)
+		}
+	}
+}
+
+// loop reloads the cache whenever the key directory changes.
+func (c *addrCache) loop(watcher *fsnotify.Watcher, quit chan struct{}) {
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-quit:
+			return
+		case <-watcher.Event:
+			if reload == nil {
+				reload = time.After(reloadDelay)
+			}
+		case err := <-watcher.Error:
+			glog.V(logger.Debug).Infof("Key directory watcher error: %v", err)
+		case <-reload:
+			reload = nil
+			c.reload()
+		}
+	}
+}
+
+// close stops watching the key directory and terminates all subscriptions.
+func (c *addrCache) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.stopWatching()
+	c.mu.Unlock()
+
+	c.mux.Stop()
+}
+
+// readKeyAddress parses the address of a (plain or encrypted) key file.
+func readKeyAddress(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var key struct{ Address []byte }
+	if err := json.Unmarshal(blob, &key); err != nil {
+		return nil, err
+	}
+	if len(key.Address) != 20 {
+		return nil, fmt.Errorf("invalid address length %d", len(key.Address))
+	}
+	return key.Address, nil
+}
+
+type accountsByAddress []Account
+
+func (s accountsByAddress) Len() int           { return len(s) }
+func (s accountsByAddress) Less(i, j int) bool { return bytes.Compare(s[i].Address, s[j].Address) < 0 }
+func (s accountsByAddress) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
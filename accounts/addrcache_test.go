@@ -0,0 +1,148 @@
+package accounts
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestCacheScan(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	// Store a few valid keys, checking the ordering later
+	var want []Account
+	for i := 0; i < 3; i++ {
+		key, err := ks.GenerateNewKey(crand.Reader, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, Account{Address: key.Address})
+	}
+	first := want[0].Address
+	for _, account := range want {
+		if bytes.Compare(account.Address, first) < 0 {
+			first = account.Address
+		}
+	}
+	// Add some junk, which should be skipped
+	writeKeyFile(t, dir, "nothex", "{}")
+	writeKeyFile(t, dir, strings.Repeat("ab", 20), "not json")
+	mismatch, _ := json.Marshal(struct{ Address []byte }{bytes.Repeat([]byte{0xef}, 20)})
+	writeKeyFile(t, dir, strings.Repeat("cd", 20), string(mismatch))
+
+	// And a duplicate of the first key, differing in case
+	blob, err := ioutil.ReadFile(filepath.Join(dir, hex.EncodeToString(want[0].Address), hex.EncodeToString(want[0].Address)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKeyFile(t, dir, strings.ToUpper(hex.EncodeToString(want[0].Address)), string(blob))
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	accounts, err := am.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != len(want) {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accounts), len(want))
+	}
+	for i := 1; i < len(accounts); i++ {
+		if bytes.Compare(accounts[i-1].Address, accounts[i].Address) >= 0 {
+			t.Fatalf("accounts not ordered: %x before %x", accounts[i-1].Address, accounts[i].Address)
+		}
+	}
+	for _, account := range want {
+		if !am.HasAccount(account.Address) {
+			t.Errorf("account %x missing", account.Address)
+		}
+	}
+	if primary, err := am.Primary(); err != nil || !bytes.Equal(primary, first) {
+		t.Errorf("primary mismatch: have %x (%v), want %x", primary, err, first)
+	}
+}
+
+func TestCacheMissingKeyDir(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	if _, err := am.Accounts(); err != ErrNoKeys {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrNoKeys)
+	}
+	account, err := am.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !am.HasAccount(account.Address) {
+		t.Fatalf("new account %x missing", account.Address)
+	}
+}
+
+func TestCacheWatch(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	sub := am.Subscribe()
+	defer sub.Unsubscribe()
+
+	if accounts, _ := am.Accounts(); len(accounts) != 0 {
+		t.Fatalf("unexpected accounts: %v", accounts)
+	}
+	// Keys added by other processes should be picked up
+	key, err := crypto.NewKeyStorePlain(dir).GenerateNewKey(crand.Reader, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-sub.Chan():
+		added, ok := ev.(AccountAddedEvent)
+		if !ok || !bytes.Equal(added.Account.Address, key.Address) {
+			t.Fatalf("event mismatch: have %#v, want added %x", ev, key.Address)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("account addition not detected")
+	}
+	if !am.HasAccount(key.Address) {
+		t.Fatalf("added account %x missing", key.Address)
+	}
+	// And so should be removed keys
+	os.RemoveAll(filepath.Join(dir, hex.EncodeToString(key.Address)))
+	select {
+	case ev := <-sub.Chan():
+		removed, ok := ev.(AccountRemovedEvent)
+		if !ok || !bytes.Equal(removed.Account.Address, key.Address) {
+			t.Fatalf("event mismatch: have %#v, want removed %x", ev, key.Address)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("account removal not detected")
+	}
+	if am.HasAccount(key.Address) {
+		t.Fatalf("removed account %x still present", key.Address)
+	}
+}
+
+func writeKeyFile(t *testing.T, dir, name, content string) {
+	if err := os.MkdirAll(filepath.Join(dir, name), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
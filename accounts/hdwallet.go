@@ -0,0 +1,214 @@
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/randentropy"
+)
+
+// The HD wallet is a BIP32 hierarchical deterministic wallet, whose accounts
+// are all derived from a single seed along the BIP44 Ethereum path, so one
+// mnemonic sentence backs up all of them. Only the seed is stored, encrypted
+// by the key store, in a file next to the key files. The file also lists the
+// derived accounts, so they can be listed without the passphrase.
+
+// hdWalletFileName is the name of the HD wallet file in the key directory.
+const hdWalletFileName = "hdwallet"
+
+// hdWalletEntropy is the entropy of new HD wallets in bytes, encoded as 24
+// mnemonic words.
+const hdWalletEntropy = 32
+
+var (
+	ErrNoHDWallet     = errors.New("no HD wallet")
+	ErrHDWalletExists = errors.New("HD wallet already exists")
+	ErrHDAccount      = errors.New("account is derived from the HD wallet")
+)
+
+type hdWalletJSON struct {
+	Seed     json.RawMessage // encrypted by the key store
+	Accounts []hdAccountJSON
+}
+
+type hdAccountJSON struct {
+	Address []byte
+	Path    string
+}
+
+// NewHDWallet creates the HD wallet from fresh entropy, returning the mnemonic
+// sentence. The mnemonic is not stored, it must be written down by the user.
+func (am *Manager) NewHDWallet(auth string) (mnemonic string, err error) {
+	mnemonic, err = crypto.NewBIP39Mnemonic(randentropy.GetEntropyCSPRNG(hdWalletEntropy))
+	if err != nil {
+		return "", err
+	}
+	if err := am.ImportHDWallet(mnemonic, auth); err != nil {
+		return "", err
+	}
+	return mnemonic, nil
+}
+
+// ImportHDWallet creates the HD wallet of an existing BIP39 mnemonic sentence.
+// Accounts used before must be derived again with DeriveAccount.
+func (am *Manager) ImportHDWallet(mnemonic, auth string) error {
+	seed, err := crypto.BIP39Seed(mnemonic, "")
+	if err != nil {
+		return err
+	}
+	am.walletMu.Lock()
+	defer am.walletMu.Unlock()
+
+	if _, err := os.Stat(am.walletPath()); err == nil {
+		return ErrHDWalletExists
+	}
+	encrypted, err := am.keyStore.EncryptSecret(seed, auth)
+	if err != nil {
+		return err
+	}
+	if err := am.writeWallet(&hdWalletJSON{Seed: encrypted}); err != nil {
+		return err
+	}
+	am.cache.reloadWallet()
+	return nil
+}
+
+// DeriveAccount derives the next account of the HD wallet, i.e. the key at
+// the first unused path m/44'/60'/0'/0/i.
+func (am *Manager) DeriveAccount(auth string) (Account, error) {
+	am.walletMu.Lock()
+	defer am.walletMu.Unlock()
+
+	wallet, err := readHDWallet(am.walletPath())
+	if os.IsNotExist(err) {
+		return Account{}, ErrNoHDWallet
+	} else if err != nil {
+		return Account{}, err
+	}
+	master, err := am.hdMasterKey(wallet, auth)
+	if err != nil {
+		return Account{}, err
+	}
+	used := make(map[string]bool)
+	for _, account := range wallet.Accounts {
+		used[account.Path] = true
+	}
+	for i := uint32(len(wallet.Accounts)); i < crypto.HardenedKeyStart; i++ {
+		path := crypto.DefaultHDBasePath.Child(i)
+		if used[path.String()] {
+			continue
+		}
+		key, err := master.Derive(path)
+		if err != nil {
+			continue // no valid key at this index
+		}
+		addr := crypto.PubkeyToAddress(key.PrivateKey.PublicKey)
+		zeroKey(key.PrivateKey)
+
+		wallet.Accounts = append(wallet.Accounts, hdAccountJSON{Address: addr, Path: path.String()})
+		if err := am.writeWallet(wallet); err != nil {
+			return Account{}, err
+		}
+		am.cache.reloadWallet()
+		return Account{Address: addr}, nil
+	}
+	return Account{}, errors.New("HD wallet accounts exhausted")
+}
+
+// getKey returns the key of an account, read from its key file or derived
+// from the HD wallet seed.
+func (am *Manager) getKey(addr []byte, auth string) (*crypto.Key, error) {
+	path, ok := am.cache.hdPath(addr)
+	if !ok {
+		return am.keyStore.GetKey(addr, auth)
+	}
+	wallet, err := readHDWallet(am.walletPath())
+	if err != nil {
+		return nil, err
+	}
+	master, err := am.hdMasterKey(wallet, auth)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	// The wallet file could have been modified to list a different address
+	if derived := crypto.PubkeyToAddress(key.PrivateKey.PublicKey); !bytes.Equal(derived, addr) {
+		zeroKey(key.PrivateKey)
+		return nil, fmt.Errorf("HD wallet key at %v has address %x, want %x", path, derived, addr)
+	}
+	return crypto.NewKeyFromECDSA(key.PrivateKey), nil
+}
+
+// hdMasterKey decrypts the seed of the HD wallet, deriving its master key.
+func (am *Manager) hdMasterKey(wallet *hdWalletJSON, auth string) (*crypto.HDKey, error) {
+	seed, err := am.keyStore.DecryptSecret(wallet.Seed, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+	return crypto.NewHDMasterKey(seed)
+}
+
+func (am *Manager) walletPath() string {
+	return filepath.Join(am.keyStore.KeyDir(), hdWalletFileName)
+}
+
+// writeWallet replaces the HD wallet file atomically.
+func (am *Manager) writeWallet(wallet *hdWalletJSON) error {
+	blob, err := json.Marshal(wallet)
+	if err != nil {
+		return err
+	}
+	dir := am.keyStore.KeyDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "."+hdWalletFileName+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), am.walletPath())
+}
+
+// readHDWallet parses an HD wallet file.
+func readHDWallet(path string) (*hdWalletJSON, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	wallet := new(hdWalletJSON)
+	if err := json.Unmarshal(blob, wallet); err != nil {
+		return nil, err
+	}
+	for _, account := range wallet.Accounts {
+		if len(account.Address) != 20 {
+			return nil, fmt.Errorf("invalid address length %d", len(account.Address))
+		}
+		if _, err := crypto.ParseDerivationPath(account.Path); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
@@ -0,0 +1,151 @@
+package accounts
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/randentropy"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestHDWallet(t *testing.T) {
+	dir, ks := tmpKeyStore(t, func(dir string) crypto.KeyStore2 {
+		return crypto.NewKeyStorePassphraseParams(dir, crypto.LightScryptN, crypto.LightScryptP)
+	})
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	if _, err := am.DeriveAccount("foo"); err != ErrNoHDWallet {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrNoHDWallet)
+	}
+	if err := am.ImportHDWallet(testMnemonic, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.ImportHDWallet(testMnemonic, "foo"); err != ErrHDWalletExists {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrHDWalletExists)
+	}
+	if _, err := am.DeriveAccount("bar"); err == nil {
+		t.Fatal("derived account with wrong passphrase")
+	}
+	// Accounts are derived along the BIP44 path
+	want := []string{"9858effd232b4033e47d90003d41ec34ecaeda94", "6fac4d18c912343bf86fa7049364dd4e424ab9c0"}
+	for i, addr := range want {
+		account, err := am.DeriveAccount("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(account.Address) != addr {
+			t.Errorf("account %d: address mismatch: have %x, want %s", i, account.Address, addr)
+		}
+	}
+	// and are listed, unlocked and used like keystore accounts, also after a restart
+	key, err := ks.GenerateNewKey(randentropy.Reader, "baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.Close()
+	am = NewManager(ks)
+
+	accounts, err := am.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 3 {
+		t.Fatalf("account count mismatch: have %d, want 3", len(accounts))
+	}
+	addr, _ := hex.DecodeString(want[1])
+	if err := am.Unlock(addr, "baz"); err == nil {
+		t.Fatal("unlocked HD account with wrong passphrase")
+	}
+	if err := am.Unlock(addr, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.Unlock(key.Address, "baz"); err != nil {
+		t.Fatal(err)
+	}
+	hash := randentropy.GetEntropyCSPRNG(32)
+	sig, err := am.Sign(Account{Address: addr}, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil || !bytes.Equal(crypto.PubkeyToAddress(*pub), addr) {
+		t.Fatalf("signer mismatch: have %x (%v), want %x", crypto.PubkeyToAddress(*pub), err, addr)
+	}
+	if err := am.DeleteAccount(addr, "foo"); err != ErrHDAccount {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrHDAccount)
+	}
+}
+
+func TestNewHDWallet(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	mnemonic, err := am.NewHDWallet("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crypto.BIP39Entropy(mnemonic); err != nil {
+		t.Fatalf("invalid mnemonic %q: %v", mnemonic, err)
+	}
+	account, err := am.DeriveAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same account is derived by a wallet restored from the mnemonic
+	restoreDir, restoreKs := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(restoreDir)
+
+	restored := NewManager(restoreKs)
+	defer restored.Close()
+
+	if err := restored.ImportHDWallet(mnemonic, ""); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := restored.DeriveAccount(""); err != nil || !bytes.Equal(again.Address, account.Address) {
+		t.Fatalf("restored account mismatch: have %x (%v), want %x", again.Address, err, account.Address)
+	}
+}
+
+func TestHDWalletTampered(t *testing.T) {
+	dir, ks := tmpKeyStore(t, crypto.NewKeyStorePlain)
+	defer os.RemoveAll(dir)
+
+	am := NewManager(ks)
+	defer am.Close()
+
+	if err := am.ImportHDWallet(testMnemonic, ""); err != nil {
+		t.Fatal(err)
+	}
+	first, err := am.DeriveAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := am.DeriveAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	// Swapping the paths of the accounts doesn't change the file size,
+	// and likely not its modification time either.
+	wallet, err := readHDWallet(am.walletPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet.Accounts[0].Path, wallet.Accounts[1].Path = wallet.Accounts[1].Path, wallet.Accounts[0].Path
+	if err := am.writeWallet(wallet); err != nil {
+		t.Fatal(err)
+	}
+	am.cache.reloadWallet()
+
+	if err := am.Unlock(first.Address, ""); err == nil {
+		t.Fatal("unlocked HD account with the key of another path")
+	}
+}
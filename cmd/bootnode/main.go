@@ -25,12 +25,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 func main() {
@@ -40,9 +42,14 @@ func main() {
 		nodeKeyFile = flag.String("nodekey", "", "private key filename")
 		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
 		natdesc     = flag.String("nat", "none", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
+		netrestrict = flag.String("netrestrict", "", "restrict network communication to the given IP networks (CIDR masks)")
+		nodeDB      = flag.String("nodedb", "", "node database path (default: in-memory)")
+		httpAddr    = flag.String("http", "", "serve node table status as JSON on this address")
+		staticFile  = flag.String("staticnodes", "", "periodically write the node table to this static nodes file")
 
-		nodeKey *ecdsa.PrivateKey
-		err     error
+		nodeKey  *ecdsa.PrivateKey
+		restrict *netutil.Netlist
+		err      error
 	)
 	flag.Parse()
 	logger.AddLogSystem(logger.NewStdLogSystem(os.Stdout, log.LstdFlags, logger.DebugLevel))
@@ -71,9 +78,24 @@ func main() {
 		}
 	}
 
-	if _, err := discover.ListenUDP(nodeKey, *listenAddr, natm, ""); err != nil {
+	if *netrestrict != "" {
+		if restrict, err = netutil.ParseNetlist(*netrestrict); err != nil {
+			log.Fatalf("-netrestrict: %v", err)
+		}
+	}
+
+	ntab, err := discover.ListenUDP(nodeKey, *listenAddr, natm, *nodeDB, restrict)
+	if err != nil {
 		log.Fatal(err)
 	}
+	if *httpAddr != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddr, statusHandler(ntab)))
+		}()
+	}
+	if *staticFile != "" {
+		go writeStaticNodesLoop(ntab, *staticFile)
+	}
 	select {}
 }
 
@@ -0,0 +1,100 @@
+/*
+	This file is part of go-ethereum
+
+	go-ethereum is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	go-ethereum is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// staticNodesInterval is the interval at which the static nodes
+// file is rewritten.
+const staticNodesInterval = time.Minute
+
+// status is the JSON document served by the status endpoint.
+type status struct {
+	Self    string                         `json:"self"`
+	Buckets []int                          `json:"buckets"`
+	Nodes   []string                       `json:"nodes"`
+	Packets map[string]discover.PacketStat `json:"packets"`
+}
+
+// statusHandler serves the content of the node table, the fill level
+// of each bucket and the discovery packet statistics as JSON.
+func statusHandler(ntab *discover.Table) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		st := status{
+			Self:    ntab.Self().String(),
+			Buckets: ntab.BucketSizes(),
+			Nodes:   nodeURLs(ntab.Nodes()),
+			Packets: discover.PacketStats(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(enc)
+	})
+}
+
+// writeStaticNodesLoop periodically writes the nodes in the table to
+// file, in the format of the static-nodes.json file read by geth.
+func writeStaticNodesLoop(ntab *discover.Table, file string) {
+	for range time.Tick(staticNodesInterval) {
+		if err := writeStaticNodes(file, ntab.Nodes()); err != nil {
+			log.Println("could not write static nodes:", err)
+		}
+	}
+}
+
+func writeStaticNodes(file string, nodes []*discover.Node) error {
+	enc, err := json.MarshalIndent(nodeURLs(nodes), "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see
+	// a partially written list.
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(enc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), file)
+}
+
+func nodeURLs(nodes []*discover.Node) []string {
+	urls := make([]string, len(nodes))
+	for i, n := range nodes {
+		urls[i] = n.String()
+	}
+	return urls
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 var (
@@ -127,6 +128,9 @@ func (self *VMEnv) GasLimit() *big.Int       { return big.NewInt(1000000000) }
 func (self *VMEnv) VmType() vm.Type          { return vm.StdVmTy }
 func (self *VMEnv) Depth() int               { return 0 }
 func (self *VMEnv) SetDepth(i int)           { self.depth = i }
+func (self *VMEnv) ChainConfig() *params.ChainConfig {
+	return params.DefaultChainConfig
+}
 func (self *VMEnv) GetHash(n uint64) common.Hash {
 	if self.block.Number().Cmp(big.NewInt(int64(n))) == 0 {
 		return self.block.Hash()
@@ -156,6 +160,11 @@ func (self *VMEnv) CallCode(caller vm.ContextRef, addr common.Address, data []by
 	exe := self.vm(&a, data, gas, price, value)
 	return exe.Call(addr, caller)
 }
+func (self *VMEnv) DelegateCall(caller vm.ContextRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	a := caller.Address()
+	exe := self.vm(&a, data, gas, price, common.Big0)
+	return exe.DelegateCall(addr, caller)
+}
 
 func (self *VMEnv) Create(caller vm.ContextRef, data []byte, gas, price, value *big.Int) ([]byte, error, vm.ContextRef) {
 	exe := self.vm(nil, data, gas, price, value)
@@ -8,10 +8,12 @@ import (
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/xeth"
@@ -28,11 +30,32 @@ func (js *jsre) adminBindings() {
 	eth := ethO.Object()
 	eth.Set("pendingTransactions", js.pendingTransactions)
 	eth.Set("resend", js.resend)
+	eth.Set("sign", js.sign)
+
+	js.re.Set("personal", struct{}{})
+	t, _ := js.re.Get("personal")
+	personal := t.Object()
+	personal.Set("sign", js.personalSign)
+	personal.Set("ecRecover", js.ecRecover)
+
+	shhO, _ := js.re.Get("shh")
+	shh := shhO.Object()
+	shh.Set("identities", js.shhIdentities)
+	shh.Set("importIdentity", js.shhImportIdentity)
+	shh.Set("exportIdentity", js.shhExportIdentity)
+	shh.Set("deleteIdentity", js.shhDeleteIdentity)
+	shh.Set("requestMessages", js.shhRequestMessages)
 
 	js.re.Set("admin", struct{}{})
-	t, _ := js.re.Get("admin")
+	t, _ = js.re.Get("admin")
 	admin := t.Object()
 	admin.Set("addPeer", js.addPeer)
+	admin.Set("removePeer", js.removePeer)
+	admin.Set("addTrustedPeer", js.addTrustedPeer)
+	admin.Set("removeTrustedPeer", js.removeTrustedPeer)
+	admin.Set("newPeerEventFilter", js.newPeerEventFilter)
+	admin.Set("getPeerEventChanges", js.getPeerEventChanges)
+	admin.Set("uninstallPeerEventFilter", js.uninstallPeerEventFilter)
 	admin.Set("startRPC", js.startRPC)
 	admin.Set("stopRPC", js.stopRPC)
 	admin.Set("nodeInfo", js.nodeInfo)
@@ -52,6 +75,14 @@ func (js *jsre) adminBindings() {
 	miner.Set("hashrate", js.hashrate)
 	miner.Set("setExtra", js.setExtra)
 
+	admin.Set("clique", struct{}{})
+	t, _ = admin.Get("clique")
+	clique := t.Object()
+	clique.Set("signers", js.cliqueSigners)
+	clique.Set("proposals", js.cliqueProposals)
+	clique.Set("propose", js.cliquePropose)
+	clique.Set("discard", js.cliqueDiscard)
+
 	admin.Set("debug", struct{}{})
 	t, _ = admin.Get("debug")
 	debug := t.Object()
@@ -61,6 +92,7 @@ func (js *jsre) adminBindings() {
 	debug.Set("getBlockRlp", js.getBlockRlp)
 	debug.Set("setHead", js.setHead)
 	debug.Set("processBlock", js.debugBlock)
+	debug.Set("metrics", js.metrics)
 }
 
 func (js *jsre) getBlock(call otto.FunctionCall) (*types.Block, error) {
@@ -226,6 +258,80 @@ func (js *jsre) hashrate(otto.FunctionCall) otto.Value {
 	return js.re.ToVal(js.ethereum.Miner().HashRate())
 }
 
+func (js *jsre) clique() (*clique.Clique, error) {
+	engine, ok := js.ethereum.Engine().(*clique.Clique)
+	if !ok {
+		return nil, errors.New("node is not running the clique proof-of-authority engine")
+	}
+	return engine, nil
+}
+
+func (js *jsre) cliqueSigners(call otto.FunctionCall) otto.Value {
+	engine, err := js.clique()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	signers, err := engine.Signers(js.ethereum.ChainManager())
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	list := make([]string, len(signers))
+	for i, signer := range signers {
+		list[i] = signer.Hex()
+	}
+	return js.re.ToVal(list)
+}
+
+func (js *jsre) cliqueProposals(call otto.FunctionCall) otto.Value {
+	engine, err := js.clique()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	proposals := make(map[string]bool)
+	for address, auth := range engine.Proposals() {
+		proposals[address.Hex()] = auth
+	}
+	return js.re.ToVal(proposals)
+}
+
+func (js *jsre) cliquePropose(call otto.FunctionCall) otto.Value {
+	engine, err := js.clique()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	auth, err := call.Argument(1).ToBoolean()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	engine.Propose(common.HexToAddress(addr), auth)
+	return otto.TrueValue()
+}
+
+func (js *jsre) cliqueDiscard(call otto.FunctionCall) otto.Value {
+	engine, err := js.clique()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	engine.Discard(common.HexToAddress(addr))
+	return otto.TrueValue()
+}
+
 func (js *jsre) backtrace(call otto.FunctionCall) otto.Value {
 	tracestr, err := call.Argument(0).ToString()
 	if err != nil {
@@ -328,6 +434,65 @@ func (js *jsre) addPeer(call otto.FunctionCall) otto.Value {
 	return otto.TrueValue()
 }
 
+func (js *jsre) removePeer(call otto.FunctionCall) otto.Value {
+	return js.peerCall(call, js.ethereum.RemovePeer)
+}
+
+func (js *jsre) addTrustedPeer(call otto.FunctionCall) otto.Value {
+	return js.peerCall(call, js.ethereum.AddTrustedPeer)
+}
+
+func (js *jsre) removeTrustedPeer(call otto.FunctionCall) otto.Value {
+	return js.peerCall(call, js.ethereum.RemoveTrustedPeer)
+}
+
+// peerCall runs fn with the node URL passed as the first argument.
+func (js *jsre) peerCall(call otto.FunctionCall, fn func(string) error) otto.Value {
+	nodeURL, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	if err := fn(nodeURL); err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	return otto.TrueValue()
+}
+
+func (js *jsre) newPeerEventFilter(call otto.FunctionCall) otto.Value {
+	messages := false
+	if len(call.ArgumentList) > 0 {
+		var err error
+		if messages, err = call.Argument(0).ToBoolean(); err != nil {
+			fmt.Println(err)
+			return otto.UndefinedValue()
+		}
+	}
+	return js.re.ToVal(js.xeth.NewPeerEventFilter(messages))
+}
+
+func (js *jsre) getPeerEventChanges(call otto.FunctionCall) otto.Value {
+	id, err := call.Argument(0).ToInteger()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(js.xeth.PeerEventsChanged(int(id)))
+}
+
+func (js *jsre) uninstallPeerEventFilter(call otto.FunctionCall) otto.Value {
+	id, err := call.Argument(0).ToInteger()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	if js.xeth.UninstallPeerEventFilter(int(id)) {
+		return otto.TrueValue()
+	}
+	return otto.FalseValue()
+}
+
 func (js *jsre) unlock(call otto.FunctionCall) otto.Value {
 	addr, err := call.Argument(0).ToString()
 	if err != nil {
@@ -401,10 +566,127 @@ func (js *jsre) nodeInfo(call otto.FunctionCall) otto.Value {
 	return js.re.ToVal(js.ethereum.NodeInfo())
 }
 
+func (js *jsre) metrics(call otto.FunctionCall) otto.Value {
+	return js.re.ToVal(metrics.Snapshot(metrics.DefaultRegistry))
+}
+
 func (js *jsre) peers(call otto.FunctionCall) otto.Value {
 	return js.re.ToVal(js.ethereum.PeersInfo())
 }
 
+func (js *jsre) whisper() (*xeth.Whisper, error) {
+	if js.ethereum.Whisper() == nil {
+		return nil, errors.New("whisper is not enabled, start with --shh")
+	}
+	return js.xeth.Whisper(), nil
+}
+
+func (js *jsre) shhIdentities(call otto.FunctionCall) otto.Value {
+	shh, err := js.whisper()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(shh.Identities())
+}
+
+func (js *jsre) shhImportIdentity(call otto.FunctionCall) otto.Value {
+	shh, err := js.whisper()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	key, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	id, err := shh.ImportIdentity(key)
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(id)
+}
+
+func (js *jsre) shhExportIdentity(call otto.FunctionCall) otto.Value {
+	shh, err := js.whisper()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	id, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	key, err := shh.ExportIdentity(id)
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(key)
+}
+
+func (js *jsre) shhDeleteIdentity(call otto.FunctionCall) otto.Value {
+	shh, err := js.whisper()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	id, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	if err := shh.DeleteIdentity(id); err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	return otto.TrueValue()
+}
+
+func (js *jsre) shhRequestMessages(call otto.FunctionCall) otto.Value {
+	shh, err := js.whisper()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	if len(call.ArgumentList) < 3 {
+		fmt.Println("requires peer id, from and to time and optional topics")
+		return otto.FalseValue()
+	}
+	peer, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	from, err := call.Argument(1).ToInteger()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	to, err := call.Argument(2).ToInteger()
+	if err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	var topics []string
+	for _, arg := range call.ArgumentList[3:] {
+		topic, err := arg.ToString()
+		if err != nil {
+			fmt.Println(err)
+			return otto.FalseValue()
+		}
+		topics = append(topics, topic)
+	}
+	if err := shh.RequestMessages(peer, uint32(from), uint32(to), topics); err != nil {
+		fmt.Println(err)
+		return otto.FalseValue()
+	}
+	return otto.TrueValue()
+}
+
 func (js *jsre) importChain(call otto.FunctionCall) otto.Value {
 	if len(call.ArgumentList) == 0 {
 		fmt.Println("err: require file name")
@@ -524,3 +806,75 @@ func newTx(t *types.Transaction) *tx {
 		GasPrice: t.GasPrice().String(),
 	}
 }
+
+func (js *jsre) sign(call otto.FunctionCall) otto.Value {
+	addr, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	data, err := call.Argument(1).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	sig, err := js.xeth.Sign(addr, data, false)
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(sig)
+}
+
+func (js *jsre) personalSign(call otto.FunctionCall) otto.Value {
+	data, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	addr, err := call.Argument(1).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	arg := call.Argument(2)
+	var passphrase string
+	if arg.IsUndefined() {
+		fmt.Println("Please enter a passphrase now.")
+		passphrase, err = readPassword("Passphrase: ", true)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+	} else {
+		passphrase, err = arg.ToString()
+		if err != nil {
+			fmt.Println(err)
+			return otto.UndefinedValue()
+		}
+	}
+	sig, err := js.xeth.SignWithPassphrase(addr, data, passphrase)
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(sig)
+}
+
+func (js *jsre) ecRecover(call otto.FunctionCall) otto.Value {
+	data, err := call.Argument(0).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	sig, err := call.Argument(1).ToString()
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	addr, err := js.xeth.EcRecover(data, sig)
+	if err != nil {
+		fmt.Println(err)
+		return otto.UndefinedValue()
+	}
+	return js.re.ToVal(addr)
+}
@@ -103,6 +103,62 @@ The output of this command is supposed to be machine-readable.
 				},
 			},
 		},
+		{
+			Name:  "hdwallet",
+			Usage: "manage the hierarchical deterministic wallet",
+			Description: `
+
+The HD wallet derives any number of accounts from a single seed, which is
+encoded as a mnemonic sentence of 24 words (BIP39). Writing down this sentence
+backs up all accounts of the wallet, the seed is stored encrypted with your
+passphrase in <DATADIR>/keys.
+
+Accounts are derived along the BIP44 paths m/44'/60'/0'/0/i and are used like
+any other account, e.g. unlocked with --unlock.
+`,
+			Subcommands: []cli.Command{
+				{
+					Action: hdWalletCreate,
+					Name:   "new",
+					Usage:  "create a new HD wallet",
+					Description: `
+
+    ethereum hdwallet new
+
+Creates a new HD wallet and prints its mnemonic sentence. Write it down, it
+is the only backup of the wallet and is not shown again.
+
+The seed is saved in encrypted format, you are prompted for a passphrase.
+`,
+				},
+				{
+					Action: hdWalletImport,
+					Name:   "import",
+					Usage:  "import an HD wallet from its mnemonic sentence",
+					Description: `
+
+    ethereum hdwallet import
+
+Restores an HD wallet from its mnemonic sentence, which you are prompted for.
+Previously used accounts have to be derived again with 'hdwallet derive'.
+
+The seed is saved in encrypted format, you are prompted for a passphrase.
+`,
+				},
+				{
+					Action: hdWalletDerive,
+					Name:   "derive",
+					Usage:  "derive the next account of the HD wallet",
+					Description: `
+
+    ethereum hdwallet derive
+
+Derives the next account of the HD wallet and prints its address. You are
+prompted for the passphrase of the wallet.
+`,
+				},
+			},
+		},
 		{
 			Action: accountList,
 			Name:   "account",
@@ -186,6 +242,31 @@ this import mechanism is not needed when you transfer an account between
 nodes.
 					`,
 				},
+				{
+					Action: accountUpdate,
+					Name:   "update",
+					Usage:  "change the passphrase of an existing account",
+					Description: `
+
+    ethereum account update <address>
+
+Changes the passphrase of an existing account. You are prompted for the current
+passphrase and for the new one.
+
+The key is re-encrypted with the current scrypt parameters, so this can also be
+used to migrate keys to stronger (or, with --lightkdf, weaker) parameters, or
+to this key store's format if the key file is a Web3 Secret Storage one. The key
+file is replaced atomically.
+
+For non-interactive use the passphrases can be specified with the --password flag,
+the current one on the first line of the file and the new one on the second:
+
+    ethereum --password <passwordfile> account update <address>
+
+Note, this is meant to be used for testing only, it is a bad idea to save your
+password to file or expose in any other way.
+					`,
+				},
 			},
 		},
 		{
@@ -236,6 +317,7 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/Javascipt-Conso
 		utils.IdentityFlag,
 		utils.UnlockedAccountFlag,
 		utils.PasswordFileFlag,
+		utils.LightKDFFlag,
 		utils.BootnodesFlag,
 		utils.DataDirFlag,
 		utils.BlockchainVersionFlag,
@@ -245,7 +327,15 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/Javascipt-Conso
 		utils.EtherbaseFlag,
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
+		utils.DevModeFlag,
+		utils.DevPeriodFlag,
+		utils.CliqueSignersFlag,
+		utils.CliquePeriodFlag,
+		utils.CliqueEpochFlag,
+		utils.ChainConfigFlag,
 		utils.NATFlag,
+		utils.NetrestrictFlag,
+		utils.BanDurationFlag,
 		utils.NatspecEnabledFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -253,6 +343,10 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/Javascipt-Conso
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.WhisperEnabledFlag,
+		utils.WhisperMinPoWFlag,
+		utils.WhisperMailServerFlag,
+		utils.WhisperTopicFilterFlag,
+		utils.WhisperPasswordFileFlag,
 		utils.VMDebugFlag,
 		utils.ProtocolVersionFlag,
 		utils.NetworkIdFlag,
@@ -265,11 +359,15 @@ JavaScript API. See https://github.com/ethereum/go-ethereum/wiki/Javascipt-Conso
 		utils.LogJSONFlag,
 		utils.PProfEanbledFlag,
 		utils.PProfPortFlag,
+		utils.MetricsAddrFlag,
 	}
 	app.Before = func(ctx *cli.Context) error {
 		if ctx.GlobalBool(utils.PProfEanbledFlag.Name) {
 			utils.StartPProf(ctx)
 		}
+		if ctx.GlobalString(utils.MetricsAddrFlag.Name) != "" {
+			utils.StartMetrics(ctx)
+		}
 		return nil
 	}
 
@@ -382,7 +480,7 @@ func startEth(ctx *cli.Context, eth *eth.Ethereum) {
 			utils.Fatalf("Error starting RPC: %v", err)
 		}
 	}
-	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) {
+	if ctx.GlobalBool(utils.MiningEnabledFlag.Name) || ctx.GlobalBool(utils.DevModeFlag.Name) {
 		if err := eth.StartMining(); err != nil {
 			utils.Fatalf("%v", err)
 		}
@@ -441,6 +539,75 @@ func accountCreate(ctx *cli.Context) {
 	fmt.Printf("Address: %x\n", acct)
 }
 
+func accountUpdate(ctx *cli.Context) {
+	account := ctx.Args().First()
+	if len(account) == 0 {
+		utils.Fatalf("account address must be given as argument")
+	}
+	addr := common.FromHex(account)
+	if len(addr) != 20 {
+		utils.Fatalf("Invalid account address '%s'", account)
+	}
+	am := utils.GetAccountManager(ctx)
+	if !am.HasAccount(addr) {
+		utils.Fatalf("Unknown account %x", addr)
+	}
+
+	var oldPassphrase, newPassphrase string
+	if passfile := ctx.GlobalString(utils.PasswordFileFlag.Name); len(passfile) > 0 {
+		passbytes, err := ioutil.ReadFile(passfile)
+		if err != nil {
+			utils.Fatalf("Unable to read password file '%s': %v", passfile, err)
+		}
+		lines := strings.Split(strings.TrimRight(string(passbytes), "\r\n"), "\n")
+		if len(lines) != 2 {
+			utils.Fatalf("Password file '%s' must contain the current and the new passphrase on two lines", passfile)
+		}
+		oldPassphrase, newPassphrase = strings.TrimRight(lines[0], "\r"), strings.TrimRight(lines[1], "\r")
+	} else {
+		oldPassphrase = getPassPhrase(ctx, "Unlocking account. Please give the current password.", false)
+		newPassphrase = getPassPhrase(ctx, "Please give a new password. Do not forget this password.", true)
+	}
+	if err := am.Update(addr, oldPassphrase, newPassphrase); err != nil {
+		utils.Fatalf("Could not update the account: %v", err)
+	}
+	fmt.Printf("Address: %x\n", addr)
+}
+
+func hdWalletCreate(ctx *cli.Context) {
+	am := utils.GetAccountManager(ctx)
+	passphrase := getPassPhrase(ctx, "Your new HD wallet is locked with a password. Please give a password. Do not forget this password.", true)
+	mnemonic, err := am.NewHDWallet(passphrase)
+	if err != nil {
+		utils.Fatalf("Could not create the HD wallet: %v", err)
+	}
+	fmt.Println("Write down the mnemonic of your wallet, it is the only way to restore its accounts:")
+	fmt.Println()
+	fmt.Println(mnemonic)
+}
+
+func hdWalletImport(ctx *cli.Context) {
+	mnemonic, err := readPassword("Mnemonic: ", true)
+	if err != nil {
+		utils.Fatalf("%v", err)
+	}
+	am := utils.GetAccountManager(ctx)
+	passphrase := getPassPhrase(ctx, "Your HD wallet is locked with a password. Please give a password. Do not forget this password.", true)
+	if err := am.ImportHDWallet(mnemonic, passphrase); err != nil {
+		utils.Fatalf("Could not import the HD wallet: %v", err)
+	}
+}
+
+func hdWalletDerive(ctx *cli.Context) {
+	am := utils.GetAccountManager(ctx)
+	passphrase := getPassPhrase(ctx, "Unlocking HD wallet. Please give the password.", false)
+	acct, err := am.DeriveAccount(passphrase)
+	if err != nil {
+		utils.Fatalf("Could not derive the account: %v", err)
+	}
+	fmt.Printf("Address: %x\n", acct)
+}
+
 func importWallet(ctx *cli.Context) {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
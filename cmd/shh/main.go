@@ -0,0 +1,205 @@
+/*
+	This file is part of go-ethereum
+
+	go-ethereum is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	go-ethereum is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Command shh runs a whisper node, serving the shh_* JSON-RPC methods without
+// the eth protocol and the blockchain.
+package main
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/whisper"
+	"github.com/ethereum/go-ethereum/whisper/mailserver"
+	"github.com/ethereum/go-ethereum/xeth"
+	"github.com/rs/cors"
+)
+
+const (
+	Version = "0.1.0"
+
+	filterTimeout = 5 * time.Minute // Time after which unpolled RPC filters are removed
+)
+
+func main() {
+	var (
+		listenAddr  = flag.String("addr", ":30303", "listen address")
+		nodeKeyFile = flag.String("nodekey", "", "private key filename (default: random key)")
+		nodeKeyHex  = flag.String("nodekeyhex", "", "private key as hex (for testing)")
+		natdesc     = flag.String("nat", "any", "port mapping mechanism (any|none|upnp|pmp|extip:<IP>)")
+		nodeDB      = flag.String("nodedb", "", "node database path (default: in-memory)")
+		bootnodes   = flag.String("bootnodes", "", "comma separated enode URLs for P2P discovery bootstrap")
+		maxPeers    = flag.Int("maxpeers", 25, "maximum number of network peers")
+		rpcAddr     = flag.String("rpcaddr", "127.0.0.1:8545", "JSON-RPC listen address")
+		rpcCors     = flag.String("rpccorsdomain", "", "domain from which to accept cross origin JSON-RPC requests")
+		minPoW      = flag.Float64("minpow", whisper.DefaultMinimumPoW, "minimum proof of work of accepted envelopes")
+		topicFilter = flag.Bool("topicfilter", false, "only receive envelopes matching the watched topics (doesn't relay others)")
+		keyDir      = flag.String("keydir", "", "persist identities in this key store directory (default: in-memory)")
+		keyPassFile = flag.String("keypassword", "", "file containing the password of the key store (default: empty password)")
+		mailDir     = flag.String("maildir", "", "archive envelopes in this directory and serve them to trusted nodes")
+		mailTrusted = flag.String("mailtrusted", "", "comma separated enode URLs of nodes allowed to request archived envelopes")
+		verbosity   = flag.Int("verbosity", 3, "logging verbosity (0-6)")
+
+		nodeKey *ecdsa.PrivateKey
+		err     error
+	)
+	flag.Parse()
+	glog.SetToStderr(true)
+	glog.SetV(*verbosity)
+
+	natm, err := nat.Parse(*natdesc)
+	if err != nil {
+		log.Fatalf("-nat: %v", err)
+	}
+	switch {
+	case *nodeKeyFile != "" && *nodeKeyHex != "":
+		log.Fatal("Options -nodekey and -nodekeyhex are mutually exclusive")
+	case *nodeKeyFile != "":
+		if nodeKey, err = crypto.LoadECDSA(*nodeKeyFile); err != nil {
+			log.Fatalf("-nodekey: %v", err)
+		}
+	case *nodeKeyHex != "":
+		if nodeKey, err = crypto.HexToECDSA(*nodeKeyHex); err != nil {
+			log.Fatalf("-nodekeyhex: %v", err)
+		}
+	default:
+		if nodeKey, err = crypto.GenerateKey(); err != nil {
+			log.Fatalf("could not generate key: %v", err)
+		}
+	}
+	bootNodes, err := parseNodes(*bootnodes)
+	if err != nil {
+		log.Fatalf("-bootnodes: %v", err)
+	}
+	trusted, err := parseNodes(*mailTrusted)
+	if err != nil {
+		log.Fatalf("-mailtrusted: %v", err)
+	}
+
+	// Assemble the whisper client, archiving envelopes if requested
+	shh := whisper.New()
+	shh.SetMinimumPoW(*minPoW)
+	shh.SetTopicFiltering(*topicFilter)
+
+	if *keyDir != "" {
+		var password []byte
+		if *keyPassFile != "" {
+			if password, err = ioutil.ReadFile(*keyPassFile); err != nil {
+				log.Fatalf("-keypassword: %v", err)
+			}
+		}
+		if err := shh.SetKeyStore(crypto.NewKeyStorePassphrase(*keyDir), string(password)); err != nil {
+			log.Fatalf("-keydir: %v", err)
+		}
+	}
+
+	var mail *mailserver.MailServer
+	if *mailDir != "" {
+		ids := make([]discover.NodeID, len(trusted))
+		for i, n := range trusted {
+			ids[i] = n.ID
+		}
+		if mail, err = mailserver.New(*mailDir, ids); err != nil {
+			log.Fatalf("-maildir: %v", err)
+		}
+		shh.RegisterMailServer(mail)
+	}
+
+	// Start the P2P server hosting only the whisper protocol
+	server := &p2p.Server{
+		PrivateKey:     nodeKey,
+		MaxPeers:       *maxPeers,
+		Name:           common.MakeName("Shh", Version),
+		BootstrapNodes: bootNodes,
+		NodeDatabase:   *nodeDB,
+		Protocols:      []p2p.Protocol{shh.Protocol()},
+		ListenAddr:     *listenAddr,
+		NAT:            natm,
+	}
+	if err := server.Start(); err != nil {
+		log.Fatalf("could not start P2P server: %v", err)
+	}
+	shh.Start()
+	glog.Infof("Whisper node started: %s", server.Self())
+
+	// Serve the shh_* JSON-RPC methods
+	api := xeth.NewWhisper(shh)
+	go expireFilters(api)
+	if *rpcAddr != "" {
+		handler := rpc.WhisperJSONRPC(api)
+		if *rpcCors != "" {
+			handler = cors.New(cors.Options{
+				AllowedMethods: []string{"POST"},
+				AllowedOrigins: []string{*rpcCors},
+			}).Handler(handler)
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(*rpcAddr, handler))
+		}()
+		glog.Infof("JSON-RPC listening on %s", *rpcAddr)
+	}
+
+	// Run until interrupted
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	<-sigc
+	glog.Infoln("Got interrupt, shutting down...")
+
+	server.Stop()
+	shh.Stop()
+	if mail != nil {
+		mail.Close()
+	}
+}
+
+// parseNodes parses a comma separated list of enode URLs.
+func parseNodes(urls string) ([]*discover.Node, error) {
+	var nodes []*discover.Node
+	for _, url := range strings.Split(urls, ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// expireFilters periodically removes the RPC message filters not polled for
+// longer than filterTimeout.
+func expireFilters(api *xeth.Whisper) {
+	for range time.Tick(2 * time.Second) {
+		api.ExpireFilters(filterTimeout)
+	}
+}
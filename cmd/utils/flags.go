@@ -2,17 +2,24 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"path"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codegangsta/cli"
-	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
@@ -20,8 +27,12 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/whisper"
 	"github.com/ethereum/go-ethereum/xeth"
 )
 
@@ -117,6 +128,38 @@ var (
 		Value: "primary",
 	}
 
+	DevModeFlag = cli.BoolFlag{
+		Name:  "dev",
+		Usage: "Development mode: ephemeral chain with a pre-funded, unlocked developer account and instant block sealing",
+	}
+	DevPeriodFlag = cli.IntFlag{
+		Name:  "dev.period",
+		Usage: "Block period in seconds for development mode (0 = seal as soon as transactions are pending)",
+		Value: 0,
+	}
+
+	CliqueSignersFlag = cli.StringFlag{
+		Name:  "clique.signers",
+		Usage: "Comma separated addresses of the initial proof-of-authority signers. Enables the clique consensus engine instead of proof-of-work",
+		Value: "",
+	}
+	CliquePeriodFlag = cli.IntFlag{
+		Name:  "clique.period",
+		Usage: "Number of seconds between proof-of-authority blocks",
+		Value: int(clique.DefaultConfig.Period),
+	}
+	CliqueEpochFlag = cli.IntFlag{
+		Name:  "clique.epoch",
+		Usage: "Number of blocks after which proof-of-authority votes are reset and the signers checkpointed",
+		Value: int(clique.DefaultConfig.Epoch),
+	}
+
+	ChainConfigFlag = cli.StringFlag{
+		Name:  "chainconfig",
+		Usage: "JSON file scheduling protocol upgrades by block number, e.g. {\"homesteadBlock\": 1000}. By default the main network rules apply",
+		Value: "",
+	}
+
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
 		Usage: "Unlock the account given until this program exits (prompts for password). '--unlock primary' unlocks the primary account",
@@ -127,6 +170,10 @@ var (
 		Usage: "Path to password file to use with options and subcommands needing a password",
 		Value: "",
 	}
+	LightKDFFlag = cli.BoolFlag{
+		Name:  "lightkdf",
+		Usage: "Encrypt new and updated keys with weaker scrypt parameters, trading key security for less CPU and memory use (e.g. for tests)",
+	}
 
 	// logging and debug settings
 	LogFileFlag = cli.StringFlag{
@@ -170,6 +217,11 @@ var (
 		Usage: "Port on which the profiler should listen",
 		Value: 6060,
 	}
+	MetricsAddrFlag = cli.StringFlag{
+		Name:  "metricsaddr",
+		Usage: "Serve metrics in the Prometheus text format on this address (e.g. localhost:6061), disabled if empty",
+		Value: "",
+	}
 
 	// RPC settings
 	RPCEnabledFlag = cli.BoolFlag{
@@ -220,10 +272,38 @@ var (
 		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
 		Value: "any",
 	}
+	NetrestrictFlag = cli.StringFlag{
+		Name:  "netrestrict",
+		Usage: "Restricts network communication to the given IP networks (CIDR masks, comma-separated)",
+		Value: "",
+	}
+	BanDurationFlag = cli.IntFlag{
+		Name:  "banduration",
+		Usage: "Minutes for which peers with bad reputation are banned",
+		Value: 60,
+	}
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable whisper",
 	}
+	WhisperTopicFilterFlag = cli.BoolFlag{
+		Name:  "shhtopicfilter",
+		Usage: "Only receive whisper envelopes matching the watched topics (doesn't relay others)",
+	}
+	WhisperPasswordFileFlag = cli.StringFlag{
+		Name:  "shhpassword",
+		Usage: "Path to the password file of the whisper identity key store (default: empty password)",
+		Value: "",
+	}
+	WhisperMailServerFlag = cli.BoolFlag{
+		Name:  "shhmailserver",
+		Usage: "Archive whisper envelopes and deliver them to trusted nodes on request",
+	}
+	WhisperMinPoWFlag = cli.StringFlag{
+		Name:  "shhminpow",
+		Usage: "Minimum proof of work of accepted whisper envelopes",
+		Value: strconv.FormatFloat(whisper.DefaultMinimumPoW, 'g', -1, 64),
+	}
 	JSpathFlag = cli.StringFlag{
 		Name:  "jspath",
 		Usage: "JS library path to be used with console and js subcommands",
@@ -239,6 +319,44 @@ func GetNAT(ctx *cli.Context) nat.Interface {
 	return natif
 }
 
+// GetWhisperMinPoW parses the minimum whisper proof of work given by the
+// shhminpow flag.
+func GetWhisperMinPoW(ctx *cli.Context) float64 {
+	pow, err := strconv.ParseFloat(ctx.GlobalString(WhisperMinPoWFlag.Name), 64)
+	if err != nil || pow < 0 {
+		Fatalf("Option %s: invalid value %q", WhisperMinPoWFlag.Name, ctx.GlobalString(WhisperMinPoWFlag.Name))
+	}
+	return pow
+}
+
+// GetWhisperPassword reads the passphrase of the whisper identity key store from
+// the file given by the shhpassword flag.
+func GetWhisperPassword(ctx *cli.Context) string {
+	file := ctx.GlobalString(WhisperPasswordFileFlag.Name)
+	if file == "" {
+		return ""
+	}
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		Fatalf("Unable to read whisper password file '%s': %v", file, err)
+	}
+	return string(blob)
+}
+
+// GetNetRestrict parses the networks given by the netrestrict flag. It returns
+// nil if the flag is not set.
+func GetNetRestrict(ctx *cli.Context) *netutil.Netlist {
+	list := ctx.GlobalString(NetrestrictFlag.Name)
+	if list == "" {
+		return nil
+	}
+	netrestrict, err := netutil.ParseNetlist(list)
+	if err != nil {
+		Fatalf("Option %s: %v", NetrestrictFlag.Name, err)
+	}
+	return netrestrict
+}
+
 func GetNodeKey(ctx *cli.Context) (key *ecdsa.PrivateKey) {
 	hex, file := ctx.GlobalString(NodeKeyHexFlag.Name), ctx.GlobalString(NodeKeyFileFlag.Name)
 	var err error
@@ -271,7 +389,7 @@ func MakeEthConfig(clientID, version string, ctx *cli.Context) *eth.Config {
 		clientID += "/" + customName
 	}
 
-	return &eth.Config{
+	cfg := &eth.Config{
 		Name:               common.MakeName(clientID, version),
 		DataDir:            ctx.GlobalString(DataDirFlag.Name),
 		ProtocolVersion:    ctx.GlobalInt(ProtocolVersionFlag.Name),
@@ -288,12 +406,83 @@ func MakeEthConfig(clientID, version string, ctx *cli.Context) *eth.Config {
 		MaxPeers:           ctx.GlobalInt(MaxPeersFlag.Name),
 		Port:               ctx.GlobalString(ListenPortFlag.Name),
 		NAT:                GetNAT(ctx),
+		NetRestrict:        GetNetRestrict(ctx),
+		BanDuration:        time.Duration(ctx.GlobalInt(BanDurationFlag.Name)) * time.Minute,
 		NatSpec:            ctx.GlobalBool(NatspecEnabledFlag.Name),
 		NodeKey:            GetNodeKey(ctx),
 		Shh:                ctx.GlobalBool(WhisperEnabledFlag.Name),
+		ShhMinPoW:          GetWhisperMinPoW(ctx),
+		ShhMailServer:      ctx.GlobalBool(WhisperMailServerFlag.Name),
+		ShhTopicFiltering:  ctx.GlobalBool(WhisperTopicFilterFlag.Name),
+		ShhKeyPassword:     GetWhisperPassword(ctx),
 		Dial:               true,
 		BootNodes:          ctx.GlobalString(BootnodesFlag.Name),
 	}
+	cfg.Clique, cfg.CliqueSigners = MakeCliqueConfig(ctx)
+	cfg.ChainConfig = MakeChainConfig(ctx)
+	if ctx.GlobalBool(DevModeFlag.Name) {
+		setDevConfig(cfg, ctx)
+	}
+	return cfg
+}
+
+// MakeChainConfig loads the protocol upgrade schedule given on the command
+// line, falling back to the rules of the main network.
+func MakeChainConfig(ctx *cli.Context) *params.ChainConfig {
+	file := ctx.GlobalString(ChainConfigFlag.Name)
+	if file == "" {
+		return params.DefaultChainConfig
+	}
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		Fatalf("Could not read chain config: %v", err)
+	}
+	config := new(params.ChainConfig)
+	if err := json.Unmarshal(blob, config); err != nil {
+		Fatalf("Invalid chain config %s: %v", file, err)
+	}
+	return config
+}
+
+// MakeCliqueConfig returns the proof-of-authority parameters and initial
+// signers given on the command line, or a nil config if clique is not used.
+func MakeCliqueConfig(ctx *cli.Context) (*clique.Config, []common.Address) {
+	list := ctx.GlobalString(CliqueSignersFlag.Name)
+	if list == "" {
+		return nil, nil
+	}
+	var signers []common.Address
+	for _, hex := range strings.Split(list, ",") {
+		hex = strings.TrimSpace(hex)
+		if len(common.FromHex(hex)) != len(common.Address{}) {
+			Fatalf("Invalid clique signer address: %q", hex)
+		}
+		signers = append(signers, common.HexToAddress(hex))
+	}
+	config := &clique.Config{
+		Period: uint64(ctx.GlobalInt(CliquePeriodFlag.Name)),
+		Epoch:  uint64(ctx.GlobalInt(CliqueEpochFlag.Name)),
+	}
+	return config, signers
+}
+
+// setDevConfig turns cfg into an isolated development node: all state lives
+// in memory or in a throwaway data directory, removed by the node when it is
+// stopped, and networking is disabled.
+func setDevConfig(cfg *eth.Config, ctx *cli.Context) {
+	dataDir, err := ioutil.TempDir("", "ethereum_dev_")
+	if err != nil {
+		Fatalf("Could not create development data directory: %v", err)
+	}
+	cfg.DataDir = dataDir
+	cfg.NewDB = func(path string) (common.Database, error) { return ethdb.NewMemDatabase() }
+	cfg.AccountManager = accounts.NewManager(crypto.NewKeyStorePlain(path.Join(dataDir, "keys")))
+	cfg.Dev = true
+	cfg.DevPeriod = time.Duration(ctx.GlobalInt(DevPeriodFlag.Name)) * time.Second
+	cfg.Etherbase = ""
+	cfg.MaxPeers = 0 // disable network
+	cfg.Dial = false
+	cfg.NAT = nil
 }
 
 func GetChain(ctx *cli.Context) (*core.ChainManager, common.Database, common.Database) {
@@ -315,10 +504,21 @@ func GetChain(ctx *cli.Context) (*core.ChainManager, common.Database, common.Dat
 	}
 
 	eventMux := new(event.TypeMux)
-	chainManager := core.NewChainManager(blockDb, stateDb, eventMux)
-	pow := ethash.New()
+	chainConfig := MakeChainConfig(ctx)
+	var (
+		chainManager *core.ChainManager
+		engine       consensus.Engine
+	)
+	if config, signers := MakeCliqueConfig(ctx); config != nil {
+		genesis := core.GenesisBlockWithExtra(stateDb, clique.GenesisExtra(signers))
+		chainManager = core.NewChainManagerWithGenesis(blockDb, stateDb, genesis, chainConfig, eventMux)
+		engine = clique.New(*config, extraDb)
+	} else {
+		chainManager = core.NewChainManagerWithGenesis(blockDb, stateDb, core.GenesisBlock(stateDb), chainConfig, eventMux)
+		engine = ethash.New()
+	}
 	txPool := core.NewTxPool(eventMux, chainManager.State, chainManager.GasLimit)
-	blockProcessor := core.NewBlockProcessor(stateDb, extraDb, pow, txPool, chainManager, eventMux)
+	blockProcessor := core.NewBlockProcessor(stateDb, extraDb, engine, txPool, chainManager, eventMux)
 	chainManager.SetProcessor(blockProcessor)
 
 	return chainManager, blockDb, stateDb
@@ -326,7 +526,11 @@ func GetChain(ctx *cli.Context) (*core.ChainManager, common.Database, common.Dat
 
 func GetAccountManager(ctx *cli.Context) *accounts.Manager {
 	dataDir := ctx.GlobalString(DataDirFlag.Name)
-	ks := crypto.NewKeyStorePassphrase(path.Join(dataDir, "keys"))
+	scryptN, scryptP := crypto.StandardScryptN, crypto.StandardScryptP
+	if ctx.GlobalBool(LightKDFFlag.Name) {
+		scryptN, scryptP = crypto.LightScryptN, crypto.LightScryptP
+	}
+	ks := crypto.NewKeyStorePassphraseParams(path.Join(dataDir, "keys"), scryptN, scryptP)
 	return accounts.NewManager(ks)
 }
 
@@ -341,6 +545,16 @@ func StartRPC(eth *eth.Ethereum, ctx *cli.Context) error {
 	return rpc.Start(xeth, config)
 }
 
+// StartMetrics serves the metrics of the default registry under /metrics.
+func StartMetrics(ctx *cli.Context) {
+	address := ctx.GlobalString(MetricsAddrFlag.Name)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(metrics.DefaultRegistry))
+	go func() {
+		log.Println(http.ListenAndServe(address, mux))
+	}()
+}
+
 func StartPProf(ctx *cli.Context) {
 	address := fmt.Sprintf("localhost:%d", ctx.GlobalInt(PProfPortFlag.Name))
 	go func() {
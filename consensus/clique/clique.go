@@ -0,0 +1,554 @@
+// Package clique implements a proof-of-authority consensus engine. A fixed
+// set of authorized signers, initially listed in the extra-data of the
+// genesis block, take turns sealing blocks by signing their headers. Signers
+// may vote to authorize or deauthorize accounts through the coinbase and nonce
+// fields of the headers they seal.
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	wiggleTime = 500 * time.Millisecond // Random delay (per signer) to allow concurrent signers
+
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
+	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
+
+	addressLength = 20 // Length of a signer address in the extra-data
+)
+
+var (
+	nonceAuthVote = common.Hex2Bytes("ffffffffffffffff") // Magic nonce number to vote on adding a new signer
+	nonceDropVote = common.Hex2Bytes("0000000000000000") // Magic nonce number to vote on removing a signer
+
+	diffInTurn = big.NewInt(2) // Block difficulty for in-turn signatures
+	diffNoTurn = big.NewInt(1) // Block difficulty for out-of-turn signatures
+)
+
+var (
+	errUnknownBlock              = errors.New("unknown block")
+	errInvalidCheckpointCoinbase = errors.New("coinbase in checkpoint block non-zero")
+	errInvalidVote               = errors.New("vote nonce not 0x00..0 or 0xff..f")
+	errInvalidCheckpointVote     = errors.New("vote nonce in checkpoint block non-zero")
+	errMissingVanity             = errors.New("extra-data 32 byte vanity prefix missing")
+	errMissingSignature          = errors.New("extra-data 65 byte suffix signature missing")
+	errExtraSigners              = errors.New("non-checkpoint block contains extra signer list")
+	errInvalidCheckpointSigners  = errors.New("invalid signer list on checkpoint block")
+	errInvalidMixDigest          = errors.New("non-zero mix digest")
+	errInvalidDifficulty         = errors.New("invalid difficulty")
+	errInvalidVotingChain        = errors.New("invalid voting chain")
+	errUnauthorized              = errors.New("unauthorized signer")
+	errRecentlySigned            = errors.New("recently signed")
+	errUnclesNotAllowed          = errors.New("uncles not allowed")
+)
+
+// Config holds the parameters of a proof-of-authority network.
+type Config struct {
+	Period uint64 // Number of seconds between blocks to enforce, must be non-zero
+	Epoch  uint64 // Epoch length to reset votes and checkpoint the signer list
+}
+
+// DefaultConfig is used for the parameters left unset in a Config.
+var DefaultConfig = Config{Period: 15, Epoch: 30000}
+
+// SignerFn is a callback to sign a hash with the key of the given account.
+type SignerFn func(signer common.Address, hash []byte) ([]byte, error)
+
+// Clique is the proof-of-authority consensus engine.
+type Clique struct {
+	config *Config
+	db     common.Database // Database to store and retrieve snapshot checkpoints
+
+	cacheMu    sync.Mutex
+	recents    map[common.Hash]*Snapshot // Snapshots of recent blocks to speed up reorgs
+	recentList []common.Hash             // Insertion order of recents for eviction
+	signatures map[common.Hash]common.Address
+
+	lock      sync.RWMutex
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+	signer    common.Address          // Account of the local signing key
+	signFn    SignerFn                // Signer function to authorize hashes with
+}
+
+// New creates a proof-of-authority consensus engine with the initial signers
+// taken from the genesis block.
+func New(config Config, db common.Database) *Clique {
+	if config.Period == 0 {
+		config.Period = DefaultConfig.Period
+	}
+	if config.Epoch == 0 {
+		config.Epoch = DefaultConfig.Epoch
+	}
+	return &Clique{
+		config:     &config,
+		db:         db,
+		recents:    make(map[common.Hash]*Snapshot),
+		signatures: make(map[common.Hash]common.Address),
+		proposals:  make(map[common.Address]bool),
+	}
+}
+
+// GenesisExtra assembles the extra-data of a genesis block authorizing the
+// given initial signers.
+func GenesisExtra(signers []common.Address) []byte {
+	extra := make([]byte, extraVanity, extraVanity+len(signers)*addressLength+extraSeal)
+	for _, signer := range signers {
+		extra = append(extra, signer[:]...)
+	}
+	return append(extra, make([]byte, extraSeal)...)
+}
+
+// sigHash returns the hash which is used as input for the proof-of-authority
+// signing. It is the hash of the entire header apart from the 65 byte
+// signature contained at the end of the extra data.
+func sigHash(header *types.Header) (hash common.Hash) {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-extraSeal],
+		header.MixDigest,
+		header.Nonce,
+	})
+	return crypto.Sha3Hash(enc)
+}
+
+// ecrecover extracts the account address from a signed header.
+func (self *Clique) ecrecover(header *types.Header) (common.Address, error) {
+	hash := header.Hash()
+
+	self.cacheMu.Lock()
+	signer, known := self.signatures[hash]
+	self.cacheMu.Unlock()
+	if known {
+		return signer, nil
+	}
+	if len(header.Extra) < extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	signature := header.Extra[len(header.Extra)-extraSeal:]
+
+	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	copy(signer[:], crypto.Sha3(pubkey[1:])[12:])
+
+	self.cacheMu.Lock()
+	if len(self.signatures) >= inmemorySignatures {
+		self.signatures = make(map[common.Hash]common.Address)
+	}
+	self.signatures[hash] = signer
+	self.cacheMu.Unlock()
+
+	return signer, nil
+}
+
+// Author retrieves the account that sealed the given header.
+func (self *Clique) Author(header *types.Header) (common.Address, error) {
+	return self.ecrecover(header)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules,
+// including the signature of an authorized signer.
+func (self *Clique) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	number := header.Number.Uint64()
+
+	if new(big.Int).Sub(header.Number, parent.Number).Cmp(common.Big1) != 0 {
+		return consensus.ErrInvalidNumber
+	}
+	// Don't waste time checking blocks from the future
+	if int64(header.Time) > time.Now().Unix() {
+		return consensus.ErrFutureBlock
+	}
+	if header.Time < parent.Time+self.config.Period {
+		return consensus.ErrInvalidTimestamp
+	}
+	// Checkpoint blocks need to enforce zero beneficiary and a signer list
+	checkpoint := number%self.config.Epoch == 0
+	if checkpoint && header.Coinbase != (common.Address{}) {
+		return errInvalidCheckpointCoinbase
+	}
+	if !bytes.Equal(header.Nonce[:], nonceAuthVote) && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidVote
+	}
+	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		return errInvalidCheckpointVote
+	}
+	if len(header.Extra) < extraVanity {
+		return errMissingVanity
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	signersBytes := len(header.Extra) - extraVanity - extraSeal
+	if !checkpoint && signersBytes != 0 {
+		return errExtraSigners
+	}
+	if checkpoint && signersBytes%addressLength != 0 {
+		return errInvalidCheckpointSigners
+	}
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	if header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0 && header.Difficulty.Cmp(diffNoTurn) != 0) {
+		return errInvalidDifficulty
+	}
+	// block.gasLimit - parent.gasLimit <= parent.gasLimit / GasLimitBoundDivisor
+	a := new(big.Int).Sub(header.GasLimit, parent.GasLimit)
+	a.Abs(a)
+	b := new(big.Int).Div(parent.GasLimit, params.GasLimitBoundDivisor)
+	if !(a.Cmp(b) < 0) || (header.GasLimit.Cmp(params.MinGasLimit) == -1) {
+		return errors.New("invalid gas limit")
+	}
+	// All basic checks passed, verify against the signers of the parent
+	snap, err := self.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	if checkpoint {
+		signers := make([]byte, 0, len(snap.Signers)*addressLength)
+		for _, signer := range snap.signers() {
+			signers = append(signers, signer[:]...)
+		}
+		if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], signers) {
+			return errInvalidCheckpointSigners
+		}
+	}
+	return self.verifySeal(snap, header)
+}
+
+// verifySeal checks that the header was signed by a signer authorized in the
+// snapshot of its parent, who didn't sign too recently, and that the
+// difficulty reflects whether it was the signer's turn.
+func (self *Clique) verifySeal(snap *Snapshot, header *types.Header) error {
+	number := header.Number.Uint64()
+
+	signer, err := self.ecrecover(header)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Signers[signer]; !ok {
+		return errUnauthorized
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only fail if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); number < limit || seen > number-limit {
+				return errRecentlySigned
+			}
+		}
+	}
+	inturn := snap.inturn(number, signer)
+	if inturn && header.Difficulty.Cmp(diffInTurn) != 0 {
+		return errInvalidDifficulty
+	}
+	if !inturn && header.Difficulty.Cmp(diffNoTurn) != 0 {
+		return errInvalidDifficulty
+	}
+	return nil
+}
+
+// VerifyUncles rejects any block containing uncles, they are meaningless
+// without proof-of-work.
+func (self *Clique) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errUnclesNotAllowed
+	}
+	return nil
+}
+
+// CalcDifficulty returns the difficulty a block sealed by the local signer on
+// top of parent should have: higher if it's the signer's turn. If the signers
+// of parent can't be determined, the out-of-turn difficulty is assumed.
+func (self *Clique) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	snap, err := self.snapshot(chain, parent.Number.Uint64(), parent.Hash())
+	if err != nil {
+		return new(big.Int).Set(diffNoTurn)
+	}
+	self.lock.RLock()
+	signer := self.signer
+	self.lock.RUnlock()
+
+	return calcDifficulty(snap, signer)
+}
+
+func calcDifficulty(snap *Snapshot, signer common.Address) *big.Int {
+	if snap.inturn(snap.Number+1, signer) {
+		return new(big.Int).Set(diffInTurn)
+	}
+	return new(big.Int).Set(diffNoTurn)
+}
+
+// Prepare fills in the consensus fields of a header: a random pending vote,
+// the difficulty, the signer list on checkpoints, room for the signature and
+// the earliest timestamp the block may be sealed at.
+func (self *Clique) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	header.Coinbase = common.Address{}
+	header.SetNonce(0)
+	header.MixDigest = common.Hash{}
+
+	number := header.Number.Uint64()
+	parent := chain.GetBlock(header.ParentHash)
+	if parent == nil {
+		return errUnknownBlock
+	}
+	snap, err := self.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	self.lock.RLock()
+	if number%self.config.Epoch != 0 {
+		// Gather all the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(self.proposals))
+		for address, authorize := range self.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			header.Coinbase = addresses[rand.Intn(len(addresses))]
+			if self.proposals[header.Coinbase] {
+				copy(header.Nonce[:], nonceAuthVote)
+			}
+		}
+	}
+	header.Difficulty = calcDifficulty(snap, self.signer)
+	self.lock.RUnlock()
+
+	// Keep the miner's vanity, add the signers on checkpoints and the seal room
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, make([]byte, extraVanity-len(header.Extra))...)
+	}
+	extra := append([]byte{}, header.Extra[:extraVanity]...)
+	if number%self.config.Epoch == 0 {
+		for _, signer := range snap.signers() {
+			extra = append(extra, signer[:]...)
+		}
+	}
+	header.Extra = append(extra, make([]byte, extraSeal)...)
+
+	header.Time = parent.Header().Time + self.config.Period
+	if now := uint64(time.Now().Unix()); header.Time < now {
+		header.Time = now
+	}
+	return nil
+}
+
+// Finalize does nothing, there are no block rewards in proof-of-authority.
+func (self *Clique) Finalize(chain consensus.ChainReader, state *state.StateDB, block *types.Block) {
+}
+
+// Seal waits until the block may be sealed by the local signer and signs it.
+// Nil is returned without an error if the local signer must not seal this
+// block because it has signed too recently.
+func (self *Clique) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	self.lock.RLock()
+	signer, signFn := self.signer, self.signFn
+	self.lock.RUnlock()
+	if signFn == nil {
+		return nil, errors.New("no signing key authorized")
+	}
+
+	snap, err := self.snapshot(chain, number-1, header.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	if _, authorized := snap.Signers[signer]; !authorized {
+		return nil, errUnauthorized
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only wait if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); number < limit || seen > number-limit {
+				glog.V(logger.Detail).Infof("Signed recently, must wait for others\n")
+				return nil, nil
+			}
+		}
+	}
+	// Sweet, the protocol permits us to sign the block, wait for our time
+	delay := time.Unix(int64(header.Time), 0).Sub(time.Now())
+	if header.Difficulty.Cmp(diffNoTurn) == 0 {
+		// It's not our turn explicitly to sign, delay it a bit
+		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
+		delay += time.Duration(rand.Int63n(int64(wiggle)))
+	}
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	// Sign all the things! The extra-data may be shared with other copies of
+	// the block, so the signature goes into a fresh slice.
+	sighash, err := signFn(signer, sigHash(header).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]byte, len(header.Extra))
+	copy(extra, header.Extra)
+	copy(extra[len(extra)-extraSeal:], sighash)
+	header.Extra = extra
+
+	return block, nil
+}
+
+// Authorize injects the account and signing function used to seal blocks.
+func (self *Clique) Authorize(signer common.Address, signFn SignerFn) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.signer = signer
+	self.signFn = signFn
+}
+
+// Propose injects a new authorization proposal the local signer will vote
+// on in the blocks it seals.
+func (self *Clique) Propose(address common.Address, auth bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.proposals[address] = auth
+}
+
+// Discard drops a currently running proposal, stopping the signer from
+// casting further votes (either for or against).
+func (self *Clique) Discard(address common.Address) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	delete(self.proposals, address)
+}
+
+// Proposals returns the current proposals the local signer votes on.
+func (self *Clique) Proposals() map[common.Address]bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	proposals := make(map[common.Address]bool)
+	for address, auth := range self.proposals {
+		proposals[address] = auth
+	}
+	return proposals
+}
+
+// Signers retrieves the list of authorized signers at the head of the chain.
+func (self *Clique) Signers(chain consensus.ChainReader) ([]common.Address, error) {
+	head := chain.CurrentBlock()
+	snap, err := self.snapshot(chain, head.NumberU64(), head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return snap.signers(), nil
+}
+
+// snapshot retrieves the authorization snapshot at a given point in time.
+func (self *Clique) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash) (*Snapshot, error) {
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		self.cacheMu.Lock()
+		s, ok := self.recents[hash]
+		self.cacheMu.Unlock()
+		if ok {
+			snap = s
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(self.config, self.db, hash); err == nil {
+				glog.V(logger.Detail).Infof("Loaded voting snapshot from disk: #%d %x\n", number, hash[:4])
+				snap = s
+				break
+			}
+		}
+		block := chain.GetBlock(hash)
+		if block == nil {
+			return nil, errUnknownBlock
+		}
+		// The genesis block lists the initial signers
+		if number == 0 {
+			extra := block.Header().Extra
+			if len(extra) < extraVanity+extraSeal || (len(extra)-extraVanity-extraSeal)%addressLength != 0 {
+				return nil, errInvalidCheckpointSigners
+			}
+			signers := make([]common.Address, (len(extra)-extraVanity-extraSeal)/addressLength)
+			for i := 0; i < len(signers); i++ {
+				copy(signers[i][:], extra[extraVanity+i*addressLength:])
+			}
+			snap = newSnapshot(self.config, 0, hash, signers)
+			if err := snap.store(self.db); err != nil {
+				return nil, err
+			}
+			break
+		}
+		// No snapshot for this header, gather it and move backward
+		headers = append(headers, block.Header())
+		number, hash = number-1, block.ParentHash()
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers, self.ecrecover)
+	if err != nil {
+		return nil, err
+	}
+	self.cacheMu.Lock()
+	if _, ok := self.recents[snap.Hash]; !ok {
+		self.recents[snap.Hash] = snap
+		self.recentList = append(self.recentList, snap.Hash)
+		if len(self.recentList) > inmemorySnapshots {
+			delete(self.recents, self.recentList[0])
+			self.recentList = self.recentList[1:]
+		}
+	}
+	self.cacheMu.Unlock()
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(self.db); err != nil {
+			return nil, err
+		}
+		glog.V(logger.Detail).Infof("Stored voting snapshot to disk: #%d %x\n", snap.Number, snap.Hash[:4])
+	}
+	return snap, nil
+}
@@ -0,0 +1,285 @@
+package clique
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// testerAccountPool is a pool to maintain currently active tester accounts,
+// mapped from textual names used in the tests below to actual keys.
+type testerAccountPool struct {
+	accounts map[string]*ecdsa.PrivateKey
+}
+
+func newTesterAccountPool() *testerAccountPool {
+	return &testerAccountPool{accounts: make(map[string]*ecdsa.PrivateKey)}
+}
+
+func (ap *testerAccountPool) sign(header *types.Header, signer string) {
+	sig, _ := crypto.Sign(sigHash(header).Bytes(), ap.key(signer))
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+}
+
+func (ap *testerAccountPool) address(account string) common.Address {
+	if account == "" {
+		return common.Address{}
+	}
+	return common.BytesToAddress(crypto.PubkeyToAddress(ap.key(account).PublicKey))
+}
+
+func (ap *testerAccountPool) key(account string) *ecdsa.PrivateKey {
+	if ap.accounts[account] == nil {
+		ap.accounts[account], _ = crypto.GenerateKey()
+	}
+	return ap.accounts[account]
+}
+
+// testerVote represents a single block signed by a particular account, where
+// the account may or may not have cast a clique vote.
+type testerVote struct {
+	signer string
+	voted  string
+	auth   bool
+}
+
+// Tests that clique signer voting is evaluated correctly for various simple
+// and complex scenarios.
+func TestVoting(t *testing.T) {
+	tests := []struct {
+		epoch   uint64
+		signers []string
+		votes   []testerVote
+		results []string
+		err     error
+	}{
+		{
+			// Single signer, no votes cast
+			signers: []string{"A"},
+			votes:   []testerVote{{signer: "A"}},
+			results: []string{"A"},
+		}, {
+			// Single signer, voting to add two others (only accept first, second needs 2 votes)
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: true},
+				{signer: "B"},
+				{signer: "A", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Two signers, voting to add three others (only accept first two, third needs 3 votes already)
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: true},
+				{signer: "B", voted: "C", auth: true},
+				{signer: "A", voted: "D", auth: true},
+				{signer: "B", voted: "D", auth: true},
+				{signer: "C"},
+				{signer: "A", voted: "E", auth: true},
+				{signer: "B", voted: "E", auth: true},
+			},
+			results: []string{"A", "B", "C", "D"},
+		}, {
+			// Single signer, dropping itself (weird, but one less cornercase by explicitly allowing this)
+			signers: []string{"A"},
+			votes:   []testerVote{{signer: "A", voted: "A", auth: false}},
+			results: []string{},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them
+			signers: []string{"A", "B"},
+			votes:   []testerVote{{signer: "A", voted: "B", auth: false}},
+			results: []string{"A", "B"},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: false},
+				{signer: "B", voted: "B", auth: false},
+			},
+			results: []string{"A"},
+		}, {
+			// Three signers, two of them deciding to drop the third
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Cascading changes are not allowed, only the account being voted on may change
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B"},
+				{signer: "C"},
+				{signer: "A", voted: "D", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "C"},
+				{signer: "A"},
+				{signer: "B", voted: "D", auth: false},
+				{signer: "C", voted: "D", auth: false},
+			},
+			results: []string{"A", "B", "C"},
+		}, {
+			// Votes from deauthorized signers are discarded immediately (auth votes)
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "C", voted: "D", auth: true},
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "A", voted: "D", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Four signers need three votes to pass, further votes are ignored
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "E", auth: true},
+				{signer: "B", voted: "E", auth: true},
+				{signer: "C", voted: "E", auth: true},
+				{signer: "D", voted: "E", auth: true},
+			},
+			results: []string{"A", "B", "C", "D", "E"},
+		}, {
+			// Epoch transitions reset all votes to allow chain checkpointing
+			epoch:   3,
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: true},
+				{signer: "B"},
+				{signer: "A"}, // Checkpoint block, (don't vote here, it's validated outside of snapshots)
+				{signer: "B", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// An unauthorized signer should not be able to sign blocks
+			signers: []string{"A"},
+			votes:   []testerVote{{signer: "B"}},
+			err:     errUnauthorized,
+		}, {
+			// An authorized signer that signed recently should not be able to sign again
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A"},
+				{signer: "A"},
+			},
+			err: errRecentlySigned,
+		},
+	}
+	for i, tt := range tests {
+		accounts := newTesterAccountPool()
+
+		signers := make([]common.Address, len(tt.signers))
+		for j, signer := range tt.signers {
+			signers[j] = accounts.address(signer)
+		}
+		config := Config{Period: 1, Epoch: tt.epoch}
+		db, _ := ethdb.NewMemDatabase()
+		engine := New(config, db)
+
+		headers := make([]*types.Header, len(tt.votes))
+		for j, vote := range tt.votes {
+			headers[j] = &types.Header{
+				Number:   big.NewInt(int64(j) + 1),
+				Time:     uint64(j) * config.Period,
+				Coinbase: accounts.address(vote.voted),
+				Extra:    make([]byte, extraVanity+extraSeal),
+			}
+			if j > 0 {
+				headers[j].ParentHash = headers[j-1].Hash()
+			}
+			if vote.auth {
+				copy(headers[j].Nonce[:], nonceAuthVote)
+			}
+			accounts.sign(headers[j], vote.signer)
+		}
+		snap := newSnapshot(engine.config, 0, common.Hash{}, signers)
+		snap, err := snap.apply(headers, engine.ecrecover)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		result := snap.signers()
+		if len(result) != len(tt.results) {
+			t.Errorf("test %d: signers mismatch: have %x, want %v", i, result, tt.results)
+			continue
+		}
+		for _, name := range tt.results {
+			if _, ok := snap.Signers[accounts.address(name)]; !ok {
+				t.Errorf("test %d: signer %s missing from %x", i, name, result)
+			}
+		}
+	}
+}
+
+// Tests that a signer who signed recently can't seal another block even at
+// heights below the recent signer limit.
+func TestVerifySealRecentAtLowHeight(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	signers := make([]common.Address, 5)
+	for i, name := range []string{"A", "B", "C", "D", "E"} {
+		signers[i] = accounts.address(name)
+	}
+	db, _ := ethdb.NewMemDatabase()
+	engine := New(Config{Period: 1}, db)
+
+	headers := make([]*types.Header, 2)
+	for i := range headers {
+		headers[i] = &types.Header{
+			Number:     big.NewInt(int64(i) + 1),
+			Time:       uint64(i),
+			Difficulty: new(big.Int).Set(diffNoTurn),
+			Extra:      make([]byte, extraVanity+extraSeal),
+		}
+		if i > 0 {
+			headers[i].ParentHash = headers[i-1].Hash()
+		}
+		accounts.sign(headers[i], "A")
+	}
+	snap, err := newSnapshot(engine.config, 0, common.Hash{}, signers).apply(headers[:1], engine.ecrecover)
+	if err != nil {
+		t.Fatalf("failed to apply first block: %v", err)
+	}
+	if err := engine.verifySeal(snap, headers[1]); err != errRecentlySigned {
+		t.Errorf("error mismatch: have %v, want %v", err, errRecentlySigned)
+	}
+}
+
+// Tests that snapshots survive a round trip through the database.
+func TestSnapshotStore(t *testing.T) {
+	accounts := newTesterAccountPool()
+	db, _ := ethdb.NewMemDatabase()
+
+	config := &Config{Period: 1, Epoch: 30000}
+	snap := newSnapshot(config, 10, common.Hash{1}, []common.Address{accounts.address("A"), accounts.address("B")})
+	snap.Recents[10] = accounts.address("A")
+	snap.cast(accounts.address("C"), true)
+	snap.Votes = append(snap.Votes, &Vote{Signer: accounts.address("A"), Block: 10, Address: accounts.address("C"), Authorize: true})
+
+	if err := snap.store(db); err != nil {
+		t.Fatalf("failed to store snapshot: %v", err)
+	}
+	loaded, err := loadSnapshot(config, db, snap.Hash)
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if loaded.Number != snap.Number || loaded.Hash != snap.Hash {
+		t.Errorf("position mismatch: have #%d %x, want #%d %x", loaded.Number, loaded.Hash, snap.Number, snap.Hash)
+	}
+	if len(loaded.Signers) != 2 || loaded.Recents[10] != accounts.address("A") {
+		t.Errorf("signers mismatch: have %v / %v", loaded.Signers, loaded.Recents)
+	}
+	if len(loaded.Votes) != 1 || !loaded.Votes[0].Authorize || loaded.Tally[accounts.address("C")].Votes != 1 {
+		t.Errorf("votes mismatch: have %v / %v", loaded.Votes, loaded.Tally)
+	}
+}
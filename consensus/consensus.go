@@ -0,0 +1,80 @@
+// Package consensus defines the interface through which the block chain
+// verifies, finalises and seals blocks, decoupling it from any particular
+// consensus algorithm.
+package consensus
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrFutureBlock is returned when a block's timestamp lies too far ahead
+	// of the local clock. Such blocks are queued rather than rejected.
+	ErrFutureBlock = errors.New("block time is in the future")
+
+	// ErrInvalidNumber is returned if a block's number doesn't equal its
+	// parent's plus one.
+	ErrInvalidNumber = errors.New("block number invalid")
+
+	// ErrInvalidTimestamp is returned if a block's timestamp doesn't advance
+	// far enough past its parent's.
+	ErrInvalidTimestamp = errors.New("block time stamp equal to previous")
+)
+
+// UncleError is returned by VerifyUncles if an uncle isn't unique or isn't
+// related to a recent ancestor of the block. Other failures, such as too many
+// uncles or an invalid uncle header, are returned as plain errors.
+type UncleError string
+
+func (err UncleError) Error() string { return string(err) }
+
+// ChainReader is the subset of the chain manager an engine needs in order to
+// inspect the ancestry of the blocks it verifies or seals.
+type ChainReader interface {
+	Config() *params.ChainConfig
+	CurrentBlock() *types.Block
+	GetBlock(hash common.Hash) *types.Block
+	GetBlockByNumber(number uint64) *types.Block
+	GetAncestors(block *types.Block, length int) []*types.Block
+}
+
+// Engine is an algorithm agnostic consensus engine.
+type Engine interface {
+	// VerifyHeader checks whether a header conforms to the consensus rules
+	// of the engine, given its parent. This includes the seal.
+	VerifyHeader(chain ChainReader, header, parent *types.Header) error
+
+	// VerifyUncles verifies that the uncles of the given block conform to
+	// the consensus rules of the engine.
+	VerifyUncles(chain ChainReader, block *types.Block) error
+
+	// CalcDifficulty returns the difficulty a block created at the given
+	// time on top of parent should have.
+	CalcDifficulty(chain ChainReader, time uint64, parent *types.Header) *big.Int
+
+	// Prepare initialises the consensus fields of a block header which is
+	// about to be filled with transactions and sealed.
+	Prepare(chain ChainReader, header *types.Header) error
+
+	// Finalize applies any post-transaction state modifications, such as
+	// block rewards, before the state root of the block is computed.
+	Finalize(chain ChainReader, state *state.StateDB, block *types.Block)
+
+	// Seal generates a sealed version of the given block. It blocks until
+	// the block is sealed or stop is closed, in which case nil is returned.
+	Seal(chain ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error)
+}
+
+// PoW is a consensus engine based on proof-of-work.
+type PoW interface {
+	Engine
+
+	// HashRate returns the current mining hashrate of the engine.
+	HashRate() int64
+}
@@ -0,0 +1,240 @@
+// Package ethash implements the proof-of-work consensus engine of the
+// Ethereum frontier release.
+package ethash
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/ethash"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/pow"
+	"gopkg.in/fatih/set.v0"
+)
+
+var (
+	BlockReward = big.NewInt(1.5e+18) // Block reward in wei for successfully sealing a block
+
+	maxUncles     = 2 // Maximum number of uncles allowed in a single block
+	uncleDistance = 7 // Maximum number of generations an uncle may lag behind its includer
+
+	homesteadDurationStep = big.NewInt(10)  // Block time step of the homestead difficulty adjustment
+	homesteadMaxDecrease  = big.NewInt(-99) // Maximum number of steps the homestead difficulty may drop at once
+)
+
+// Ethash enforces the frontier consensus rules, sealing and verifying blocks
+// with a proof-of-work.
+type Ethash struct {
+	pow pow.PoW
+}
+
+// New creates an engine sealing and verifying blocks with the ethash
+// proof-of-work.
+func New() *Ethash {
+	return NewWithPoW(ethash.New())
+}
+
+// NewWithPoW creates an engine enforcing the ethash consensus rules on top of
+// an arbitrary proof-of-work algorithm, e.g. one which requires no work for
+// tests and development chains.
+func NewWithPoW(pow pow.PoW) *Ethash {
+	return &Ethash{pow: pow}
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules,
+// including its proof-of-work.
+func (self *Ethash) VerifyHeader(chain consensus.ChainReader, header, parent *types.Header) error {
+	if big.NewInt(int64(len(header.Extra))).Cmp(params.MaximumExtraDataSize) == 1 {
+		return fmt.Errorf("Block extra data too long (%d)", len(header.Extra))
+	}
+
+	expd := CalcDifficulty(chain.Config(), header.Time, parent)
+	if expd.Cmp(header.Difficulty) != 0 {
+		return fmt.Errorf("Difficulty check failed for block %v, %v", header.Difficulty, expd)
+	}
+
+	// block.gasLimit - parent.gasLimit <= parent.gasLimit / GasLimitBoundDivisor
+	a := new(big.Int).Sub(header.GasLimit, parent.GasLimit)
+	a.Abs(a)
+	b := new(big.Int).Div(parent.GasLimit, params.GasLimitBoundDivisor)
+	if !(a.Cmp(b) < 0) || (header.GasLimit.Cmp(params.MinGasLimit) == -1) {
+		return fmt.Errorf("GasLimit check failed for block %v (%v > %v)", header.GasLimit, a, b)
+	}
+
+	// Allow future blocks up to 4 seconds
+	if int64(header.Time) > time.Now().Unix()+4 {
+		return consensus.ErrFutureBlock
+	}
+
+	if new(big.Int).Sub(header.Number, parent.Number).Cmp(big.NewInt(1)) != 0 {
+		return consensus.ErrInvalidNumber
+	}
+
+	if header.Time <= parent.Time {
+		return consensus.ErrInvalidTimestamp
+	}
+
+	// Verify the nonce of the block. Return an error if it's not valid
+	if !self.pow.Verify(types.NewBlockWithHeader(header)) {
+		return fmt.Errorf("Block's nonce is invalid (= %x)", header.Nonce)
+	}
+
+	return nil
+}
+
+// VerifyUncles checks that the block holds at most two uncles, each of them
+// unique, a descendant of a recent ancestor and valid on its own.
+func (self *Ethash) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > maxUncles {
+		return fmt.Errorf("Block can only contain %d uncles (contained %v)", maxUncles, len(block.Uncles()))
+	}
+
+	ancestors := set.New()
+	uncles := set.New()
+	ancestorHeaders := make(map[common.Hash]*types.Header)
+	for _, ancestor := range chain.GetAncestors(block, uncleDistance) {
+		ancestorHeaders[ancestor.Hash()] = ancestor.Header()
+		ancestors.Add(ancestor.Hash())
+		// Include ancestors uncles in the uncle set. Uncles must be unique.
+		for _, uncle := range ancestor.Uncles() {
+			uncles.Add(uncle.Hash())
+		}
+	}
+
+	uncles.Add(block.Hash())
+	for i, uncle := range block.Uncles() {
+		if uncles.Has(uncle.Hash()) {
+			// Error not unique
+			return consensus.UncleError("Uncle not unique")
+		}
+
+		uncles.Add(uncle.Hash())
+
+		if ancestors.Has(uncle.Hash()) {
+			return consensus.UncleError("Uncle is ancestor")
+		}
+
+		if !ancestors.Has(uncle.ParentHash) {
+			return consensus.UncleError(fmt.Sprintf("Uncle's parent unknown (%x)", uncle.ParentHash[0:4]))
+		}
+
+		if err := self.VerifyHeader(chain, uncle, ancestorHeaders[uncle.ParentHash]); err != nil {
+			return fmt.Errorf("uncle[%d](%x) header invalid: %v", i, uncle.Hash().Bytes()[:4], err)
+		}
+	}
+
+	return nil
+}
+
+// CalcDifficulty returns the difficulty a block created at the given time on
+// top of parent should have.
+func (self *Ethash) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return CalcDifficulty(chain.Config(), time, parent)
+}
+
+// Prepare sets the difficulty of the header according to its timestamp.
+func (self *Ethash) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	parent := chain.GetBlock(header.ParentHash)
+	if parent == nil {
+		return fmt.Errorf("unknown parent %x", header.ParentHash)
+	}
+	header.Difficulty = CalcDifficulty(chain.Config(), header.Time, parent.Header())
+
+	return nil
+}
+
+// Finalize credits the coinbase of the block and of its uncles with the
+// static mining rewards.
+func (self *Ethash) Finalize(chain consensus.ChainReader, state *state.StateDB, block *types.Block) {
+	AccumulateRewards(state, block)
+}
+
+// Seal searches for a nonce satisfying the proof-of-work of the block.
+func (self *Ethash) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	nonce, mixDigest := self.pow.Search(block, stop)
+	if nonce == 0 {
+		return nil, nil
+	}
+	block.SetNonce(nonce)
+	block.Header().MixDigest = common.BytesToHash(mixDigest)
+
+	return block, nil
+}
+
+// HashRate returns the hashrate of the underlying proof-of-work.
+func (self *Ethash) HashRate() int64 {
+	return self.pow.GetHashrate()
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the
+// difficulty a block created at the given time on top of parent should have,
+// using the formula in effect at that block according to config.
+func CalcDifficulty(config *params.ChainConfig, time uint64, parent *types.Header) *big.Int {
+	if config.IsHomestead(new(big.Int).Add(parent.Number, common.Big1)) {
+		return calcDifficultyHomestead(time, parent)
+	}
+	return calcDifficultyFrontier(time, parent)
+}
+
+// calcDifficultyHomestead adjusts the difficulty in proportion to the block
+// time: by parent_diff / 2048 * max(1 - (time - parent_time) / 10, -99).
+func calcDifficultyHomestead(time uint64, parent *types.Header) *big.Int {
+	x := new(big.Int).SetUint64(time - parent.Time)
+	x.Div(x, homesteadDurationStep)
+	x.Sub(common.Big1, x)
+	if x.Cmp(homesteadMaxDecrease) < 0 {
+		x.Set(homesteadMaxDecrease)
+	}
+	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
+	diff := new(big.Int).Add(parent.Difficulty, adjust.Mul(adjust, x))
+
+	if diff.Cmp(params.MinimumDifficulty) < 0 {
+		return params.MinimumDifficulty
+	}
+	return diff
+}
+
+func calcDifficultyFrontier(time uint64, parent *types.Header) *big.Int {
+	diff := new(big.Int)
+
+	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
+	if big.NewInt(int64(time)-int64(parent.Time)).Cmp(params.DurationLimit) < 0 {
+		diff.Add(parent.Difficulty, adjust)
+	} else {
+		diff.Sub(parent.Difficulty, adjust)
+	}
+
+	if diff.Cmp(params.MinimumDifficulty) < 0 {
+		return params.MinimumDifficulty
+	}
+
+	return diff
+}
+
+// AccumulateRewards credits the coinbase of the given block with the mining
+// reward. The total reward consists of the static block reward and rewards
+// for included uncles. The coinbase of each uncle block is also rewarded.
+func AccumulateRewards(statedb *state.StateDB, block *types.Block) {
+	reward := new(big.Int).Set(BlockReward)
+
+	for _, uncle := range block.Uncles() {
+		num := new(big.Int).Add(big.NewInt(8), uncle.Number)
+		num.Sub(num, block.Number())
+
+		r := new(big.Int)
+		r.Mul(BlockReward, num)
+		r.Div(r, big.NewInt(8))
+
+		statedb.AddBalance(uncle.Coinbase, r)
+
+		reward.Add(reward, new(big.Int).Div(BlockReward, big.NewInt(32)))
+	}
+
+	// Get the account associated with the coinbase
+	statedb.AddBalance(block.Header().Coinbase, reward)
+}
@@ -0,0 +1,86 @@
+package ethash
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCalcDifficulty(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(1), Time: 1000, Difficulty: big.NewInt(2048 * 1000)}
+	adjust := big.NewInt(1000)
+
+	// blocks faster than the duration limit raise the difficulty
+	fast := CalcDifficulty(params.DefaultChainConfig, parent.Time+params.DurationLimit.Uint64()-1, parent)
+	if exp := new(big.Int).Add(parent.Difficulty, adjust); fast.Cmp(exp) != 0 {
+		t.Errorf("fast block difficulty mismatch: have %v, want %v", fast, exp)
+	}
+	// slower blocks lower it
+	slow := CalcDifficulty(params.DefaultChainConfig, parent.Time+params.DurationLimit.Uint64(), parent)
+	if exp := new(big.Int).Sub(parent.Difficulty, adjust); slow.Cmp(exp) != 0 {
+		t.Errorf("slow block difficulty mismatch: have %v, want %v", slow, exp)
+	}
+	// but never below the minimum
+	parent.Difficulty = params.MinimumDifficulty
+	if diff := CalcDifficulty(params.DefaultChainConfig, parent.Time+100, parent); diff.Cmp(params.MinimumDifficulty) != 0 {
+		t.Errorf("difficulty dropped below minimum: %v", diff)
+	}
+}
+
+func TestCalcDifficultyHomestead(t *testing.T) {
+	parent := &types.Header{Number: big.NewInt(1), Time: 1000, Difficulty: big.NewInt(2048 * 1000)}
+	adjust := big.NewInt(1000)
+
+	tests := []struct {
+		delay uint64
+		steps int64
+	}{
+		{1, 1},      // blocks within 10 seconds raise the difficulty
+		{10, 0},     // blocks within 20 seconds keep it
+		{25, -1},    // slower blocks lower it by one step per 10 seconds
+		{5000, -99}, // but at most by 99 steps
+	}
+	config := &params.ChainConfig{HomesteadBlock: big.NewInt(2)}
+	for i, tt := range tests {
+		diff := CalcDifficulty(config, parent.Time+tt.delay, parent)
+		exp := new(big.Int).Add(parent.Difficulty, new(big.Int).Mul(adjust, big.NewInt(tt.steps)))
+		if diff.Cmp(exp) != 0 {
+			t.Errorf("test %d: difficulty mismatch: have %v, want %v", i, diff, exp)
+		}
+	}
+	// before the fork block the frontier formula stays in effect
+	config.HomesteadBlock = big.NewInt(3)
+	if diff, exp := CalcDifficulty(config, parent.Time+25, parent), new(big.Int).Sub(parent.Difficulty, adjust); diff.Cmp(exp) != 0 {
+		t.Errorf("pre-fork difficulty mismatch: have %v, want %v", diff, exp)
+	}
+}
+
+func TestAccumulateRewards(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	statedb := state.New(common.Hash{}, db)
+
+	var (
+		miner  = common.Address{1}
+		uncler = common.Address{2}
+	)
+	block := types.NewBlock(common.Hash{}, miner, common.Hash{}, common.Big1, 0, nil)
+	block.Header().Number = big.NewInt(10)
+	block.SetUncles([]*types.Header{{Coinbase: uncler, Number: big.NewInt(9)}})
+
+	AccumulateRewards(statedb, block)
+
+	// the uncle is rewarded 7/8 of the block reward, the miner 1/32 extra
+	uncleReward := new(big.Int).Div(new(big.Int).Mul(BlockReward, big.NewInt(7)), big.NewInt(8))
+	if bal := statedb.GetBalance(uncler); bal.Cmp(uncleReward) != 0 {
+		t.Errorf("uncle reward mismatch: have %v, want %v", bal, uncleReward)
+	}
+	minerReward := new(big.Int).Add(BlockReward, new(big.Int).Div(BlockReward, big.NewInt(32)))
+	if bal := statedb.GetBalance(miner); bal.Cmp(minerReward) != 0 {
+		t.Errorf("miner reward mismatch: have %v, want %v", bal, minerReward)
+	}
+}
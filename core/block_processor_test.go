@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/pow/ezp"
@@ -15,7 +17,7 @@ func proc() (*BlockProcessor, *ChainManager) {
 	var mux event.TypeMux
 
 	chainMan := NewChainManager(db, db, &mux)
-	return NewBlockProcessor(db, db, ezp.New(), nil, chainMan, &mux), chainMan
+	return NewBlockProcessor(db, db, ethash.NewWithPoW(ezp.New()), nil, chainMan, &mux), chainMan
 }
 
 func TestNumber(t *testing.T) {
@@ -23,6 +25,7 @@ func TestNumber(t *testing.T) {
 	block1 := chain.NewBlock(common.Address{})
 	block1.Header().Number = big.NewInt(3)
 	block1.Header().Time--
+	bp.Engine().Prepare(chain, block1.Header())
 
 	err := bp.ValidateHeader(block1.Header(), chain.Genesis().Header())
 	if err != BlockNumberErr {
@@ -30,8 +33,31 @@ func TestNumber(t *testing.T) {
 	}
 
 	block1 = chain.NewBlock(common.Address{})
+	bp.Engine().Prepare(chain, block1.Header())
 	err = bp.ValidateHeader(block1.Header(), chain.Genesis().Header())
 	if err == BlockNumberErr {
 		t.Errorf("didn't expect block number error")
 	}
 }
+
+func TestVerifyUnclesErrors(t *testing.T) {
+	bp, chain := proc()
+	genesis := chain.Genesis()
+
+	// Uncles with an unknown parent are uncle errors
+	block := chain.NewBlock(common.Address{})
+	block.SetUncles([]*types.Header{{ParentHash: common.Hash{1}, Number: big.NewInt(1)}})
+	if err := bp.VerifyUncles(block); !IsUncleErr(err) {
+		t.Errorf("unknown uncle parent: expected uncle error, got %v", err)
+	}
+	// while invalid uncle headers and too many uncles are validation errors
+	uncle := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(5), Difficulty: new(big.Int), GasLimit: new(big.Int), GasUsed: new(big.Int)}
+	block.SetUncles([]*types.Header{uncle})
+	if err := bp.VerifyUncles(block); !IsValidationErr(err) {
+		t.Errorf("invalid uncle header: expected validation error, got %v", err)
+	}
+	block.SetUncles([]*types.Header{uncle, uncle, uncle})
+	if err := bp.VerifyUncles(block); !IsValidationErr(err) {
+		t.Errorf("too many uncles: expected validation error, got %v", err)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/pow"
 )
 
@@ -61,9 +63,9 @@ func newBlockFromParent(addr common.Address, parent *types.Block) *types.Block {
 	block.SetReceipts(nil)
 
 	header := block.Header()
-	header.Difficulty = CalcDifficulty(block.Header(), parent.Header())
 	header.Number = new(big.Int).Add(parent.Header().Number, common.Big1)
 	header.Time = parent.Header().Time + 10
+	header.Difficulty = ethash.CalcDifficulty(params.DefaultChainConfig, header.Time, parent.Header())
 	header.GasLimit = CalcGasLimit(parent)
 
 	block.Td = parent.Td
@@ -80,7 +82,7 @@ func makeBlock(bman *BlockProcessor, parent *types.Block, i int, db common.Datab
 	state := state.New(block.Root(), db)
 	cbase := state.GetOrNewStateObject(addr)
 	cbase.SetGasPool(CalcGasLimit(parent))
-	cbase.AddBalance(BlockReward)
+	cbase.AddBalance(ethash.BlockReward)
 	state.Update()
 	block.SetRoot(state.Root())
 	return block
@@ -109,7 +111,7 @@ func makeChain(bman *BlockProcessor, parent *types.Block, max int, db common.Dat
 // Effectively a fork factory
 func newChainManager(block *types.Block, eventMux *event.TypeMux, db common.Database) *ChainManager {
 	genesis := GenesisBlock(db)
-	bc := &ChainManager{blockDb: db, stateDb: db, genesisBlock: genesis, eventMux: eventMux}
+	bc := &ChainManager{blockDb: db, stateDb: db, genesisBlock: genesis, config: params.DefaultChainConfig, eventMux: eventMux}
 	bc.txState = state.ManageState(state.New(genesis.Root(), db))
 	bc.futureBlocks = NewBlockCache(1000)
 	if block == nil {
@@ -125,7 +127,7 @@ func newChainManager(block *types.Block, eventMux *event.TypeMux, db common.Data
 func newBlockProcessor(db common.Database, cman *ChainManager, eventMux *event.TypeMux) *BlockProcessor {
 	chainMan := newChainManager(nil, eventMux, db)
 	txpool := NewTxPool(eventMux, chainMan.State, chainMan.GasLimit)
-	bman := NewBlockProcessor(db, db, FakePow{}, txpool, chainMan, eventMux)
+	bman := NewBlockProcessor(db, db, ethash.NewWithPoW(FakePow{}), txpool, chainMan, eventMux)
 	return bman
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -24,6 +25,11 @@ var (
 
 	blockHashPre = []byte("block-hash-")
 	blockNumPre  = []byte("block-num-")
+
+	blockInsertTimer  = metrics.NewTimer("chain/inserts")
+	blockQueuedMeter  = metrics.NewMeter("chain/queued")
+	blockInvalidMeter = metrics.NewMeter("chain/invalid")
+	chainSplitMeter   = metrics.NewMeter("chain/splits")
 )
 
 const (
@@ -31,23 +37,6 @@ const (
 	maxFutureBlocks = 256
 )
 
-func CalcDifficulty(block, parent *types.Header) *big.Int {
-	diff := new(big.Int)
-
-	adjust := new(big.Int).Div(parent.Difficulty, params.DifficultyBoundDivisor)
-	if big.NewInt(int64(block.Time)-int64(parent.Time)).Cmp(params.DurationLimit) < 0 {
-		diff.Add(parent.Difficulty, adjust)
-	} else {
-		diff.Sub(parent.Difficulty, adjust)
-	}
-
-	if diff.Cmp(params.MinimumDifficulty) < 0 {
-		return params.MinimumDifficulty
-	}
-
-	return diff
-}
-
 func CalculateTD(block, parent *types.Block) *big.Int {
 	if parent == nil {
 		return block.Difficulty()
@@ -77,6 +66,7 @@ type ChainManager struct {
 	processor    types.BlockProcessor
 	eventMux     *event.TypeMux
 	genesisBlock *types.Block
+	config       *params.ChainConfig
 	// Last known total difficulty
 	mu   sync.RWMutex
 	tsmu sync.RWMutex
@@ -97,10 +87,18 @@ type ChainManager struct {
 }
 
 func NewChainManager(blockDb, stateDb common.Database, mux *event.TypeMux) *ChainManager {
+	return NewChainManagerWithGenesis(blockDb, stateDb, GenesisBlock(stateDb), params.DefaultChainConfig, mux)
+}
+
+// NewChainManagerWithGenesis creates a chain manager which uses the given
+// genesis block instead of the default one if the database holds no chain yet,
+// and enforces the rules of the given chain config.
+func NewChainManagerWithGenesis(blockDb, stateDb common.Database, genesis *types.Block, config *params.ChainConfig, mux *event.TypeMux) *ChainManager {
 	bc := &ChainManager{
 		blockDb:      blockDb,
 		stateDb:      stateDb,
-		genesisBlock: GenesisBlock(stateDb),
+		genesisBlock: genesis,
+		config:       config,
 		eventMux:     mux,
 		quit:         make(chan struct{}),
 		cache:        NewBlockCache(blockCacheLimit),
@@ -164,6 +162,11 @@ func (self *ChainManager) GasLimit() *big.Int {
 	return self.currentGasLimit
 }
 
+// Config returns the chain rules enforced by the chain manager.
+func (self *ChainManager) Config() *params.ChainConfig {
+	return self.config
+}
+
 func (self *ChainManager) LastBlockHash() common.Hash {
 	self.mu.RLock()
 	defer self.mu.RUnlock()
@@ -277,7 +280,6 @@ func (bc *ChainManager) NewBlock(coinbase common.Address) *types.Block {
 	parent := bc.currentBlock
 	if parent != nil {
 		header := block.Header()
-		header.Difficulty = CalcDifficulty(block.Header(), parent.Header())
 		header.Number = new(big.Int).Add(parent.Header().Number, common.Big1)
 		header.GasLimit = CalcGasLimit(parent)
 
@@ -524,6 +526,7 @@ func (self *ChainManager) InsertChain(chain types.Blocks) (int, error) {
 		if block == nil {
 			continue
 		}
+		bstart := time.Now()
 		// Setting block.Td regardless of error (known for example) prevents errors down the line
 		// in the protocol handler
 		block.Td = new(big.Int).Set(CalculateTD(block, self.GetBlock(block.ParentHash())))
@@ -544,6 +547,7 @@ func (self *ChainManager) InsertChain(chain types.Blocks) (int, error) {
 				block.SetQueued(true)
 				self.futureBlocks.Push(block)
 				stats.queued++
+				blockQueuedMeter.Mark(1)
 				continue
 			}
 
@@ -551,8 +555,10 @@ func (self *ChainManager) InsertChain(chain types.Blocks) (int, error) {
 				block.SetQueued(true)
 				self.futureBlocks.Push(block)
 				stats.queued++
+				blockQueuedMeter.Mark(1)
 				continue
 			}
+			blockInvalidMeter.Mark(1)
 
 			h := block.Header()
 
@@ -586,6 +592,7 @@ func (self *ChainManager) InsertChain(chain types.Blocks) (int, error) {
 
 					queue[i] = ChainSplitEvent{block, logs}
 					queueEvent.splitCount++
+					chainSplitMeter.Mark(1)
 				}
 
 				self.setTotalDifficulty(block.Td)
@@ -620,7 +627,7 @@ func (self *ChainManager) InsertChain(chain types.Blocks) (int, error) {
 		self.mu.Unlock()
 
 		stats.processed++
-
+		blockInsertTimer.UpdateSince(bstart)
 	}
 
 	if (stats.queued > 0 || stats.processed > 0 || stats.ignored > 0) && bool(glog.V(logger.Info)) {
@@ -1,17 +1,17 @@
 package core
 
 import (
-	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 )
 
 var (
-	BlockNumberErr  = errors.New("block number invalid")
-	BlockFutureErr  = errors.New("block time is in the future")
-	BlockEqualTSErr = errors.New("block time stamp equal to previous")
+	BlockNumberErr  = consensus.ErrInvalidNumber
+	BlockFutureErr  = consensus.ErrFutureBlock
+	BlockEqualTSErr = consensus.ErrInvalidTimestamp
 )
 
 // Parent error. In case a parent is unknown this error will be thrown
@@ -18,6 +18,8 @@ type Execution struct {
 	evm     vm.VirtualMachine
 
 	Gas, price, value *big.Int
+
+	delegate bool // Run the code on behalf of the caller, see DelegateCall
 }
 
 func NewExecution(env vm.Environment, address *common.Address, input []byte, gas, gasPrice, value *big.Int) *Execution {
@@ -33,6 +35,15 @@ func (self *Execution) Call(codeAddr common.Address, caller vm.ContextRef) ([]by
 	return self.exec(&codeAddr, code, caller)
 }
 
+// DelegateCall runs the code at codeAddr on the account of the caller, keeping
+// the caller's own caller and value. No value is transferred.
+func (self *Execution) DelegateCall(codeAddr common.Address, caller vm.ContextRef) ([]byte, error) {
+	code := self.env.State().GetCode(codeAddr)
+	self.delegate = true
+
+	return self.exec(&codeAddr, code, caller)
+}
+
 func (self *Execution) Create(caller vm.ContextRef) (ret []byte, err error, account *state.StateObject) {
 	// Input must be nil for create
 	code := self.input
@@ -77,7 +88,9 @@ func (self *Execution) exec(contextAddr *common.Address, code []byte, caller vm.
 		to = env.State().GetOrNewStateObject(*self.address)
 	}
 
-	err = env.Transfer(from, to, self.value)
+	if !self.delegate {
+		err = env.Transfer(from, to, self.value)
+	}
 	if err != nil {
 		env.State().Set(vsnapshot)
 
@@ -88,6 +101,9 @@ func (self *Execution) exec(contextAddr *common.Address, code []byte, caller vm.
 
 	context := vm.NewContext(caller, to, self.value, self.Gas, self.price)
 	context.SetCallCode(contextAddr, code)
+	if self.delegate {
+		context.AsDelegate()
+	}
 
 	ret, err = evm.Run(context, self.input)
 	evm.Printf("message call took %v", time.Since(start)).Endl()
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// senderMessage is a transaction with a fixed sender, which saves signing it.
+type senderMessage struct {
+	*types.Transaction
+	sender common.Address
+}
+
+func (m senderMessage) From() (common.Address, error) { return m.sender, nil }
+
+// Tests that DELEGATECALL runs the callee's code on the caller's storage,
+// keeping its caller and value, and that it only exists from homestead on.
+func TestDelegateCall(t *testing.T) {
+	var (
+		sender  = common.BytesToAddress([]byte{0xa1})
+		proxy   = common.BytesToAddress([]byte{0xa2})
+		library = common.BytesToAddress([]byte{0xa3})
+		value   = big.NewInt(5)
+	)
+	// library stores CALLER in slot 0 and CALLVALUE in slot 1
+	libraryCode := []byte{
+		byte(vm.CALLER), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.CALLVALUE), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+	}
+	// proxy delegates to library with 50000 gas, without any input or output
+	proxyCode := []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH1), library[19], byte(vm.PUSH2), 0xc3, 0x50, byte(vm.DELEGATECALL),
+	}
+	run := func(config *params.ChainConfig) (*state.StateDB, error) {
+		db, _ := ethdb.NewMemDatabase()
+		chain := NewChainManagerWithGenesis(db, db, GenesisBlock(db), config, new(event.TypeMux))
+
+		statedb := state.New(common.Hash{}, db)
+		statedb.AddBalance(sender, big.NewInt(100))
+		statedb.SetCode(library, libraryCode)
+		statedb.SetCode(proxy, proxyCode)
+
+		msg := types.NewTransactionMessage(proxy, value, big.NewInt(100000), common.Big1, nil)
+		env := NewEnv(statedb, chain, senderMessage{msg, sender}, chain.Genesis())
+		exe := NewExecution(env, &proxy, nil, big.NewInt(100000), common.Big1, value)
+		_, err := exe.Call(proxy, statedb.GetStateObject(sender))
+
+		return statedb, err
+	}
+
+	if _, err := run(params.DefaultChainConfig); err == nil {
+		t.Errorf("expected DELEGATECALL to be invalid before homestead")
+	}
+
+	statedb, err := run(&params.ChainConfig{HomesteadBlock: common.Big0})
+	if err != nil {
+		t.Fatalf("delegate call failed: %v", err)
+	}
+	if caller := statedb.GetState(proxy, common.Hash{}); !bytes.Equal(common.LeftPadBytes(caller, 20), sender[:]) {
+		t.Errorf("caller mismatch: have %x, want %x", caller, sender)
+	}
+	if val := statedb.GetState(proxy, common.BigToHash(common.Big1)); common.Bytes2Big(val).Cmp(value) != 0 {
+		t.Errorf("value mismatch: have %x, want %v", val, value)
+	}
+	if stored := statedb.GetState(library, common.Hash{}); len(stored) != 0 {
+		t.Errorf("library storage modified: %x", stored)
+	}
+	if balance := statedb.GetBalance(library); balance.Cmp(common.Big0) != 0 {
+		t.Errorf("value transferred to library: %v", balance)
+	}
+}
@@ -20,6 +20,25 @@ var ZeroHash160 = make([]byte, 20)
 var ZeroHash512 = make([]byte, 64)
 
 func GenesisBlock(db common.Database) *types.Block {
+	return genesisBlock(db, GenesisData)
+}
+
+// DevGenesisBlock returns the genesis block of a development chain, which
+// allocates a practically unlimited balance to the given developer account.
+func DevGenesisBlock(db common.Database, developer common.Address) *types.Block {
+	alloc := fmt.Sprintf(`{"%x": {"balance": "%v"}}`, developer, common.BigPow(2, 200))
+	return genesisBlock(db, []byte(alloc))
+}
+
+// GenesisBlockWithExtra returns the default genesis block carrying the given
+// extra-data, e.g. the initial signers of a proof-of-authority network.
+func GenesisBlockWithExtra(db common.Database, extra []byte) *types.Block {
+	genesis := genesisBlock(db, GenesisData)
+	genesis.Header().Extra = extra
+	return genesis
+}
+
+func genesisBlock(db common.Database, data []byte) *types.Block {
 	genesis := types.NewBlock(common.Hash{}, common.Address{}, common.Hash{}, params.GenesisDifficulty, 42, nil)
 	genesis.Header().Number = common.Big0
 	genesis.Header().GasLimit = params.GenesisGasLimit
@@ -36,7 +55,7 @@ func GenesisBlock(db common.Database) *types.Block {
 		Balance string
 		Code    string
 	}
-	err := json.Unmarshal(GenesisData, &accounts)
+	err := json.Unmarshal(data, &accounts)
 	if err != nil {
 		fmt.Println("enable to decode genesis json data:", err)
 		os.Exit(1)
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+func TestDevGenesisBlock(t *testing.T) {
+	developer := common.HexToAddress("0x8605cdbbdb6d264aa742e77020dcbc58fcdce182")
+
+	db, _ := ethdb.NewMemDatabase()
+	genesis := DevGenesisBlock(db, developer)
+
+	// The developer account is funded, and nothing else is allocated
+	statedb := state.New(genesis.Root(), db)
+	if balance := statedb.GetBalance(developer); balance.Cmp(common.BigPow(2, 200)) != 0 {
+		t.Errorf("developer balance mismatch: have %v, want %v", balance, common.BigPow(2, 200))
+	}
+	if balance := statedb.GetBalance(common.HexToAddress("0x0000000000000000000000000000000000000001")); balance.Sign() != 0 {
+		t.Errorf("default allocation present: balance %v", balance)
+	}
+	// The header matches the default genesis apart from the state
+	mainDb, _ := ethdb.NewMemDatabase()
+	main := GenesisBlock(mainDb)
+	if genesis.Number().Sign() != 0 || genesis.ParentHash() != (common.Hash{}) {
+		t.Errorf("genesis position mismatch: number %v, parent %x", genesis.Number(), genesis.ParentHash())
+	}
+	if genesis.Difficulty().Cmp(main.Difficulty()) != 0 || genesis.GasLimit().Cmp(main.GasLimit()) != 0 {
+		t.Errorf("genesis parameter mismatch: have difficulty %v gas limit %v, want %v %v", genesis.Difficulty(), genesis.GasLimit(), main.Difficulty(), main.GasLimit())
+	}
+	if genesis.Root() == main.Root() {
+		t.Errorf("development genesis has the default state root")
+	}
+	// Different developers get different chains
+	otherDb, _ := ethdb.NewMemDatabase()
+	if other := DevGenesisBlock(otherDb, common.HexToAddress("0x01")); other.Hash() == genesis.Hash() {
+		t.Errorf("genesis hash identical for different developers")
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -25,6 +26,10 @@ var (
 	ErrInsufficientFunds  = errors.New("Insufficient funds for gas * price + value")
 	ErrIntrinsicGas       = errors.New("Intrinsic gas too low")
 	ErrGasLimit           = errors.New("Exceeds block gas limit")
+
+	pendingGauge = metrics.NewGauge("txpool/pending")
+	queuedGauge  = metrics.NewGauge("txpool/queued")
+	invalidMeter = metrics.NewMeter("txpool/invalid")
 )
 
 const txPoolQueueSize = 50
@@ -156,10 +161,12 @@ func (self *TxPool) add(tx *types.Transaction) error {
 	}
 	err := self.ValidateTransaction(tx)
 	if err != nil {
+		invalidMeter.Mark(1)
 		return err
 	}
 
 	self.queueTx(tx)
+	self.updateGauges()
 
 	var toname string
 	if to := tx.To(); to != nil {
@@ -237,6 +244,7 @@ func (self *TxPool) RemoveTransactions(txs types.Transactions) {
 	for _, tx := range txs {
 		self.removeTx(tx.Hash())
 	}
+	self.updateGauges()
 }
 
 func (pool *TxPool) Flush() {
@@ -304,6 +312,7 @@ func (pool *TxPool) checkQueue() {
 			delete(pool.queue, address)
 		}
 	}
+	pool.updateGauges()
 }
 
 func (pool *TxPool) removeTx(hash common.Hash) {
@@ -340,4 +349,16 @@ func (pool *TxPool) validatePool() {
 			pool.removeTx(hash)
 		}
 	}
+	pool.updateGauges()
+}
+
+// updateGauges reports the current number of pending and queued transactions.
+// It must be called with the pool lock held.
+func (pool *TxPool) updateGauges() {
+	var queued int
+	for _, txs := range pool.queue {
+		queued += len(txs)
+	}
+	pendingGauge.Update(int64(len(pool.txs)))
+	queuedGauge.Update(int64(queued))
 }
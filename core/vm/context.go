@@ -13,6 +13,10 @@ type ContextRef interface {
 }
 
 type Context struct {
+	// CallerAddress is the address reported by CALLER. It's the address of
+	// caller, except in delegate calls where it's inherited from the parent.
+	CallerAddress common.Address
+
 	caller ContextRef
 	self   ContextRef
 
@@ -26,7 +30,7 @@ type Context struct {
 
 // Create a new context for the given data items
 func NewContext(caller ContextRef, object ContextRef, value, gas, price *big.Int) *Context {
-	c := &Context{caller: caller, self: object, Args: nil}
+	c := &Context{CallerAddress: caller.Address(), caller: caller, self: object, Args: nil}
 
 	// Gas should be a pointer so it can safely be reduced through the run
 	// This pointer will be off the state transition
@@ -40,6 +44,17 @@ func NewContext(caller ContextRef, object ContextRef, value, gas, price *big.Int
 	return c
 }
 
+// AsDelegate sets the context up for a delegate call: the code runs on behalf
+// of the parent context, keeping its caller and value. The caller of the
+// context must be the parent *Context.
+func (c *Context) AsDelegate() *Context {
+	parent := c.caller.(*Context)
+	c.CallerAddress = parent.CallerAddress
+	c.value = parent.value
+
+	return c
+}
+
 func (c *Context) GetOp(n *big.Int) OpCode {
 	return OpCode(c.GetByte(n))
 }
@@ -8,11 +8,13 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type Environment interface {
 	State() *state.StateDB
+	ChainConfig() *params.ChainConfig
 
 	Origin() common.Address
 	BlockNumber() *big.Int
@@ -31,6 +33,7 @@ type Environment interface {
 
 	Call(me ContextRef, addr common.Address, data []byte, gas, price, value *big.Int) ([]byte, error)
 	CallCode(me ContextRef, addr common.Address, data []byte, gas, price, value *big.Int) ([]byte, error)
+	DelegateCall(me ContextRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error)
 	Create(me ContextRef, data []byte, gas, price, value *big.Int) ([]byte, error, ContextRef)
 }
 
@@ -21,7 +21,7 @@ var (
 	GasContractByte = big.NewInt(200)
 )
 
-func baseCheck(op OpCode, stack *stack, gas *big.Int) error {
+func baseCheck(op OpCode, stack *stack, gas *big.Int, gasTable params.GasTable) error {
 	// PUSH and DUP are a bit special. They all cost the same but we do want to have checking on stack push limit
 	// PUSH is also allowed to calculate the same price for all PUSHes
 	// DUP requirements are handled elsewhere (except for the stack limit check)
@@ -42,7 +42,31 @@ func baseCheck(op OpCode, stack *stack, gas *big.Int) error {
 			return fmt.Errorf("stack limit reached %d (%d)", len(stack.data), params.StackLimit.Int64())
 		}
 
-		gas.Add(gas, r.gas)
+		if price := repricedGas(op, gasTable); price != nil {
+			gas.Add(gas, price)
+		} else {
+			gas.Add(gas, r.gas)
+		}
+	}
+	return nil
+}
+
+// repricedGas returns the base price of op in the given gas table, or nil if
+// the price of op is the same in all of them.
+func repricedGas(op OpCode, gasTable params.GasTable) *big.Int {
+	switch op {
+	case EXTCODESIZE:
+		return gasTable.ExtcodeSize
+	case EXTCODECOPY:
+		return gasTable.ExtcodeCopy
+	case BALANCE:
+		return gasTable.Balance
+	case SLOAD:
+		return gasTable.SLoad
+	case CALL, CALLCODE, DELEGATECALL:
+		return gasTable.Calls
+	case SUICIDE:
+		return gasTable.Suicide
 	}
 	return nil
 }
@@ -118,6 +142,7 @@ var _baseCheck = map[OpCode]req{
 	CREATE:       {3, params.CreateGas, 1},
 	CALL:         {7, params.CallGas, 1},
 	CALLCODE:     {7, params.CallGas, 1},
+	DELEGATECALL: {6, params.CallGas, 1},
 	JUMPDEST:     {0, params.JumpdestGas, 0},
 	SUICIDE:      {1, Zero, 0},
 	RETURN:       {2, Zero, 0},
@@ -165,6 +165,7 @@ const (
 	CALL
 	CALLCODE
 	RETURN
+	DELEGATECALL
 
 	// 0x70 range - other
 	SUICIDE = 0xff
@@ -320,6 +321,8 @@ var opCodeToString = map[OpCode]string{
 	RETURN:   "RETURN",
 	CALLCODE: "CALLCODE",
 
+	DELEGATECALL: "DELEGATECALL",
+
 	// 0x70 range - other
 	SUICIDE: "SUICIDE",
 }
@@ -74,6 +74,9 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 	var (
 		op OpCode
 
+		number       = self.env.BlockNumber()
+		homestead    = self.env.ChainConfig().IsHomestead(number)
+		gasTable     = self.env.ChainConfig().GasTable(number)
 		destinations = analyseJumpDests(context.Code)
 		mem          = NewMemory()
 		stack        = newStack()
@@ -108,7 +111,10 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 		op = context.GetOp(pc)
 
 		self.Printf("(pc) %-3d -o- %-14s (m) %-4d (s) %-4d ", pc, op.String(), mem.Len(), stack.len())
-		newMemSize, gas, err := self.calculateGasAndSize(context, caller, op, statedb, mem, stack)
+		if op == DELEGATECALL && !homestead {
+			return nil, fmt.Errorf("Invalid opcode %x", op)
+		}
+		newMemSize, gas, err := self.calculateGasAndSize(gasTable, context, caller, op, statedb, mem, stack)
 		if err != nil {
 			return nil, err
 		}
@@ -417,7 +423,7 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 
 			self.Printf(" => %x", origin)
 		case CALLER:
-			caller := context.caller.Address()
+			caller := context.CallerAddress
 			stack.push(common.Bytes2Big(caller.Bytes()))
 
 			self.Printf(" => %x", caller)
@@ -693,6 +699,26 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 				mem.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 			}
 			self.Printf("resume %x (%v)", context.Address(), context.Gas)
+		case DELEGATECALL:
+			gas, addr := stack.pop(), stack.pop()
+			inOffset, inSize := stack.pop(), stack.pop()
+			retOffset, retSize := stack.pop(), stack.pop()
+
+			address := common.BigToAddress(addr)
+			self.Printf(" => %x", address).Endl()
+
+			args := mem.Get(inOffset.Int64(), inSize.Int64())
+			ret, err := self.env.DelegateCall(context, address, args, gas, price)
+			if err != nil {
+				stack.push(common.BigFalse)
+
+				self.Printf(" %v", err).Endl()
+			} else {
+				stack.push(common.BigTrue)
+
+				mem.Set(retOffset.Uint64(), retSize.Uint64(), ret)
+			}
+			self.Printf("resume %x (%v)", context.Address(), context.Gas)
 		case RETURN:
 			offset, size := stack.pop(), stack.pop()
 			ret := mem.Get(offset.Int64(), size.Int64())
@@ -727,12 +753,12 @@ func (self *Vm) Run(context *Context, callData []byte) (ret []byte, err error) {
 	}
 }
 
-func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCode, statedb *state.StateDB, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
+func (self *Vm) calculateGasAndSize(gasTable params.GasTable, context *Context, caller ContextRef, op OpCode, statedb *state.StateDB, mem *Memory, stack *stack) (*big.Int, *big.Int, error) {
 	var (
 		gas                 = new(big.Int)
 		newMemSize *big.Int = new(big.Int)
 	)
-	err := baseCheck(op, stack, gas)
+	err := baseCheck(op, stack, gas, gasTable)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -841,6 +867,13 @@ func (self *Vm) calculateGasAndSize(context *Context, caller ContextRef, op OpCo
 		x := calcMemSize(stack.data[stack.len()-6], stack.data[stack.len()-7])
 		y := calcMemSize(stack.data[stack.len()-4], stack.data[stack.len()-5])
 
+		newMemSize = common.BigMax(x, y)
+	case DELEGATECALL:
+		gas.Add(gas, stack.data[stack.len()-1])
+
+		x := calcMemSize(stack.data[stack.len()-5], stack.data[stack.len()-6])
+		y := calcMemSize(stack.data[stack.len()-3], stack.data[stack.len()-4])
+
 		newMemSize = common.BigMax(x, y)
 	}
 
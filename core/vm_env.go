@@ -7,6 +7,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 type VMEnv struct {
@@ -40,6 +41,9 @@ func (self *VMEnv) Depth() int               { return self.depth }
 func (self *VMEnv) SetDepth(i int)           { self.depth = i }
 func (self *VMEnv) VmType() vm.Type          { return self.typ }
 func (self *VMEnv) SetVmType(t vm.Type)      { self.typ = t }
+func (self *VMEnv) ChainConfig() *params.ChainConfig {
+	return self.chain.Config()
+}
 func (self *VMEnv) GetHash(n uint64) common.Hash {
 	if block := self.chain.GetBlockByNumber(n); block != nil {
 		return block.Hash()
@@ -64,6 +68,12 @@ func (self *VMEnv) CallCode(me vm.ContextRef, addr common.Address, data []byte,
 	return exe.Call(addr, me)
 }
 
+func (self *VMEnv) DelegateCall(me vm.ContextRef, addr common.Address, data []byte, gas, price *big.Int) ([]byte, error) {
+	maddr := me.Address()
+	exe := NewExecution(self, &maddr, data, gas, price, common.Big0)
+	return exe.DelegateCall(addr, me)
+}
+
 func (self *VMEnv) Create(me vm.ContextRef, data []byte, gas, price, value *big.Int) ([]byte, error, vm.ContextRef) {
 	exe := NewExecution(self, nil, data, gas, price, value)
 	return exe.Create(me)
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BIP39 mnemonic sentences [1] encode 128 to 256 bits of entropy and a
+// checksum of its SHA256 hash as words of the English word list, 11 bits per
+// word. The wallet seed is derived from the sentence with PBKDF2.
+//
+// [1] https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+const (
+	bip39SeedIterations = 2048
+	bip39SeedLen        = 64
+)
+
+var (
+	errBIP39Checksum = errors.New("invalid mnemonic checksum")
+	bip39Index       = make(map[string]int, len(bip39Words))
+)
+
+func init() {
+	for i, word := range bip39Words {
+		bip39Index[word] = i
+	}
+}
+
+// NewBIP39Mnemonic encodes entropy as a BIP39 mnemonic sentence. The entropy
+// must be 16 to 32 bytes long, in steps of 4 bytes.
+func NewBIP39Mnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("invalid entropy length %d", len(entropy))
+	}
+	// The checksum is the first len(entropy)/4 bits of the entropy hash
+	hash := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), hash[0])
+
+	words := make([]string, (len(entropy)*8+len(entropy)/4)/11)
+	for i := range words {
+		index := 0
+		for j := i * 11; j < (i+1)*11; j++ {
+			index = index<<1 | int(bits[j/8]>>uint(7-j%8)&1)
+		}
+		words[i] = bip39Words[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// BIP39Entropy decodes a BIP39 mnemonic sentence, verifying its checksum.
+func BIP39Entropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("invalid mnemonic length %d", len(words))
+	}
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := bip39Index[word]
+		if !ok {
+			return nil, fmt.Errorf("unknown mnemonic word %q", word)
+		}
+		for j := 0; j < 11; j++ {
+			if index&(1<<uint(10-j)) != 0 {
+				bit := i*11 + j
+				bits[bit/8] |= 1 << uint(7-bit%8)
+			}
+		}
+	}
+	entropy := bits[:len(words)*4/3]
+
+	hash := sha256.Sum256(entropy)
+	checksumBits := uint(len(words) / 3)
+	if bits[len(entropy)]>>(8-checksumBits) != hash[0]>>(8-checksumBits) {
+		return nil, errBIP39Checksum
+	}
+	return entropy, nil
+}
+
+// BIP39Seed derives the wallet seed of a BIP39 mnemonic sentence, which is
+// validated first. The optional passphrase is used as given, without the
+// Unicode normalization the specification asks for, which only matters for
+// non-ASCII passphrases.
+func BIP39Seed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := BIP39Entropy(mnemonic); err != nil {
+		return nil, err
+	}
+	sentence := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return pbkdf2.Key([]byte(sentence), []byte("mnemonic"+passphrase), bip39SeedIterations, bip39SeedLen, sha512.New), nil
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test vectors of the BIP39 reference implementation.
+var bip39Tests = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		entropy:  "00000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+	},
+	{
+		entropy:  "80808080808080808080808080808080",
+		mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+	},
+	{
+		entropy:  "ffffffffffffffffffffffffffffffff",
+		mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+	},
+	{
+		entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+		mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+	},
+}
+
+func TestBIP39(t *testing.T) {
+	for i, test := range bip39Tests {
+		entropy, _ := hex.DecodeString(test.entropy)
+		mnemonic, err := NewBIP39Mnemonic(entropy)
+		if err != nil {
+			t.Errorf("test %d: encoding failed: %v", i, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("test %d: mnemonic mismatch:\nhave %q\nwant %q", i, mnemonic, test.mnemonic)
+		}
+		decoded, err := BIP39Entropy(strings.ToUpper(test.mnemonic))
+		if err != nil || !bytes.Equal(decoded, entropy) {
+			t.Errorf("test %d: entropy mismatch: have %x (%v), want %x", i, decoded, err, entropy)
+		}
+		if test.seed != "" {
+			seed, err := BIP39Seed(test.mnemonic, "TREZOR")
+			if err != nil || hex.EncodeToString(seed) != test.seed {
+				t.Errorf("test %d: seed mismatch: have %x (%v), want %s", i, seed, err, test.seed)
+			}
+		}
+	}
+}
+
+func TestBIP39Invalid(t *testing.T) {
+	invalid := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", // bad checksum
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",           // too short
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon aboutt",  // unknown word
+	}
+	for _, mnemonic := range invalid {
+		if _, err := BIP39Seed(mnemonic, ""); err == nil {
+			t.Errorf("invalid mnemonic %q accepted", mnemonic)
+		}
+	}
+	if _, err := NewBIP39Mnemonic(make([]byte, 15)); err == nil {
+		t.Error("invalid entropy length accepted")
+	}
+}
@@ -170,6 +170,14 @@ func Sign(hash []byte, prv *ecdsa.PrivateKey) (sig []byte, err error) {
 	return
 }
 
+// MessageHash returns the hash signed by message signatures, the Sha3 of
+// "\x19Ethereum Signed Message:\n" followed by the length and the data. The
+// prefix makes sure that a signed message can't be a signed transaction.
+func MessageHash(data []byte) []byte {
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return Sha3([]byte(msg))
+}
+
 func Encrypt(pub *ecdsa.PublicKey, message []byte) ([]byte, error) {
 	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), message, nil, nil)
 }
@@ -242,6 +250,20 @@ func decryptPreSaleKey(fileContent []byte, password string) (key *Key, err error
 	return key, err
 }
 
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("Decryption failed: invalid IV length")
+	}
+	stream := cipher.NewCTR(aesBlock, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
+
 func aesCBCDecrypt(key []byte, cipherText []byte, iv []byte) (plainText []byte, err error) {
 	aesBlock, err := aes.NewCipher(key)
 	if err != nil {
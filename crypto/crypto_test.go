@@ -27,6 +27,12 @@ func TestSha3Hash(t *testing.T) {
 	checkhash(t, "Sha3-256-array", func(in []byte) []byte { h := Sha3Hash(in); return h[:] }, msg, exp)
 }
 
+func TestMessageHash(t *testing.T) {
+	msg := []byte("Hello World")
+	exp, _ := hex.DecodeString("a1de988600a42c4b4ab089b619297c17d53cffae5d5120d82d8a92d0bb3b78f2")
+	checkhash(t, "MessageHash", MessageHash, msg, exp)
+}
+
 func TestSha256(t *testing.T) {
 	msg := []byte("abc")
 	exp, _ := hex.DecodeString("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Hierarchical deterministic keys as specified by BIP32 [1], derived along
+// the BIP44 [2] paths registered for Ethereum.
+//
+// [1] https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki
+// [2] https://github.com/bitcoin/bips/blob/master/bip-0044.mediawiki
+
+// HardenedKeyStart is the index of the first hardened child key.
+const HardenedKeyStart = 0x80000000
+
+// DefaultHDBasePath is the BIP44 path m/44'/60'/0'/0 of the external Ethereum
+// accounts, whose child keys m/44'/60'/0'/0/i are the wallet accounts.
+var DefaultHDBasePath = DerivationPath{HardenedKeyStart + 44, HardenedKeyStart + 60, HardenedKeyStart + 0, 0}
+
+var errInvalidHDKey = errors.New("invalid HD key, try another index")
+
+// DerivationPath is a BIP32 key derivation path, the indices of the child keys
+// to derive from the master key.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a derivation path like m/44'/60'/0'/0/0, where
+// hardened indices are marked by an apostrophe.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+	elems := strings.Split(strings.TrimSpace(path), "/")
+	if elems[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q doesn't start at the master key", path)
+	}
+	var result DerivationPath
+	for _, elem := range elems[1:] {
+		offset := uint64(0)
+		if strings.HasSuffix(elem, "'") {
+			offset, elem = HardenedKeyStart, strings.TrimSuffix(elem, "'")
+		}
+		index, err := strconv.ParseUint(elem, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", elem, path)
+		}
+		result = append(result, uint32(index+offset))
+	}
+	return result, nil
+}
+
+func (path DerivationPath) String() string {
+	result := "m"
+	for _, index := range path {
+		if index >= HardenedKeyStart {
+			result += fmt.Sprintf("/%d'", index-HardenedKeyStart)
+		} else {
+			result += fmt.Sprintf("/%d", index)
+		}
+	}
+	return result
+}
+
+// Child returns the path to the i'th child of the key at path.
+func (path DerivationPath) Child(i uint32) DerivationPath {
+	return append(append(DerivationPath{}, path...), i)
+}
+
+// HDKey is an extended private key, a secp256k1 key together with the chain
+// code needed to derive its child keys.
+type HDKey struct {
+	PrivateKey *ecdsa.PrivateKey
+	ChainCode  []byte
+}
+
+// NewHDMasterKey derives the master key of a wallet from its seed.
+func NewHDMasterKey(seed []byte) (*HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid seed length %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	d := new(big.Int).SetBytes(sum[:32])
+	if d.Sign() == 0 || d.Cmp(S256().Params().N) >= 0 {
+		return nil, errInvalidHDKey
+	}
+	return &HDKey{PrivateKey: ToECDSA(sum[:32]), ChainCode: sum[32:]}, nil
+}
+
+// Child derives the i'th child key. Indices from HardenedKeyStart on derive
+// hardened keys, which can't be derived from the parent public key. In the
+// unlikely case that the index yields no valid key, an error is returned and
+// the next index should be used instead.
+func (k *HDKey) Child(i uint32) (*HDKey, error) {
+	mac := hmac.New(sha512.New, k.ChainCode)
+	if i >= HardenedKeyStart {
+		mac.Write([]byte{0})
+		mac.Write(common.LeftPadBytes(FromECDSA(k.PrivateKey), 32))
+	} else {
+		mac.Write(CompressPubkey(&k.PrivateKey.PublicKey))
+	}
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], i)
+	mac.Write(index[:])
+	sum := mac.Sum(nil)
+
+	n := S256().Params().N
+	d := new(big.Int).SetBytes(sum[:32])
+	if d.Cmp(n) >= 0 {
+		return nil, errInvalidHDKey
+	}
+	d.Add(d, k.PrivateKey.D).Mod(d, n)
+	if d.Sign() == 0 {
+		return nil, errInvalidHDKey
+	}
+	return &HDKey{PrivateKey: ToECDSA(common.LeftPadBytes(d.Bytes(), 32)), ChainCode: sum[32:]}, nil
+}
+
+// Derive derives the key at the given path relative to k.
+func (k *HDKey) Derive(path DerivationPath) (*HDKey, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+// CompressPubkey encodes a public key in the 33 byte compressed format.
+func CompressPubkey(pub *ecdsa.PublicKey) []byte {
+	result := make([]byte, 33)
+	result[0] = byte(2 + pub.Y.Bit(0))
+	copy(result[1:], common.LeftPadBytes(pub.X.Bytes(), 32))
+	return result
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestHDKeyDerivation(t *testing.T) {
+	// Test vector 1 of BIP32
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewHDMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path, key, chainCode string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19"},
+	}
+	for _, test := range tests {
+		path, err := ParseDerivationPath(test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		key, err := master.Derive(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		if have := hex.EncodeToString(FromECDSA(key.PrivateKey)); have != test.key {
+			t.Errorf("%s: key mismatch: have %s, want %s", test.path, have, test.key)
+		}
+		if have := hex.EncodeToString(key.ChainCode); have != test.chainCode {
+			t.Errorf("%s: chain code mismatch: have %s, want %s", test.path, have, test.chainCode)
+		}
+	}
+}
+
+func TestHDKeyEthereumAccount(t *testing.T) {
+	seed, err := BIP39Seed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := NewHDMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := master.Derive(DefaultHDBasePath.Child(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := hex.EncodeToString(PubkeyToAddress(key.PrivateKey.PublicKey)), "9858effd232b4033e47d90003d41ec34ecaeda94"; have != want {
+		t.Errorf("address mismatch: have %s, want %s", have, want)
+	}
+}
+
+func TestDerivationPath(t *testing.T) {
+	for _, valid := range []string{"m", "m/0", "m/44'/60'/0'/0/0", "m/2147483647'"} {
+		path, err := ParseDerivationPath(valid)
+		if err != nil {
+			t.Errorf("%s: %v", valid, err)
+		} else if path.String() != valid {
+			t.Errorf("%s: round trip mismatch: have %s", valid, path)
+		}
+	}
+	for _, invalid := range []string{"", "44'/60'", "m/", "m/-1", "m/2147483648", "m/0''"} {
+		if _, err := ParseDerivationPath(invalid); err == nil {
+			t.Errorf("invalid path %q accepted", invalid)
+		}
+	}
+	if have, want := DefaultHDBasePath.Child(3).String(), "m/44'/60'/0'/0/3"; have != want {
+		t.Errorf("default path mismatch: have %s, want %s", have, want)
+	}
+}
@@ -47,10 +47,21 @@ type plainKeyJSON struct {
 	PrivateKey []byte
 }
 
+type plainSecretJSON struct {
+	Secret []byte
+}
+
 type cipherJSON struct {
 	Salt       []byte
 	IV         []byte
 	CipherText []byte
+
+	// Key derivation function and its parameters, scrypt with the standard
+	// parameters if missing.
+	KDF string `json:",omitempty"`
+	N   int    `json:",omitempty"` // scrypt CPU/memory cost
+	R   int    `json:",omitempty"` // scrypt block size
+	P   int    `json:",omitempty"` // scrypt parallelization
 }
 
 type encryptedKeyJSON struct {
@@ -59,6 +70,27 @@ type encryptedKeyJSON struct {
 	Crypto  cipherJSON
 }
 
+// encryptedKeyJSONV3 is a key file in the Web3 Secret Storage format, version 3.
+type encryptedKeyJSONV3 struct {
+	Address string     `json:"address"`
+	Crypto  cryptoJSON `json:"crypto"`
+	Id      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+type cryptoJSON struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams cipherparamsJSON       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type cipherparamsJSON struct {
+	IV string `json:"iv"`
+}
+
 func (k *Key) MarshalJSON() (j []byte, err error) {
 	jStruct := plainKeyJSON{
 		k.Id,
@@ -29,7 +29,11 @@ the private key is encrypted and on disk uses another JSON encoding.
 Cryptography:
 
 1. Encryption key is scrypt derived key from user passphrase. Scrypt parameters
-   (work factors) [1][2] are defined as constants below.
+   (work factors) [1][2] are chosen when creating the key store, the standard
+   and light ones are defined as constants below. The parameters are stored
+   alongside the ciphertext, key files written without them use the standard
+   parameters. Key files in the Web3 Secret Storage format [10] (version 3,
+   scrypt or PBKDF2-HMAC-SHA256 derived key, AES-128-CTR) can be read as well.
 2. Scrypt salt is 32 random bytes from CSPRNG. It is appended to ciphertext.
 3. Checksum is SHA3 of the private key bytes.
 4. Plaintext is concatenation of private key bytes and checksum.
@@ -58,6 +62,7 @@ References:
 7. http://bitcoin.stackexchange.com/questions/3059/what-is-a-compressed-bitcoin-key
 8. http://golang.org/pkg/crypto/ecdsa/#PrivateKey
 9. https://golang.org/pkg/math/big/#Int.Bytes
+10. https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
 
 */
 
@@ -67,38 +72,74 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
 
 	"code.google.com/p/go-uuid/uuid"
 	"github.com/ethereum/go-ethereum/crypto/randentropy"
+	"golang.org/x/crypto/pbkdf2"
 	"golang.org/x/crypto/scrypt"
 )
 
 const (
+	// StandardScryptN and StandardScryptP are the default scrypt parameters.
 	// 2^18 / 8 / 1 uses 256MB memory and approx 1s CPU time on a modern CPU.
-	scryptN     = 1 << 18
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+
+	// LightScryptN and LightScryptP are scrypt parameters for tests and
+	// low-powered devices. 2^12 / 8 / 6 uses 4MB memory and approx 100ms CPU
+	// time on a modern CPU.
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
 	scryptr     = 8
-	scryptp     = 1
 	scryptdkLen = 32
+
+	kdfScrypt     = "scrypt"
+	kdfPBKDF2     = "pbkdf2"
+	pbkdf2HMACSHA = "hmac-sha256"
+
+	// Upper bounds on the scrypt parameters of key files, so a crafted file
+	// can't make decryption take unbounded memory or CPU time. The memory
+	// used is 128 * N * r bytes.
+	maxScryptMemory = 1 << 30
+	maxScryptP      = 16
 )
 
 type keyStorePassphrase struct {
 	keysDirPath string
+	scryptN     int
+	scryptP     int
 }
 
+// NewKeyStorePassphrase creates a key store encrypting keys with the standard
+// scrypt parameters.
 func NewKeyStorePassphrase(path string) KeyStore2 {
-	return &keyStorePassphrase{path}
+	return NewKeyStorePassphraseParams(path, StandardScryptN, StandardScryptP)
+}
+
+// NewKeyStorePassphraseParams creates a key store encrypting keys with the
+// given scrypt parameters. Keys are always decrypted with the parameters they
+// were stored with.
+func NewKeyStorePassphraseParams(path string, scryptN, scryptP int) KeyStore2 {
+	return &keyStorePassphrase{path, scryptN, scryptP}
 }
 
 func (ks keyStorePassphrase) GenerateNewKey(rand io.Reader, auth string) (key *Key, err error) {
 	return GenerateNewKeyDefault(ks, rand, auth)
 }
 
+func (ks keyStorePassphrase) KeyDir() string {
+	return ks.keysDirPath
+}
+
 func (ks keyStorePassphrase) GetKey(keyAddr []byte, auth string) (key *Key, err error) {
 	keyBytes, keyId, err := DecryptKey(ks, keyAddr, auth)
 	if err != nil {
@@ -117,20 +158,58 @@ func (ks keyStorePassphrase) GetKeyAddresses() (addresses [][]byte, err error) {
 }
 
 func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
+	cipherStruct, err := ks.encryptData(FromECDSA(key.PrivateKey), auth)
+	if err != nil {
+		return err
+	}
+	keyStruct := encryptedKeyJSON{
+		key.Id,
+		key.Address,
+		cipherStruct,
+	}
+	keyJSON, err := json.Marshal(keyStruct)
+	if err != nil {
+		return err
+	}
+
+	return WriteKeyFile(key.Address, ks.keysDirPath, keyJSON)
+}
+
+// EncryptSecret encrypts data the same way as keys, returning the JSON
+// encoding of the ciphertext and its parameters.
+func (ks keyStorePassphrase) EncryptSecret(data []byte, auth string) ([]byte, error) {
+	cipherStruct, err := ks.encryptData(data, auth)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cipherStruct)
+}
+
+// DecryptSecret decrypts data encrypted by EncryptSecret.
+func (ks keyStorePassphrase) DecryptSecret(blob []byte, auth string) ([]byte, error) {
+	cipherStruct := new(cipherJSON)
+	if err := json.Unmarshal(blob, cipherStruct); err != nil {
+		return nil, err
+	}
+	return decryptData(*cipherStruct, auth)
+}
+
+// encryptData encrypts data with a key derived from the passphrase using the
+// scrypt parameters of the key store.
+func (ks keyStorePassphrase) encryptData(data []byte, auth string) (cipherJSON, error) {
 	authArray := []byte(auth)
 	salt := randentropy.GetEntropyMixed(32)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+	derivedKey, err := scrypt.Key(authArray, salt, ks.scryptN, scryptr, ks.scryptP, scryptdkLen)
 	if err != nil {
-		return err
+		return cipherJSON{}, err
 	}
 
-	keyBytes := FromECDSA(key.PrivateKey)
-	keyBytesHash := Sha3(keyBytes)
-	toEncrypt := PKCS7Pad(append(keyBytes, keyBytesHash...))
+	dataHash := Sha3(data)
+	toEncrypt := PKCS7Pad(append(append([]byte{}, data...), dataHash...))
 
 	AES256Block, err := aes.NewCipher(derivedKey)
 	if err != nil {
-		return err
+		return cipherJSON{}, err
 	}
 
 	iv := randentropy.GetEntropyMixed(aes.BlockSize) // 16
@@ -138,22 +217,15 @@ func (ks keyStorePassphrase) StoreKey(key *Key, auth string) (err error) {
 	cipherText := make([]byte, len(toEncrypt))
 	AES256CBCEncrypter.CryptBlocks(cipherText, toEncrypt)
 
-	cipherStruct := cipherJSON{
-		salt,
-		iv,
-		cipherText,
-	}
-	keyStruct := encryptedKeyJSON{
-		key.Id,
-		key.Address,
-		cipherStruct,
-	}
-	keyJSON, err := json.Marshal(keyStruct)
-	if err != nil {
-		return err
-	}
-
-	return WriteKeyFile(key.Address, ks.keysDirPath, keyJSON)
+	return cipherJSON{
+		Salt:       salt,
+		IV:         iv,
+		CipherText: cipherText,
+		KDF:        kdfScrypt,
+		N:          ks.scryptN,
+		R:          scryptr,
+		P:          ks.scryptP,
+	}, nil
 }
 
 func (ks keyStorePassphrase) DeleteKey(keyAddr []byte, auth string) (err error) {
@@ -173,28 +245,172 @@ func DecryptKey(ks keyStorePassphrase, keyAddr []byte, auth string) (keyBytes []
 		return nil, nil, err
 	}
 
+	// Web3 Secret Storage files carry a version, the original format doesn't
+	version := new(struct {
+		Version int `json:"version"`
+	})
+	if err = json.Unmarshal(fileContent, version); err != nil {
+		return nil, nil, err
+	}
+	if version.Version != 0 {
+		return decryptKeyV3(fileContent, auth)
+	}
+
 	keyProtected := new(encryptedKeyJSON)
-	err = json.Unmarshal(fileContent, keyProtected)
+	if err = json.Unmarshal(fileContent, keyProtected); err != nil {
+		return nil, nil, err
+	}
 
 	keyId = keyProtected.Id
-	salt := keyProtected.Crypto.Salt
-	iv := keyProtected.Crypto.IV
-	cipherText := keyProtected.Crypto.CipherText
+	keyBytes, err = decryptData(keyProtected.Crypto, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyBytes, keyId, err
+}
 
-	authArray := []byte(auth)
-	derivedKey, err := scrypt.Key(authArray, salt, scryptN, scryptr, scryptp, scryptdkLen)
+// decryptKeyV3 decrypts a key file in the Web3 Secret Storage format.
+func decryptKeyV3(fileContent []byte, auth string) (keyBytes []byte, keyId []byte, err error) {
+	keyProtected := new(encryptedKeyJSONV3)
+	if err = json.Unmarshal(fileContent, keyProtected); err != nil {
+		return nil, nil, err
+	}
+	if keyProtected.Version != 3 {
+		return nil, nil, fmt.Errorf("Unsupported key file version: %d", keyProtected.Version)
+	}
+	cryptoJSON := keyProtected.Crypto
+	if cryptoJSON.Cipher != "aes-128-ctr" {
+		return nil, nil, fmt.Errorf("Unsupported cipher: %s", cryptoJSON.Cipher)
+	}
+	keyId = uuid.Parse(keyProtected.Id)
+	if keyId == nil {
+		return nil, nil, fmt.Errorf("Invalid key id: %s", keyProtected.Id)
+	}
+	mac, err := hex.DecodeString(cryptoJSON.MAC)
 	if err != nil {
 		return nil, nil, err
 	}
-	plainText, err := aesCBCDecrypt(derivedKey, cipherText, iv)
+	iv, err := hex.DecodeString(cryptoJSON.CipherParams.IV)
 	if err != nil {
 		return nil, nil, err
 	}
-	keyBytes = plainText[:len(plainText)-32]
-	keyBytesHash := plainText[len(plainText)-32:]
-	if !bytes.Equal(Sha3(keyBytes), keyBytesHash) {
-		err = errors.New("Decryption failed: checksum mismatch")
+	cipherText, err := hex.DecodeString(cryptoJSON.CipherText)
+	if err != nil {
 		return nil, nil, err
 	}
-	return keyBytes, keyId, err
+
+	derivedKey, err := deriveKeyV3(cryptoJSON, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	calculatedMAC := Sha3(derivedKey[16:32], cipherText)
+	if !bytes.Equal(calculatedMAC, mac) {
+		return nil, nil, errors.New("Decryption failed: MAC mismatch")
+	}
+	keyBytes, err = aesCTRXOR(derivedKey[:16], cipherText, iv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyBytes, keyId, nil
+}
+
+// decryptData decrypts data encrypted by encryptData, verifying its checksum.
+func decryptData(cryptoJSON cipherJSON, auth string) ([]byte, error) {
+	derivedKey, err := deriveKey(cryptoJSON, auth)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := aesCBCDecrypt(derivedKey, cryptoJSON.CipherText, cryptoJSON.IV)
+	if err != nil {
+		return nil, err
+	}
+	if len(plainText) < 32 {
+		return nil, errors.New("Decryption failed: plaintext too short")
+	}
+	data := plainText[:len(plainText)-32]
+	dataHash := plainText[len(plainText)-32:]
+	if !bytes.Equal(Sha3(data), dataHash) {
+		return nil, errors.New("Decryption failed: checksum mismatch")
+	}
+	return data, nil
+}
+
+// deriveKey derives the decryption key of an encrypted key file from the
+// passphrase, using the key derivation function and parameters of the file.
+func deriveKey(cryptoJSON cipherJSON, auth string) ([]byte, error) {
+	authArray := []byte(auth)
+	salt := cryptoJSON.Salt
+	switch cryptoJSON.KDF {
+	case "":
+		// written before the parameters were stored
+		return scrypt.Key(authArray, salt, StandardScryptN, scryptr, StandardScryptP, scryptdkLen)
+	case kdfScrypt:
+		if err := checkScryptParams(cryptoJSON.N, cryptoJSON.R, cryptoJSON.P); err != nil {
+			return nil, err
+		}
+		return scrypt.Key(authArray, salt, cryptoJSON.N, cryptoJSON.R, cryptoJSON.P, scryptdkLen)
+	}
+	return nil, fmt.Errorf("Unsupported KDF: %s", cryptoJSON.KDF)
+}
+
+// deriveKeyV3 derives the decryption key of a Web3 Secret Storage key file from
+// the passphrase, using the key derivation function and parameters of the file.
+func deriveKeyV3(cryptoJSON cryptoJSON, auth string) ([]byte, error) {
+	authArray := []byte(auth)
+	salt, err := hex.DecodeString(kdfParamString(cryptoJSON.KDFParams, "salt"))
+	if err != nil {
+		return nil, err
+	}
+	if dkLen := kdfParamInt(cryptoJSON.KDFParams, "dklen"); dkLen != scryptdkLen {
+		return nil, fmt.Errorf("Unsupported derived key length: %d", dkLen)
+	}
+	switch cryptoJSON.KDF {
+	case kdfScrypt:
+		n := kdfParamInt(cryptoJSON.KDFParams, "n")
+		r := kdfParamInt(cryptoJSON.KDFParams, "r")
+		p := kdfParamInt(cryptoJSON.KDFParams, "p")
+		if err := checkScryptParams(n, r, p); err != nil {
+			return nil, err
+		}
+		return scrypt.Key(authArray, salt, n, r, p, scryptdkLen)
+	case kdfPBKDF2:
+		if prf := kdfParamString(cryptoJSON.KDFParams, "prf"); prf != pbkdf2HMACSHA {
+			return nil, fmt.Errorf("Unsupported PBKDF2 PRF: %s", prf)
+		}
+		c := kdfParamInt(cryptoJSON.KDFParams, "c")
+		if c <= 0 {
+			return nil, fmt.Errorf("Invalid PBKDF2 iteration count: %d", c)
+		}
+		return pbkdf2.Key(authArray, salt, c, scryptdkLen, sha256.New), nil
+	}
+	return nil, fmt.Errorf("Unsupported KDF: %s", cryptoJSON.KDF)
+}
+
+// checkScryptParams rejects scrypt parameters that are invalid or exceed the
+// limits on the memory and CPU time spent decrypting a key file.
+func checkScryptParams(n, r, p int) error {
+	if n <= 1 || n&(n-1) != 0 || r <= 0 || p <= 0 {
+		return fmt.Errorf("Invalid scrypt parameters: N=%d r=%d p=%d", n, r, p)
+	}
+	if n > maxScryptMemory/128/r || p > maxScryptP {
+		return fmt.Errorf("Scrypt parameters too large: N=%d r=%d p=%d", n, r, p)
+	}
+	return nil
+}
+
+// kdfParamInt returns an integer KDF parameter, or zero if it is missing or
+// not an integer.
+func kdfParamInt(params map[string]interface{}, name string) int {
+	f, ok := params[name].(float64)
+	if !ok || f != float64(int(f)) {
+		return 0
+	}
+	return int(f)
+}
+
+// kdfParamString returns a string KDF parameter, or the empty string if it is
+// missing or not a string.
+func kdfParamString(params map[string]interface{}, name string) string {
+	s, _ := params[name].(string)
+	return s
 }
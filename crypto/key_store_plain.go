@@ -41,6 +41,10 @@ type KeyStore2 interface {
 	GetKeyAddresses() ([][]byte, error)  // get all addresses
 	StoreKey(*Key, string) error         // store key optionally using auth string
 	DeleteKey([]byte, string) error      // delete key by addr and auth string
+	KeyDir() string                      // directory holding the key files
+	// encrypt other secrets the way keys are stored, and decrypt them again
+	EncryptSecret([]byte, string) ([]byte, error)
+	DecryptSecret([]byte, string) ([]byte, error)
 }
 
 type keyStorePlain struct {
@@ -66,6 +70,10 @@ func GenerateNewKeyDefault(ks KeyStore2, rand io.Reader, auth string) (key *Key,
 	return key, err
 }
 
+func (ks keyStorePlain) KeyDir() string {
+	return ks.keysDirPath
+}
+
 func (ks keyStorePlain) GetKey(keyAddr []byte, auth string) (key *Key, err error) {
 	fileContent, err := GetKeyFile(ks.keysDirPath, keyAddr)
 	if err != nil {
@@ -96,6 +104,20 @@ func (ks keyStorePlain) DeleteKey(keyAddr []byte, auth string) (err error) {
 	return err
 }
 
+// EncryptSecret stores data unencrypted, like the keys.
+func (ks keyStorePlain) EncryptSecret(data []byte, auth string) ([]byte, error) {
+	return json.Marshal(plainSecretJSON{data})
+}
+
+// DecryptSecret returns data stored by EncryptSecret.
+func (ks keyStorePlain) DecryptSecret(blob []byte, auth string) ([]byte, error) {
+	secret := new(plainSecretJSON)
+	if err := json.Unmarshal(blob, secret); err != nil {
+		return nil, err
+	}
+	return secret.Secret, nil
+}
+
 func GetKeyFile(keysDirPath string, keyAddr []byte) (fileContent []byte, err error) {
 	fileName := hex.EncodeToString(keyAddr)
 	return ioutil.ReadFile(path.Join(keysDirPath, fileName, fileName))
@@ -109,7 +131,22 @@ func WriteKeyFile(addr []byte, keysDirPath string, content []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(keyFilePath, content, 0600) // read, write for user
+	// Write to a temporary file first and rename it, so an existing key is
+	// never left half overwritten.
+	f, err := ioutil.TempFile(keyDirPath, "."+addrHex+".tmp") // read, write for user
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), keyFilePath)
 }
 
 func GetKeyAddresses(keysDirPath string) (addresses [][]byte, err error) {
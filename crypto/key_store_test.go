@@ -1,10 +1,18 @@
 package crypto
 
 import (
-	"github.com/ethereum/go-ethereum/crypto/randentropy"
-	"github.com/ethereum/go-ethereum/common"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/randentropy"
+	"golang.org/x/crypto/scrypt"
 )
 
 func TestKeyStorePlain(t *testing.T) {
@@ -97,3 +105,159 @@ func TestImportPreSaleKey(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestKeyStorePassphraseParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Keys stored with light parameters record them in the key file
+	light := NewKeyStorePassphraseParams(dir, LightScryptN, LightScryptP)
+	k1, err := light.GenerateNewKey(randentropy.Reader, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := GetKeyFile(dir, k1.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := new(encryptedKeyJSON)
+	if err := json.Unmarshal(blob, stored); err != nil {
+		t.Fatal(err)
+	}
+	if c := stored.Crypto; c.KDF != kdfScrypt || c.N != LightScryptN || c.R != scryptr || c.P != LightScryptP {
+		t.Fatalf("KDF parameter mismatch: have %s N=%d r=%d p=%d", c.KDF, c.N, c.R, c.P)
+	}
+	// so a key store with different parameters can still decrypt them
+	k2, err := NewKeyStorePassphrase(dir).GetKey(k1.Address, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(k1.PrivateKey, k2.PrivateKey) {
+		t.Fatal("private key mismatch")
+	}
+}
+
+func TestKeyStorePassphraseKDFs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePassphrase(dir)
+	pass := "foo"
+	salt := randentropy.GetEntropyCSPRNG(32)
+
+	// Key file written before the scrypt parameters were stored
+	legacy, _ := scrypt.Key([]byte(pass), salt, StandardScryptN, scryptr, StandardScryptP, scryptdkLen)
+	k1 := writeEncryptedKey(t, dir, legacy, cipherJSON{Salt: salt})
+	if k, err := ks.GetKey(k1.Address, pass); err != nil || !reflect.DeepEqual(k.PrivateKey, k1.PrivateKey) {
+		t.Fatalf("legacy key mismatch (%v)", err)
+	}
+	// Unknown key derivation functions and oversized scrypt parameters are rejected
+	k2 := writeEncryptedKey(t, dir, legacy, cipherJSON{Salt: salt, KDF: "bcrypt"})
+	if _, err := ks.GetKey(k2.Address, pass); err == nil {
+		t.Fatal("key with unknown KDF decrypted")
+	}
+	k3 := writeEncryptedKey(t, dir, legacy, cipherJSON{Salt: salt, KDF: kdfScrypt, N: 1 << 30, R: scryptr, P: 1})
+	if _, err := ks.GetKey(k3.Address, pass); err == nil {
+		t.Fatal("key with oversized scrypt parameters decrypted")
+	}
+}
+
+// Test vectors from the Web3 Secret Storage definition, both encrypting the
+// same private key with the passphrase "testpassword".
+var web3KeyTests = []struct {
+	kdf  string
+	json string
+}{
+	{
+		kdf: kdfPBKDF2,
+		json: `{
+			"crypto": {
+				"cipher": "aes-128-ctr",
+				"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+				"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+				"kdf": "pbkdf2",
+				"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+				"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+			},
+			"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+			"version": 3
+		}`,
+	},
+	{
+		kdf: kdfScrypt,
+		json: `{
+			"crypto": {
+				"cipher": "aes-128-ctr",
+				"cipherparams": {"iv": "83dbcc02d8ccb40e466191a123791e0e"},
+				"ciphertext": "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+				"kdf": "scrypt",
+				"kdfparams": {"dklen": 32, "n": 262144, "r": 1, "p": 8, "salt": "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"},
+				"mac": "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"
+			},
+			"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+			"version": 3
+		}`,
+	},
+}
+
+func TestKeyStorePassphraseWeb3(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := NewKeyStorePassphrase(dir)
+	priv := common.Hex2Bytes("7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d")
+	addr := PubkeyToAddress(ToECDSA(priv).PublicKey)
+
+	for _, tt := range web3KeyTests {
+		if err := WriteKeyFile(addr, dir, []byte(tt.json)); err != nil {
+			t.Fatal(err)
+		}
+		key, err := ks.GetKey(addr, "testpassword")
+		if err != nil {
+			t.Fatalf("%s: failed to decrypt key: %v", tt.kdf, err)
+		}
+		if have := FromECDSA(key.PrivateKey); !bytes.Equal(have, priv) {
+			t.Errorf("%s: private key mismatch: have %x, want %x", tt.kdf, have, priv)
+		}
+		if have, want := key.Id.String(), "3198bc9c-6672-5ab3-d995-4942343ae5b6"; have != want {
+			t.Errorf("%s: key id mismatch: have %s, want %s", tt.kdf, have, want)
+		}
+		if _, err := ks.GetKey(addr, "wrongpassword"); err == nil {
+			t.Errorf("%s: key decrypted with wrong passphrase", tt.kdf)
+		}
+	}
+}
+
+// writeEncryptedKey stores a new random key in dir, encrypted with the given
+// derived key and described by the given crypto parameters.
+func writeEncryptedKey(t *testing.T, dir string, derivedKey []byte, params cipherJSON) *Key {
+	key := NewKey(randentropy.Reader)
+	keyBytes := FromECDSA(key.PrivateKey)
+	plainText := PKCS7Pad(append(keyBytes, Sha3(keyBytes)...))
+
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.IV = randentropy.GetEntropyCSPRNG(aes.BlockSize)
+	params.CipherText = make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, params.IV).CryptBlocks(params.CipherText, plainText)
+
+	blob, err := json.Marshal(encryptedKeyJSON{key.Id, key.Address, params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyFile(key.Address, dir, blob); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
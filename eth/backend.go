@@ -11,9 +11,11 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -27,7 +29,11 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/pow/dev"
 	"github.com/ethereum/go-ethereum/whisper"
+	"github.com/ethereum/go-ethereum/whisper/mailserver"
 )
 
 var (
@@ -43,6 +49,13 @@ var (
 
 	staticNodes  = "static-nodes.json"  // Path within <datadir> to search for the static node list
 	trustedNodes = "trusted-nodes.json" // Path within <datadir> to search for the trusted node list
+	shhMailDir   = "shhmail"            // Path within <datadir> of the whisper mail server archive
+	shhKeyDir    = "shhkeys"            // Path within <datadir> of the whisper identity key store
+)
+
+const (
+	shhTopic   = discover.Topic("shh") // Discovery topic of whisper nodes
+	topicSlots = 3                     // Outbound connections reserved per discovery topic
 )
 
 type Config struct {
@@ -74,10 +87,53 @@ type Config struct {
 	Shh  bool
 	Dial bool
 
+	// ShhMinPoW is the minimum proof of work of whisper envelopes
+	// accepted by the node.
+	ShhMinPoW float64
+
+	// ShhMailServer enables the whisper mail server, which archives
+	// envelopes and delivers them to trusted nodes on request.
+	ShhMailServer bool
+
+	// ShhTopicFiltering makes whisper only request envelopes matching the
+	// watched topics from peers, instead of relaying all of them.
+	ShhTopicFiltering bool
+
+	// ShhKeyPassword is the passphrase encrypting the whisper identities
+	// persisted in the data directory.
+	ShhKeyPassword string
+
+	// NetRestrict, if set, limits networking and discovery to the given
+	// networks.
+	NetRestrict *netutil.Netlist
+
+	// BanDuration is the time for which peers with bad reputation are
+	// banned.
+	BanDuration time.Duration
+
 	Etherbase      string
 	MinerThreads   int
 	AccountManager *accounts.Manager
 
+	// Dev enables the development mode: a fresh developer account is
+	// created, unlocked and funded in the genesis block, and blocks are
+	// sealed without proof-of-work as soon as transactions are pending
+	// or, if DevPeriod is non-zero, at that fixed interval. DataDir is
+	// removed when the node is stopped.
+	Dev       bool
+	DevPeriod time.Duration
+
+	// Clique, if set, replaces proof-of-work with proof-of-authority: blocks
+	// are sealed in turns by the signers listed in the genesis block, which
+	// are initially CliqueSigners. The etherbase must be an unlocked signer
+	// account for this node to seal blocks.
+	Clique        *clique.Config
+	CliqueSigners []common.Address
+
+	// ChainConfig schedules the protocol upgrades of the network. If nil,
+	// the rules of the main network are used.
+	ChainConfig *params.ChainConfig
+
 	// NewDB is used to create databases.
 	// If nil, the default is to create leveldb databases on disk.
 	NewDB func(path string) (common.Database, error)
@@ -176,7 +232,8 @@ type Ethereum struct {
 	chainManager    *core.ChainManager
 	accountManager  *accounts.Manager
 	whisper         *whisper.Whisper
-	pow             *ethash.Ethash
+	mailServer      *mailserver.MailServer
+	engine          consensus.Engine
 	protocolManager *ProtocolManager
 	downloader      *downloader.Downloader
 
@@ -190,6 +247,7 @@ type Ethereum struct {
 	Mining        bool
 	NatSpec       bool
 	DataDir       string
+	dev           bool
 	etherbase     common.Address
 	clientVersion string
 	ethVersionId  int
@@ -251,6 +309,7 @@ func New(config *Config) (*Ethereum, error) {
 		eventMux:        &event.TypeMux{},
 		accountManager:  config.AccountManager,
 		DataDir:         config.DataDir,
+		dev:             config.Dev,
 		etherbase:       common.HexToAddress(config.Etherbase),
 		clientVersion:   config.Name, // TODO should separate from Name
 		ethVersionId:    config.ProtocolVersion,
@@ -258,17 +317,62 @@ func New(config *Config) (*Ethereum, error) {
 		NatSpec:         config.NatSpec,
 	}
 
-	eth.chainManager = core.NewChainManager(blockDb, stateDb, eth.EventMux())
+	chainConfig := config.ChainConfig
+	if chainConfig == nil {
+		chainConfig = params.DefaultChainConfig
+	}
+	if config.Dev {
+		developer, err := createDeveloper(config.AccountManager)
+		if err != nil {
+			return nil, err
+		}
+		glog.V(logger.Info).Infof("Using developer account %x", developer)
+
+		eth.etherbase = developer
+		eth.chainManager = core.NewChainManagerWithGenesis(blockDb, stateDb, core.DevGenesisBlock(stateDb, developer), chainConfig, eth.EventMux())
+		eth.engine = ethash.NewWithPoW(dev.New())
+	} else if config.Clique != nil {
+		genesis := core.GenesisBlockWithExtra(stateDb, clique.GenesisExtra(config.CliqueSigners))
+		eth.chainManager = core.NewChainManagerWithGenesis(blockDb, stateDb, genesis, chainConfig, eth.EventMux())
+		eth.engine = clique.New(*config.Clique, extraDb)
+	} else {
+		eth.chainManager = core.NewChainManagerWithGenesis(blockDb, stateDb, core.GenesisBlock(stateDb), chainConfig, eth.EventMux())
+		eth.engine = ethash.New()
+	}
 	eth.downloader = downloader.New(eth.chainManager.HasBlock, eth.chainManager.GetBlock)
-	eth.pow = ethash.New()
 	eth.txPool = core.NewTxPool(eth.EventMux(), eth.chainManager.State, eth.chainManager.GasLimit)
-	eth.blockProcessor = core.NewBlockProcessor(stateDb, extraDb, eth.pow, eth.txPool, eth.chainManager, eth.EventMux())
+	eth.blockProcessor = core.NewBlockProcessor(stateDb, extraDb, eth.engine, eth.txPool, eth.chainManager, eth.EventMux())
 	eth.chainManager.SetProcessor(eth.blockProcessor)
-	eth.miner = miner.New(eth, eth.pow, config.MinerThreads)
+	if config.Dev {
+		eth.miner = miner.NewInstant(eth, eth.engine, config.DevPeriod)
+	} else if config.Clique != nil {
+		// A single signature seals a block, more threads would only race
+		eth.miner = miner.New(eth, eth.engine, 1)
+	} else {
+		eth.miner = miner.New(eth, eth.engine, config.MinerThreads)
+	}
 	eth.protocolManager = NewProtocolManager(config.ProtocolVersion, config.NetworkId, eth.eventMux, eth.txPool, eth.chainManager, eth.downloader)
 	if config.Shh {
 		eth.whisper = whisper.New()
+		eth.whisper.SetMinimumPoW(config.ShhMinPoW)
+		eth.whisper.SetTopicFiltering(config.ShhTopicFiltering)
+		keyStore := crypto.NewKeyStorePassphrase(filepath.Join(config.DataDir, shhKeyDir))
+		if err := eth.whisper.SetKeyStore(keyStore, config.ShhKeyPassword); err != nil {
+			return nil, err
+		}
 		eth.shhVersionId = int(eth.whisper.Version())
+
+		if config.ShhMailServer {
+			var trusted []discover.NodeID
+			for _, n := range config.parseNodes(trustedNodes) {
+				trusted = append(trusted, n.ID)
+			}
+			eth.mailServer, err = mailserver.New(filepath.Join(config.DataDir, shhMailDir), trusted)
+			if err != nil {
+				return nil, err
+			}
+			eth.whisper.RegisterMailServer(eth.mailServer)
+		}
 	}
 
 	netprv, err := config.nodeKey()
@@ -276,8 +380,11 @@ func New(config *Config) (*Ethereum, error) {
 		return nil, err
 	}
 	protocols := []p2p.Protocol{eth.protocolManager.SubProtocol}
+	var topics []discover.Topic
 	if config.Shh {
 		protocols = append(protocols, eth.whisper.Protocol())
+		// Whisper nodes are rare, find them through topic discovery.
+		topics = append(topics, shhTopic)
 	}
 	eth.net = &p2p.Server{
 		PrivateKey:     netprv,
@@ -290,6 +397,11 @@ func New(config *Config) (*Ethereum, error) {
 		StaticNodes:    config.parseNodes(staticNodes),
 		TrustedNodes:   config.parseNodes(trustedNodes),
 		NodeDatabase:   nodeDb,
+		EventMux:       eth.eventMux,
+		NetRestrict:    config.NetRestrict,
+		BanDuration:    config.BanDuration,
+		Topics:         topics,
+		TopicSlots:     len(topics) * topicSlots,
 	}
 	if len(config.Port) > 0 {
 		eth.net.ListenAddr = ":" + config.Port
@@ -300,6 +412,19 @@ func New(config *Config) (*Ethereum, error) {
 	return eth, nil
 }
 
+// createDeveloper creates a new account with an empty passphrase and unlocks
+// it for the lifetime of the process.
+func createDeveloper(am *accounts.Manager) (common.Address, error) {
+	account, err := am.NewAccount("")
+	if err != nil {
+		return common.Address{}, fmt.Errorf("could not create developer account: %v", err)
+	}
+	if err := am.Unlock(account.Address, ""); err != nil {
+		return common.Address{}, fmt.Errorf("could not unlock developer account: %v", err)
+	}
+	return common.BytesToAddress(account.Address), nil
+}
+
 type NodeInfo struct {
 	Name       string
 	NodeUrl    string
@@ -332,6 +457,9 @@ type PeerInfo struct {
 	Caps          string
 	RemoteAddress string
 	LocalAddress  string
+	Ingress       int64 // subprotocol payload bytes received
+	Egress        int64 // subprotocol payload bytes sent
+	Reputation    int64
 }
 
 func newPeerInfo(peer *p2p.Peer) *PeerInfo {
@@ -339,12 +467,16 @@ func newPeerInfo(peer *p2p.Peer) *PeerInfo {
 	for _, cap := range peer.Caps() {
 		caps = append(caps, cap.String())
 	}
+	ingress, egress := peer.Traffic()
 	return &PeerInfo{
 		ID:            peer.ID().String(),
 		Name:          peer.Name(),
 		Caps:          strings.Join(caps, ", "),
 		RemoteAddress: peer.RemoteAddr().String(),
 		LocalAddress:  peer.LocalAddr().String(),
+		Ingress:       ingress,
+		Egress:        egress,
+		Reputation:    peer.Reputation(),
 	}
 }
 
@@ -369,6 +501,11 @@ func (s *Ethereum) StartMining() error {
 		glog.V(logger.Error).Infoln(err)
 		return err
 	}
+	if engine, ok := s.engine.(*clique.Clique); ok {
+		engine.Authorize(eb, func(signer common.Address, hash []byte) ([]byte, error) {
+			return s.accountManager.Sign(accounts.Account{Address: signer.Bytes()}, hash)
+		})
+	}
 
 	go s.miner.Start(eb)
 	return nil
@@ -396,6 +533,7 @@ func (s *Ethereum) Name() string                         { return s.net.Name }
 func (s *Ethereum) AccountManager() *accounts.Manager    { return s.accountManager }
 func (s *Ethereum) ChainManager() *core.ChainManager     { return s.chainManager }
 func (s *Ethereum) BlockProcessor() *core.BlockProcessor { return s.blockProcessor }
+func (s *Ethereum) Engine() consensus.Engine             { return s.engine }
 func (s *Ethereum) TxPool() *core.TxPool                 { return s.txPool }
 func (s *Ethereum) Whisper() *whisper.Whisper            { return s.whisper }
 func (s *Ethereum) EventMux() *event.TypeMux             { return s.eventMux }
@@ -495,6 +633,37 @@ func (self *Ethereum) AddPeer(nodeURL string) error {
 	return nil
 }
 
+// RemovePeer stops maintaining the connection to the given node and
+// disconnects it if it is currently connected.
+func (self *Ethereum) RemovePeer(nodeURL string) error {
+	n, err := discover.ParseNode(nodeURL)
+	if err != nil {
+		return fmt.Errorf("invalid node URL: %v", err)
+	}
+	self.net.RemovePeer(n)
+	return nil
+}
+
+// AddTrustedPeer allows the given node to connect even above the peer limit.
+func (self *Ethereum) AddTrustedPeer(nodeURL string) error {
+	n, err := discover.ParseNode(nodeURL)
+	if err != nil {
+		return fmt.Errorf("invalid node URL: %v", err)
+	}
+	self.net.AddTrustedPeer(n)
+	return nil
+}
+
+// RemoveTrustedPeer makes the given node subject to the peer limit again.
+func (self *Ethereum) RemoveTrustedPeer(nodeURL string) error {
+	n, err := discover.ParseNode(nodeURL)
+	if err != nil {
+		return fmt.Errorf("invalid node URL: %v", err)
+	}
+	self.net.RemoveTrustedPeer(n)
+	return nil
+}
+
 func (s *Ethereum) Stop() {
 	s.txSub.Unsubscribe() // quits txBroadcastLoop
 
@@ -505,6 +674,14 @@ func (s *Ethereum) Stop() {
 	if s.whisper != nil {
 		s.whisper.Stop()
 	}
+	if s.mailServer != nil {
+		s.mailServer.Close()
+	}
+	if s.dev {
+		if err := os.RemoveAll(s.DataDir); err != nil {
+			glog.V(logger.Error).Infof("Failed to remove development data directory: %v", err)
+		}
+	}
 
 	glog.V(logger.Info).Infoln("Server stopped")
 	close(s.shutdownChan)
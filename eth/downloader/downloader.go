@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -35,6 +36,12 @@ var (
 	errPeersUnavailable    = errors.New("no peers available or all peers tried for block download process")
 	errAlreadyInPool       = errors.New("hash already in pool")
 	errBlockNumberOverflow = errors.New("received block which overflows")
+
+	syncTimer         = metrics.NewTimer("downloader/syncs")
+	hashInMeter       = metrics.NewMeter("downloader/hashes/in")
+	blockInMeter      = metrics.NewMeter("downloader/blocks/in")
+	blockTakenMeter   = metrics.NewMeter("downloader/blocks/taken")
+	syncFailuresMeter = metrics.NewMeter("downloader/syncs/failed")
 )
 
 type hashCheckFn func(common.Hash) bool
@@ -96,14 +103,17 @@ func (d *Downloader) Stats() (current int, max int) {
 	return d.queue.blockHashes.Size(), d.queue.fetchPool.Size() + d.queue.hashPool.Size()
 }
 
-func (d *Downloader) RegisterPeer(id string, hash common.Hash, getHashes hashFetcherFn, getBlocks blockFetcherFn) error {
+// RegisterPeer injects a new download peer into the set of block sources. The
+// peers with the highest reputation are asked for blocks first. The outcome
+// of block requests is reported through report, which may be nil.
+func (d *Downloader) RegisterPeer(id string, hash common.Hash, rep int, getHashes hashFetcherFn, getBlocks blockFetcherFn, report deliveryReportFn) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	glog.V(logger.Detail).Infoln("Register peer", id)
 
 	// Create a new peer and add it to the list of known peers
-	peer := newPeer(id, hash, getHashes, getBlocks)
+	peer := newPeer(id, hash, rep, getHashes, getBlocks, report)
 	// add peer to our peer set
 	d.peers[id] = peer
 	// broadcast new peer
@@ -145,10 +155,13 @@ func (d *Downloader) Synchronise(id string, hash common.Hash) error {
 	}
 
 	// Get the hash from the peer and initiate the downloading progress.
+	start := time.Now()
 	err := d.getFromPeer(p, hash, false)
 	if err != nil {
+		syncFailuresMeter.Mark(1)
 		return err
 	}
+	syncTimer.UpdateSince(start)
 
 	return nil
 }
@@ -201,6 +214,7 @@ func (d *Downloader) TakeBlocks() types.Blocks {
 		}
 
 	}
+	blockTakenMeter.Mark(int64(len(blocks)))
 
 	return blocks
 }
@@ -473,6 +487,7 @@ out:
 // Deliver a chunk to the downloader. This is usually done through the BlocksMsg by
 // the protocol handler.
 func (d *Downloader) DeliverChunk(id string, blocks []*types.Block) {
+	blockInMeter.Mark(int64(len(blocks)))
 	d.blockCh <- blockPack{id, blocks}
 }
 
@@ -489,6 +504,7 @@ func (d *Downloader) AddHashes(id string, hashes []common.Hash) error {
 		glog.Infof("adding %d (T=%d) hashes [ %x / %x ] from: %s\n", len(hashes), d.queue.hashPool.Size(), from[:4], to[:4], id)
 	}
 
+	hashInMeter.Mark(int64(len(hashes)))
 	d.hashCh <- hashPack{id, hashes}
 
 	return nil
@@ -96,16 +96,16 @@ func (dl *downloadTester) getBlocks(id string) func([]common.Hash) error {
 func (dl *downloadTester) newPeer(id string, td *big.Int, hash common.Hash) {
 	dl.pcount++
 
-	dl.downloader.RegisterPeer(id, hash, dl.getHashes, dl.getBlocks(id))
+	dl.downloader.RegisterPeer(id, hash, 0, dl.getHashes, dl.getBlocks(id), nil)
 }
 
 func (dl *downloadTester) badBlocksPeer(id string, td *big.Int, hash common.Hash) {
 	dl.pcount++
 
 	// This bad peer never returns any blocks
-	dl.downloader.RegisterPeer(id, hash, dl.getHashes, func([]common.Hash) error {
+	dl.downloader.RegisterPeer(id, hash, 0, dl.getHashes, func([]common.Hash) error {
 		return nil
-	})
+	}, nil)
 }
 
 func TestDownload(t *testing.T) {
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -15,6 +16,7 @@ const (
 
 type hashFetcherFn func(common.Hash) error
 type blockFetcherFn func([]common.Hash) error
+type deliveryReportFn func(useful bool)
 
 // XXX make threadsafe!!!!
 type peers map[string]*peer
@@ -25,6 +27,8 @@ func (p peers) reset() {
 	}
 }
 
+// get returns the peers in the given state, the ones with the best
+// reputation first.
 func (p peers) get(state int) []*peer {
 	var peers []*peer
 	for _, peer := range p {
@@ -34,10 +38,24 @@ func (p peers) get(state int) []*peer {
 		}
 		peer.mu.RUnlock()
 	}
+	sort.Sort(peersByRep(peers))
 
 	return peers
 }
 
+type peersByRep []*peer
+
+func (ps peersByRep) Len() int      { return len(ps) }
+func (ps peersByRep) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps peersByRep) Less(i, j int) bool {
+	ps[i].mu.RLock()
+	defer ps[i].mu.RUnlock()
+	ps[j].mu.RLock()
+	defer ps[j].mu.RUnlock()
+
+	return ps[i].rep > ps[j].rep
+}
+
 func (p peers) setState(id string, state int) {
 	if peer, exist := p[id]; exist {
 		peer.mu.Lock()
@@ -53,7 +71,7 @@ func (p peers) getPeer(id string) *peer {
 // peer represents an active peer
 type peer struct {
 	state int // Peer state (working, idle)
-	rep   int // TODO peer reputation
+	rep   int // Peer reputation, determines the order in which peers are used
 
 	mu         sync.RWMutex
 	id         string
@@ -63,15 +81,21 @@ type peer struct {
 
 	getHashes hashFetcherFn
 	getBlocks blockFetcherFn
+	report    deliveryReportFn
 }
 
 // create a new peer
-func newPeer(id string, hash common.Hash, getHashes hashFetcherFn, getBlocks blockFetcherFn) *peer {
+func newPeer(id string, hash common.Hash, rep int, getHashes hashFetcherFn, getBlocks blockFetcherFn, report deliveryReportFn) *peer {
+	if rep < 0 {
+		rep = 0
+	}
 	return &peer{
 		id:         id,
 		recentHash: hash,
+		rep:        rep,
 		getHashes:  getHashes,
 		getBlocks:  getBlocks,
+		report:     report,
 		state:      idleState,
 		ignored:    set.New(),
 	}
@@ -103,21 +127,27 @@ func (p *peer) fetch(chunk *chunk) error {
 // promote increases the peer's reputation
 func (p *peer) promote() {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	p.rep++
+	p.mu.Unlock()
+
+	if p.report != nil {
+		p.report(true)
+	}
 }
 
 // demote decreases the peer's reputation or leaves it at 0
 func (p *peer) demote() {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	if p.rep > 1 {
 		p.rep -= 2
 	} else {
 		p.rep = 0
 	}
+	p.mu.Unlock()
+
+	if p.report != nil {
+		p.report(false)
+	}
 }
 
 func (p *peer) reset() {
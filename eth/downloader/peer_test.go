@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPeerReputation(t *testing.T) {
+	var reports []bool
+	report := func(useful bool) { reports = append(reports, useful) }
+
+	ps := make(peers)
+	ps["low"] = newPeer("low", common.Hash{}, -5, nil, nil, report)
+	ps["mid"] = newPeer("mid", common.Hash{}, 3, nil, nil, nil)
+	ps["high"] = newPeer("high", common.Hash{}, 10, nil, nil, nil)
+
+	if rep := ps["low"].rep; rep != 0 {
+		t.Errorf("negative reputation not clamped: got %d", rep)
+	}
+	order := func() (ids []string) {
+		for _, p := range ps.get(idleState) {
+			ids = append(ids, p.id)
+		}
+		return ids
+	}
+	if ids := order(); len(ids) != 3 || ids[0] != "high" || ids[1] != "mid" || ids[2] != "low" {
+		t.Errorf("wrong peer order: %v", ids)
+	}
+
+	// promote low past the others.
+	for i := 0; i < 11; i++ {
+		ps["low"].promote()
+	}
+	ps["high"].demote()
+	if ids := order(); ids[0] != "low" {
+		t.Errorf("wrong peer order after promotion: %v", ids)
+	}
+	ps["low"].demote()
+	if len(reports) != 12 || !reports[0] || reports[11] {
+		t.Errorf("wrong reports: %v", reports)
+	}
+}
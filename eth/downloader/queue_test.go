@@ -33,8 +33,8 @@ func createBlocksFromHashSet(hashes *set.Set) []*types.Block {
 
 func TestChunking(t *testing.T) {
 	queue := newqueue()
-	peer1 := newPeer("peer1", common.Hash{}, nil, nil)
-	peer2 := newPeer("peer2", common.Hash{}, nil, nil)
+	peer1 := newPeer("peer1", common.Hash{}, 0, nil, nil, nil)
+	peer2 := newPeer("peer2", common.Hash{}, 0, nil, nil, nil)
 
 	// 99 + 1 (1 == known genesis hash)
 	hashes := createHashes(0, 99)
@@ -15,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -25,8 +26,18 @@ const (
 	blockProcAmount     = 256
 )
 
+// protocolError is returned for messages which violate the protocol.
+type protocolError struct {
+	code errCode
+	msg  string
+}
+
+func (err *protocolError) Error() string {
+	return fmt.Sprintf("%v - %v", err.code, err.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protocolError{code, fmt.Sprintf(format, v...)}
 }
 
 type hashFetcherFn func(common.Hash) error
@@ -78,6 +89,8 @@ func NewProtocolManager(protocolVersion, networkId int, mux *event.TypeMux, txpo
 		quitSync:   make(chan struct{}),
 	}
 
+	_, _, genesis := chainman.Status()
+	ourEntry := ethEntry{NetworkId: uint64(networkId), Genesis: genesis}
 	manager.SubProtocol = p2p.Protocol{
 		Name:    "eth",
 		Version: uint(protocolVersion),
@@ -89,6 +102,16 @@ func NewProtocolManager(protocolVersion, networkId int, mux *event.TypeMux, txpo
 
 			return manager.handle(peer)
 		},
+		Attributes: []enr.Entry{ourEntry},
+		NodeFilter: func(r *enr.Record) bool {
+			var entry ethEntry
+			if err := r.Load(&entry); err != nil {
+				// Nodes which don't advertise their chain are checked
+				// in the status handshake.
+				return enr.IsNotFound(err)
+			}
+			return entry == ourEntry
+		},
 	}
 
 	return manager
@@ -143,7 +166,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	pm.peers[p.id] = p
 	pm.pmu.Unlock()
 
-	pm.downloader.RegisterPeer(p.id, p.recentHash, p.requestHashes, p.requestBlocks)
+	pm.downloader.RegisterPeer(p.id, p.recentHash, int(p.Reputation()), p.requestHashes, p.requestBlocks, p.reportDelivery)
 	defer func() {
 		pm.removePeer(p)
 	}()
@@ -157,6 +180,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// main loop. handle incoming messages.
 	for {
 		if err := pm.handleMsg(p); err != nil {
+			if perr, ok := err.(*protocolError); ok && perr.code != ErrSuspendedPeer {
+				p.Report(p2p.ProtocolError)
+			}
 			return err
 		}
 	}
@@ -310,6 +336,9 @@ func (self *ProtocolManager) handleMsg(p *peer) error {
 		// otherwise synchronise with the peer
 		if self.chainman.HasBlock(request.Block.ParentHash()) {
 			if _, err := self.chainman.InsertChain(types.Blocks{request.Block}); err != nil {
+				if p.Report(p2p.InvalidBlock) {
+					return errResp(ErrSuspendedPeer, "invalid block %x: %v", hash, err)
+				}
 				glog.V(logger.Error).Infoln("removed peer (", p.id, ") due to block error")
 
 				self.removePeer(p)
@@ -70,6 +70,16 @@ func newPeer(protv, netid int, genesis, recentHash common.Hash, td *big.Int, p *
 	}
 }
 
+// reportDelivery records the outcome of a block request made by the
+// downloader in the peer's reputation.
+func (p *peer) reportDelivery(useful bool) {
+	if useful {
+		p.Report(p2p.UsefulDelivery)
+	} else {
+		p.Report(p2p.RequestTimeout)
+	}
+}
+
 // sendTransactions sends transactions to the peer and includes the hashes
 // in it's tx hash set for future reference. The tx hash will allow the
 // manager to check whether the peer has already received this particular
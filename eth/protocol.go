@@ -72,6 +72,16 @@ type chainManager interface {
 	Status() (td *big.Int, currentBlock common.Hash, genesisBlock common.Hash)
 }
 
+// ethEntry is the "eth" node record entry. It advertises the network and
+// genesis block of a node so that nodes on other chains can be skipped
+// before connecting to them.
+type ethEntry struct {
+	NetworkId uint64
+	Genesis   common.Hash
+}
+
+func (ethEntry) ENRKey() string { return "eth" }
+
 // message structs used for RLP serialization
 type newBlockMsgData struct {
 	Block *types.Block
@@ -7,6 +7,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
 )
 
 // Sync contains all synchronisation code for the eth protocol
@@ -100,6 +101,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	err := pm.downloader.Synchronise(peer.id, peer.recentHash)
 	if err != nil && err == downloader.ErrBadPeer {
 		glog.V(logger.Debug).Infoln("removed peer from peer set due to bad action")
+		peer.Report(p2p.ProtocolError)
 		pm.removePeer(peer)
 	} else if err != nil {
 		// handle error
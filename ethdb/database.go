@@ -1,11 +1,14 @@
 package ethdb
 
 import (
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/compression/rle"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
 )
@@ -19,6 +22,11 @@ type LDBDatabase struct {
 	queue map[string][]byte
 
 	quit chan struct{}
+
+	getTimer   *metrics.Timer // database reads, including queued values
+	missMeter  *metrics.Meter // reads of missing keys
+	writeMeter *metrics.Meter // bytes put
+	flushTimer *metrics.Timer // writes of the queue to disk
 }
 
 func NewLDBDatabase(file string) (*LDBDatabase, error) {
@@ -27,10 +35,16 @@ func NewLDBDatabase(file string) (*LDBDatabase, error) {
 	if err != nil {
 		return nil, err
 	}
+	// metrics are named after the database directory, e.g. "db/blockchain/gets"
+	prefix := "db/" + filepath.Base(file) + "/"
 	database := &LDBDatabase{
-		fn:   file,
-		db:   db,
-		quit: make(chan struct{}),
+		fn:         file,
+		db:         db,
+		quit:       make(chan struct{}),
+		getTimer:   metrics.NewTimer(prefix + "gets"),
+		missMeter:  metrics.NewMeter(prefix + "misses"),
+		writeMeter: metrics.NewMeter(prefix + "writes"),
+		flushTimer: metrics.NewTimer(prefix + "flushes"),
 	}
 	database.makeQueue()
 
@@ -46,6 +60,7 @@ func (self *LDBDatabase) Put(key []byte, value []byte) {
 	defer self.mu.Unlock()
 
 	self.queue[string(key)] = value
+	self.writeMeter.Mark(int64(len(value)))
 	/*
 		value = rle.Compress(value)
 
@@ -59,6 +74,7 @@ func (self *LDBDatabase) Put(key []byte, value []byte) {
 func (self *LDBDatabase) Get(key []byte) ([]byte, error) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
+	defer self.getTimer.UpdateSince(time.Now())
 
 	// Check queue first
 	if dat, ok := self.queue[string(key)]; ok {
@@ -67,6 +83,9 @@ func (self *LDBDatabase) Get(key []byte) ([]byte, error) {
 
 	dat, err := self.db.Get(key, nil)
 	if err != nil {
+		if err == leveldb.ErrNotFound {
+			self.missMeter.Mark(1)
+		}
 		return nil, err
 	}
 
@@ -100,6 +119,7 @@ func (self *LDBDatabase) NewIterator() iterator.Iterator {
 func (self *LDBDatabase) Flush() error {
 	self.mu.Lock()
 	defer self.mu.Unlock()
+	defer self.flushTimer.UpdateSince(time.Now())
 
 	batch := new(leveldb.Batch)
 
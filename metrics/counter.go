@@ -0,0 +1,40 @@
+package metrics
+
+import "sync/atomic"
+
+// Counter is a value which is incremented and decremented, like the number
+// of messages handled so far.
+type Counter struct {
+	count int64
+}
+
+// Inc increments the counter by n.
+func (c *Counter) Inc(n int64) {
+	atomic.AddInt64(&c.count, n)
+}
+
+// Dec decrements the counter by n.
+func (c *Counter) Dec(n int64) {
+	atomic.AddInt64(&c.count, -n)
+}
+
+// Count returns the current value of the counter.
+func (c *Counter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// Gauge is an instantaneous value which is set as a whole, like the size of
+// a queue.
+type Gauge struct {
+	value int64
+}
+
+// Update sets the value of the gauge.
+func (g *Gauge) Update(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+// Value returns the last value set.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
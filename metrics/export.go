@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// quantiles are the percentiles reported for histograms and timers.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+// Snapshot returns the current values of all metrics of a registry, keyed by
+// metric name. Counters and gauges map to their value, the other types to a
+// map of their statistics.
+func Snapshot(r *Registry) map[string]interface{} {
+	snapshot := make(map[string]interface{})
+	r.Each(func(name string, metric interface{}) {
+		switch metric := metric.(type) {
+		case *Counter:
+			snapshot[name] = metric.Count()
+		case *Gauge:
+			snapshot[name] = metric.Value()
+		case *Meter:
+			snapshot[name] = map[string]interface{}{
+				"count":    metric.Count(),
+				"rate1":    metric.Rate1(),
+				"rateMean": metric.RateMean(),
+			}
+		case *Histogram:
+			snapshot[name] = histogramStats(metric)
+		case *Timer:
+			stats := histogramStats(metric.Histogram)
+			stats["rate1"] = metric.Rate1()
+			stats["rateMean"] = metric.RateMean()
+			snapshot[name] = stats
+		}
+	})
+	return snapshot
+}
+
+func histogramStats(h *Histogram) map[string]interface{} {
+	ps := h.Percentiles(quantiles...)
+	return map[string]interface{}{
+		"count": h.Count(),
+		"min":   h.Min(),
+		"max":   h.Max(),
+		"mean":  h.Mean(),
+		"p50":   ps[0],
+		"p75":   ps[1],
+		"p95":   ps[2],
+		"p99":   ps[3],
+	}
+}
+
+// WritePrometheus writes all metrics of a registry in the Prometheus text
+// exposition format. Meters become counters with an extra rate gauge, and
+// histograms and timers become summaries, the latter in seconds.
+func WritePrometheus(w io.Writer, r *Registry) error {
+	bw := bufio.NewWriter(w)
+	r.Each(func(name string, metric interface{}) {
+		name = promName(name)
+		switch metric := metric.(type) {
+		case *Counter:
+			fmt.Fprintf(bw, "# TYPE %s counter\n%s %d\n", name, name, metric.Count())
+		case *Gauge:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %d\n", name, name, metric.Value())
+		case *Meter:
+			fmt.Fprintf(bw, "# TYPE %s_total counter\n%s_total %d\n", name, name, metric.Count())
+			fmt.Fprintf(bw, "# TYPE %s_rate1 gauge\n%s_rate1 %g\n", name, name, metric.Rate1())
+		case *Histogram:
+			writeSummary(bw, name, metric, 1)
+		case *Timer:
+			writeSummary(bw, name+"_seconds", metric.Histogram, float64(time.Second))
+		}
+	})
+	return bw.Flush()
+}
+
+func writeSummary(w io.Writer, name string, h *Histogram, unit float64) {
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	for i, v := range h.Percentiles(quantiles...) {
+		fmt.Fprintf(w, "%s{quantile=\"%g\"} %g\n", name, quantiles[i], v/unit)
+	}
+	h.lock.Lock()
+	count, sum := h.count, h.sum
+	h.lock.Unlock()
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, float64(sum)/unit, name, count)
+}
+
+// promName converts a metric name into one valid for Prometheus, which only
+// allows letters, digits and underscores.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// Handler returns an HTTP handler serving the metrics of a registry in the
+// Prometheus text format.
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WritePrometheus(w, r)
+	})
+}
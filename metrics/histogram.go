@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// histogramSampleSize is the number of values a histogram keeps to estimate
+// percentiles. It offers a 99.9% confidence level with a 5% margin of error
+// for normal distributions.
+const histogramSampleSize = 1028
+
+// Histogram measures the distribution of values, like the sizes of
+// messages. Count, minimum, maximum and mean are exact, percentiles are
+// estimated from a uniform sample of the values.
+type Histogram struct {
+	lock     sync.Mutex
+	count    int64
+	sum      int64
+	min, max int64
+	sample   []int64
+	rand     *rand.Rand
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{
+		sample: make([]int64, 0, histogramSampleSize),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Update records a value.
+func (h *Histogram) Update(v int64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if h.count == 0 || v > h.max {
+		h.max = v
+	}
+	h.count++
+	h.sum += v
+
+	// reservoir sampling keeps every value with the same probability
+	if len(h.sample) < histogramSampleSize {
+		h.sample = append(h.sample, v)
+	} else if r := h.rand.Int63n(h.count); r < histogramSampleSize {
+		h.sample[r] = v
+	}
+}
+
+// Count returns the number of values recorded.
+func (h *Histogram) Count() int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.count
+}
+
+// Min returns the smallest value recorded, or zero if there is none.
+func (h *Histogram) Min() int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.min
+}
+
+// Max returns the largest value recorded, or zero if there is none.
+func (h *Histogram) Max() int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.max
+}
+
+// Mean returns the average of the values recorded, or zero if there is none.
+func (h *Histogram) Mean() float64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.count == 0 {
+		return 0
+	}
+	return float64(h.sum) / float64(h.count)
+}
+
+// Percentiles returns the estimated values below which the given fractions
+// (between 0 and 1) of the recorded values fall.
+func (h *Histogram) Percentiles(ps ...float64) []float64 {
+	h.lock.Lock()
+	sample := make([]int64, len(h.sample))
+	copy(sample, h.sample)
+	h.lock.Unlock()
+
+	results := make([]float64, len(ps))
+	if len(sample) == 0 {
+		return results
+	}
+	sort.Sort(int64Slice(sample))
+	for i, p := range ps {
+		// interpolate between the closest ranks
+		pos := p * float64(len(sample)-1)
+		lower := int(math.Floor(pos))
+		if lower >= len(sample)-1 {
+			results[i] = float64(sample[len(sample)-1])
+			continue
+		}
+		if lower < 0 {
+			lower, pos = 0, 0
+		}
+		frac := pos - float64(lower)
+		results[i] = float64(sample[lower]) + frac*float64(sample[lower+1]-sample[lower])
+	}
+	return results
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Timer measures both the rate at which an operation happens and the
+// distribution of its durations, like block imports. Durations are recorded
+// in nanoseconds.
+type Timer struct {
+	*Histogram
+	meter *Meter
+}
+
+func newTimer() *Timer {
+	return &Timer{Histogram: newHistogram(), meter: newMeter()}
+}
+
+// Update records an operation that took d.
+func (t *Timer) Update(d time.Duration) {
+	t.Histogram.Update(int64(d))
+	t.meter.Mark(1)
+}
+
+// UpdateSince records an operation that started at start and just ended.
+func (t *Timer) UpdateSince(start time.Time) {
+	t.Update(time.Since(start))
+}
+
+// Time runs f and records its duration.
+func (t *Timer) Time(f func()) {
+	start := time.Now()
+	f()
+	t.UpdateSince(start)
+}
+
+// Rate1 returns the per-second rate of operations, averaged over about the
+// last minute.
+func (t *Timer) Rate1() float64 {
+	return t.meter.Rate1()
+}
+
+// RateMean returns the per-second rate of operations since the timer was
+// created.
+func (t *Timer) RateMean() float64 {
+	return t.meter.RateMean()
+}
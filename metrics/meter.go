@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const meterTick = 5 * time.Second
+
+// alpha1 is the smoothing factor of a one minute moving average updated every
+// meterTick.
+var alpha1 = 1 - math.Exp(-meterTick.Seconds()/time.Minute.Seconds())
+
+// Meter counts events and measures the rate at which they occur, like the
+// number of bytes received per second.
+type Meter struct {
+	lock      sync.Mutex
+	count     int64
+	uncounted int64 // events since the last tick
+	start     time.Time
+	last      time.Time // time of the last tick
+	rate1     float64   // one minute exponentially weighted moving average
+	init      bool
+}
+
+func newMeter() *Meter {
+	now := time.Now()
+	return &Meter{start: now, last: now}
+}
+
+// Mark records n events.
+func (m *Meter) Mark(n int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.tick(time.Now())
+	m.count += n
+	m.uncounted += n
+}
+
+// Count returns the number of events recorded.
+func (m *Meter) Count() int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.count
+}
+
+// Rate1 returns the per-second rate of events, averaged over about the last
+// minute.
+func (m *Meter) Rate1() float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.tick(time.Now())
+	return m.rate1
+}
+
+// RateMean returns the per-second rate of events since the meter was created.
+func (m *Meter) RateMean() float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	elapsed := time.Since(m.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(m.count) / elapsed
+}
+
+// tick folds the events of all completed tick intervals into the moving
+// average. Instead of using a ticker goroutine per meter, the intervals are
+// caught up lazily whenever the meter is used.
+func (m *Meter) tick(now time.Time) {
+	ticks := int64(now.Sub(m.last) / meterTick)
+	if ticks == 0 {
+		return
+	}
+	instant := float64(m.uncounted) / meterTick.Seconds()
+	if m.init {
+		m.rate1 += alpha1 * (instant - m.rate1)
+	} else {
+		m.rate1, m.init = instant, true
+	}
+	// all further intervals passed without any events
+	m.rate1 *= math.Pow(1-alpha1, float64(ticks-1))
+
+	m.uncounted = 0
+	m.last = m.last.Add(time.Duration(ticks) * meterTick)
+}
@@ -0,0 +1,116 @@
+// Package metrics collects counters, gauges, meters, histograms and timers
+// describing the inner workings of the node.
+//
+// Metrics are identified by slash separated names such as "p2p/eth/in" and
+// live in a Registry. Packages usually create theirs at package level in the
+// DefaultRegistry:
+//
+//	var blockInsertTimer = metrics.NewTimer("chain/inserts")
+//
+// All metric types are safe for concurrent use.
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultRegistry is the registry the package level constructors use.
+var DefaultRegistry = NewRegistry()
+
+// NewCounter returns the counter registered in the DefaultRegistry under
+// name, creating it if needed.
+func NewCounter(name string) *Counter { return DefaultRegistry.Counter(name) }
+
+// NewGauge returns the gauge registered in the DefaultRegistry under name,
+// creating it if needed.
+func NewGauge(name string) *Gauge { return DefaultRegistry.Gauge(name) }
+
+// NewMeter returns the meter registered in the DefaultRegistry under name,
+// creating it if needed.
+func NewMeter(name string) *Meter { return DefaultRegistry.Meter(name) }
+
+// NewHistogram returns the histogram registered in the DefaultRegistry under
+// name, creating it if needed.
+func NewHistogram(name string) *Histogram { return DefaultRegistry.Histogram(name) }
+
+// NewTimer returns the timer registered in the DefaultRegistry under name,
+// creating it if needed.
+func NewTimer(name string) *Timer { return DefaultRegistry.Timer(name) }
+
+// Registry holds a set of named metrics.
+type Registry struct {
+	lock    sync.Mutex
+	metrics map[string]interface{}
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]interface{})}
+}
+
+// Counter returns the counter registered under name, creating it if needed.
+func (self *Registry) Counter(name string) *Counter {
+	return self.getOrRegister(name, func() interface{} { return new(Counter) }).(*Counter)
+}
+
+// Gauge returns the gauge registered under name, creating it if needed.
+func (self *Registry) Gauge(name string) *Gauge {
+	return self.getOrRegister(name, func() interface{} { return new(Gauge) }).(*Gauge)
+}
+
+// Meter returns the meter registered under name, creating it if needed.
+func (self *Registry) Meter(name string) *Meter {
+	return self.getOrRegister(name, func() interface{} { return newMeter() }).(*Meter)
+}
+
+// Histogram returns the histogram registered under name, creating it if
+// needed.
+func (self *Registry) Histogram(name string) *Histogram {
+	return self.getOrRegister(name, func() interface{} { return newHistogram() }).(*Histogram)
+}
+
+// Timer returns the timer registered under name, creating it if needed.
+func (self *Registry) Timer(name string) *Timer {
+	return self.getOrRegister(name, func() interface{} { return newTimer() }).(*Timer)
+}
+
+// getOrRegister returns the metric registered under name, or registers the
+// one returned by create. Requesting a metric under the name of one with a
+// different type is a programming error and makes the callers panic.
+func (self *Registry) getOrRegister(name string, create func() interface{}) interface{} {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if metric, ok := self.metrics[name]; ok {
+		return metric
+	}
+	metric := create()
+	self.metrics[name] = metric
+	return metric
+}
+
+// Get returns the metric registered under name, or nil.
+func (self *Registry) Get(name string) interface{} {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	return self.metrics[name]
+}
+
+// Each calls fn for every registered metric, in the order of their names.
+func (self *Registry) Each(fn func(name string, metric interface{})) {
+	self.lock.Lock()
+	names := make([]string, 0, len(self.metrics))
+	metrics := make(map[string]interface{}, len(self.metrics))
+	for name, metric := range self.metrics {
+		names = append(names, name)
+		metrics[name] = metric
+	}
+	self.lock.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, metrics[name])
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	c := new(Counter)
+	c.Inc(5)
+	c.Dec(2)
+	if n := c.Count(); n != 3 {
+		t.Errorf("count mismatch: got %d, want 3", n)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	g := new(Gauge)
+	g.Update(10)
+	g.Update(7)
+	if v := g.Value(); v != 7 {
+		t.Errorf("value mismatch: got %d, want 7", v)
+	}
+}
+
+func TestMeterRate(t *testing.T) {
+	m := newMeter()
+	start := m.start
+
+	// 50 events per second during the first tick interval
+	m.count, m.uncounted = 250, 250
+	m.tick(start.Add(meterTick))
+	if rate := m.rate1; rate != 50 {
+		t.Errorf("rate after first tick mismatch: got %g, want 50", rate)
+	}
+	// no events during the next minute, the rate must decay
+	m.tick(start.Add(meterTick + time.Minute))
+	want := 50 * math.Pow(1-alpha1, float64(time.Minute/meterTick)-1) * (1 - alpha1)
+	if math.Abs(m.rate1-want) > 1e-9 {
+		t.Errorf("decayed rate mismatch: got %g, want %g", m.rate1, want)
+	}
+	if m.count != 250 {
+		t.Errorf("count mismatch: got %d, want 250", m.count)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	for i := int64(1); i <= 101; i++ {
+		h.Update(i)
+	}
+	if h.Count() != 101 || h.Min() != 1 || h.Max() != 101 || h.Mean() != 51 {
+		t.Errorf("stats mismatch: count %d, min %d, max %d, mean %g", h.Count(), h.Min(), h.Max(), h.Mean())
+	}
+	ps := h.Percentiles(0, 0.5, 0.99, 1)
+	if want := []float64{1, 51, 100, 101}; !reflect.DeepEqual(ps, want) {
+		t.Errorf("percentiles mismatch: got %v, want %v", ps, want)
+	}
+}
+
+func TestHistogramSample(t *testing.T) {
+	h := newHistogram()
+	for i := int64(0); i < 10*histogramSampleSize; i++ {
+		h.Update(i)
+	}
+	if len(h.sample) != histogramSampleSize {
+		t.Fatalf("sample size mismatch: got %d, want %d", len(h.sample), histogramSampleSize)
+	}
+	// the median of a uniform sample should be close to the real one
+	median := h.Percentiles(0.5)[0]
+	if real := float64(5 * histogramSampleSize); math.Abs(median-real) > real/10 {
+		t.Errorf("median estimate too far off: got %g, want about %g", median, real)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	timer := newTimer()
+	timer.Update(time.Second)
+	timer.Update(3 * time.Second)
+	if timer.Count() != 2 || timer.Mean() != float64(2*time.Second) {
+		t.Errorf("stats mismatch: count %d, mean %g", timer.Count(), timer.Mean())
+	}
+	if n := timer.meter.Count(); n != 2 {
+		t.Errorf("meter count mismatch: got %d, want 2", n)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("b/counter")
+	if r.Counter("b/counter") != c {
+		t.Error("registry returned a different counter for the same name")
+	}
+	r.Meter("c/meter")
+	r.Gauge("a/gauge")
+
+	var names []string
+	r.Each(func(name string, metric interface{}) {
+		names = append(names, name)
+	})
+	if want := []string{"a/gauge", "b/counter", "c/meter"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("iteration order mismatch: got %v, want %v", names, want)
+	}
+	if r.Get("b/counter") != c {
+		t.Error("Get returned the wrong metric")
+	}
+	if r.Get("missing") != nil {
+		t.Error("Get returned a metric for an unknown name")
+	}
+}
+
+func TestRegistryTypeMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x")
+	defer func() {
+		if recover() == nil {
+			t.Error("requesting a meter under a counter's name did not panic")
+		}
+	}()
+	r.Meter("x")
+}
+
+func TestSnapshot(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("counter").Inc(3)
+	r.Gauge("gauge").Update(4)
+	r.Meter("meter").Mark(5)
+
+	snapshot := Snapshot(r)
+	if snapshot["counter"] != int64(3) || snapshot["gauge"] != int64(4) {
+		t.Errorf("snapshot value mismatch: %v", snapshot)
+	}
+	if m, ok := snapshot["meter"].(map[string]interface{}); !ok || m["count"] != int64(5) {
+		t.Errorf("snapshot meter mismatch: %v", snapshot["meter"])
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("p2p/eth-in").Inc(3)
+	r.Histogram("sizes").Update(10)
+	r.Timer("chain/inserts").Update(2 * time.Second)
+
+	buf := new(bytes.Buffer)
+	if err := WritePrometheus(buf, r); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE p2p_eth_in counter",
+		"p2p_eth_in 3",
+		"# TYPE sizes summary",
+		`sizes{quantile="0.5"} 10`,
+		"sizes_sum 10",
+		"sizes_count 1",
+		"# TYPE chain_inserts_seconds summary",
+		`chain_inserts_seconds{quantile="0.99"} 2`,
+		"chain_inserts_seconds_count 1",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output is missing line %q:\n%s", line, out)
+		}
+	}
+}
@@ -3,11 +3,10 @@ package miner
 import (
 	"sync"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/pow"
 )
 
 type CpuMiner struct {
@@ -17,21 +16,23 @@ type CpuMiner struct {
 	quitCurrentOp chan struct{}
 	returnCh      chan<- *types.Block
 
-	index int
-	pow   pow.PoW
+	index  int
+	chain  consensus.ChainReader
+	engine consensus.Engine
 }
 
-func NewCpuMiner(index int, pow pow.PoW) *CpuMiner {
+func NewCpuMiner(index int, chain consensus.ChainReader, engine consensus.Engine) *CpuMiner {
 	miner := &CpuMiner{
-		pow:   pow,
-		index: index,
+		chain:  chain,
+		engine: engine,
+		index:  index,
 	}
 
 	return miner
 }
 
 func (self *CpuMiner) Work() chan<- *types.Block          { return self.c }
-func (self *CpuMiner) Pow() pow.PoW                       { return self.pow }
+func (self *CpuMiner) Engine() consensus.Engine           { return self.engine }
 func (self *CpuMiner) SetReturnCh(ch chan<- *types.Block) { self.returnCh = ch }
 
 func (self *CpuMiner) Stop() {
@@ -85,16 +86,16 @@ func (self *CpuMiner) mine(block *types.Block) {
 	self.chMu.Unlock()
 
 	// Mine
-	nonce, mixDigest := self.pow.Search(block, self.quitCurrentOp)
-	if nonce != 0 {
-		block.SetNonce(nonce)
-		block.Header().MixDigest = common.BytesToHash(mixDigest)
-		self.returnCh <- block
-	} else {
-		self.returnCh <- nil
+	result, err := self.engine.Seal(self.chain, block, self.quitCurrentOp)
+	if err != nil {
+		glog.V(logger.Warn).Infof("agent[%d] failed to seal block: %v\n", self.index, err)
 	}
+	self.returnCh <- result
 }
 
 func (self *CpuMiner) GetHashRate() int64 {
-	return self.pow.GetHashrate()
+	if pow, ok := self.engine.(consensus.PoW); ok {
+		return pow.HashRate()
+	}
+	return 0
 }
@@ -0,0 +1,80 @@
+package miner
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+)
+
+// InstantSealer is an agent for development chains. Rather than searching
+// for a proof-of-work it hands blocks straight back to the worker, either as
+// soon as they contain transactions or, if a period is set, at that fixed
+// interval regardless of their contents.
+type InstantSealer struct {
+	period   time.Duration
+	work     chan *types.Block
+	quit     chan struct{}
+	returnCh chan<- *types.Block
+}
+
+func NewInstantSealer(period time.Duration) *InstantSealer {
+	return &InstantSealer{period: period}
+}
+
+func (self *InstantSealer) Work() chan<- *types.Block          { return self.work }
+func (self *InstantSealer) SetReturnCh(ch chan<- *types.Block) { self.returnCh = ch }
+func (self *InstantSealer) GetHashRate() int64                 { return 0 }
+
+func (self *InstantSealer) Start() {
+	self.quit = make(chan struct{})
+	self.work = make(chan *types.Block, 1)
+
+	go self.update()
+}
+
+func (self *InstantSealer) Stop() {
+	close(self.quit)
+}
+
+func (self *InstantSealer) update() {
+	var (
+		pending *types.Block     // latest work which was not sealed yet
+		ready   <-chan time.Time // fires once pending may be sealed
+		tick    <-chan time.Time
+	)
+	if self.period > 0 {
+		ticker := time.NewTicker(self.period)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case block := <-self.work:
+			// newer work always supersedes the pending one
+			pending, ready = block, nil
+			if self.period == 0 && len(block.Transactions()) > 0 {
+				ready = notBefore(block)
+			}
+		case <-tick:
+			if pending != nil && ready == nil {
+				ready = notBefore(pending)
+			}
+		case <-ready:
+			glog.V(logger.Debug).Infof("instantly sealing block #%v with %d txs\n", pending.Number(), len(pending.Transactions()))
+			// deliver asynchronously so new work can't pile up behind the worker
+			go func(block *types.Block) { self.returnCh <- block }(pending)
+			pending, ready = nil, nil
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// notBefore returns a channel which fires once the timestamp of the block is
+// no longer in the future, so sealed blocks never fail header validation.
+func notBefore(block *types.Block) <-chan time.Time {
+	return time.After(time.Unix(block.Time(), 0).Sub(time.Now()))
+}
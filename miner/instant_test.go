@@ -0,0 +1,81 @@
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newTestBlock creates a block with the given number and transaction count.
+func newTestBlock(number int64, txs int) *types.Block {
+	block := types.NewBlock(common.Hash{}, common.Address{}, common.Hash{}, big.NewInt(1), 0, nil)
+	block.Header().Number = big.NewInt(number)
+
+	transactions := make(types.Transactions, txs)
+	for i := range transactions {
+		transactions[i] = types.NewTransactionMessage(common.Address{}, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	}
+	block.SetTransactions(transactions)
+	return block
+}
+
+func TestInstantSealerPending(t *testing.T) {
+	returnCh := make(chan *types.Block)
+	sealer := NewInstantSealer(0)
+	sealer.SetReturnCh(returnCh)
+	sealer.Start()
+	defer sealer.Stop()
+
+	// Empty blocks are never sealed without a period
+	sealer.Work() <- newTestBlock(1, 0)
+	select {
+	case block := <-returnCh:
+		t.Fatalf("empty block #%v sealed", block.Number())
+	case <-time.After(100 * time.Millisecond):
+	}
+	// while blocks with transactions are, superseding the pending one
+	sealer.Work() <- newTestBlock(2, 1)
+	select {
+	case block := <-returnCh:
+		if block.Number().Int64() != 2 {
+			t.Fatalf("sealed block mismatch: have #%v, want #2", block.Number())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("block with transactions not sealed")
+	}
+}
+
+func TestInstantSealerPeriod(t *testing.T) {
+	returnCh := make(chan *types.Block)
+	sealer := NewInstantSealer(200 * time.Millisecond)
+	sealer.SetReturnCh(returnCh)
+	sealer.Start()
+	defer sealer.Stop()
+
+	// With a period, blocks are sealed at the next tick, even if empty
+	start := time.Now()
+	sealer.Work() <- newTestBlock(1, 1)
+	select {
+	case block := <-returnCh:
+		if block.Number().Int64() != 1 {
+			t.Fatalf("sealed block mismatch: have #%v, want #1", block.Number())
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Fatalf("block sealed before the period: after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("block not sealed at the period")
+	}
+	sealer.Work() <- newTestBlock(2, 0)
+	select {
+	case block := <-returnCh:
+		if block.Number().Int64() != 2 {
+			t.Fatalf("sealed block mismatch: have #%v, want #2", block.Number())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("empty block not sealed at the period")
+	}
+}
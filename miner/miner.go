@@ -2,12 +2,14 @@ package miner
 
 import (
 	"math/big"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/pow"
 )
 
 type Miner struct {
@@ -18,21 +20,32 @@ type Miner struct {
 	threads int
 	mining  bool
 	eth     core.Backend
-	pow     pow.PoW
+	engine  consensus.Engine
 }
 
-func New(eth core.Backend, pow pow.PoW, minerThreads int) *Miner {
+func New(eth core.Backend, engine consensus.Engine, minerThreads int) *Miner {
 	// note: minerThreads is currently ignored because
 	// ethash is not thread safe.
-	miner := &Miner{eth: eth, pow: pow, worker: newWorker(common.Address{}, eth)}
+	miner := &Miner{eth: eth, engine: engine, worker: newWorker(common.Address{}, eth, engine)}
 	for i := 0; i < minerThreads; i++ {
-		miner.worker.register(NewCpuMiner(i, pow))
+		miner.worker.register(NewCpuMiner(i, eth.ChainManager(), engine))
 	}
 	miner.threads = minerThreads
 
 	return miner
 }
 
+// NewInstant creates a miner for development chains which seals blocks without
+// consulting the engine as soon as transactions are pending or, if period is
+// non-zero, at that fixed interval.
+func NewInstant(eth core.Backend, engine consensus.Engine, period time.Duration) *Miner {
+	miner := &Miner{eth: eth, engine: engine, worker: newWorker(common.Address{}, eth, engine)}
+	atomic.StoreInt32(&miner.worker.recommit, 1)
+	miner.worker.register(NewInstantSealer(period))
+
+	return miner
+}
+
 func (self *Miner) Mining() bool {
 	return self.mining
 }
@@ -57,6 +70,8 @@ func (self *Miner) Stop() {
 	self.worker.stop()
 }
 
+// HashRate returns the combined hashrate of the local CPU miners and all
+// registered remote agents.
 func (self *Miner) HashRate() int64 {
 	return self.worker.HashRate()
 }
@@ -2,12 +2,23 @@ package miner
 
 import (
 	"math/big"
+	"sync"
+	"time"
 
 	"github.com/ethereum/ethash"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
+// hashrateExpiry is the time after which a remote miner's hashrate report is
+// no longer taken into account unless it gets refreshed.
+const hashrateExpiry = 10 * time.Second
+
+type hashrate struct {
+	ping time.Time
+	rate uint64
+}
+
 type RemoteAgent struct {
 	work        *types.Block
 	currentWork *types.Block
@@ -15,10 +26,13 @@ type RemoteAgent struct {
 	quit     chan struct{}
 	workCh   chan *types.Block
 	returnCh chan<- *types.Block
+
+	hashrateMu sync.RWMutex
+	hashrate   map[common.Hash]hashrate
 }
 
 func NewRemoteAgent() *RemoteAgent {
-	agent := &RemoteAgent{}
+	agent := &RemoteAgent{hashrate: make(map[common.Hash]hashrate)}
 
 	return agent
 }
@@ -42,9 +56,33 @@ func (a *RemoteAgent) Stop() {
 	close(a.workCh)
 }
 
-func (a *RemoteAgent) GetHashRate() int64 { return 0 }
+// SubmitHashrate records the hashrate reported by the external miner
+// identified by id. Reports expire unless refreshed within hashrateExpiry.
+func (a *RemoteAgent) SubmitHashrate(id common.Hash, rate uint64) {
+	a.hashrateMu.Lock()
+	defer a.hashrateMu.Unlock()
+
+	a.hashrate[id] = hashrate{time.Now(), rate}
+}
+
+// GetHashRate returns the aggregated hashrate of all external miners
+// that reported within the last hashrateExpiry.
+func (a *RemoteAgent) GetHashRate() (tot int64) {
+	a.hashrateMu.RLock()
+	defer a.hashrateMu.RUnlock()
+
+	for _, hashrate := range a.hashrate {
+		if time.Since(hashrate.ping) < hashrateExpiry {
+			tot += int64(hashrate.rate)
+		}
+	}
+	return
+}
 
 func (a *RemoteAgent) run() {
+	ticker := time.NewTicker(hashrateExpiry / 2)
+	defer ticker.Stop()
+
 out:
 	for {
 		select {
@@ -52,6 +90,15 @@ out:
 			break out
 		case work := <-a.workCh:
 			a.work = work
+		case <-ticker.C:
+			// drop the reports of miners that went silent
+			a.hashrateMu.Lock()
+			for id, hashrate := range a.hashrate {
+				if time.Since(hashrate.ping) >= hashrateExpiry {
+					delete(a.hashrate, id)
+				}
+			}
+			a.hashrateMu.Unlock()
 		}
 	}
 }
@@ -8,13 +8,13 @@ import (
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
-	"github.com/ethereum/go-ethereum/pow"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -65,7 +65,7 @@ type worker struct {
 	recv   chan *types.Block
 	mux    *event.TypeMux
 	quit   chan struct{}
-	pow    pow.PoW
+	engine consensus.Engine
 
 	eth   core.Backend
 	chain *core.ChainManager
@@ -84,13 +84,15 @@ type worker struct {
 	txQueue   map[common.Hash]*types.Transaction
 
 	// atomic status counters
-	mining int32
-	atWork int32
+	mining   int32
+	atWork   int32
+	recommit int32 // assemble new work on every transaction, even while mining
 }
 
-func newWorker(coinbase common.Address, eth core.Backend) *worker {
+func newWorker(coinbase common.Address, eth core.Backend, engine consensus.Engine) *worker {
 	worker := &worker{
 		eth:            eth,
+		engine:         engine,
 		mux:            eth.EventMux(),
 		recv:           make(chan *types.Block),
 		chain:          eth.ChainManager(),
@@ -163,7 +165,7 @@ out:
 				self.possibleUncles[ev.Block.Hash()] = ev.Block
 				self.uncleMu.Unlock()
 			case core.TxPreEvent:
-				if atomic.LoadInt32(&self.mining) == 0 {
+				if atomic.LoadInt32(&self.mining) == 0 || atomic.LoadInt32(&self.recommit) == 1 {
 					self.commitNewWork()
 				}
 			}
@@ -229,6 +231,9 @@ func (self *worker) makeCurrent() {
 		block.Header().Time++
 	}
 	block.Header().Extra = self.extra
+	if err := self.engine.Prepare(self.chain, block.Header()); err != nil {
+		glog.V(logger.Error).Infoln("failed to prepare header for mining:", err)
+	}
 
 	self.current = env(block, self.eth)
 	for _, ancestor := range self.chain.GetAncestors(block, 7) {
@@ -319,6 +324,15 @@ func (self *worker) commitNewWork() {
 		}
 	}
 
+	// Some engines, e.g. proof-of-authority, don't accept uncles at all
+	if len(uncles) > 0 {
+		self.current.block.SetUncles(uncles)
+		if err := self.engine.VerifyUncles(self.chain, self.current.block); err != nil {
+			glog.V(logger.Detail).Infof("Dropping uncles rejected by the consensus engine: %v\n", err)
+			uncles = nil
+		}
+	}
+
 	// We only care about logging if we're actually mining
 	if atomic.LoadInt32(&self.mining) == 1 {
 		glog.V(logger.Info).Infof("commit new work on block %v with %d txs & %d uncles\n", self.current.block.Number(), tcount, len(uncles))
@@ -330,19 +344,13 @@ func (self *worker) commitNewWork() {
 
 	self.current.block.SetUncles(uncles)
 
-	core.AccumulateRewards(self.current.state, self.current.block)
+	self.engine.Finalize(self.chain, self.current.state, self.current.block)
 
 	self.current.state.Update()
 
 	self.push()
 }
 
-var (
-	inclusionReward = new(big.Int).Div(core.BlockReward, big.NewInt(32))
-	_uncleReward    = new(big.Int).Mul(core.BlockReward, big.NewInt(15))
-	uncleReward     = new(big.Int).Div(_uncleReward, big.NewInt(16))
-)
-
 func (self *worker) commitUncle(uncle *types.Header) error {
 	if self.current.uncles.Has(uncle.Hash()) {
 		// Error not unique
@@ -376,8 +384,18 @@ func (self *worker) commitTransaction(tx *types.Transaction) error {
 }
 
 func (self *worker) HashRate() int64 {
-	var tot int64
+	var (
+		tot int64
+		cpu bool
+	)
 	for _, agent := range self.agents {
+		// all cpu miners share a single pow instance, count it only once
+		if _, ok := agent.(*CpuMiner); ok {
+			if cpu {
+				continue
+			}
+			cpu = true
+		}
 		tot += agent.GetHashRate()
 	}
 
@@ -13,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
@@ -34,6 +35,10 @@ type nodeDB struct {
 
 	runner sync.Once     // Ensures we can start at most one expirer
 	quit   chan struct{} // Channel to signal the expiring thread to stop
+
+	netrestrict *netutil.Netlist // If set, nodes outside these networks are neither stored nor returned as seeds
+
+	repLock sync.Mutex // Serializes reputation updates
 }
 
 // Schema layout for the node database
@@ -44,6 +49,11 @@ var (
 	nodeDBDiscoverRoot = ":discover"
 	nodeDBDiscoverPing = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong = nodeDBDiscoverRoot + ":lastpong"
+
+	nodeDBReputationRoot    = ":reputation"
+	nodeDBReputationScore   = nodeDBReputationRoot + ":score"
+	nodeDBReputationBanned  = nodeDBReputationRoot + ":banned"
+	nodeDBReputationUpdated = nodeDBReputationRoot + ":updated"
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -174,6 +184,9 @@ func (db *nodeDB) node(id NodeID) *Node {
 
 // updateNode inserts - potentially overwriting - a node into the peer database.
 func (db *nodeDB) updateNode(node *Node) error {
+	if db.netrestrict != nil && !db.netrestrict.Contains(node.IP) {
+		return errNetRestrict
+	}
 	blob, err := rlp.EncodeToBytes(node)
 	if err != nil {
 		return err
@@ -212,30 +225,54 @@ func (db *nodeDB) expirer() {
 }
 
 // expireNodes iterates over the database and deletes all nodes that have not
-// been seen (i.e. received a pong from) for some alloted time.
+// been seen (i.e. received a pong from) for some alloted time. The reputation
+// of a node is expired separately, once it wasn't updated for the same time
+// and the node isn't banned anymore. This also covers peers that never took
+// part in discovery.
 func (db *nodeDB) expireNodes() error {
-	threshold := time.Now().Add(-nodeDBNodeExpiration)
+	now := time.Now()
+	threshold := now.Add(-nodeDBNodeExpiration)
 
-	// Find discovered nodes that are older than the allowance
+	// Find discovered nodes and reputations that are older than the allowance
 	it := db.lvl.NewIterator(nil, nil)
 	defer it.Release()
 
 	for it.Next() {
-		// Skip the item if not a discovery node
 		id, field := splitKey(it.Key())
-		if field != nodeDBDiscoverRoot {
+
+		var root string
+		switch field {
+		case nodeDBDiscoverRoot:
+			// Skip the node if not expired yet
+			if seen := db.lastPong(id); seen.After(threshold) {
+				continue
+			}
+			root = nodeDBDiscoverRoot
+		case nodeDBReputationScore:
+			// Skip the reputation if recently updated or still banned
+			if db.reputationUpdated(id).After(threshold) || db.bannedUntil(id).After(now) {
+				continue
+			}
+			root = nodeDBReputationRoot
+		default:
 			continue
 		}
-		// Skip the node if not expired yet
-		if seen := db.lastPong(id); seen.After(threshold) {
-			continue
+		// Otherwise delete the expired information
+		if err := db.deletePrefix(makeKey(id, root)); err != nil {
+			return err
 		}
-		// Otherwise delete all associated information
-		deleter := db.lvl.NewIterator(util.BytesPrefix(makeKey(id, "")), nil)
-		for deleter.Next() {
-			if err := db.lvl.Delete(deleter.Key(), nil); err != nil {
-				return err
-			}
+	}
+	return nil
+}
+
+// deletePrefix deletes all database entries whose keys start with prefix.
+func (db *nodeDB) deletePrefix(prefix []byte) error {
+	deleter := db.lvl.NewIterator(util.BytesPrefix(prefix), nil)
+	defer deleter.Release()
+
+	for deleter.Next() {
+		if err := db.lvl.Delete(deleter.Key(), nil); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -262,6 +299,56 @@ func (db *nodeDB) updateLastPong(id NodeID, instance time.Time) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverPong), instance.Unix())
 }
 
+// reputation retrieves the reputation score of a node.
+func (db *nodeDB) reputation(id NodeID) int64 {
+	return db.fetchInt64(makeKey(id, nodeDBReputationScore))
+}
+
+// addReputation adjusts the reputation score of a node by delta, keeping it
+// within [min, max], and returns the new score.
+func (db *nodeDB) addReputation(id NodeID, delta, min, max int64) (int64, error) {
+	db.repLock.Lock()
+	defer db.repLock.Unlock()
+
+	score := db.reputation(id) + delta
+	if score < min {
+		score = min
+	}
+	if score > max {
+		score = max
+	}
+	if err := db.storeInt64(makeKey(id, nodeDBReputationUpdated), time.Now().Unix()); err != nil {
+		return score, err
+	}
+	return score, db.storeInt64(makeKey(id, nodeDBReputationScore), score)
+}
+
+// reputationUpdated retrieves the time the reputation of a node was last
+// updated.
+func (db *nodeDB) reputationUpdated(id NodeID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, nodeDBReputationUpdated)), 0)
+}
+
+// bannedUntil retrieves the time until which a node is banned.
+func (db *nodeDB) bannedUntil(id NodeID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, nodeDBReputationBanned)), 0)
+}
+
+// ban bans a node until the given time and resets its reputation, so it
+// starts out fresh once the ban is over.
+func (db *nodeDB) ban(id NodeID, until time.Time) error {
+	db.repLock.Lock()
+	defer db.repLock.Unlock()
+
+	if err := db.storeInt64(makeKey(id, nodeDBReputationUpdated), time.Now().Unix()); err != nil {
+		return err
+	}
+	if err := db.storeInt64(makeKey(id, nodeDBReputationScore), 0); err != nil {
+		return err
+	}
+	return db.storeInt64(makeKey(id, nodeDBReputationBanned), until.Unix())
+}
+
 // querySeeds retrieves a batch of nodes to be used as potential seed servers
 // during bootstrapping the node into the network.
 //
@@ -285,8 +372,9 @@ func (db *nodeDB) querySeeds(n int) []*Node {
 		if field != nodeDBDiscoverRoot {
 			continue
 		}
-		// Load it as a potential seed
-		if node := db.node(id); node != nil {
+		// Load it as a potential seed, unless it was stored before a network
+		// restriction was configured
+		if node := db.node(id); node != nil && (db.netrestrict == nil || db.netrestrict.Contains(node.IP)) {
 			nodes = append(nodes, node)
 		}
 	}
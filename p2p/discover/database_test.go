@@ -9,6 +9,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 var nodeDBKeyTests = []struct {
@@ -230,6 +232,36 @@ func TestNodeDBSeedQueryContinuation(t *testing.T) {
 	}
 }
 
+func TestNodeDBNetRestrict(t *testing.T) {
+	db, _ := newNodeDB("", Version)
+	defer db.close()
+
+	// Store a node before any restriction is configured
+	stale := newNode(NodeID{1}, net.IP{192, 168, 0, 1}, 30303, 30303)
+	if err := db.updateNode(stale); err != nil {
+		t.Fatalf("failed to insert unrestricted node: %v", err)
+	}
+	db.netrestrict = new(netutil.Netlist)
+	db.netrestrict.Add("10.0.0.0/8")
+
+	inside := newNode(NodeID{2}, net.IP{10, 0, 0, 1}, 30303, 30303)
+	outside := newNode(NodeID{3}, net.IP{127, 0, 0, 1}, 30303, 30303)
+	if err := db.updateNode(inside); err != nil {
+		t.Errorf("failed to insert allowed node: %v", err)
+	}
+	if err := db.updateNode(outside); err != errNetRestrict {
+		t.Errorf("insert error mismatch for restricted node: have %v, want %v", err, errNetRestrict)
+	}
+	if db.node(outside.ID) != nil {
+		t.Errorf("restricted node stored")
+	}
+	// Only the allowed node may be used as a seed
+	seeds := db.querySeeds(10)
+	if len(seeds) != 1 || seeds[0].ID != inside.ID {
+		t.Errorf("seeds mismatch: have %v, want [%v]", seeds, inside)
+	}
+}
+
 func TestNodeDBPersistency(t *testing.T) {
 	root, err := ioutil.TempDir("", "nodedb-")
 	if err != nil {
@@ -323,3 +355,114 @@ func TestNodeDBExpiration(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeDBReputation(t *testing.T) {
+	db, _ := newNodeDB("", Version)
+	defer db.close()
+
+	id := NodeID{1}
+	if rep := db.reputation(id); rep != 0 {
+		t.Errorf("initial reputation mismatch: have %d, want 0", rep)
+	}
+	for i, delta := range []int64{5, -3, 100, -500} {
+		want := []int64{5, 2, 50, -100}[i]
+		rep, err := db.addReputation(id, delta, -100, 50)
+		if err != nil {
+			t.Fatalf("update %d: failed to store reputation: %v", i, err)
+		}
+		if rep != want || db.reputation(id) != want {
+			t.Errorf("update %d: reputation mismatch: have %d, want %d", i, rep, want)
+		}
+	}
+
+	// Banning resets the reputation.
+	until := time.Now().Add(time.Hour)
+	if err := db.ban(id, until); err != nil {
+		t.Fatalf("failed to ban node: %v", err)
+	}
+	if rep := db.reputation(id); rep != 0 {
+		t.Errorf("reputation after ban mismatch: have %d, want 0", rep)
+	}
+	if have := db.bannedUntil(id); have.Unix() != until.Unix() {
+		t.Errorf("ban time mismatch: have %v, want %v", have, until)
+	}
+}
+
+func TestNodeDBExpirationBanned(t *testing.T) {
+	db, _ := newNodeDB("", Version)
+	defer db.close()
+
+	node := nodeDBExpirationNodes[1].node
+	if err := db.updateNode(node); err != nil {
+		t.Fatalf("failed to insert node: %v", err)
+	}
+	if err := db.updateLastPong(node.ID, nodeDBExpirationNodes[1].pong); err != nil {
+		t.Fatalf("failed to update pong: %v", err)
+	}
+	if err := db.ban(node.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to ban node: %v", err)
+	}
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if db.bannedUntil(node.ID).Before(time.Now()) {
+		t.Errorf("ban removed by expiration")
+	}
+}
+
+func TestNodeDBReputationExpiration(t *testing.T) {
+	db, _ := newNodeDB("", Version)
+	defer db.close()
+
+	var (
+		stale   = NodeID{1} // rated long ago
+		recent  = NodeID{2} // rated recently, but not seen recently
+		banned  = NodeID{3} // rated long ago, but still banned
+		expired = NodeID{4} // rated long ago, ban is over
+		old     = time.Now().Add(-nodeDBNodeExpiration - time.Minute)
+	)
+	for _, id := range []NodeID{stale, recent} {
+		if _, err := db.addReputation(id, 5, -100, 50); err != nil {
+			t.Fatalf("failed to store reputation: %v", err)
+		}
+	}
+	if err := db.ban(banned, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to ban node: %v", err)
+	}
+	if err := db.ban(expired, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to ban node: %v", err)
+	}
+	for _, id := range []NodeID{stale, banned, expired} {
+		if err := db.storeInt64(makeKey(id, nodeDBReputationUpdated), old.Unix()); err != nil {
+			t.Fatalf("failed to set update time: %v", err)
+		}
+	}
+	node := newNode(recent, net.IP{127, 0, 0, 1}, 30303, 30303)
+	if err := db.updateNode(node); err != nil {
+		t.Fatalf("failed to insert node: %v", err)
+	}
+	if err := db.updateLastPong(recent, old); err != nil {
+		t.Fatalf("failed to update pong: %v", err)
+	}
+
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if db.node(recent) != nil {
+		t.Errorf("unseen node not expired")
+	}
+	if rep := db.reputation(recent); rep != 5 {
+		t.Errorf("recent reputation expired with the node: have %d, want 5", rep)
+	}
+	if rep := db.reputation(stale); rep != 0 {
+		t.Errorf("stale reputation not expired: have %d", rep)
+	}
+	if db.bannedUntil(banned).Before(time.Now()) {
+		t.Errorf("ban removed by expiration")
+	}
+	for _, id := range []NodeID{stale, expired} {
+		if _, err := db.lvl.Get(makeKey(id, nodeDBReputationUpdated), nil); err == nil {
+			t.Errorf("node %x: reputation entries not expired", id[:1])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package discover
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var packetNames = [...]string{
+	pingPacket:          "ping",
+	pongPacket:          "pong",
+	findnodePacket:      "findnode",
+	neighborsPacket:     "neighbors",
+	enrRequestPacket:    "enrrequest",
+	enrResponsePacket:   "enrresponse",
+	topicRegisterPacket: "topicregister",
+	topicQueryPacket:    "topicquery",
+	topicNodesPacket:    "topicnodes",
+}
+
+var (
+	// ingressPacketMeters and egressPacketMeters count discovery packets
+	// by type, named like "discover/ping/in".
+	ingressPacketMeters = packetMeters("in")
+	egressPacketMeters  = packetMeters("out")
+)
+
+func packetMeters(direction string) []*metrics.Meter {
+	meters := make([]*metrics.Meter, len(packetNames))
+	for ptype, name := range packetNames {
+		if name != "" {
+			meters[ptype] = metrics.NewMeter(fmt.Sprintf("discover/%s/%s", name, direction))
+		}
+	}
+	return meters
+}
+
+// markPacket counts an encoded packet in the meter of its type.
+func markPacket(meters []*metrics.Meter, packet []byte) {
+	if len(packet) <= headSize {
+		return
+	}
+	if ptype := int(packet[headSize]); ptype < len(meters) && meters[ptype] != nil {
+		meters[ptype].Mark(1)
+	}
+}
+
+// PacketStat holds the number of discovery packets of one type sent
+// and received, along with their one-minute rates.
+type PacketStat struct {
+	In       int64   `json:"in"`
+	Out      int64   `json:"out"`
+	InRate1  float64 `json:"inRate1"`
+	OutRate1 float64 `json:"outRate1"`
+}
+
+// PacketStats returns the packet statistics of all discovery
+// instances in the process, keyed by packet type.
+func PacketStats() map[string]PacketStat {
+	stats := make(map[string]PacketStat)
+	for ptype, name := range packetNames {
+		if name == "" {
+			continue
+		}
+		in, out := ingressPacketMeters[ptype], egressPacketMeters[ptype]
+		stats[name] = PacketStat{
+			In:       in.Count(),
+			Out:      out.Count(),
+			InRate1:  in.Rate1(),
+			OutRate1: out.Rate1(),
+		}
+	}
+	return stats
+}
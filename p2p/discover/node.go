@@ -82,25 +82,36 @@ func (n *Node) String() string {
 // and UDP discovery port 30301.
 //
 //    enode://<hex node id>@10.3.58.6:30303?discport=30301
+//
+// IPv6 addresses are enclosed in square brackets:
+//
+//    enode://<hex node id>@[2001:db8::1]:30303
 func ParseNode(rawurl string) (*Node, error) {
 	var (
 		id               NodeID
 		ip               net.IP
 		tcpPort, udpPort uint64
+		err              error
 	)
-	u, err := url.Parse(rawurl)
-	if u.Scheme != "enode" {
+	// The URL is split by hand because net/url rejects some
+	// hosts before we get to look at them.
+	if !strings.HasPrefix(rawurl, "enode://") {
 		return nil, errors.New("invalid URL scheme, want \"enode\"")
 	}
+	rest, query := rawurl[len("enode://"):], ""
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
 	// Parse the Node ID from the user portion.
-	if u.User == nil {
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
 		return nil, errors.New("does not contain node ID")
 	}
-	if id, err = HexID(u.User.String()); err != nil {
+	if id, err = HexID(rest[:at]); err != nil {
 		return nil, fmt.Errorf("invalid node ID (%v)", err)
 	}
-	// Parse the IP address.
-	host, port, err := net.SplitHostPort(u.Host)
+	// Parse the IP address. IPv6 addresses must be enclosed in brackets.
+	host, port, err := net.SplitHostPort(rest[at+1:])
 	if err != nil {
 		return nil, fmt.Errorf("invalid host: %v", err)
 	}
@@ -116,7 +127,10 @@ func ParseNode(rawurl string) (*Node, error) {
 		return nil, errors.New("invalid port")
 	}
 	udpPort = tcpPort
-	qv := u.Query()
+	qv, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
 	if qv.Get("discport") != "" {
 		udpPort, err = strconv.ParseUint(qv.Get("discport"), 10, 16)
 		if err != nil {
@@ -7,6 +7,7 @@
 package discover
 
 import (
+	"crypto/ecdsa"
 	"crypto/rand"
 	"net"
 	"sort"
@@ -17,6 +18,8 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 const (
@@ -33,6 +36,7 @@ type Table struct {
 	buckets [nBuckets]*bucket // index of known nodes by distance
 	nursery []*Node           // bootstrap nodes
 	db      *nodeDB           // database of known nodes
+	topics  *topicTable       // topic registrations of remote nodes
 
 	bondmu    sync.Mutex
 	bonding   map[NodeID]*bondproc
@@ -40,6 +44,13 @@ type Table struct {
 
 	net  transport
 	self *Node // metadata of the local node
+
+	netrestrict *netutil.Netlist // if set, only nodes in these networks are used
+
+	recordMu sync.Mutex        // protects record
+	priv     *ecdsa.PrivateKey // signs the local node record
+	record   *enr.Record       // signed record of the local node
+	ip6      net.IP            // IPv6 address advertised in addition to self.IP
 }
 
 type bondproc struct {
@@ -55,6 +66,9 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
+	registerTopics(toid NodeID, addr *net.UDPAddr, topics []Topic) error
+	queryTopic(toid NodeID, addr *net.UDPAddr, topic Topic) ([]*Node, error)
 	close()
 }
 
@@ -66,19 +80,23 @@ type bucket struct {
 	entries    []*Node
 }
 
-func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string) *Table {
+func newTable(t transport, ourID NodeID, ourAddr *net.UDPAddr, nodeDBPath string, netrestrict *netutil.Netlist) *Table {
 	// If no node database was given, use an in-memory one
 	db, err := newNodeDB(nodeDBPath, Version)
 	if err != nil {
 		glog.V(logger.Warn).Infoln("Failed to open node database:", err)
 		db, _ = newNodeDB("", Version)
 	}
+	db.netrestrict = netrestrict
 	tab := &Table{
 		net:       t,
 		db:        db,
+		topics:    newTopicTable(),
 		self:      newNode(ourID, ourAddr.IP, uint16(ourAddr.Port), uint16(ourAddr.Port)),
 		bonding:   make(map[NodeID]*bondproc),
 		bondslots: make(chan struct{}, maxBondingPingPongs),
+
+		netrestrict: netrestrict,
 	}
 	for i := 0; i < cap(tab.bondslots); i++ {
 		tab.bondslots <- struct{}{}
@@ -94,6 +112,116 @@ func (tab *Table) Self() *Node {
 	return tab.self
 }
 
+// Nodes returns all nodes contained in the table, ordered by
+// bucket, i.e. by increasing distance to the local node.
+func (tab *Table) Nodes() []*Node {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+	var nodes []*Node
+	for _, b := range tab.buckets {
+		nodes = append(nodes, b.entries...)
+	}
+	return nodes
+}
+
+// BucketSizes returns the number of nodes in each bucket.
+func (tab *Table) BucketSizes() []int {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+	sizes := make([]int, len(tab.buckets))
+	for i, b := range tab.buckets {
+		sizes[i] = len(b.entries)
+	}
+	return sizes
+}
+
+// allowed reports whether nodes at the given IP may be used, i.e. whether it
+// is contained in the network restriction, if any.
+func (tab *Table) allowed(ip net.IP) bool {
+	return tab.netrestrict == nil || tab.netrestrict.Contains(ip)
+}
+
+// Record returns the signed node record of the local node. It returns nil
+// if the table was created without a private key.
+func (tab *Table) Record() *enr.Record {
+	tab.recordMu.Lock()
+	defer tab.recordMu.Unlock()
+	return tab.record
+}
+
+// SetRecordEntries replaces the additional entries of the local node
+// record, e.g. the capabilities and network of the node. The endpoint
+// entries are always derived from the local node. The record is signed
+// again with an increased sequence number.
+func (tab *Table) SetRecordEntries(entries ...enr.Entry) error {
+	tab.recordMu.Lock()
+	defer tab.recordMu.Unlock()
+
+	var r enr.Record
+	if tab.record != nil {
+		r.SetSeq(tab.record.Seq() + 1)
+	} else {
+		// Start out with the current time so that restarting the node
+		// doesn't publish records with a lower sequence number.
+		r.SetSeq(uint64(time.Now().Unix()))
+	}
+	switch ip := tab.self.IP; {
+	case ip.IsUnspecified():
+		// nothing to advertise
+	case ip.To4() != nil:
+		r.Set(enr.IP4(ip.To4()))
+	default:
+		r.Set(enr.IP6(ip))
+	}
+	if tab.ip6 != nil {
+		r.Set(enr.IP6(tab.ip6))
+	}
+	r.Set(enr.UDP(tab.self.UDP))
+	r.Set(enr.TCP(tab.self.TCP))
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := r.SignV4(tab.priv); err != nil {
+		return err
+	}
+	tab.record = &r
+	return nil
+}
+
+// RequestENR retrieves the current node record of n. The record is
+// verified to belong to n.
+func (tab *Table) RequestENR(n *Node) (*enr.Record, error) {
+	return tab.net.requestENR(n.ID, n.addr())
+}
+
+// Reputation returns the stored reputation score of a node.
+func (tab *Table) Reputation(id NodeID) int64 {
+	return tab.db.reputation(id)
+}
+
+// AddReputation adjusts the stored reputation score of a node by delta,
+// keeping it within [min, max]. It returns the new score.
+func (tab *Table) AddReputation(id NodeID, delta, min, max int64) int64 {
+	rep, err := tab.db.addReputation(id, delta, min, max)
+	if err != nil {
+		glog.V(logger.Warn).Infof("Failed to store reputation of %x: %v", id[:8], err)
+	}
+	return rep
+}
+
+// Ban marks a node as banned until the given time. The node's reputation
+// is reset.
+func (tab *Table) Ban(id NodeID, until time.Time) {
+	if err := tab.db.ban(id, until); err != nil {
+		glog.V(logger.Warn).Infof("Failed to store ban of %x: %v", id[:8], err)
+	}
+}
+
+// BannedUntil returns the time until which a node is banned.
+func (tab *Table) BannedUntil(id NodeID) time.Time {
+	return tab.db.bannedUntil(id)
+}
+
 // Close terminates the network listener and flushes the node database.
 func (tab *Table) Close() {
 	tab.net.close()
@@ -214,6 +342,22 @@ func (tab *Table) closest(target common.Hash, nresults int) *nodesByDistance {
 	return close
 }
 
+// closestInFamily is like closest, but only returns nodes whose IP
+// address belongs to the same address family as ip. It is used to
+// answer queries with nodes the querying node can reach.
+func (tab *Table) closestInFamily(target common.Hash, nresults int, ip net.IP) *nodesByDistance {
+	close := &nodesByDistance{target: target}
+	ipv4 := ip.To4() != nil
+	for _, b := range tab.buckets {
+		for _, n := range b.entries {
+			if (n.IP.To4() != nil) == ipv4 {
+				close.push(n, nresults)
+			}
+		}
+	}
+	return close
+}
+
 func (tab *Table) len() (n int) {
 	for _, b := range tab.buckets {
 		n += len(b.entries)
@@ -256,6 +400,9 @@ func (tab *Table) bondall(nodes []*Node) (result []*Node) {
 // If pinged is true, the remote node has just pinged us and one half
 // of the process can be skipped.
 func (tab *Table) bond(pinged bool, id NodeID, addr *net.UDPAddr, tcpPort uint16) (*Node, error) {
+	if !tab.allowed(addr.IP) {
+		return nil, errNetRestrict
+	}
 	var n *Node
 	if n = tab.db.node(id); n == nil {
 		tab.bondmu.Lock()
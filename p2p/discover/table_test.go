@@ -12,12 +12,13 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 )
 
 func TestTable_pingReplace(t *testing.T) {
 	doit := func(newNodeIsResponding, lastInBucketIsResponding bool) {
 		transport := newPingRecorder()
-		tab := newTable(transport, NodeID{}, &net.UDPAddr{}, "")
+		tab := newTable(transport, NodeID{}, &net.UDPAddr{}, "", nil)
 		pingSender := newNode(MustHexID("a502af0f59b2aab7746995408c79e9ca312d2793cc997e44fc55eda62f0150bbb8c59a6f9269ba3a081518b62699ee807c7c19c20125ddfccca872608af9e370"), net.IP{}, 99, 99)
 
 		// fill up the sender's bucket.
@@ -127,6 +128,7 @@ func fillBucket(tab *Table, ld int) (last *Node) {
 // The node's ID does not correspond to n.sha.
 func nodeAtDistance(base common.Hash, ld int) (n *Node) {
 	n = new(Node)
+	n.IP = net.IP{127, 0, 0, 1}
 	n.sha = hashAtDistance(base, ld)
 	copy(n.ID[:], n.sha[:]) // ensure the node still has a unique ID
 	return n
@@ -141,6 +143,15 @@ func newPingRecorder() *pingRecorder {
 func (t *pingRecorder) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
 	panic("findnode called on pingRecorder")
 }
+func (t *pingRecorder) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	panic("requestENR called on pingRecorder")
+}
+func (t *pingRecorder) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	panic("registerTopics called on pingRecorder")
+}
+func (t *pingRecorder) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	panic("queryTopic called on pingRecorder")
+}
 func (t *pingRecorder) close() {
 	panic("close called on pingRecorder")
 }
@@ -161,7 +172,7 @@ func TestTable_closest(t *testing.T) {
 
 	test := func(test *closeTest) bool {
 		// for any node table, Target and N
-		tab := newTable(nil, test.Self, &net.UDPAddr{}, "")
+		tab := newTable(nil, test.Self, &net.UDPAddr{}, "", nil)
 		tab.add(test.All)
 
 		// check that doClosest(Target, N) returns nodes
@@ -231,7 +242,7 @@ func (*closeTest) Generate(rand *rand.Rand, size int) reflect.Value {
 
 func TestTable_Lookup(t *testing.T) {
 	self := nodeAtDistance(common.Hash{}, 0)
-	tab := newTable(lookupTestnet, self.ID, &net.UDPAddr{}, "")
+	tab := newTable(lookupTestnet, self.ID, &net.UDPAddr{}, "", nil)
 
 	// lookup on empty table returns no nodes
 	if results := tab.Lookup(lookupTestnet.target); len(results) > 0 {
@@ -476,6 +487,15 @@ func (tn *preminedTestnet) findnode(toid NodeID, toaddr *net.UDPAddr, target Nod
 	return result, nil
 }
 
+func (*preminedTestnet) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errTimeout
+}
+func (*preminedTestnet) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return nil
+}
+func (*preminedTestnet) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	return nil, errTimeout
+}
 func (*preminedTestnet) close()                                      {}
 func (*preminedTestnet) waitping(from NodeID) error                  { return nil }
 func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
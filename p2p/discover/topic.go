@@ -0,0 +1,172 @@
+package discover
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Topic is a name under which nodes can advertise themselves, usually
+// a protocol name combined with a network identifier.
+//
+// Nodes register under a topic with the nodes closest to the hash of the
+// topic. Topic lookups ask the same nodes for the registrations they hold.
+type Topic string
+
+const (
+	topicRegTTL      = 20 * time.Minute // how long registrations are kept
+	topicRegInterval = 10 * time.Minute // how often the local node registers
+	topicRegRetry    = 10 * time.Second // retry interval while no nodes are known
+	topicRadius      = 8                // number of nodes registrations are sent to
+
+	maxTopics     = 256 // maximum number of topics held by a node
+	maxTopicNodes = 64  // maximum number of registrations held per topic
+)
+
+// target returns the node ID whose neighbourhood holds the
+// registrations for the topic.
+func (t Topic) target() (id NodeID) {
+	copy(id[:], crypto.Sha3([]byte(t)))
+	return id
+}
+
+// topicTable holds registrations made by remote nodes.
+type topicTable struct {
+	mutex  sync.Mutex
+	topics map[Topic]map[NodeID]*topicReg
+}
+
+type topicReg struct {
+	node    *Node
+	expires time.Time
+}
+
+func newTopicTable() *topicTable {
+	return &topicTable{topics: make(map[Topic]map[NodeID]*topicReg)}
+}
+
+// add registers n under the given topic. If the topic is full,
+// the registration expiring first is replaced.
+func (tt *topicTable) add(topic Topic, n *Node, now time.Time) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	regs := tt.topics[topic]
+	if regs == nil {
+		if len(tt.topics) >= maxTopics {
+			tt.expire(now)
+			if len(tt.topics) >= maxTopics {
+				return
+			}
+		}
+		regs = make(map[NodeID]*topicReg)
+		tt.topics[topic] = regs
+	}
+	if _, ok := regs[n.ID]; !ok && len(regs) >= maxTopicNodes {
+		var oldest *topicReg
+		for _, reg := range regs {
+			if oldest == nil || reg.expires.Before(oldest.expires) {
+				oldest = reg
+			}
+		}
+		delete(regs, oldest.node.ID)
+	}
+	regs[n.ID] = &topicReg{node: n, expires: now.Add(topicRegTTL)}
+}
+
+// get returns up to max nodes registered under the topic.
+func (tt *topicTable) get(topic Topic, max int, now time.Time) []*Node {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tt.expire(now)
+	var nodes []*Node
+	for _, reg := range tt.topics[topic] {
+		if len(nodes) == max {
+			break
+		}
+		nodes = append(nodes, reg.node)
+	}
+	return nodes
+}
+
+// expire removes registrations which have expired.
+// The caller must hold tt.mutex.
+func (tt *topicTable) expire(now time.Time) {
+	for topic, regs := range tt.topics {
+		for id, reg := range regs {
+			if now.After(reg.expires) {
+				delete(regs, id)
+			}
+		}
+		if len(regs) == 0 {
+			delete(tt.topics, topic)
+		}
+	}
+}
+
+// RegisterTopic advertises the local node under the given topic. It
+// registers with the nodes closest to the topic periodically until stop
+// is closed. Registration is retried sooner while the table is empty,
+// e.g. before bootstrapping has completed.
+func (tab *Table) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			nodes := tab.topicNodes(topic)
+			for _, n := range nodes {
+				tab.net.registerTopics(n.ID, n.addr(), []Topic{topic})
+			}
+			if len(nodes) == 0 {
+				timer.Reset(topicRegRetry)
+			} else {
+				timer.Reset(topicRegInterval)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// LookupTopic searches the network for nodes which have registered
+// under the given topic.
+func (tab *Table) LookupTopic(topic Topic) []*Node {
+	var (
+		seen   = map[NodeID]bool{tab.self.ID: true}
+		result []*Node
+	)
+	add := func(nodes []*Node) {
+		for _, n := range nodes {
+			if !seen[n.ID] && tab.allowed(n.IP) {
+				seen[n.ID] = true
+				result = append(result, n)
+			}
+		}
+	}
+	add(tab.topics.get(topic, bucketSize, time.Now()))
+
+	closest := tab.topicNodes(topic)
+	reply := make(chan []*Node, len(closest))
+	for _, n := range closest {
+		go func(n *Node) {
+			nodes, _ := tab.net.queryTopic(n.ID, n.addr(), topic)
+			reply <- nodes
+		}(n)
+	}
+	for range closest {
+		add(<-reply)
+	}
+	return result
+}
+
+// topicNodes returns the nodes which hold registrations for the topic.
+func (tab *Table) topicNodes(topic Topic) []*Node {
+	nodes := tab.Lookup(topic.target())
+	if len(nodes) > topicRadius {
+		nodes = nodes[:topicRadius]
+	}
+	return nodes
+}
@@ -0,0 +1,60 @@
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTopicTable(t *testing.T) {
+	tt := newTopicTable()
+	now := time.Now()
+	n1 := newNode(NodeID{1}, net.IP{10, 0, 0, 1}, 30303, 30303)
+	n2 := newNode(NodeID{2}, net.IP{10, 0, 0, 2}, 30303, 30303)
+
+	tt.add("shh", n1, now)
+	tt.add("shh", n2, now.Add(time.Minute))
+	tt.add("shh", n1, now.Add(time.Minute)) // refresh
+	tt.add("les", n2, now)
+
+	if nodes := tt.get("shh", bucketSize, now); len(nodes) != 2 {
+		t.Errorf("got %d nodes for shh, want 2", len(nodes))
+	}
+	if nodes := tt.get("shh", 1, now); len(nodes) != 1 {
+		t.Errorf("got %d nodes for shh with max 1, want 1", len(nodes))
+	}
+	if nodes := tt.get("eth", bucketSize, now); len(nodes) != 0 {
+		t.Errorf("got %d nodes for unknown topic, want 0", len(nodes))
+	}
+
+	// registrations expire after topicRegTTL.
+	later := now.Add(topicRegTTL + time.Second)
+	if nodes := tt.get("les", bucketSize, later); len(nodes) != 0 {
+		t.Errorf("got %d nodes after expiry, want 0", len(nodes))
+	}
+	if _, ok := tt.topics["les"]; ok {
+		t.Error("expired topic not removed")
+	}
+	if nodes := tt.get("shh", bucketSize, later); len(nodes) != 2 {
+		t.Errorf("got %d refreshed nodes, want 2", len(nodes))
+	}
+}
+
+func TestTopicTableLimit(t *testing.T) {
+	tt := newTopicTable()
+	now := time.Now()
+	for i := 0; i < maxTopicNodes+10; i++ {
+		id := NodeID{byte(i), byte(i >> 8)}
+		tt.add("shh", newNode(id, net.IP{10, 0, 0, 1}, 30303, 30303), now.Add(time.Duration(i)*time.Second))
+	}
+	regs := tt.topics["shh"]
+	if len(regs) != maxTopicNodes {
+		t.Fatalf("got %d registrations, want %d", len(regs), maxTopicNodes)
+	}
+	// the oldest registrations should have been replaced.
+	for i := 0; i < 10; i++ {
+		if _, ok := regs[NodeID{byte(i)}]; ok {
+			t.Errorf("registration %d not replaced", i)
+		}
+	}
+}
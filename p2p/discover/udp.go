@@ -11,7 +11,9 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -27,6 +29,8 @@ var (
 	errUnknownNode      = errors.New("unknown node")
 	errTimeout          = errors.New("RPC timeout")
 	errClosed           = errors.New("socket closed")
+	errNetRestrict      = errors.New("not contained in netrestrict whitelist")
+	errWrongRecord      = errors.New("node record belongs to a different node")
 )
 
 // Timeouts
@@ -44,6 +48,11 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
+	topicRegisterPacket
+	topicQueryPacket
+	topicNodesPacket
 )
 
 // RPC request structures
@@ -77,6 +86,36 @@ type (
 		Expiration uint64
 	}
 
+	// enrRequest queries for the node record of the recipient.
+	enrRequest struct {
+		Expiration uint64
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // hash of the enrRequest packet
+		Record   enr.Record
+	}
+
+	// topicRegister advertises the sender under the given topics.
+	topicRegister struct {
+		Topics     []Topic
+		Expiration uint64
+	}
+
+	// topicQuery asks for nodes registered under a topic.
+	topicQuery struct {
+		Topic      Topic
+		Expiration uint64
+	}
+
+	// reply to topicQuery
+	topicNodes struct {
+		Topic      Topic
+		Nodes      []rpcNode
+		Expiration uint64
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -176,7 +215,8 @@ type reply struct {
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
-func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string) (*Table, error) {
+// If netrestrict is non-nil, nodes outside of the given networks are ignored.
+func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBPath string, netrestrict *netutil.Netlist) (*Table, error) {
 	addr, err := net.ResolveUDPAddr("udp", laddr)
 	if err != nil {
 		return nil, err
@@ -185,12 +225,12 @@ func ListenUDP(priv *ecdsa.PrivateKey, laddr string, natm nat.Interface, nodeDBP
 	if err != nil {
 		return nil, err
 	}
-	tab, _ := newUDP(priv, conn, natm, nodeDBPath)
+	tab, _ := newUDP(priv, conn, natm, nodeDBPath, netrestrict)
 	glog.V(logger.Info).Infoln("Listening,", tab.self)
 	return tab, nil
 }
 
-func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath string) (*Table, *udp) {
+func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath string, netrestrict *netutil.Netlist) (*Table, *udp) {
 	udp := &udp{
 		conn:       c,
 		priv:       priv,
@@ -200,22 +240,53 @@ func newUDP(priv *ecdsa.PrivateKey, c conn, natm nat.Interface, nodeDBPath strin
 	}
 	realaddr := c.LocalAddr().(*net.UDPAddr)
 	if natm != nil {
-		if !realaddr.IP.IsLoopback() {
+		if nat.Mappable(realaddr.IP) {
 			go nat.Map(natm, udp.closing, "udp", realaddr.Port, realaddr.Port, "ethereum discovery")
 		}
 		// TODO: react to external IP changes over time.
-		if ext, err := natm.ExternalIP(); err == nil {
+		if ext, err := natm.ExternalIP(); err == nil && nat.SameFamily(realaddr.IP, ext) {
 			realaddr = &net.UDPAddr{IP: ext, Port: realaddr.Port}
 		}
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
-	udp.Table = newTable(udp, PubkeyID(&priv.PublicKey), realaddr, nodeDBPath)
+	udp.Table = newTable(udp, PubkeyID(&priv.PublicKey), realaddr, nodeDBPath, netrestrict)
+	udp.Table.priv = priv
+	// Sockets bound to the unspecified address accept both address
+	// families. Advertise a global IPv6 address if the endpoint
+	// doesn't already have one.
+	if laddr := c.LocalAddr().(*net.UDPAddr); laddr.IP.IsUnspecified() && (realaddr.IP.IsUnspecified() || realaddr.IP.To4() != nil) {
+		udp.Table.ip6 = globalIPv6()
+	}
+	if err := udp.Table.SetRecordEntries(); err != nil {
+		glog.V(logger.Error).Infoln("Can't sign node record:", err)
+	}
 	go udp.loop()
 	go udp.readLoop()
 	return udp.Table, udp
 }
 
+// globalIPv6 returns a global unicast IPv6 address of the local
+// machine, or nil if there is none.
+func globalIPv6() net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() != nil || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		// Skip unique local addresses (fc00::/7).
+		if ipnet.IP[0]&0xfe == 0xfc {
+			continue
+		}
+		return ipnet.IP
+	}
+	return nil
+}
+
 func (t *udp) close() {
 	close(t.closing)
 	t.conn.Close()
@@ -248,7 +319,7 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 		reply := r.(*neighbors)
 		for _, rn := range reply.Nodes {
 			nreceived++
-			if n, valid := nodeFromRPC(rn); valid {
+			if n, valid := nodeFromRPC(rn); valid && t.allowed(n.IP) {
 				nodes = append(nodes, n)
 			}
 		}
@@ -262,6 +333,68 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// requestENR sends an enrRequest to the given node and waits for
+// its record.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	req := enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	hash := packet[:macSize]
+	var record *enr.Record
+	errc := t.pending(toid, enrResponsePacket, func(r interface{}) bool {
+		reply := r.(*enrResponse)
+		if !bytes.Equal(reply.ReplyTok, hash) {
+			return false
+		}
+		record = &reply.Record
+		return true
+	})
+	t.write(toaddr, req, packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(record.NodeID(), toid[:]) {
+		return nil, errWrongRecord
+	}
+	return record, nil
+}
+
+// registerTopics advertises the local node under the given topics
+// at the given node. There is no reply to this request.
+func (t *udp) registerTopics(toid NodeID, toaddr *net.UDPAddr, topics []Topic) error {
+	return t.send(toaddr, topicRegisterPacket, topicRegister{
+		Topics:     topics,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+}
+
+// queryTopic asks the given node for nodes registered under topic.
+func (t *udp) queryTopic(toid NodeID, toaddr *net.UDPAddr, topic Topic) ([]*Node, error) {
+	var nodes []*Node
+	errc := t.pending(toid, topicNodesPacket, func(r interface{}) bool {
+		reply := r.(*topicNodes)
+		if reply.Topic != topic {
+			return false
+		}
+		for _, rn := range reply.Nodes {
+			if n, valid := nodeFromRPC(rn); valid && t.allowed(n.IP) {
+				nodes = append(nodes, n)
+			}
+		}
+		return true
+	})
+	t.send(toaddr, topicQueryPacket, topicQuery{
+		Topic:      topic,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	err := <-errc
+	return nodes, err
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -370,9 +503,16 @@ func (t *udp) send(toaddr *net.UDPAddr, ptype byte, req interface{}) error {
 	if err != nil {
 		return err
 	}
+	return t.write(toaddr, req, packet)
+}
+
+func (t *udp) write(toaddr *net.UDPAddr, req interface{}, packet []byte) error {
 	glog.V(logger.Detail).Infof(">>> %v %T\n", toaddr, req)
-	if _, err = t.conn.WriteToUDP(packet, toaddr); err != nil {
+	_, err := t.conn.WriteToUDP(packet, toaddr)
+	if err != nil {
 		glog.V(logger.Detail).Infoln("UDP send failed:", err)
+	} else {
+		markPacket(egressPacketMeters, packet)
 	}
 	return err
 }
@@ -413,11 +553,16 @@ func (t *udp) readLoop() {
 }
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
+	if !t.allowed(from.IP) {
+		glog.V(logger.Detail).Infof("Ignoring packet from %v: %v\n", from, errNetRestrict)
+		return errNetRestrict
+	}
 	packet, fromID, hash, err := decodePacket(buf)
 	if err != nil {
 		glog.V(logger.Debug).Infof("Bad packet from %v: %v\n", from, err)
 		return err
 	}
+	markPacket(ingressPacketMeters, buf)
 	status := "ok"
 	if err = packet.handle(t, from, fromID, hash); err != nil {
 		status = err.Error()
@@ -449,6 +594,16 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
+	case topicRegisterPacket:
+		req = new(topicRegister)
+	case topicQueryPacket:
+		req = new(topicQuery)
+	case topicNodesPacket:
+		req = new(topicNodes)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -501,7 +656,9 @@ func (req *findnode) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte
 	}
 	target := crypto.Sha3Hash(req.Target[:])
 	t.mutex.Lock()
-	closest := t.closest(target, bucketSize).entries
+	// Only send nodes of the address family the request came from,
+	// the requester might not be able to reach the others.
+	closest := t.closestInFamily(target, bucketSize, from.IP).entries
 	t.mutex.Unlock()
 
 	// TODO: this conversion could use a cached version of the slice
@@ -526,6 +683,80 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 	return nil
 }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.node(fromID) == nil {
+		// Like findnode, records are only sent to bonded nodes.
+		return errUnknownNode
+	}
+	record := t.Record()
+	if record == nil {
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, enrResponse{
+		ReplyTok: mac,
+		Record:   *record,
+	})
+	return nil
+}
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *topicRegister) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	n := t.db.node(fromID)
+	if n == nil {
+		// Only bonded nodes can register, this ensures that
+		// the endpoint has been verified.
+		return errUnknownNode
+	}
+	now := time.Now()
+	for _, topic := range req.Topics {
+		t.topics.add(topic, newNode(fromID, from.IP, uint16(from.Port), n.TCP), now)
+	}
+	return nil
+}
+
+func (req *topicQuery) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if t.db.node(fromID) == nil {
+		// See findnode.
+		return errUnknownNode
+	}
+	nodes := t.topics.get(req.Topic, bucketSize, time.Now())
+	rpcnodes := make([]rpcNode, len(nodes))
+	for i, n := range nodes {
+		rpcnodes[i] = nodeToRPC(n)
+	}
+	t.send(from, topicNodesPacket, topicNodes{
+		Topic:      req.Topic,
+		Nodes:      rpcnodes,
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	})
+	return nil
+}
+
+func (req *topicNodes) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.handleReply(fromID, topicNodesPacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
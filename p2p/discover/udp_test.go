@@ -18,6 +18,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 func init() {
@@ -51,7 +53,7 @@ func newUDPTest(t *testing.T) *udpTest {
 		remotekey:  newkey(),
 		remoteaddr: &net.UDPAddr{IP: net.IP{1, 2, 3, 4}, Port: 30303},
 	}
-	test.table, test.udp = newUDP(test.localkey, test.pipe, nil, "")
+	test.table, test.udp = newUDP(test.localkey, test.pipe, nil, "", nil)
 	return test
 }
 
@@ -110,6 +112,24 @@ func TestUDP_packetErrors(t *testing.T) {
 	test.packetIn(errUnsolicitedReply, neighborsPacket, &neighbors{Expiration: futureExp})
 }
 
+func TestUDP_packetStats(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	before := PacketStats()
+	test.packetIn(nil, pingPacket, &ping{From: testRemote, To: testLocalAnnounced, Version: Version, Expiration: futureExp})
+	test.waitPacketOut(func(p *pong) {})
+	after := PacketStats()
+
+	// Other tests may run concurrently, so only check lower bounds.
+	if after["ping"].In < before["ping"].In+1 {
+		t.Errorf("ping in count: got %d, want at least %d", after["ping"].In, before["ping"].In+1)
+	}
+	if after["pong"].Out < before["pong"].Out+1 {
+		t.Errorf("pong out count: got %d, want at least %d", after["pong"].Out, before["pong"].Out+1)
+	}
+}
+
 func TestUDP_pingTimeout(t *testing.T) {
 	t.Parallel()
 	test := newUDPTest(t)
@@ -176,6 +196,42 @@ func TestUDP_findnode(t *testing.T) {
 	})
 }
 
+func TestUDP_findnodeFamily(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+	test.remoteaddr = &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 30303}
+
+	// put nodes of both address families into the table.
+	var want []NodeID
+	for i := 0; i < bucketSize; i++ {
+		n := nodeAtDistance(test.table.self.sha, i+2)
+		if i%2 == 0 {
+			n.IP = net.ParseIP("2001:db8::2")
+			want = append(want, n.ID)
+		}
+		test.table.add([]*Node{n})
+	}
+	test.table.db.updateNode(newNode(
+		PubkeyID(&test.remotekey.PublicKey),
+		test.remoteaddr.IP,
+		uint16(test.remoteaddr.Port),
+		99,
+	))
+
+	// a request over IPv6 is answered with IPv6 nodes only.
+	test.packetIn(nil, findnodePacket, &findnode{Target: testTarget, Expiration: futureExp})
+	test.waitPacketOut(func(p *neighbors) {
+		if len(p.Nodes) != len(want) {
+			t.Fatalf("wrong number of results: got %d, want %d", len(p.Nodes), len(want))
+		}
+		for _, n := range p.Nodes {
+			if n.IP.To4() != nil {
+				t.Errorf("IPv4 node %x sent to IPv6 requester", n.ID[:8])
+			}
+		}
+	})
+}
+
 func TestUDP_findnodeMultiReply(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.table.Close()
@@ -227,6 +283,165 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_netRestrict(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// packets from outside the allowed networks are dropped
+	test.table.netrestrict = new(netutil.Netlist)
+	test.table.netrestrict.Add("10.0.0.0/8")
+	test.packetIn(errNetRestrict, pingPacket, &ping{From: testRemote, To: testLocalAnnounced, Version: Version, Expiration: futureExp})
+
+	// neighbors outside the allowed networks are ignored
+	test.table.netrestrict.Add("1.2.3.4/32")
+	resultc := make(chan []*Node)
+	go func() {
+		rid := PubkeyID(&test.remotekey.PublicKey)
+		ns, _ := test.udp.findnode(rid, test.remoteaddr, testTarget)
+		resultc <- ns
+	}()
+	test.waitPacketOut(func(p *findnode) {})
+
+	inside := MustParseNode("enode://ba85011c70bcc5c04d8607d3a0ed29aa6179c092cbdda10d5d32684fb33ed01bd94f588ca8f91ac48318087dcb02eaf36773a7a453f0eedd6742af668097b29c@10.0.1.16:30303?discport=30304")
+	outside := MustParseNode("enode://81fa361d25f157cd421c60dcc28d8dac5ef6a89476633339c5df30287474520caca09627da18543d9079b5b288698b542d56167aa5c09111e55acdbbdf2ef799@192.168.0.1:30303")
+	test.packetIn(nil, neighborsPacket, &neighbors{Expiration: futureExp, Nodes: []rpcNode{nodeToRPC(inside), nodeToRPC(outside)}})
+
+	select {
+	case result := <-resultc:
+		if want := []*Node{inside}; !reflect.DeepEqual(result, want) {
+			t.Errorf("neighbors mismatch:\n  got:  %v\n  want: %v", result, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("findnode did not return within 5 seconds")
+	}
+}
+
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// records are only sent to bonded nodes.
+	test.packetIn(errExpired, enrRequestPacket, &enrRequest{})
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	test.table.db.updateNode(newNode(
+		PubkeyID(&test.remotekey.PublicKey),
+		test.remoteaddr.IP,
+		uint16(test.remoteaddr.Port),
+		99,
+	))
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, test.sent[len(test.sent)-1][:macSize]) {
+			t.Errorf("wrong reply token: %x", p.ReplyTok)
+		}
+		if id := p.Record.NodeID(); !bytes.Equal(id, test.table.self.ID[:]) {
+			t.Errorf("record has wrong node ID: %x", id)
+		}
+		if p.Record.Seq() != test.table.Record().Seq() {
+			t.Errorf("record has wrong seq: got %d, want %d", p.Record.Seq(), test.table.Record().Seq())
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	var record enr.Record
+	record.Set(enr.UDP(30303))
+	if err := record.SignV4(test.remotekey); err != nil {
+		t.Fatal(err)
+	}
+	test.packetIn(errUnsolicitedReply, enrResponsePacket, &enrResponse{Record: record})
+
+	resultc, errc := make(chan *enr.Record), make(chan error)
+	go func() {
+		rid := PubkeyID(&test.remotekey.PublicKey)
+		r, err := test.udp.requestENR(rid, test.remoteaddr)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- r
+		}
+	}()
+	dgram := test.pipe.waitPacketOut()
+	if p, _, _, err := decodePacket(dgram); err != nil {
+		t.Fatalf("sent packet decode error: %v", err)
+	} else if _, ok := p.(*enrRequest); !ok {
+		t.Fatalf("sent packet type mismatch, got %T, want *enrRequest", p)
+	}
+
+	// replies with the wrong token are not matched.
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: []byte{1}, Record: record})
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: dgram[:macSize], Record: record})
+
+	select {
+	case r := <-resultc:
+		if r.Seq() != record.Seq() || !bytes.Equal(r.NodeID(), record.NodeID()) {
+			t.Errorf("record mismatch: got seq %d, id %x", r.Seq(), r.NodeID())
+		}
+	case err := <-errc:
+		t.Errorf("requestENR error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Error("requestENR did not return within 5 seconds")
+	}
+}
+
+func TestUDP_topics(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// registration and queries require a bond.
+	test.packetIn(errUnknownNode, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh"}, Expiration: futureExp})
+	test.packetIn(errUnknownNode, topicQueryPacket, &topicQuery{Topic: "shh", Expiration: futureExp})
+
+	remoteID := PubkeyID(&test.remotekey.PublicKey)
+	test.table.db.updateNode(newNode(remoteID, test.remoteaddr.IP, uint16(test.remoteaddr.Port), 99))
+	test.packetIn(errExpired, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh"}})
+	test.packetIn(nil, topicRegisterPacket, &topicRegister{Topics: []Topic{"shh"}, Expiration: futureExp})
+
+	// the remote node should now be returned for the topic.
+	test.packetIn(nil, topicQueryPacket, &topicQuery{Topic: "shh", Expiration: futureExp})
+	test.waitPacketOut(func(p *topicNodes) {
+		want := rpcNode{ID: remoteID, IP: test.remoteaddr.IP, UDP: uint16(test.remoteaddr.Port), TCP: 99}
+		if p.Topic != "shh" {
+			t.Errorf("wrong topic: got %q, want %q", p.Topic, "shh")
+		}
+		if len(p.Nodes) != 1 || !reflect.DeepEqual(p.Nodes[0], want) {
+			t.Errorf("wrong nodes: got %v, want [%v]", p.Nodes, want)
+		}
+	})
+
+	// query the remote node for a topic.
+	resultc := make(chan []*Node)
+	go func() {
+		ns, err := test.udp.queryTopic(remoteID, test.remoteaddr, "les")
+		if err != nil {
+			t.Errorf("queryTopic error: %v", err)
+		}
+		resultc <- ns
+	}()
+	test.waitPacketOut(func(p *topicQuery) {
+		if p.Topic != "les" {
+			t.Errorf("wrong topic: got %q, want %q", p.Topic, "les")
+		}
+	})
+	node := MustParseNode("enode://ba85011c70bcc5c04d8607d3a0ed29aa6179c092cbdda10d5d32684fb33ed01bd94f588ca8f91ac48318087dcb02eaf36773a7a453f0eedd6742af668097b29c@10.0.1.16:30303?discport=30304")
+	// replies for other topics don't complete the query.
+	test.packetIn(nil, topicNodesPacket, &topicNodes{Topic: "shh", Expiration: futureExp})
+	test.packetIn(nil, topicNodesPacket, &topicNodes{Topic: "les", Nodes: []rpcNode{nodeToRPC(node)}, Expiration: futureExp})
+
+	select {
+	case result := <-resultc:
+		if want := []*Node{node}; !reflect.DeepEqual(result, want) {
+			t.Errorf("topic nodes mismatch:\n  got:  %v\n  want: %v", result, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("queryTopic did not return within 5 seconds")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.table.Close()
@@ -0,0 +1,292 @@
+// Package enr implements signed node records.
+//
+// A node record holds arbitrary information about a node on the peer-to-peer
+// network, like its endpoints, the protocols it supports and the network it
+// is part of. Records are made of key/value pairs and carry a sequence number
+// which is increased whenever the record changes. Every record is signed by
+// the private key of the node it describes.
+//
+// Records are encoded as the RLP list
+//
+//	[signature, seq, k1, v1, k2, v2, ...]
+//
+// where the keys are sorted and unique. The signature is made according to
+// the identity scheme named by the "id" key. The only scheme supported at this
+// time is "v4", which signs the keccak256 hash of the RLP list [seq, k1, v1,
+// ...] with the node's secp256k1 key. The signature is in the 65 byte
+// recoverable [R || S || V] format and the "secp256k1" key holds the 64 byte
+// public key of the node, just like a discovery node ID.
+package enr
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// SizeLimit is the maximum encoded size of a node record in bytes.
+const SizeLimit = 300
+
+// idV4 is the name of the only identity scheme implemented by this package.
+const idV4 = "v4"
+
+var (
+	errNoID           = errors.New("unknown or unspecified identity scheme")
+	errInvalidSig     = errors.New("invalid signature")
+	errNotSorted      = errors.New("record key/value pairs are not sorted by key")
+	errDuplicateKey   = errors.New("record contains duplicate key")
+	errIncompletePair = errors.New("record contains incomplete k/v pair")
+	errTooBig         = fmt.Errorf("record bigger than %d bytes", SizeLimit)
+	errEncodeUnsigned = errors.New("can't encode unsigned record")
+	errNotFound       = errors.New("no such key in record")
+)
+
+// Record represents a node record. The zero value is an empty record.
+type Record struct {
+	seq       uint64 // sequence number
+	signature []byte // the signature
+	raw       []byte // RLP encoded record
+	pairs     []pair // sorted list of all key/value pairs
+}
+
+// pair is a key/value pair in a record.
+type pair struct {
+	k string
+	v rawValue
+}
+
+// rawValue is an already encoded RLP value.
+type rawValue []byte
+
+func (v rawValue) EncodeRLP(w io.Writer) error {
+	_, err := w.Write(v)
+	return err
+}
+
+// Signed reports whether the record has a valid signature.
+func (r *Record) Signed() bool {
+	return r.signature != nil
+}
+
+// Seq returns the sequence number.
+func (r *Record) Seq() uint64 {
+	return r.seq
+}
+
+// SetSeq updates the record sequence number. This invalidates any signature
+// on the record. Calling SetSeq is usually not required because Set and
+// SignV4 take care of increasing the sequence number.
+func (r *Record) SetSeq(s uint64) {
+	r.signature = nil
+	r.raw = nil
+	r.seq = s
+}
+
+// Load retrieves the value of a key/value pair. The given Entry must be a
+// pointer and will be set to the value of the entry in the record.
+//
+// Errors returned by Load are wrapped in KeyError. You can distinguish
+// decoding errors from missing keys using the IsNotFound function.
+func (r *Record) Load(e Entry) error {
+	i := sort.Search(len(r.pairs), func(i int) bool { return r.pairs[i].k >= e.ENRKey() })
+	if i < len(r.pairs) && r.pairs[i].k == e.ENRKey() {
+		if err := rlp.DecodeBytes(r.pairs[i].v, e); err != nil {
+			return &KeyError{Key: e.ENRKey(), Err: err}
+		}
+		return nil
+	}
+	return &KeyError{Key: e.ENRKey(), Err: errNotFound}
+}
+
+// Set adds or updates the given entry in the record. It panics if the value
+// can't be encoded. If the record is signed, Set increments the sequence
+// number and invalidates the signature.
+func (r *Record) Set(e Entry) {
+	blob, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		panic(fmt.Errorf("enr: can't encode %s: %v", e.ENRKey(), err))
+	}
+	r.invalidate()
+
+	pairs := make([]pair, len(r.pairs))
+	copy(pairs, r.pairs)
+	i := sort.Search(len(pairs), func(i int) bool { return pairs[i].k >= e.ENRKey() })
+	switch {
+	case i < len(pairs) && pairs[i].k == e.ENRKey():
+		// element is present at r.pairs[i]
+		pairs[i].v = blob
+	case i < len(r.pairs):
+		// insert pair before i-th elem
+		el := pair{e.ENRKey(), blob}
+		pairs = append(pairs, pair{})
+		copy(pairs[i+1:], pairs[i:])
+		pairs[i] = el
+	default:
+		// element should be placed at the end of r.pairs
+		pairs = append(pairs, pair{e.ENRKey(), blob})
+	}
+	r.pairs = pairs
+}
+
+func (r *Record) invalidate() {
+	if r.signature != nil {
+		r.seq++
+	}
+	r.signature = nil
+	r.raw = nil
+}
+
+// EncodeRLP implements rlp.Encoder. Encoding fails if the record is unsigned.
+func (r Record) EncodeRLP(w io.Writer) error {
+	if !r.Signed() {
+		return errEncodeUnsigned
+	}
+	_, err := w.Write(r.raw)
+	return err
+}
+
+// DecodeRLP implements rlp.Decoder. Decoding verifies the signature.
+func (r *Record) DecodeRLP(s *rlp.Stream) error {
+	raw, err := s.Raw()
+	if err != nil {
+		return err
+	}
+	if len(raw) > SizeLimit {
+		return errTooBig
+	}
+
+	// Decode the RLP container.
+	dec := Record{raw: raw}
+	s = rlp.NewStream(bytes.NewReader(raw), 0)
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	if dec.signature, err = s.Bytes(); err != nil {
+		return err
+	}
+	if dec.seq, err = s.Uint(); err != nil {
+		return err
+	}
+	// The rest of the record contains sorted k/v pairs.
+	var prevkey string
+	for i := 0; ; i++ {
+		var kv pair
+		if err := s.Decode(&kv.k); err == rlp.EOL {
+			break
+		} else if err != nil {
+			return err
+		}
+		if kv.v, err = s.Raw(); err == rlp.EOL {
+			return errIncompletePair
+		} else if err != nil {
+			return err
+		}
+		if i > 0 {
+			if kv.k == prevkey {
+				return errDuplicateKey
+			}
+			if kv.k < prevkey {
+				return errNotSorted
+			}
+		}
+		dec.pairs = append(dec.pairs, kv)
+		prevkey = kv.k
+	}
+	if err := s.ListEnd(); err != nil {
+		return err
+	}
+
+	if err := dec.verifySignature(); err != nil {
+		return err
+	}
+	*r = dec
+	return nil
+}
+
+// NodeID returns the 64 byte public key of the node the record describes, or
+// nil if the record doesn't contain one.
+func (r *Record) NodeID() []byte {
+	var pubkey Secp256k1
+	if r.Load(&pubkey) != nil {
+		return nil
+	}
+	return pubkey[:]
+}
+
+// SignV4 signs a record using the v4 scheme. It sets the "id" and
+// "secp256k1" entries and, if the record was signed before, increments the
+// sequence number.
+func (r *Record) SignV4(privkey *ecdsa.PrivateKey) error {
+	cpy := *r
+	cpy.Set(ID(idV4))
+	var pubkey Secp256k1
+	copy(pubkey[:], crypto.FromECDSAPub(&privkey.PublicKey)[1:])
+	cpy.Set(pubkey)
+
+	h := crypto.Sha3(cpy.content())
+	sig, err := crypto.Sign(h, privkey)
+	if err != nil {
+		return err
+	}
+	if err = cpy.setSig(sig); err == nil {
+		*r = cpy
+	}
+	return err
+}
+
+// content returns the RLP encoding of the signed part of the record.
+func (r *Record) content() []byte {
+	list := []interface{}{r.seq}
+	for _, p := range r.pairs {
+		list = append(list, p.k, p.v)
+	}
+	blob, _ := rlp.EncodeToBytes(list)
+	return blob
+}
+
+func (r *Record) setSig(sig []byte) error {
+	list := []interface{}{sig, r.seq}
+	for _, p := range r.pairs {
+		list = append(list, p.k, p.v)
+	}
+	raw, err := rlp.EncodeToBytes(list)
+	if err != nil {
+		return err
+	}
+	if len(raw) > SizeLimit {
+		return errTooBig
+	}
+	r.signature = sig
+	r.raw = raw
+	return nil
+}
+
+func (r *Record) verifySignature() error {
+	// Get identity scheme, public key, signature.
+	var id ID
+	var pubkey Secp256k1
+	if err := r.Load(&id); err != nil {
+		return err
+	} else if id != idV4 {
+		return errNoID
+	}
+	if err := r.Load(&pubkey); err != nil {
+		return err
+	}
+
+	// Verify the signature by recovering the public key.
+	if len(r.signature) != 65 || r.signature[64] > 3 {
+		return errInvalidSig
+	}
+	recovered, err := crypto.Ecrecover(crypto.Sha3(r.content()), r.signature)
+	if err != nil || len(recovered) != 65 || !bytes.Equal(recovered[1:], pubkey[:]) {
+		return errInvalidSig
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package enr
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var privkey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+func signTest(t *testing.T, r *Record, key *ecdsa.PrivateKey) {
+	if err := r.SignV4(key); err != nil {
+		t.Fatalf("sign error: %v", err)
+	}
+}
+
+// TestGetSetIP4 tests encoding/decoding and setting/getting of the IP4 key.
+func TestGetSetIP4(t *testing.T) {
+	ip := IP4{192, 168, 0, 3}
+	var r Record
+	r.Set(ip)
+
+	var ip2 IP4
+	if err := r.Load(&ip2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ip, ip2) {
+		t.Errorf("IP mismatch: got %v, want %v", ip2, ip)
+	}
+}
+
+// TestGetSetIP6 tests encoding/decoding and setting/getting of the IP6 key.
+func TestGetSetIP6(t *testing.T) {
+	ip := IP6(net.ParseIP("2001:db8::1"))
+	var r Record
+	r.Set(ip)
+
+	var ip2 IP6
+	if err := r.Load(&ip2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ip, ip2) {
+		t.Errorf("IP mismatch: got %v, want %v", ip2, ip)
+	}
+}
+
+// TestGetSetPorts tests encoding/decoding and setting/getting of the UDP and
+// TCP keys.
+func TestGetSetPorts(t *testing.T) {
+	var r Record
+	r.Set(UDP(30309))
+	r.Set(TCP(30303))
+
+	var udp UDP
+	var tcp TCP
+	if err := r.Load(&udp); err != nil || udp != 30309 {
+		t.Errorf("UDP mismatch: got %d (err %v), want 30309", udp, err)
+	}
+	if err := r.Load(&tcp); err != nil || tcp != 30303 {
+		t.Errorf("TCP mismatch: got %d (err %v), want 30303", tcp, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var r Record
+	r.Set(WithEntry("ip", uint(42)))
+
+	// check error for missing keys
+	var udp UDP
+	err := r.Load(&udp)
+	if !IsNotFound(err) {
+		t.Error("IsNotFound should return true for missing key")
+	}
+	if want := (&KeyError{Key: "udp", Err: errNotFound}); !reflect.DeepEqual(err, want) {
+		t.Errorf("wrong error for missing key: got %#v, want %#v", err, want)
+	}
+
+	// check error for invalid keys
+	var ip IP4
+	err = r.Load(&ip)
+	if _, ok := err.(*KeyError); !ok {
+		t.Errorf("expected KeyError for invalid key, got %#v", err)
+	}
+	if IsNotFound(err) {
+		t.Error("IsNotFound should return false for decoding errors")
+	}
+}
+
+// TestSortedGetAndSet tests that keys are kept sorted regardless of the
+// insertion order.
+func TestSortedGetAndSet(t *testing.T) {
+	var r Record
+	for _, k := range []string{"c", "a", "e", "b", "d"} {
+		r.Set(WithEntry(k, uint(1)))
+	}
+	var keys []string
+	for _, p := range r.pairs {
+		keys = append(keys, p.k)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys not sorted: got %v, want %v", keys, want)
+	}
+}
+
+// TestDirty tests record signature removal on setting of new key/value pair
+// in record.
+func TestDirty(t *testing.T) {
+	var r Record
+
+	if _, err := rlp.EncodeToBytes(r); err != errEncodeUnsigned {
+		t.Errorf("expected errEncodeUnsigned, got %#v", err)
+	}
+
+	signTest(t, &r, privkey)
+	if !r.Signed() {
+		t.Error("Signed returned false for signed record")
+	}
+	if _, err := rlp.EncodeToBytes(r); err != nil {
+		t.Fatal(err)
+	}
+
+	r.SetSeq(3)
+	if r.Signed() {
+		t.Error("Signed returned true for modified record")
+	}
+	if _, err := rlp.EncodeToBytes(r); err != errEncodeUnsigned {
+		t.Errorf("expected errEncodeUnsigned, got %#v", err)
+	}
+}
+
+// TestSeqIncrement checks that signing a modified record increases the
+// sequence number exactly once.
+func TestSeqIncrement(t *testing.T) {
+	var r Record
+	signTest(t, &r, privkey)
+	if r.Seq() != 0 {
+		t.Fatalf("initial seq %d, want 0", r.Seq())
+	}
+	r.Set(UDP(1))
+	r.Set(TCP(2))
+	signTest(t, &r, privkey)
+	if r.Seq() != 1 {
+		t.Errorf("seq %d after update, want 1", r.Seq())
+	}
+}
+
+// TestSignEncodeAndDecode tests signing, RLP encoding and RLP decoding of a
+// record.
+func TestSignEncodeAndDecode(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	r.Set(IP4{127, 0, 0, 1})
+	signTest(t, &r, privkey)
+
+	blob, err := rlp.EncodeToBytes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var r2 Record
+	if err := rlp.DecodeBytes(blob, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, r2) {
+		t.Errorf("records mismatch:\n  got:  %#v\n  want: %#v", r2, r)
+	}
+	if id := r2.NodeID(); !bytes.Equal(id, crypto.FromECDSAPub(&privkey.PublicKey)[1:]) {
+		t.Errorf("node ID mismatch: got %x", id)
+	}
+}
+
+// TestDecodeInvalid checks that records with bad signatures or a bad layout
+// are rejected.
+func TestDecodeInvalid(t *testing.T) {
+	var r Record
+	r.Set(UDP(30303))
+	signTest(t, &r, privkey)
+	valid, _ := rlp.EncodeToBytes(r)
+
+	// flip a bit in the signed content
+	tampered := make([]byte, len(valid))
+	copy(tampered, valid)
+	tampered[len(tampered)-1] ^= 1
+	if err := rlp.DecodeBytes(tampered, new(Record)); err != errInvalidSig {
+		t.Errorf("tampered record: got error %v, want %v", err, errInvalidSig)
+	}
+
+	// records need to contain an identity scheme
+	unsigned, _ := rlp.EncodeToBytes([]interface{}{make([]byte, 65), uint(0), "udp", uint(1)})
+	if err := rlp.DecodeBytes(unsigned, new(Record)); !IsNotFound(err) {
+		t.Errorf("record without id: got error %v, want missing key", err)
+	}
+
+	// keys must be sorted and unique
+	unsorted, _ := rlp.EncodeToBytes([]interface{}{make([]byte, 65), uint(0), "udp", uint(1), "id", "v4"})
+	if err := rlp.DecodeBytes(unsorted, new(Record)); err != errNotSorted {
+		t.Errorf("unsorted record: got error %v, want %v", err, errNotSorted)
+	}
+	duplicate, _ := rlp.EncodeToBytes([]interface{}{make([]byte, 65), uint(0), "id", "v4", "id", "v4"})
+	if err := rlp.DecodeBytes(duplicate, new(Record)); err != errDuplicateKey {
+		t.Errorf("duplicate key: got error %v, want %v", err, errDuplicateKey)
+	}
+	incomplete, _ := rlp.EncodeToBytes([]interface{}{make([]byte, 65), uint(0), "id"})
+	if err := rlp.DecodeBytes(incomplete, new(Record)); err != errIncompletePair {
+		t.Errorf("incomplete pair: got error %v, want %v", err, errIncompletePair)
+	}
+}
+
+// TestRecordTooBig tests that records bigger than SizeLimit bytes cannot be
+// signed.
+func TestRecordTooBig(t *testing.T) {
+	var r Record
+	r.Set(WithEntry("k", make([]byte, SizeLimit)))
+	if err := r.SignV4(privkey); err != errTooBig {
+		t.Fatalf("expected to get errTooBig, got %#v", err)
+	}
+	if r.Signed() {
+		t.Error("record signed despite error")
+	}
+}
@@ -0,0 +1,23 @@
+package p2p
+
+// PeerAddEvent is posted when a peer has passed all handshakes and checks and
+// its protocols are about to be started.
+type PeerAddEvent struct{ Peer *Peer }
+
+// PeerDropEvent is posted when a peer has been disconnected and removed from
+// the server.
+type PeerDropEvent struct {
+	Peer   *Peer
+	Reason DiscReason
+}
+
+// PeerMsgEvent is posted when a subprotocol message is sent to or received
+// from a peer. Code is relative to the protocol, as seen by the protocol
+// implementation.
+type PeerMsgEvent struct {
+	Peer     *Peer
+	Protocol string
+	Code     uint64
+	Size     uint32
+	Incoming bool
+}
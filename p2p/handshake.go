@@ -96,6 +96,7 @@ func setupInboundConn(fd net.Conn, prv *ecdsa.PrivateKey, our *protoHandshake, a
 	if err := Send(rw, handshakeMsg, our); err != nil {
 		return nil, fmt.Errorf("protocol handshake write error: %v", err)
 	}
+	rw.snappy = useSnappy(our, rhs)
 	return &conn{rw, rhs}, nil
 }
 
@@ -127,6 +128,7 @@ func setupOutboundConn(fd net.Conn, prv *ecdsa.PrivateKey, our *protoHandshake,
 	if rhs.ID != dial.ID {
 		return nil, errors.New("dialed node id mismatch")
 	}
+	rw.snappy = useSnappy(our, rhs)
 	return &conn{rw, rhs}, nil
 }
 
@@ -446,3 +448,24 @@ func readProtocolHandshake(rw MsgReadWriter, wantID discover.NodeID, our *protoH
 	}
 	return &hs, nil
 }
+
+// snappyCap is advertised in the protocol handshake by nodes which can
+// compress message payloads. It doesn't name a subprotocol, so nodes
+// without compression support ignore it and keep talking uncompressed.
+var snappyCap = Cap{Name: "snappy", Version: 1}
+
+// useSnappy returns whether both sides of a protocol handshake support
+// message compression. The handshake messages themselves are never
+// compressed, so the decision can only be made after they are exchanged.
+func useSnappy(our, their *protoHandshake) bool {
+	return hasCap(our.Caps, snappyCap) && hasCap(their.Caps, snappyCap)
+}
+
+func hasCap(caps []Cap, cap Cap) bool {
+	for _, c := range caps {
+		if c == cap {
+			return true
+		}
+	}
+	return false
+}
@@ -169,3 +169,79 @@ func TestSetupConn(t *testing.T) {
 
 	<-done
 }
+
+// Tests that compression is only enabled if both sides support it, and that
+// messages pass in both directions either way. Nodes without compression
+// support are the version 4 nodes before it was added, which drop peers
+// of any other version, so compression must not change the version.
+func TestSetupConnSnappy(t *testing.T) {
+	const oldVersion = 4
+	tests := []struct {
+		dialer, listener []Cap
+		snappy           bool
+	}{
+		{dialer: nil, listener: nil, snappy: false},
+		{dialer: nil, listener: []Cap{snappyCap}, snappy: false},
+		{dialer: []Cap{{"a", 1}, snappyCap}, listener: []Cap{{"a", 1}}, snappy: false},
+		{dialer: []Cap{snappyCap}, listener: []Cap{{"a", 1}, snappyCap}, snappy: true},
+	}
+	for i, tt := range tests {
+		prv0, _ := crypto.GenerateKey()
+		prv1, _ := crypto.GenerateKey()
+		node1 := &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey)}
+		hs0 := &protoHandshake{Version: baseProtocolVersion, Caps: tt.dialer, ID: discover.PubkeyID(&prv0.PublicKey)}
+		hs1 := &protoHandshake{Version: baseProtocolVersion, Caps: tt.listener, ID: node1.ID}
+		if !hasCap(tt.dialer, snappyCap) {
+			hs0.Version = oldVersion
+		}
+		if !hasCap(tt.listener, snappyCap) {
+			hs1.Version = oldVersion
+		}
+
+		fd0, fd1 := net.Pipe()
+		result := make(chan *conn, 1)
+		go func() {
+			conn0, err := setupConn(fd0, prv0, hs0, node1, false, nil)
+			if err != nil {
+				t.Errorf("test %d: outbound side error: %v", i, err)
+			}
+			result <- conn0
+		}()
+		conn1, err := setupConn(fd1, prv1, hs1, nil, false, nil)
+		if err != nil {
+			t.Fatalf("test %d: inbound side error: %v", i, err)
+		}
+		conn0 := <-result
+		if conn0 == nil {
+			continue
+		}
+		for j, c := range []*conn{conn0, conn1} {
+			if snappy := c.MsgReadWriter.(*rlpxFrameRW).snappy; snappy != tt.snappy {
+				t.Errorf("test %d: conn %d snappy mismatch: have %v, want %v", i, j, snappy, tt.snappy)
+			}
+		}
+		go SendItems(conn0, 0x10, "ping")
+		if err := ExpectMsg(conn1, 0x10, []string{"ping"}); err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		fd0.Close()
+		fd1.Close()
+	}
+}
+
+// Tests that peers of a different base protocol version are rejected.
+func TestSetupConnVersionMismatch(t *testing.T) {
+	prv0, _ := crypto.GenerateKey()
+	prv1, _ := crypto.GenerateKey()
+	node1 := &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey)}
+	hs0 := &protoHandshake{Version: baseProtocolVersion + 1, ID: discover.PubkeyID(&prv0.PublicKey)}
+	hs1 := &protoHandshake{Version: baseProtocolVersion, ID: node1.ID}
+
+	fd0, fd1 := net.Pipe()
+	defer fd0.Close()
+	defer fd1.Close()
+	go setupConn(fd0, prv0, hs0, node1, false, nil)
+	if _, err := setupConn(fd1, prv1, hs1, nil, false, nil); err == nil {
+		t.Fatalf("peer with version %d accepted", hs0.Version)
+	}
+}
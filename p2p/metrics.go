@@ -0,0 +1,24 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	// ingressTrafficMeter and egressTrafficMeter measure the bytes of all
+	// RLPx frames, including headers, padding and MACs.
+	ingressTrafficMeter = metrics.NewMeter("p2p/ingress")
+	egressTrafficMeter  = metrics.NewMeter("p2p/egress")
+)
+
+// protoMeters returns the meters measuring the payload bytes of each message
+// code of a subprotocol in one direction, named like "p2p/eth/2/in".
+func protoMeters(proto Protocol, direction string) []*metrics.Meter {
+	meters := make([]*metrics.Meter, proto.Length)
+	for code := range meters {
+		meters[code] = metrics.NewMeter(fmt.Sprintf("p2p/%s/%d/%s", proto.Name, code, direction))
+	}
+	return meters
+}
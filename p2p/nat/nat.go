@@ -111,6 +111,21 @@ func Map(m Interface, c chan struct{}, protocol string, extport, intport int, na
 	}
 }
 
+// Mappable reports whether ports bound to the given local address can
+// be mapped. Port mapping protocols only exist for IPv4, so addresses
+// of other families are never mapped. The unspecified address is
+// mappable because sockets bound to it accept IPv4 connections.
+func Mappable(laddr net.IP) bool {
+	return !laddr.IsLoopback() && (laddr.IsUnspecified() || laddr.To4() != nil)
+}
+
+// SameFamily reports whether the external address ext can stand in for
+// the local address laddr, i.e. whether both belong to the same address
+// family. Any address can stand in for the unspecified address.
+func SameFamily(laddr, ext net.IP) bool {
+	return laddr.IsUnspecified() || (laddr.To4() == nil) == (ext.To4() == nil)
+}
+
 // ExtIP assumes that the local machine is reachable on the given
 // external IP address, and that any required ports were mapped manually.
 // Mapping operations will not return an error but won't actually do anything.
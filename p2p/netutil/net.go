@@ -0,0 +1,61 @@
+// Package netutil contains extensions to the net package.
+package netutil
+
+import (
+	"net"
+	"strings"
+)
+
+// Netlist is a list of IP networks.
+type Netlist []net.IPNet
+
+// ParseNetlist parses a comma-separated list of CIDR masks, like
+// "10.0.0.0/8, 192.168.1.0/24". Whitespace and empty entries are ignored.
+func ParseNetlist(s string) (*Netlist, error) {
+	var l Netlist
+	for _, mask := range strings.Split(s, ",") {
+		mask = strings.TrimSpace(mask)
+		if mask == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(mask)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, *n)
+	}
+	return &l, nil
+}
+
+// Add parses a CIDR mask and appends it to the list. It panics for invalid
+// masks and is intended to be used for setting up static lists.
+func (l *Netlist) Add(cidr string) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	*l = append(*l, *n)
+}
+
+// Contains reports whether the given IP is contained in the list.
+// A nil list contains no addresses.
+func (l *Netlist) Contains(ip net.IP) bool {
+	if l == nil {
+		return false
+	}
+	for _, n := range *l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the list as a comma-separated list of CIDR masks.
+func (l Netlist) String() string {
+	masks := make([]string, len(l))
+	for i, n := range l {
+		masks[i] = n.String()
+	}
+	return strings.Join(masks, ",")
+}
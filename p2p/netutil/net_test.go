@@ -0,0 +1,77 @@
+package netutil
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseNetlist(t *testing.T) {
+	var tests = []struct {
+		input    string
+		wantErr  bool
+		wantList Netlist
+	}{
+		{
+			input:    "",
+			wantList: nil,
+		},
+		{
+			input:    "127.0.0.0/8",
+			wantList: Netlist{{IP: net.IP{127, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+		},
+		{
+			input:   "127.0.0.0/44",
+			wantErr: true,
+		},
+		{
+			input: " 10.0.0.0/8, ,192.168.1.0/24 ",
+			wantList: Netlist{
+				{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+				{IP: net.IP{192, 168, 1, 0}, Mask: net.CIDRMask(24, 32)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		l, err := ParseNetlist(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: got no error, expected parse error", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: got error %q, want no error", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(*l, test.wantList) {
+			t.Errorf("%q: got %v, want %v", test.input, l, test.wantList)
+		}
+	}
+}
+
+func TestNetlistContains(t *testing.T) {
+	var l Netlist
+	l.Add("10.0.0.0/8")
+	l.Add("192.168.1.0/24")
+
+	for _, ip := range []string{"10.0.0.1", "10.255.1.2", "192.168.1.77"} {
+		if !l.Contains(net.ParseIP(ip)) {
+			t.Errorf("%s not contained in %v", ip, l)
+		}
+	}
+	for _, ip := range []string{"11.0.0.1", "192.168.2.1", "127.0.0.1"} {
+		if l.Contains(net.ParseIP(ip)) {
+			t.Errorf("%s contained in %v", ip, l)
+		}
+	}
+
+	var nilList *Netlist
+	if nilList.Contains(net.ParseIP("10.0.0.1")) {
+		t.Error("nil list contains an address")
+	}
+	if s := l.String(); s != "10.0.0.0/8,192.168.1.0/24" {
+		t.Errorf("String mismatch: got %q", s)
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -43,6 +45,12 @@ type Peer struct {
 	protoErr chan error
 	closed   chan struct{}
 	disc     chan DiscReason
+
+	events *event.TypeMux  // if set, message events are posted here
+	rep    reputationStore // if set, reputation events are reported here
+
+	// subprotocol payload bytes exchanged with the peer
+	ingress, egress metrics.Counter
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -55,6 +63,38 @@ func NewPeer(id discover.NodeID, name string, caps []Cap) *Peer {
 	return peer
 }
 
+// NewPipePeer creates a peer for the remote node described by id, name and
+// caps, and runs the matching protocols over rw, typically one end of a
+// message pipe. It allows protocols to talk between nodes of a single process
+// without any networking. If rw implements io.Closer, it is closed when the
+// peer disconnects. The disconnect reason is sent on the returned channel
+// once the peer has stopped. If mux is non-nil, subprotocol messages are
+// posted on it as PeerMsgEvents.
+func NewPipePeer(id discover.NodeID, name string, caps []Cap, rw MsgReadWriter, protocols []Protocol, mux *event.TypeMux) (*Peer, <-chan DiscReason) {
+	fd, _ := net.Pipe()
+	conn := &conn{rw, &protoHandshake{ID: id, Name: name, Caps: caps, Version: baseProtocolVersion}}
+	peer := newPeer(&pipeConn{fd, rw}, conn, protocols)
+	peer.events = mux
+
+	done := make(chan DiscReason, 1)
+	go func() { done <- peer.run() }()
+	return peer, done
+}
+
+// pipeConn closes its message pipe along with the connection, unblocking any
+// protocols still reading or writing.
+type pipeConn struct {
+	net.Conn
+	rw MsgReadWriter
+}
+
+func (c *pipeConn) Close() error {
+	if closer, ok := c.rw.(io.Closer); ok {
+		closer.Close()
+	}
+	return c.Conn.Close()
+}
+
 // ID returns the node's public key.
 func (p *Peer) ID() discover.NodeID {
 	return p.rw.ID
@@ -81,6 +121,12 @@ func (p *Peer) LocalAddr() net.Addr {
 	return p.conn.LocalAddr()
 }
 
+// Traffic returns the number of subprotocol payload bytes received from and
+// sent to the peer so far.
+func (p *Peer) Traffic() (ingress, egress int64) {
+	return p.ingress.Count(), p.egress.Count()
+}
+
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason DiscReason) {
@@ -90,6 +136,23 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Report records a reputation event for the peer. It returns true if
+// the peer was banned as a result.
+func (p *Peer) Report(ev ReputationEvent) bool {
+	if p.rep == nil {
+		return false
+	}
+	return p.rep.Report(p.ID(), ev)
+}
+
+// Reputation returns the current reputation score of the peer.
+func (p *Peer) Reputation() int64 {
+	if p.rep == nil {
+		return 0
+	}
+	return p.rep.Reputation(p.ID())
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %.8x %v", p.rw.ID[:], p.RemoteAddr())
@@ -220,7 +283,14 @@ outer:
 	for _, cap := range caps {
 		for _, proto := range protocols {
 			if proto.Name == cap.Name && proto.Version == cap.Version && result[cap.Name] == nil {
-				result[cap.Name] = &protoRW{Protocol: proto, offset: offset, in: make(chan Msg), w: rw}
+				result[cap.Name] = &protoRW{
+					Protocol:  proto,
+					offset:    offset,
+					in:        make(chan Msg),
+					w:         rw,
+					inMeters:  protoMeters(proto, "in"),
+					outMeters: protoMeters(proto, "out"),
+				}
 				offset += proto.Length
 				continue outer
 			}
@@ -234,6 +304,7 @@ func (p *Peer) startProtocols() {
 	for _, proto := range p.running {
 		proto := proto
 		proto.closed = p.closed
+		proto.peer = p
 		glog.V(logger.Detail).Infof("%v: Starting protocol %s/%d\n", p, proto.Name, proto.Version)
 		go func() {
 			err := proto.Run(p, proto)
@@ -280,22 +351,52 @@ type protoRW struct {
 	closed <-chan struct{}
 	offset uint64
 	w      MsgWriter
+	peer   *Peer
+
+	inMeters, outMeters []*metrics.Meter // payload bytes by message code
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) error {
 	if msg.Code >= rw.Length {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
+	code := msg.Code
 	msg.Code += rw.offset
-	return rw.w.WriteMsg(msg)
+	if err := rw.w.WriteMsg(msg); err != nil {
+		return err
+	}
+	rw.outMeters[code].Mark(int64(msg.Size))
+	if rw.peer != nil {
+		rw.peer.egress.Inc(int64(msg.Size))
+	}
+	rw.postMsgEvent(code, msg.Size, false)
+	return nil
 }
 
 func (rw *protoRW) ReadMsg() (Msg, error) {
 	select {
 	case msg := <-rw.in:
 		msg.Code -= rw.offset
+		rw.inMeters[msg.Code].Mark(int64(msg.Size))
+		if rw.peer != nil {
+			rw.peer.ingress.Inc(int64(msg.Size))
+		}
+		rw.postMsgEvent(msg.Code, msg.Size, true)
 		return msg, nil
 	case <-rw.closed:
 		return Msg{}, io.EOF
 	}
 }
+
+func (rw *protoRW) postMsgEvent(code uint64, size uint32, incoming bool) {
+	if rw.peer == nil || rw.peer.events == nil {
+		return
+	}
+	rw.peer.events.Post(PeerMsgEvent{
+		Peer:     rw.peer,
+		Protocol: rw.Name,
+		Code:     code,
+		Size:     size,
+		Incoming: incoming,
+	})
+}
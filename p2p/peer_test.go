@@ -9,6 +9,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/event"
 )
 
 var discard = Protocol{
@@ -87,6 +89,68 @@ func TestPeerProtoReadMsg(t *testing.T) {
 	}
 }
 
+// Tests that subprotocol messages in both directions are posted as events,
+// with codes relative to the protocol.
+func TestPeerMsgEvents(t *testing.T) {
+	defer testlog(t).detach()
+
+	mux := new(event.TypeMux)
+	defer mux.Stop()
+	sub := mux.Subscribe(PeerMsgEvent{})
+	defer sub.Unsubscribe()
+
+	proto := Protocol{
+		Name:   "a",
+		Length: 5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, uint(2)); err != nil {
+				t.Error(err)
+			}
+			// keep the protocol running until the peer is closed
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	fd, _ := net.Pipe()
+	p1, p2 := MsgPipe()
+	defer p1.Close()
+	defer fd.Close()
+
+	hs := &protoHandshake{ID: randomID(), Version: baseProtocolVersion, Caps: []Cap{proto.cap()}}
+	peer := newPeer(fd, &conn{p1, hs}, []Protocol{proto})
+	peer.events = mux
+	go peer.run()
+
+	go Send(p2, baseProtocolLength+2, []uint{1})
+	go ExpectMsg(p2, baseProtocolLength+3, []uint{2})
+
+	want := []PeerMsgEvent{
+		{Peer: peer, Protocol: "a", Code: 2, Incoming: true},
+		{Peer: peer, Protocol: "a", Code: 3, Incoming: false},
+	}
+	var sizes []int64
+	for i, w := range want {
+		select {
+		case ev := <-sub.Chan():
+			have := ev.(PeerMsgEvent)
+			sizes = append(sizes, int64(have.Size))
+			have.Size = 0
+			if have != w {
+				t.Errorf("event %d mismatch: have %+v, want %+v", i, have, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event %d timeout", i)
+		}
+	}
+	// traffic is accounted before the events are posted
+	if in, out := peer.Traffic(); in != sizes[0] || out != sizes[1] {
+		t.Errorf("traffic mismatch: have %d/%d, want %d/%d", in, out, sizes[0], sizes[1])
+	}
+}
+
 func TestPeerProtoEncodeMsg(t *testing.T) {
 	defer testlog(t).detach()
 
@@ -1,6 +1,10 @@
 package p2p
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
 
 // Protocol represents a P2P subprotocol implementation.
 type Protocol struct {
@@ -23,6 +27,17 @@ type Protocol struct {
 	// any protocol-level error (such as an I/O error) that is
 	// encountered.
 	Run func(peer *Peer, rw MsgReadWriter) error
+
+	// Attributes contains protocol specific information for the
+	// node record, e.g. the network the protocol is running on.
+	// They are published through discovery.
+	Attributes []enr.Entry
+
+	// NodeFilter is called for the record of each node found through
+	// discovery that supports the protocol. If it returns false, the
+	// node is not dialed for the purpose of running the protocol.
+	// NodeFilter is optional.
+	NodeFilter func(*enr.Record) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -43,6 +58,12 @@ func (cap Cap) String() string {
 	return fmt.Sprintf("%s/%d", cap.Name, cap.Version)
 }
 
+// capsEntry is the "caps" node record entry, which holds the
+// capabilities of a node.
+type capsEntry []Cap
+
+func (capsEntry) ENRKey() string { return "caps" }
+
 type capsByName []Cap
 
 func (cs capsByName) Len() int           { return len(cs) }
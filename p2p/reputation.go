@@ -0,0 +1,126 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// ReputationEvent is an observation about the behaviour of a remote
+// node. Events are reported by protocols and change the reputation of
+// the node, which is stored in the node database.
+type ReputationEvent int
+
+const (
+	UsefulDelivery ReputationEvent = iota // the node delivered requested data
+	RequestTimeout                        // the node didn't answer a request in time
+	ProtocolError                         // the node violated a protocol
+	InvalidBlock                          // the node sent an invalid block
+)
+
+var reputationWeights = [...]int64{
+	UsefulDelivery: 1,
+	RequestTimeout: -5,
+	ProtocolError:  -25,
+	InvalidBlock:   -50,
+}
+
+const (
+	// Nodes whose reputation falls to banThreshold are banned.
+	banThreshold = -100
+	// Good behaviour can't accumulate more credit than maxReputation.
+	maxReputation = 100
+
+	defaultBanDuration = time.Hour
+)
+
+func (ev ReputationEvent) String() string {
+	switch ev {
+	case UsefulDelivery:
+		return "useful delivery"
+	case RequestTimeout:
+		return "request timeout"
+	case ProtocolError:
+		return "protocol error"
+	case InvalidBlock:
+		return "invalid block"
+	default:
+		return "unknown event"
+	}
+}
+
+// reputationStore is implemented by Server.
+type reputationStore interface {
+	Report(id discover.NodeID, ev ReputationEvent) bool
+	Reputation(id discover.NodeID) int64
+}
+
+// Report records an event for the given node. If the reputation of the node
+// falls to the ban threshold, the node is banned for BanDuration and
+// disconnected if it is connected. Report returns true if the node was
+// banned.
+func (srv *Server) Report(id discover.NodeID, ev ReputationEvent) bool {
+	ntab := srv.table()
+	if ntab == nil {
+		return false
+	}
+	rep := ntab.AddReputation(id, reputationWeights[ev], banThreshold, maxReputation)
+	glog.V(logger.Detail).Infof("Reputation of %x after %v: %d", id[:8], ev, rep)
+	if rep > banThreshold {
+		return false
+	}
+	srv.Ban(id, srv.banDuration())
+	return true
+}
+
+// Reputation returns the current reputation score of a node.
+func (srv *Server) Reputation(id discover.NodeID) int64 {
+	ntab := srv.table()
+	if ntab == nil {
+		return 0
+	}
+	return ntab.Reputation(id)
+}
+
+// Ban prevents connections to and from the given node for duration d.
+// The node is disconnected if it is connected.
+func (srv *Server) Ban(id discover.NodeID, d time.Duration) {
+	ntab := srv.table()
+	if ntab == nil {
+		return
+	}
+	glog.V(logger.Debug).Infof("Banning %x for %v", id[:8], d)
+	ntab.Ban(id, time.Now().Add(d))
+
+	srv.lock.RLock()
+	p := srv.peers[id]
+	srv.lock.RUnlock()
+	if p != nil {
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
+// Banned reports whether the given node is currently banned.
+func (srv *Server) Banned(id discover.NodeID) bool {
+	ntab := srv.table()
+	return ntab != nil && time.Now().Before(ntab.BannedUntil(id))
+}
+
+func (srv *Server) banDuration() time.Duration {
+	if srv.BanDuration == 0 {
+		return defaultBanDuration
+	}
+	return srv.BanDuration
+}
+
+// table returns the node table, or nil if the server is not running.
+func (srv *Server) table() *discover.Table {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	if !srv.running {
+		return nil
+	}
+	return srv.ntab
+}
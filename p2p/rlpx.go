@@ -6,10 +6,13 @@ import (
 	"crypto/cipher"
 	"crypto/hmac"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
+	"io/ioutil"
 
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/syndtr/gosnappy/snappy"
 )
 
 var (
@@ -21,6 +24,8 @@ var (
 	zero16 = make([]byte, 16)
 
 	maxUint24 = ^uint32(0) >> 8
+
+	errPlainMessageTooLarge = errors.New("message size exceeds maximum")
 )
 
 // rlpxFrameRW implements a simplified version of RLPx framing.
@@ -36,6 +41,8 @@ type rlpxFrameRW struct {
 	macCipher  cipher.Block
 	egressMAC  hash.Hash
 	ingressMAC hash.Hash
+
+	snappy bool // compress message payloads, set after the protocol handshake
 }
 
 func newRlpxFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
@@ -63,6 +70,23 @@ func newRlpxFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
 func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 	ptype, _ := rlp.EncodeToBytes(msg.Code)
 
+	// if snappy is enabled, compress message now
+	if rw.snappy {
+		if msg.Size > maxUint24 {
+			return errPlainMessageTooLarge
+		}
+		payload, err := ioutil.ReadAll(msg.Payload)
+		if err != nil {
+			return err
+		}
+		payload, err = snappy.Encode(nil, payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = bytes.NewReader(payload)
+		msg.Size = uint32(len(payload))
+	}
+
 	// write header
 	headbuf := make([]byte, 32)
 	fsize := uint32(len(ptype)) + msg.Size
@@ -98,8 +122,11 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 	// frame content was written to it as well.
 	fmacseed := rw.egressMAC.Sum(nil)
 	mac := updateMAC(rw.egressMAC, rw.macCipher, fmacseed)
-	_, err := rw.conn.Write(mac)
-	return err
+	if _, err := rw.conn.Write(mac); err != nil {
+		return err
+	}
+	egressTrafficMeter.Mark(int64(len(headbuf)) + int64(fsize+(16-fsize%16)%16) + int64(len(mac)))
+	return nil
 }
 
 func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
@@ -138,6 +165,8 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 		return msg, errors.New("bad frame MAC")
 	}
 
+	ingressTrafficMeter.Mark(int64(len(headbuf)) + int64(rsize) + 16)
+
 	// decrypt frame content
 	rw.dec.XORKeyStream(framebuf, framebuf)
 
@@ -148,6 +177,23 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	}
 	msg.Size = uint32(content.Len())
 	msg.Payload = content
+
+	// if snappy is enabled, verify and decompress message
+	if rw.snappy {
+		payload := framebuf[fsize-uint32(content.Len()) : fsize]
+		size, err := snappy.DecodedLen(payload)
+		if err != nil {
+			return msg, err
+		}
+		// reject decompression bombs before allocating
+		if size > baseProtocolMaxMsgSize {
+			return msg, fmt.Errorf("%v: %d > %d", errPlainMessageTooLarge, size, baseProtocolMaxMsgSize)
+		}
+		if payload, err = snappy.Decode(nil, payload); err != nil {
+			return msg, err
+		}
+		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
+	}
 	return msg, nil
 }
 
@@ -3,6 +3,8 @@ package p2p
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/binary"
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -67,6 +69,83 @@ func (h fakeHash) Size() int           { return len(h) }
 func (h fakeHash) Sum(b []byte) []byte { return append(b, h...) }
 
 func TestRlpxFrameRW(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newRlpxFramePair(conn)
+
+	// send some messages
+	for i := 0; i < 10; i++ {
+		// write message into conn buffer
+		wmsg := []interface{}{"foo", "bar", strings.Repeat("test", i)}
+		err := Send(rw1, uint64(i), wmsg)
+		if err != nil {
+			t.Fatalf("WriteMsg error (i=%d): %v", i, err)
+		}
+
+		// read message that rw1 just wrote
+		msg, err := rw2.ReadMsg()
+		if err != nil {
+			t.Fatalf("ReadMsg error (i=%d): %v", i, err)
+		}
+		if msg.Code != uint64(i) {
+			t.Fatalf("msg code mismatch: got %d, want %d", msg.Code, i)
+		}
+		payload, _ := ioutil.ReadAll(msg.Payload)
+		wantPayload, _ := rlp.EncodeToBytes(wmsg)
+		if !bytes.Equal(payload, wantPayload) {
+			t.Fatalf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
+		}
+	}
+}
+
+// Tests that payloads are compressed on the wire when snappy is enabled, and
+// decompressed transparently on the other side.
+func TestRlpxFrameSnappy(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newRlpxFramePair(conn)
+	rw1.snappy, rw2.snappy = true, true
+
+	wmsg := []interface{}{strings.Repeat("test", 1000)}
+	wantPayload, _ := rlp.EncodeToBytes(wmsg)
+	if err := Send(rw1, 8, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if conn.Len() >= len(wantPayload) {
+		t.Errorf("payload not compressed: %d bytes on the wire for %d payload bytes", conn.Len(), len(wantPayload))
+	}
+	msg, err := rw2.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Code != 8 || msg.Size != uint32(len(wantPayload)) {
+		t.Errorf("msg mismatch: got code %d size %d, want code 8 size %d", msg.Code, msg.Size, len(wantPayload))
+	}
+	payload, _ := ioutil.ReadAll(msg.Payload)
+	if !bytes.Equal(payload, wantPayload) {
+		t.Fatalf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
+	}
+}
+
+// Tests that compressed payloads claiming a decompressed size above the
+// limit are rejected without decompressing them.
+func TestRlpxFrameSnappyBomb(t *testing.T) {
+	conn := new(bytes.Buffer)
+	rw1, rw2 := newRlpxFramePair(conn)
+	rw2.snappy = true
+
+	// snappy blocks start with the decoded length as a uvarint
+	bomb := make([]byte, binary.MaxVarintLen64)
+	bomb = bomb[:binary.PutUvarint(bomb, baseProtocolMaxMsgSize+1)]
+	if err := rw1.WriteMsg(Msg{Code: 8, Size: uint32(len(bomb)), Payload: bytes.NewReader(bomb)}); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if _, err := rw2.ReadMsg(); err == nil {
+		t.Fatalf("oversized message accepted")
+	}
+}
+
+// newRlpxFramePair creates two frame readwriters with matching secrets on
+// top of conn, the first one writing what the second one reads.
+func newRlpxFramePair(conn io.ReadWriter) (*rlpxFrameRW, *rlpxFrameRW) {
 	var (
 		aesSecret      = make([]byte, 16)
 		macSecret      = make([]byte, 16)
@@ -76,7 +155,6 @@ func TestRlpxFrameRW(t *testing.T) {
 	for _, s := range [][]byte{aesSecret, macSecret, egressMACinit, ingressMACinit} {
 		rand.Read(s)
 	}
-	conn := new(bytes.Buffer)
 
 	s1 := secrets{
 		AES:        aesSecret,
@@ -98,27 +176,5 @@ func TestRlpxFrameRW(t *testing.T) {
 	s2.IngressMAC.Write(egressMACinit)
 	rw2 := newRlpxFrameRW(conn, s2)
 
-	// send some messages
-	for i := 0; i < 10; i++ {
-		// write message into conn buffer
-		wmsg := []interface{}{"foo", "bar", strings.Repeat("test", i)}
-		err := Send(rw1, uint64(i), wmsg)
-		if err != nil {
-			t.Fatalf("WriteMsg error (i=%d): %v", i, err)
-		}
-
-		// read message that rw1 just wrote
-		msg, err := rw2.ReadMsg()
-		if err != nil {
-			t.Fatalf("ReadMsg error (i=%d): %v", i, err)
-		}
-		if msg.Code != uint64(i) {
-			t.Fatalf("msg code mismatch: got %d, want %d", msg.Code, i)
-		}
-		payload, _ := ioutil.ReadAll(msg.Payload)
-		wantPayload, _ := rlp.EncodeToBytes(wmsg)
-		if !bytes.Equal(payload, wantPayload) {
-			t.Fatalf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
-		}
-	}
+	return rw1, rw2
 }
@@ -10,10 +10,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -97,10 +100,32 @@ type Server struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool
 
+	// If NetRestrict is set to a non-nil value, connections and discovery
+	// are restricted to nodes within the given networks.
+	NetRestrict *netutil.Netlist
+
+	// Topics are advertised through discovery so that other nodes
+	// can find this node by capability. Nodes registered under the
+	// same topics are looked up when dialing.
+	Topics []discover.Topic
+
+	// TopicSlots is the number of outbound connections reserved for
+	// nodes found through topic lookups.
+	TopicSlots int
+
+	// BanDuration is the time for which nodes with bad reputation are
+	// banned. The default is one hour.
+	BanDuration time.Duration
+
+	// If EventMux is set to a non-nil value, PeerAddEvent, PeerDropEvent
+	// and PeerMsgEvent are posted on it as peers come and go.
+	EventMux *event.TypeMux
+
 	// Hooks for testing. These are useful because we can inhibit
 	// the whole protocol stack.
 	setupFunc
 	newPeerHook
+	reachable func(net.IP) bool
 
 	ourHandshake *protoHandshake
 
@@ -150,9 +175,53 @@ func (srv *Server) AddPeer(node *discover.Node) {
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
+	srv.initTrustMaps()
 	srv.staticNodes[node.ID] = node
 }
 
+// RemovePeer stops maintaining the connection to the given node and
+// disconnects it if it is currently connected.
+func (srv *Server) RemovePeer(node *discover.Node) {
+	srv.lock.Lock()
+	delete(srv.staticNodes, node.ID)
+	peer := srv.peers[node.ID]
+	srv.lock.Unlock()
+
+	if peer != nil {
+		peer.Disconnect(DiscRequested)
+	}
+}
+
+// AddTrustedPeer marks the given node as trusted, allowing it to connect even
+// above the peer limit. It does not initiate a connection.
+func (srv *Server) AddTrustedPeer(node *discover.Node) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	srv.initTrustMaps()
+	srv.trustedNodes[node.ID] = true
+}
+
+// RemoveTrustedPeer removes the trust mark of the given node. An existing
+// connection is kept, but it counts towards the peer limit from now on.
+func (srv *Server) RemoveTrustedPeer(node *discover.Node) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	delete(srv.trustedNodes, node.ID)
+}
+
+// initTrustMaps creates the static and trusted node sets if they don't exist
+// yet. The caller must hold srv.lock.
+func (srv *Server) initTrustMaps() {
+	if srv.trustedNodes == nil {
+		srv.trustedNodes = make(map[discover.NodeID]bool)
+	}
+	if srv.staticNodes == nil {
+		srv.staticNodes = make(map[discover.NodeID]*discover.Node)
+	}
+}
+
 // Broadcast sends an RLP-encoded message to all connected peers.
 // This method is deprecated and will be removed later.
 func (srv *Server) Broadcast(protocol string, code uint64, data interface{}) error {
@@ -212,12 +281,12 @@ func (srv *Server) Start() (err error) {
 	srv.quit = make(chan struct{})
 	srv.peers = make(map[discover.NodeID]*Peer)
 
-	// Create the current trust maps, and the associated dialing channel
-	srv.trustedNodes = make(map[discover.NodeID]bool)
+	// Create the current trust maps, and the associated dialing channel.
+	// Nodes added at runtime before this start are kept.
+	srv.initTrustMaps()
 	for _, node := range srv.TrustedNodes {
 		srv.trustedNodes[node.ID] = true
 	}
-	srv.staticNodes = make(map[discover.NodeID]*discover.Node)
 	for _, node := range srv.StaticNodes {
 		srv.staticNodes[node.ID] = node
 	}
@@ -226,9 +295,12 @@ func (srv *Server) Start() (err error) {
 	if srv.setupFunc == nil {
 		srv.setupFunc = setupConn
 	}
+	if srv.reachable == nil {
+		srv.reachable = routeExists
+	}
 
 	// node table
-	ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase)
+	ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.NetRestrict)
 	if err != nil {
 		return err
 	}
@@ -240,6 +312,27 @@ func (srv *Server) Start() (err error) {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
 
+	// node record
+	entries := []enr.Entry{capsEntry(srv.ourHandshake.Caps)}
+	for _, p := range srv.Protocols {
+		entries = append(entries, p.Attributes...)
+	}
+	if err := ntab.SetRecordEntries(entries...); err != nil {
+		glog.V(logger.Warn).Infoln("Can't publish node record:", err)
+	}
+	// Compression support is only announced in the handshake, it isn't a
+	// subprotocol worth publishing in the record.
+	srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, snappyCap)
+
+	// topic registration
+	for _, topic := range srv.Topics {
+		srv.loopWG.Add(1)
+		go func(topic discover.Topic) {
+			defer srv.loopWG.Done()
+			srv.ntab.RegisterTopic(topic, srv.quit)
+		}(topic)
+	}
+
 	// listen/dial
 	if srv.ListenAddr != "" {
 		if err := srv.startListening(); err != nil {
@@ -273,7 +366,7 @@ func (srv *Server) startListening() error {
 	srv.listener = listener
 	srv.loopWG.Add(1)
 	go srv.listenLoop()
-	if !laddr.IP.IsLoopback() && srv.NAT != nil {
+	if srv.NAT != nil && nat.Mappable(laddr.IP) {
 		srv.loopWG.Add(1)
 		go func() {
 			nat.Map(srv.NAT, srv.quit, "tcp", laddr.Port, laddr.Port, "ethereum p2p")
@@ -343,6 +436,13 @@ func (srv *Server) listenLoop() {
 		if err != nil {
 			return
 		}
+		// Reject connections from outside the allowed networks.
+		if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !srv.allowed(tcp.IP) {
+			glog.V(logger.Debug).Infof("Rejected conn %v: not in netrestrict whitelist\n", conn.RemoteAddr())
+			conn.Close()
+			slots <- struct{}{}
+			continue
+		}
 		glog.V(logger.Debug).Infof("Accepted conn %v\n", conn.RemoteAddr())
 		srv.peerWG.Add(1)
 		go func() {
@@ -393,16 +493,18 @@ func (srv *Server) staticNodesLoop() {
 
 func (srv *Server) dialLoop() {
 	var (
-		dialed      = make(chan *discover.Node)
-		dialing     = make(map[discover.NodeID]bool)
-		findresults = make(chan []*discover.Node)
-		refresh     = time.NewTimer(0)
+		dialed       = make(chan *discover.Node)
+		dialing      = make(map[discover.NodeID]bool)
+		findresults  = make(chan []*discover.Node)
+		topicresults = make(chan []*discover.Node)
+		topicDialed  = make(map[discover.NodeID]bool)
+		refresh      = time.NewTimer(0)
 	)
 	defer srv.loopWG.Done()
 	defer refresh.Stop()
 
 	// TODO: maybe limit number of active dials
-	dial := func(dest *discover.Node) {
+	dial := func(dest *discover.Node) bool {
 		// Don't dial nodes that would fail the checks in addPeer.
 		// This is important because the connection handshake is a lot
 		// of work and we'd rather avoid doing that work for peers
@@ -410,8 +512,8 @@ func (srv *Server) dialLoop() {
 		srv.lock.RLock()
 		ok, _ := srv.checkPeer(dest.ID)
 		srv.lock.RUnlock()
-		if !ok || dialing[dest.ID] {
-			return
+		if !ok || dialing[dest.ID] || !srv.allowed(dest.IP) {
+			return false
 		}
 
 		dialing[dest.ID] = true
@@ -420,6 +522,7 @@ func (srv *Server) dialLoop() {
 			srv.dialNode(dest)
 			dialed <- dest
 		}()
+		return true
 	}
 
 	srv.ntab.Bootstrap(srv.BootstrapNodes)
@@ -427,16 +530,30 @@ func (srv *Server) dialLoop() {
 		select {
 		case <-refresh.C:
 			// Grab some nodes to connect to if we're not at capacity.
+			// Slots reserved for topic peers are not filled by random
+			// lookups.
 			srv.lock.RLock()
-			needpeers := len(srv.peers) < srv.MaxPeers/2
+			ntopic := srv.topicPeers(topicDialed, dialing)
+			needpeers := len(srv.peers)-ntopic < srv.MaxPeers/2-srv.TopicSlots
+			needtopic := ntopic < srv.TopicSlots && len(srv.Topics) > 0
 			srv.lock.RUnlock()
 			if needpeers {
 				go func() {
 					var target discover.NodeID
 					rand.Read(target[:])
-					findresults <- srv.ntab.Lookup(target)
+					findresults <- srv.filterByRecord(srv.ntab.Lookup(target))
+				}()
+			}
+			if needtopic {
+				go func() {
+					var nodes []*discover.Node
+					for _, topic := range srv.Topics {
+						nodes = append(nodes, srv.ntab.LookupTopic(topic)...)
+					}
+					topicresults <- nodes
 				}()
-			} else {
+			}
+			if !needpeers && !needtopic {
 				// Make sure we check again if the peer count falls
 				// below MaxPeers.
 				refresh.Reset(refreshPeersInterval)
@@ -448,6 +565,9 @@ func (srv *Server) dialLoop() {
 				dial(dest)
 			}
 			refresh.Reset(refreshPeersInterval)
+		case dests := <-topicresults:
+			srv.dialTopicNodes(dests, topicDialed, dial)
+			refresh.Reset(refreshPeersInterval)
 		case dest := <-dialed:
 			delete(dialing, dest.ID)
 			if len(dialing) == 0 {
@@ -461,6 +581,137 @@ func (srv *Server) dialLoop() {
 	}
 }
 
+// topicPeers returns the number of connected peers which were dialed
+// for a topic. Topic dials which are neither in progress nor connected
+// are forgotten, freeing their reserved slot. The caller must hold
+// srv.lock.
+func (srv *Server) topicPeers(topicDialed, dialing map[discover.NodeID]bool) int {
+	ntopic := 0
+	for id := range topicDialed {
+		if srv.peers[id] != nil {
+			ntopic++
+		} else if !dialing[id] {
+			delete(topicDialed, id)
+		}
+	}
+	return ntopic
+}
+
+// dialTopicNodes dials nodes found by topic lookups until all slots
+// reserved for topic peers are taken.
+func (srv *Server) dialTopicNodes(dests []*discover.Node, topicDialed map[discover.NodeID]bool, dial func(*discover.Node) bool) {
+	for _, dest := range dests {
+		if len(topicDialed) >= srv.TopicSlots {
+			return
+		}
+		if dial(dest) {
+			topicDialed[dest.ID] = true
+		}
+	}
+}
+
+// filterByRecord removes nodes whose node record shows that they
+// don't run any of our protocols on a compatible network. Nodes that
+// don't answer the record request are kept because they might run an
+// older version of the discovery protocol.
+//
+// If the address of a node isn't reachable from the local host, the
+// other address family listed in its record is used instead.
+func (srv *Server) filterByRecord(nodes []*discover.Node) []*discover.Node {
+	result := make([]*discover.Node, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *discover.Node) {
+			defer wg.Done()
+			r, err := srv.ntab.RequestENR(n)
+			if err != nil {
+				result[i] = n
+				return
+			}
+			if !srv.acceptRecord(r) {
+				glog.V(logger.Detail).Infof("Skipping %v: node record not acceptable", n)
+				return
+			}
+			if result[i] = srv.reachableNode(n, r); result[i] == nil {
+				glog.V(logger.Detail).Infof("Skipping %v: no reachable address", n)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	filtered := nodes[:0]
+	for _, n := range result {
+		if n != nil {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered
+}
+
+// reachableNode returns n if its IP is reachable. Otherwise it returns
+// a copy of n using a reachable address from the node record, or nil if
+// the record doesn't contain one.
+func (srv *Server) reachableNode(n *discover.Node, r *enr.Record) *discover.Node {
+	if srv.reachable(n.IP) {
+		return n
+	}
+	var (
+		ip4 enr.IP4
+		ip6 enr.IP6
+	)
+	var alts []net.IP
+	if r.Load(&ip4) == nil {
+		alts = append(alts, net.IP(ip4))
+	}
+	if r.Load(&ip6) == nil {
+		alts = append(alts, net.IP(ip6))
+	}
+	for _, ip := range alts {
+		if !ip.Equal(n.IP) && srv.allowed(ip) && srv.reachable(ip) {
+			cpy := *n
+			cpy.IP = ip
+			return &cpy
+		}
+	}
+	return nil
+}
+
+// routeExists reports whether the local host has a route to ip.
+// Connecting a UDP socket doesn't send any packets.
+func routeExists(ip net.IP) bool {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: 9})
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// acceptRecord reports whether the node described by the given record
+// runs at least one of our protocols and passes its NodeFilter.
+func (srv *Server) acceptRecord(r *enr.Record) bool {
+	var caps capsEntry
+	if err := r.Load(&caps); err != nil {
+		// Records without capabilities don't tell us anything.
+		return enr.IsNotFound(err)
+	}
+	for _, p := range srv.Protocols {
+		for _, c := range caps {
+			if c == p.cap() && (p.NodeFilter == nil || p.NodeFilter(r)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowed reports whether connections to or from the given IP are permitted
+// by NetRestrict.
+func (srv *Server) allowed(ip net.IP) bool {
+	return srv.NetRestrict == nil || srv.NetRestrict.Contains(ip)
+}
+
 func (srv *Server) dialNode(dest *discover.Node) {
 	addr := &net.TCPAddr{IP: dest.IP, Port: int(dest.TCP)}
 	glog.V(logger.Debug).Infof("Dialing %v\n", dest)
@@ -493,9 +744,13 @@ func (srv *Server) startPeer(fd net.Conn, dest *discover.Node) {
 			atcap = false
 		}
 	}
+	trusted := make(map[discover.NodeID]bool, len(srv.trustedNodes))
+	for id := range srv.trustedNodes {
+		trusted[id] = true
+	}
 	srv.lock.RUnlock()
 
-	conn, err := srv.setupFunc(fd, srv.PrivateKey, srv.ourHandshake, dest, atcap, srv.trustedNodes)
+	conn, err := srv.setupFunc(fd, srv.PrivateKey, srv.ourHandshake, dest, atcap, trusted)
 	if err != nil {
 		fd.Close()
 		glog.V(logger.Debug).Infof("Handshake with %v failed: %v", fd.RemoteAddr(), err)
@@ -507,6 +762,8 @@ func (srv *Server) startPeer(fd net.Conn, dest *discover.Node) {
 		conn:    fd, rtimeout: frameReadTimeout, wtimeout: frameWriteTimeout,
 	}
 	p := newPeer(fd, conn, srv.Protocols)
+	p.events = srv.EventMux
+	p.rep = srv
 	if ok, reason := srv.addPeer(conn.ID, p); !ok {
 		glog.V(logger.Detail).Infof("Not adding %v (%v)\n", p, reason)
 		p.politeDisconnect(reason)
@@ -529,8 +786,14 @@ func (srv *Server) runPeer(p *Peer) {
 	if srv.newPeerHook != nil {
 		srv.newPeerHook(p)
 	}
+	if srv.EventMux != nil {
+		srv.EventMux.Post(PeerAddEvent{Peer: p})
+	}
 	discreason := p.run()
 	srv.removePeer(p)
+	if srv.EventMux != nil {
+		srv.EventMux.Post(PeerDropEvent{Peer: p, Reason: discreason})
+	}
 	glog.V(logger.Debug).Infof("Removed %v (%v)\n", p, discreason)
 	srvjslog.LogJson(&logger.P2PDisconnected{
 		RemoteId:       p.ID().String(),
@@ -565,6 +828,8 @@ func (srv *Server) checkPeer(id discover.NodeID) (bool, DiscReason) {
 		return false, DiscAlreadyConnected
 	case id == srv.ntab.Self().ID:
 		return false, DiscSelf
+	case !static && !trusted && time.Now().Before(srv.ntab.BannedUntil(id)):
+		return false, DiscUselessPeer
 	default:
 		return true, 0
 	}
@@ -12,11 +12,23 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
 func startTestServer(t *testing.T, pf newPeerHook) *Server {
-	server := &Server{
+	server := newTestServer(pf)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	return server
+}
+
+// newTestServer creates a server which skips the encryption handshake.
+func newTestServer(pf newPeerHook) *Server {
+	return &Server{
 		Name:        "test",
 		MaxPeers:    10,
 		ListenAddr:  "127.0.0.1:0",
@@ -36,10 +48,6 @@ func startTestServer(t *testing.T, pf newPeerHook) *Server {
 			}, nil
 		},
 	}
-	if err := server.Start(); err != nil {
-		t.Fatalf("Could not start server: %v", err)
-	}
-	return server
 }
 
 func TestServerListen(t *testing.T) {
@@ -122,6 +130,177 @@ func TestServerDial(t *testing.T) {
 	}
 }
 
+// Tests that the server can listen and dial on the IPv6 loopback address.
+func TestServerDialIPv6(t *testing.T) {
+	defer testlog(t).detach()
+
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		accepted <- conn
+	}()
+
+	connected := make(chan *Peer, 1)
+	srv := newTestServer(func(p *Peer) { connected <- p })
+	srv.ListenAddr = "[::1]:0"
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	defer srv.Stop()
+	if ip := srv.Self().IP; !ip.Equal(net.IPv6loopback) {
+		t.Errorf("wrong self IP: got %v, want %v", ip, net.IPv6loopback)
+	}
+
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	srv.staticDial <- &discover.Node{IP: tcpAddr.IP, TCP: uint16(tcpAddr.Port)}
+	select {
+	case <-accepted:
+		select {
+		case <-connected:
+		case <-time.After(1 * time.Second):
+			t.Error("server did not launch peer within one second")
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("server did not connect within one second")
+	}
+}
+
+// Tests that the server picks a reachable address from the node record.
+func TestServerReachableNode(t *testing.T) {
+	var (
+		ip4 = net.IP{10, 0, 0, 1}
+		ip6 = net.ParseIP("2001:db8::1")
+		key = newkey()
+	)
+	reachable := func(ips ...net.IP) func(net.IP) bool {
+		return func(ip net.IP) bool {
+			for _, rip := range ips {
+				if rip.Equal(ip) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	n := &discover.Node{IP: ip4, TCP: 30303}
+
+	tests := []struct {
+		r         *enr.Record
+		reachable func(net.IP) bool
+		want      net.IP // nil means the node is dropped
+	}{
+		{newTestRecord(t, key, enr.IP4(ip4), enr.IP6(ip6)), reachable(ip4, ip6), ip4},
+		{newTestRecord(t, key, enr.IP4(ip4), enr.IP6(ip6)), reachable(ip6), ip6},
+		{newTestRecord(t, key, enr.IP4(ip4), enr.IP6(ip6)), reachable(), nil},
+		{newTestRecord(t, key, enr.IP4(ip4)), reachable(ip6), nil},
+	}
+	for i, test := range tests {
+		srv := &Server{reachable: test.reachable}
+		got := srv.reachableNode(n, test.r)
+		switch {
+		case test.want == nil && got != nil:
+			t.Errorf("test %d: got node with IP %v, want nil", i, got.IP)
+		case test.want != nil && got == nil:
+			t.Errorf("test %d: got nil, want node with IP %v", i, test.want)
+		case test.want != nil && !got.IP.Equal(test.want):
+			t.Errorf("test %d: got IP %v, want %v", i, got.IP, test.want)
+		}
+	}
+	if !n.IP.Equal(ip4) {
+		t.Error("reachableNode modified its argument")
+	}
+}
+
+// Tests that the server neither accepts nor dials connections outside of the
+// networks allowed by NetRestrict.
+func TestServerNetRestrict(t *testing.T) {
+	defer testlog(t).detach()
+
+	connected := make(chan *Peer, 2)
+	srv := newTestServer(func(p *Peer) { connected <- p })
+	srv.NetRestrict = new(netutil.Netlist)
+	srv.NetRestrict.Add("10.0.0.0/8")
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	// inbound connections from localhost must be closed right away
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("inbound connection not closed, read error: %v", err)
+	}
+
+	// outbound connections to localhost must not be attempted
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not setup listener: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+			accepted <- conn
+		}
+	}()
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	srv.staticDial <- &discover.Node{ID: randomID(), IP: tcpAddr.IP, TCP: uint16(tcpAddr.Port)}
+
+	select {
+	case <-accepted:
+		t.Error("server dialed a node outside of NetRestrict")
+	case p := <-connected:
+		t.Errorf("server added peer %v outside of NetRestrict", p)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestServerAcceptRecord(t *testing.T) {
+	srv := &Server{Protocols: []Protocol{
+		{Name: "a", Version: 1},
+		{Name: "b", Version: 2, NodeFilter: func(r *enr.Record) bool {
+			var net uint
+			return r.Load(enr.WithEntry("net", &net)) == nil && net == 1
+		}},
+	}}
+	key := newkey()
+
+	tests := []struct {
+		r    *enr.Record
+		want bool
+	}{
+		{newTestRecord(t, key), true}, // no caps, could be anything
+		{newTestRecord(t, key, capsEntry{{"a", 1}}), true},
+		{newTestRecord(t, key, capsEntry{{"a", 2}}), false},
+		{newTestRecord(t, key, capsEntry{{"c", 1}}), false},
+		{newTestRecord(t, key, capsEntry{{"b", 2}}), false},
+		{newTestRecord(t, key, capsEntry{{"b", 2}}, enr.WithEntry("net", uint(2))), false},
+		{newTestRecord(t, key, capsEntry{{"b", 2}}, enr.WithEntry("net", uint(1))), true},
+		{newTestRecord(t, key, capsEntry{{"a", 1}, {"b", 2}}, enr.WithEntry("net", uint(2))), true},
+		{newTestRecord(t, key, enr.WithEntry("caps", "invalid")), false},
+	}
+	for i, test := range tests {
+		if got := srv.acceptRecord(test.r); got != test.want {
+			t.Errorf("test %d: got %t, want %t", i, got, test.want)
+		}
+	}
+}
+
 func TestServerBroadcast(t *testing.T) {
 	defer testlog(t).detach()
 
@@ -369,6 +548,155 @@ func TestServerTrustedPeers(t *testing.T) {
 	}
 }
 
+// Tests that trusted peers can be added at runtime and are then allowed to
+// connect above max peer caps.
+func TestServerAddTrustedPeer(t *testing.T) {
+	defer testlog(t).detach()
+
+	// Create a test server with limited connection slots
+	started := make(chan *Peer)
+	server := &Server{
+		ListenAddr:  "127.0.0.1:0",
+		PrivateKey:  newkey(),
+		MaxPeers:    3,
+		NoDial:      true,
+		newPeerHook: func(p *Peer) { started <- p },
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// Fill up all the slots on the server
+	dialer := &net.Dialer{Deadline: time.Now().Add(3 * time.Second)}
+	for i := 0; i < server.MaxPeers; i++ {
+		conn, err := dialer.Dial("tcp", server.ListenAddr)
+		if err != nil {
+			t.Fatalf("conn %d: dial error: %v", i, err)
+		}
+		defer conn.Close()
+
+		key := newkey()
+		shake := &protoHandshake{Version: baseProtocolVersion, ID: discover.PubkeyID(&key.PublicKey)}
+		if _, err = setupConn(conn, key, shake, server.Self(), false, nil); err != nil {
+			t.Fatalf("conn %d: unexpected error: %v", i, err)
+		}
+		<-started
+	}
+	// Trust a new node and ensure its connection is accepted
+	key := newkey()
+	trusted := &discover.Node{ID: discover.PubkeyID(&key.PublicKey)}
+	server.AddTrustedPeer(trusted)
+
+	conn, err := dialer.Dial("tcp", server.ListenAddr)
+	if err != nil {
+		t.Fatalf("trusted node: dial error: %v", err)
+	}
+	defer conn.Close()
+
+	shake := &protoHandshake{Version: baseProtocolVersion, ID: trusted.ID}
+	if _, err = setupConn(conn, key, shake, server.Self(), false, nil); err != nil {
+		t.Fatalf("trusted node: unexpected error: %v", err)
+	}
+	select {
+	case <-started:
+		// Ok, trusted peer accepted
+
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("trusted node timeout")
+	}
+	// Revoke the trust and ensure it's gone
+	server.RemoveTrustedPeer(trusted)
+
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	if server.trustedNodes[trusted.ID] {
+		t.Errorf("trusted node not removed")
+	}
+}
+
+// Tests that peer additions and drops are posted on the event mux, and that
+// RemovePeer disconnects the peer.
+func TestServerPeerEvents(t *testing.T) {
+	defer testlog(t).detach()
+
+	mux := new(event.TypeMux)
+	defer mux.Stop()
+	sub := mux.Subscribe(PeerAddEvent{}, PeerDropEvent{})
+	defer sub.Unsubscribe()
+
+	server := &Server{
+		ListenAddr: "127.0.0.1:0",
+		PrivateKey: newkey(),
+		MaxPeers:   3,
+		NoDial:     true,
+		EventMux:   mux,
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// Connect a peer and wait for it to be announced
+	conn, err := net.DialTimeout("tcp", server.ListenAddr, 3*time.Second)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer conn.Close()
+
+	key := newkey()
+	node := &discover.Node{ID: discover.PubkeyID(&key.PublicKey)}
+	shake := &protoHandshake{Version: baseProtocolVersion, ID: node.ID}
+	if _, err = setupConn(conn, key, shake, server.Self(), false, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case ev := <-sub.Chan():
+		add, ok := ev.(PeerAddEvent)
+		if !ok {
+			t.Fatalf("event type mismatch: have %T, want PeerAddEvent", ev)
+		}
+		if add.Peer.ID() != node.ID {
+			t.Fatalf("added peer mismatch: have %x, want %x", add.Peer.ID(), node.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer add event timeout")
+	}
+	// Remove the peer and wait for the drop
+	server.RemovePeer(node)
+
+	select {
+	case ev := <-sub.Chan():
+		drop, ok := ev.(PeerDropEvent)
+		if !ok {
+			t.Fatalf("event type mismatch: have %T, want PeerDropEvent", ev)
+		}
+		if drop.Peer.ID() != node.ID {
+			t.Errorf("dropped peer mismatch: have %x, want %x", drop.Peer.ID(), node.ID)
+		}
+		if drop.Reason != DiscRequested {
+			t.Errorf("drop reason mismatch: have %v, want %v", drop.Reason, DiscRequested)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("peer drop event timeout")
+	}
+	if n := server.PeerCount(); n != 0 {
+		t.Errorf("peer count mismatch: have %d, want 0", n)
+	}
+}
+
+// newTestRecord creates a node record holding the given entries, signed by key.
+func newTestRecord(t *testing.T, key *ecdsa.PrivateKey, entries ...enr.Entry) *enr.Record {
+	var r enr.Record
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := r.SignV4(key); err != nil {
+		t.Fatal(err)
+	}
+	return &r
+}
+
 func newkey() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
@@ -383,3 +711,114 @@ func randomID() (id discover.NodeID) {
 	}
 	return id
 }
+
+// Tests that peers with bad reputation are disconnected and banned.
+func TestServerReputation(t *testing.T) {
+	defer testlog(t).detach()
+
+	started := make(chan *Peer)
+	server := &Server{
+		ListenAddr:  "127.0.0.1:0",
+		PrivateKey:  newkey(),
+		MaxPeers:    3,
+		NoDial:      true,
+		BanDuration: time.Minute,
+		newPeerHook: func(p *Peer) { started <- p },
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, err := net.DialTimeout("tcp", server.ListenAddr, 3*time.Second)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer conn.Close()
+
+	key := newkey()
+	shake := &protoHandshake{Version: baseProtocolVersion, ID: discover.PubkeyID(&key.PublicKey)}
+	if _, err = setupConn(conn, key, shake, server.Self(), false, nil); err != nil {
+		t.Fatalf("unexpected handshake error: %v", err)
+	}
+	peer := <-started
+
+	// Useful deliveries raise the reputation.
+	peer.Report(UsefulDelivery)
+	if rep := peer.Reputation(); rep != 1 {
+		t.Errorf("reputation mismatch: have %d, want 1", rep)
+	}
+	// Enough misbehaviour gets the peer banned.
+	for i := 0; i < 5; i++ {
+		if peer.Report(ProtocolError) {
+			break
+		}
+		if i == 4 {
+			t.Fatal("peer not banned after 5 protocol errors")
+		}
+	}
+	if !server.Banned(peer.ID()) {
+		t.Error("peer not reported as banned")
+	}
+	if rep := peer.Reputation(); rep != 0 {
+		t.Errorf("reputation not reset after ban: have %d", rep)
+	}
+	// The banned peer should be disconnected.
+	deadline := time.Now().Add(3 * time.Second)
+	for server.PeerCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("banned peer not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// It must not be allowed to connect again.
+	server.lock.RLock()
+	ok, reason := server.checkPeer(peer.ID())
+	server.lock.RUnlock()
+	if ok || reason != DiscUselessPeer {
+		t.Errorf("checkPeer mismatch for banned peer: have (%t, %v), want (false, %v)", ok, reason, DiscUselessPeer)
+	}
+}
+
+// Tests the accounting of the outbound slots reserved for topic peers.
+func TestServerTopicSlots(t *testing.T) {
+	srv := &Server{MaxPeers: 10, TopicSlots: 2, peers: make(map[discover.NodeID]*Peer)}
+	var (
+		connected = randomID()
+		pending   = randomID()
+		failed    = randomID()
+		dialing   = map[discover.NodeID]bool{pending: true}
+		dialed    = map[discover.NodeID]bool{connected: true, pending: true, failed: true}
+	)
+	srv.peers[connected] = &Peer{}
+	srv.peers[randomID()] = &Peer{}
+
+	// Failed topic dials free their slot, pending ones keep it.
+	if n := srv.topicPeers(dialed, dialing); n != 1 {
+		t.Errorf("topic peer count mismatch: have %d, want 1", n)
+	}
+	if dialed[failed] || !dialed[pending] || !dialed[connected] {
+		t.Errorf("wrong topic dials kept: %v", dialed)
+	}
+	// Only the free slots are filled with topic nodes.
+	var (
+		nodes    = []*discover.Node{{ID: randomID()}, {ID: randomID()}, {ID: randomID()}}
+		attempts []discover.NodeID
+	)
+	srv.dialTopicNodes(nodes, dialed, func(n *discover.Node) bool {
+		attempts = append(attempts, n.ID)
+		return true
+	})
+	if len(attempts) != 0 {
+		t.Errorf("dialed topic nodes with all slots taken: %v", attempts)
+	}
+	delete(dialed, pending)
+	srv.dialTopicNodes(nodes, dialed, func(n *discover.Node) bool {
+		attempts = append(attempts, n.ID)
+		return n.ID != nodes[0].ID
+	})
+	if len(attempts) != 2 || !dialed[nodes[1].ID] || dialed[nodes[0].ID] {
+		t.Errorf("topic slot filled wrongly: attempts %v, dialed %v", attempts, dialed)
+	}
+}
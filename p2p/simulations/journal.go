@@ -0,0 +1,112 @@
+package simulations
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// Journal records the events of a network so tests can wait for and assert
+// on them.
+type Journal struct {
+	sub event.Subscription
+
+	lock    sync.Mutex
+	events  []interface{}
+	changed chan struct{} // closed and replaced on every new event
+}
+
+// NewJournal starts recording the ConnEvents and MsgEvents of a network.
+func NewJournal(net *Network) *Journal {
+	j := &Journal{
+		sub:     net.Events().Subscribe(ConnEvent{}, MsgEvent{}),
+		changed: make(chan struct{}),
+	}
+	go j.loop()
+	return j
+}
+
+func (j *Journal) loop() {
+	for ev := range j.sub.Chan() {
+		j.lock.Lock()
+		j.events = append(j.events, ev)
+		close(j.changed)
+		j.changed = make(chan struct{})
+		j.lock.Unlock()
+	}
+}
+
+// Close stops recording.
+func (j *Journal) Close() {
+	j.sub.Unsubscribe()
+}
+
+// Events returns the events recorded so far, in order.
+func (j *Journal) Events() []interface{} {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return append([]interface{}(nil), j.events...)
+}
+
+// Count returns the number of recorded events accepted by match.
+func (j *Journal) Count(match func(interface{}) bool) int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.count(match)
+}
+
+func (j *Journal) count(match func(interface{}) bool) int {
+	n := 0
+	for _, ev := range j.events {
+		if match(ev) {
+			n++
+		}
+	}
+	return n
+}
+
+// WaitFor blocks until at least n recorded events are accepted by match, or
+// the timeout expires.
+func (j *Journal) WaitFor(n int, timeout time.Duration, match func(interface{}) bool) error {
+	deadline := time.After(timeout)
+	for {
+		j.lock.Lock()
+		have, changed := j.count(match), j.changed
+		j.lock.Unlock()
+
+		if have >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return fmt.Errorf("timeout: have %d matching events, want %d", have, n)
+		}
+	}
+}
+
+// IsMsg returns a matcher accepting the MsgEvents of a protocol message sent
+// from one node to another.
+func IsMsg(from, to discover.NodeID, protocol string, code uint64) func(interface{}) bool {
+	return func(ev interface{}) bool {
+		msg, ok := ev.(MsgEvent)
+		return ok && msg.From == from && msg.To == to && msg.Protocol == protocol && msg.Code == code
+	}
+}
+
+// IsConn returns a matcher accepting the ConnEvents of two nodes connecting
+// (up) or disconnecting, in either direction.
+func IsConn(one, other discover.NodeID, up bool) func(interface{}) bool {
+	return func(ev interface{}) bool {
+		conn, ok := ev.(ConnEvent)
+		if !ok || conn.Up != up {
+			return false
+		}
+		return (conn.One == one && conn.Other == other) || (conn.One == other && conn.Other == one)
+	}
+}
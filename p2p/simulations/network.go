@@ -0,0 +1,334 @@
+// Package simulations runs devp2p protocols between nodes of a single process.
+// The nodes are connected by message pipes instead of TCP connections, so
+// multi-peer protocol behaviour can be tested without any networking, under
+// full control of the network topology.
+package simulations
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+var (
+	errUnknownNode      = errors.New("unknown node")
+	errSelfConnect      = errors.New("node cannot connect to itself")
+	errAlreadyConnected = errors.New("nodes already connected")
+	errNotConnected     = errors.New("nodes not connected")
+	errPartitioned      = errors.New("nodes are partitioned")
+	errShutdown         = errors.New("network shut down")
+)
+
+// ConnEvent is posted when a connection between two nodes is established
+// (Up) or torn down. Reason holds the disconnect reason of the side that
+// terminated the connection.
+type ConnEvent struct {
+	One, Other discover.NodeID
+	Up         bool
+	Reason     p2p.DiscReason
+}
+
+// MsgEvent is posted when a subprotocol message has been delivered from one
+// node to another. Code is relative to the protocol.
+type MsgEvent struct {
+	From, To discover.NodeID
+	Protocol string
+	Code     uint64
+	Size     uint32
+}
+
+// Node is a simulated node running a set of protocols.
+type Node struct {
+	ID        discover.NodeID
+	Name      string
+	Protocols []p2p.Protocol
+
+	mux *event.TypeMux // receives the message events of the node's peers
+}
+
+func (n *Node) caps() []p2p.Cap {
+	caps := make([]p2p.Cap, len(n.Protocols))
+	for i, proto := range n.Protocols {
+		caps[i] = p2p.Cap{Name: proto.Name, Version: proto.Version}
+	}
+	return caps
+}
+
+// connKey identifies the connection between two nodes regardless of which of
+// them initiated it.
+type connKey [2]discover.NodeID
+
+func newConnKey(one, other discover.NodeID) connKey {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return connKey{one, other}
+}
+
+// conn is a live connection, holding the peer objects on both ends.
+type conn struct {
+	one, other *Node
+	peers      [2]*p2p.Peer             // other as seen by one, one as seen by other
+	ends       [2]<-chan p2p.DiscReason // disconnect reasons of the peers
+	done       chan struct{}
+}
+
+// Network is a set of simulated nodes and the connections between them.
+type Network struct {
+	events *event.TypeMux
+
+	lock     sync.RWMutex
+	nodes    map[discover.NodeID]*Node
+	conns    map[connKey]*conn
+	cut      map[connKey]bool // pairs that may not connect during a partition
+	shutdown bool
+
+	wg sync.WaitGroup // connection and event loops
+}
+
+// NewNetwork creates an empty network.
+func NewNetwork() *Network {
+	return &Network{
+		events: new(event.TypeMux),
+		nodes:  make(map[discover.NodeID]*Node),
+		conns:  make(map[connKey]*conn),
+		cut:    make(map[connKey]bool),
+	}
+}
+
+// Events returns the mux on which ConnEvents and MsgEvents are posted.
+func (self *Network) Events() *event.TypeMux {
+	return self.events
+}
+
+// NewNode creates a node with a random identity which runs the given
+// protocols with every node it is connected to.
+func (self *Network) NewNode(name string, protocols ...p2p.Protocol) *Node {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic("couldn't generate key: " + err.Error())
+	}
+	node := &Node{
+		ID:        discover.PubkeyID(&key.PublicKey),
+		Name:      name,
+		Protocols: protocols,
+		mux:       new(event.TypeMux),
+	}
+	self.lock.Lock()
+	self.nodes[node.ID] = node
+	self.lock.Unlock()
+
+	self.wg.Add(1)
+	go self.msgLoop(node, node.mux.Subscribe(p2p.PeerMsgEvent{}))
+
+	return node
+}
+
+// msgLoop translates the messages sent by the peers of a node into network
+// wide MsgEvents. Only outgoing messages are reported, as each of them is
+// also seen as incoming by the remote node.
+func (self *Network) msgLoop(node *Node, sub event.Subscription) {
+	defer self.wg.Done()
+
+	for ev := range sub.Chan() {
+		if msg := ev.(p2p.PeerMsgEvent); !msg.Incoming {
+			self.events.Post(MsgEvent{
+				From:     node.ID,
+				To:       msg.Peer.ID(),
+				Protocol: msg.Protocol,
+				Code:     msg.Code,
+				Size:     msg.Size,
+			})
+		}
+	}
+}
+
+// Node returns the node with the given id, or nil if it doesn't exist.
+func (self *Network) Node(id discover.NodeID) *Node {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.nodes[id]
+}
+
+// Nodes returns all nodes of the network.
+func (self *Network) Nodes() []*Node {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	nodes := make([]*Node, 0, len(self.nodes))
+	for _, node := range self.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Connected returns whether there is a live connection between two nodes.
+func (self *Network) Connected(one, other discover.NodeID) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.conns[newConnKey(one, other)] != nil
+}
+
+// Peers returns the ids of the nodes a node is connected to.
+func (self *Network) Peers(id discover.NodeID) []discover.NodeID {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	var peers []discover.NodeID
+	for key := range self.conns {
+		switch id {
+		case key[0]:
+			peers = append(peers, key[1])
+		case key[1]:
+			peers = append(peers, key[0])
+		}
+	}
+	return peers
+}
+
+// Connect connects two nodes and starts their common protocols on both ends.
+func (self *Network) Connect(one, other discover.NodeID) error {
+	c, err := self.connect(one, other)
+	if err != nil {
+		return err
+	}
+	glog.V(logger.Detail).Infof("sim: connected %x <-> %x\n", one[:4], other[:4])
+	self.events.Post(ConnEvent{One: one, Other: other, Up: true})
+
+	self.wg.Add(1)
+	go self.connLoop(newConnKey(one, other), c)
+
+	return nil
+}
+
+func (self *Network) connect(one, other discover.NodeID) (*conn, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.shutdown {
+		return nil, errShutdown
+	}
+	n1, n2 := self.nodes[one], self.nodes[other]
+	if n1 == nil || n2 == nil {
+		return nil, errUnknownNode
+	}
+	if one == other {
+		return nil, errSelfConnect
+	}
+	key := newConnKey(one, other)
+	if self.conns[key] != nil {
+		return nil, errAlreadyConnected
+	}
+	if self.cut[key] {
+		return nil, errPartitioned
+	}
+	rw1, rw2 := msgPipe()
+	c := &conn{one: n1, other: n2, done: make(chan struct{})}
+	c.peers[0], c.ends[0] = p2p.NewPipePeer(n2.ID, n2.Name, n2.caps(), rw1, n1.Protocols, n1.mux)
+	c.peers[1], c.ends[1] = p2p.NewPipePeer(n1.ID, n1.Name, n1.caps(), rw2, n2.Protocols, n2.mux)
+	self.conns[key] = c
+
+	return c, nil
+}
+
+// connLoop waits for both ends of a connection to terminate and removes it.
+func (self *Network) connLoop(key connKey, c *conn) {
+	defer self.wg.Done()
+
+	// The remote end of the side that terminated sees a disconnect request,
+	// so report the other reason if there's any.
+	reason := <-c.ends[0]
+	if other := <-c.ends[1]; reason == p2p.DiscRequested {
+		reason = other
+	}
+	self.lock.Lock()
+	if self.conns[key] == c {
+		delete(self.conns, key)
+	}
+	self.lock.Unlock()
+
+	glog.V(logger.Detail).Infof("sim: disconnected %x <-> %x (%v)\n", c.one.ID[:4], c.other.ID[:4], reason)
+	self.events.Post(ConnEvent{One: c.one.ID, Other: c.other.ID, Reason: reason})
+	close(c.done)
+}
+
+// Disconnect tears down the connection between two nodes, returning once
+// both ends have stopped.
+func (self *Network) Disconnect(one, other discover.NodeID) error {
+	self.lock.RLock()
+	c := self.conns[newConnKey(one, other)]
+	self.lock.RUnlock()
+
+	if c == nil {
+		return errNotConnected
+	}
+	self.disconnect(c)
+	return nil
+}
+
+func (self *Network) disconnect(c *conn) {
+	c.peers[0].Disconnect(p2p.DiscRequested)
+	<-c.done
+}
+
+// Partition splits the network between two groups of nodes: all connections
+// between the groups are torn down and no new ones can be made until Heal is
+// called. Connections within each group are unaffected.
+func (self *Network) Partition(group1, group2 []discover.NodeID) {
+	var drop []*conn
+
+	self.lock.Lock()
+	for _, one := range group1 {
+		for _, other := range group2 {
+			key := newConnKey(one, other)
+			self.cut[key] = true
+			if c := self.conns[key]; c != nil {
+				drop = append(drop, c)
+			}
+		}
+	}
+	self.lock.Unlock()
+
+	for _, c := range drop {
+		self.disconnect(c)
+	}
+}
+
+// Heal lifts all partitions, allowing any two nodes to connect again. It does
+// not restore the connections dropped by Partition.
+func (self *Network) Heal() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.cut = make(map[connKey]bool)
+}
+
+// Shutdown disconnects all nodes and stops the network. Events are not posted
+// anymore afterwards.
+func (self *Network) Shutdown() {
+	self.lock.Lock()
+	self.shutdown = true
+	conns := make([]*conn, 0, len(self.conns))
+	for _, c := range self.conns {
+		conns = append(conns, c)
+	}
+	self.lock.Unlock()
+
+	for _, c := range conns {
+		self.disconnect(c)
+	}
+	for _, node := range self.Nodes() {
+		node.mux.Stop()
+	}
+	self.wg.Wait()
+	self.events.Stop()
+}
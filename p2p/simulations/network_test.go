@@ -0,0 +1,201 @@
+package simulations
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+const (
+	pingMsg = 0x00
+	pongMsg = 0x01
+)
+
+// pingPong is a protocol which sends a ping on start and answers every ping
+// with a pong.
+var pingPong = p2p.Protocol{
+	Name:    "pingpong",
+	Version: 1,
+	Length:  2,
+	Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+		if err := p2p.SendItems(rw, pingMsg); err != nil {
+			return err
+		}
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			msg.Discard()
+			if msg.Code == pingMsg {
+				if err := p2p.SendItems(rw, pongMsg); err != nil {
+					return err
+				}
+			}
+		}
+	},
+}
+
+// newTestNetwork creates a network with n pingpong nodes.
+func newTestNetwork(n int) (*Network, *Journal, []discover.NodeID) {
+	net := NewNetwork()
+	journal := NewJournal(net)
+
+	ids := make([]discover.NodeID, n)
+	for i := range ids {
+		ids[i] = net.NewNode("test", pingPong).ID
+	}
+	return net, journal, ids
+}
+
+// Tests that connected nodes run their protocols and that the delivered
+// messages are reported.
+func TestConnectMessages(t *testing.T) {
+	net, journal, ids := newTestNetwork(3)
+	defer net.Shutdown()
+	defer journal.Close()
+
+	// Connect the nodes in a line: 0 - 1 - 2
+	for i := 0; i < 2; i++ {
+		if err := net.Connect(ids[i], ids[i+1]); err != nil {
+			t.Fatalf("connect %d-%d failed: %v", i, i+1, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		for _, pair := range [][2]discover.NodeID{{ids[i], ids[i+1]}, {ids[i+1], ids[i]}} {
+			if err := journal.WaitFor(1, time.Second, IsMsg(pair[0], pair[1], "pingpong", pingMsg)); err != nil {
+				t.Errorf("ping %x -> %x: %v", pair[0][:4], pair[1][:4], err)
+			}
+			if err := journal.WaitFor(1, time.Second, IsMsg(pair[1], pair[0], "pingpong", pongMsg)); err != nil {
+				t.Errorf("pong %x -> %x: %v", pair[1][:4], pair[0][:4], err)
+			}
+		}
+	}
+	if n := journal.Count(IsMsg(ids[0], ids[2], "pingpong", pingMsg)); n != 0 {
+		t.Errorf("unconnected nodes exchanged %d messages", n)
+	}
+	if peers := net.Peers(ids[1]); len(peers) != 2 {
+		t.Errorf("peer count mismatch: have %d, want 2", len(peers))
+	}
+}
+
+// Tests that invalid connection requests are rejected.
+func TestConnectErrors(t *testing.T) {
+	net, journal, ids := newTestNetwork(2)
+	defer net.Shutdown()
+	defer journal.Close()
+
+	if err := net.Connect(ids[0], ids[0]); err != errSelfConnect {
+		t.Errorf("self connect error mismatch: have %v, want %v", err, errSelfConnect)
+	}
+	if err := net.Connect(ids[0], discover.NodeID{}); err != errUnknownNode {
+		t.Errorf("unknown node error mismatch: have %v, want %v", err, errUnknownNode)
+	}
+	if err := net.Connect(ids[0], ids[1]); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := net.Connect(ids[1], ids[0]); err != errAlreadyConnected {
+		t.Errorf("duplicate connect error mismatch: have %v, want %v", err, errAlreadyConnected)
+	}
+}
+
+// Tests that disconnecting tears down both ends of a connection.
+func TestDisconnect(t *testing.T) {
+	net, journal, ids := newTestNetwork(2)
+	defer net.Shutdown()
+	defer journal.Close()
+
+	if err := net.Connect(ids[0], ids[1]); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := net.Disconnect(ids[1], ids[0]); err != nil {
+		t.Fatalf("disconnect failed: %v", err)
+	}
+	if net.Connected(ids[0], ids[1]) {
+		t.Errorf("nodes still connected")
+	}
+	if err := journal.WaitFor(1, time.Second, IsConn(ids[0], ids[1], false)); err != nil {
+		t.Fatalf("disconnect event: %v", err)
+	}
+	if err := net.Disconnect(ids[0], ids[1]); err != errNotConnected {
+		t.Errorf("repeated disconnect error mismatch: have %v, want %v", err, errNotConnected)
+	}
+	// Reconnecting should work after a clean disconnect
+	if err := net.Connect(ids[0], ids[1]); err != nil {
+		t.Fatalf("reconnect failed: %v", err)
+	}
+	if err := journal.WaitFor(2, time.Second, IsConn(ids[0], ids[1], true)); err != nil {
+		t.Errorf("reconnect event: %v", err)
+	}
+}
+
+// Tests that a protocol failing on one side drops the connection, reporting
+// the reason of the failing side.
+func TestProtocolError(t *testing.T) {
+	failing := p2p.Protocol{
+		Name:    "failing",
+		Version: 1,
+		Length:  1,
+		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+			if peer.Name() == "other" {
+				return errors.New("failure")
+			}
+			_, err := rw.ReadMsg()
+			return err
+		},
+	}
+	net := NewNetwork()
+	defer net.Shutdown()
+	journal := NewJournal(net)
+	defer journal.Close()
+
+	one, other := net.NewNode("one", failing), net.NewNode("other", failing)
+	if err := net.Connect(one.ID, other.ID); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if err := journal.WaitFor(1, time.Second, IsConn(one.ID, other.ID, false)); err != nil {
+		t.Fatalf("disconnect event: %v", err)
+	}
+	for _, ev := range journal.Events() {
+		if conn, ok := ev.(ConnEvent); ok && !conn.Up && conn.Reason != p2p.DiscSubprotocolError {
+			t.Errorf("disconnect reason mismatch: have %v, want %v", conn.Reason, p2p.DiscSubprotocolError)
+		}
+	}
+}
+
+// Tests that partitions cut the connections between the groups only, and
+// prevent reconnecting until healed.
+func TestPartition(t *testing.T) {
+	net, journal, ids := newTestNetwork(4)
+	defer net.Shutdown()
+	defer journal.Close()
+
+	// Fully connect the network
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			if err := net.Connect(ids[i], ids[j]); err != nil {
+				t.Fatalf("connect %d-%d failed: %v", i, j, err)
+			}
+		}
+	}
+	net.Partition(ids[:2], ids[2:])
+
+	for i := 0; i < len(ids); i++ {
+		for j := i + 1; j < len(ids); j++ {
+			want := (i < 2) == (j < 2)
+			if have := net.Connected(ids[i], ids[j]); have != want {
+				t.Errorf("connection %d-%d mismatch: have %v, want %v", i, j, have, want)
+			}
+		}
+	}
+	if err := net.Connect(ids[0], ids[3]); err != errPartitioned {
+		t.Errorf("partitioned connect error mismatch: have %v, want %v", err, errPartitioned)
+	}
+	net.Heal()
+	if err := net.Connect(ids[0], ids[3]); err != nil {
+		t.Errorf("connect after heal failed: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package simulations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// msgPipe creates a message pipe which, unlike p2p.MsgPipe, buffers the sent
+// messages. Writes never wait for the reader, just like on a TCP connection,
+// so protocols writing to each other at the same time cannot deadlock.
+func msgPipe() (*pipeEnd, *pipeEnd) {
+	var (
+		q1, q2  = newMsgQueue(), newMsgQueue()
+		closing = make(chan struct{})
+		once    = new(sync.Once)
+	)
+	return &pipeEnd{q1, q2, closing, once}, &pipeEnd{q2, q1, closing, once}
+}
+
+// pipeEnd is one end of a buffered message pipe.
+type pipeEnd struct {
+	in, out *msgQueue
+	closing chan struct{}
+	once    *sync.Once
+}
+
+// WriteMsg queues a message for the other end, reading its payload.
+func (p *pipeEnd) WriteMsg(msg p2p.Msg) error {
+	select {
+	case <-p.closing:
+		return p2p.ErrPipeClosed
+	default:
+	}
+	var payload []byte
+	if msg.Payload != nil {
+		var err error
+		if payload, err = ioutil.ReadAll(msg.Payload); err != nil {
+			return err
+		}
+	}
+	msg.Payload = bytes.NewReader(payload)
+	msg.Size = uint32(len(payload))
+	p.out.push(msg)
+	return nil
+}
+
+// ReadMsg returns the next message sent from the other end.
+func (p *pipeEnd) ReadMsg() (p2p.Msg, error) {
+	for {
+		if msg, ok := p.in.pop(); ok {
+			return msg, nil
+		}
+		select {
+		case <-p.in.notify:
+		case <-p.closing:
+			return p2p.Msg{}, p2p.ErrPipeClosed
+		}
+	}
+}
+
+// Close unblocks any pending ReadMsg calls on both ends of the pipe. All
+// further operations return p2p.ErrPipeClosed.
+func (p *pipeEnd) Close() error {
+	p.once.Do(func() { close(p.closing) })
+	return nil
+}
+
+// msgQueue is an unbounded message queue.
+type msgQueue struct {
+	lock   sync.Mutex
+	msgs   []p2p.Msg
+	notify chan struct{} // signaled when a message is pushed
+}
+
+func newMsgQueue() *msgQueue {
+	return &msgQueue{notify: make(chan struct{}, 1)}
+}
+
+func (q *msgQueue) push(msg p2p.Msg) {
+	q.lock.Lock()
+	q.msgs = append(q.msgs, msg)
+	q.lock.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *msgQueue) pop() (p2p.Msg, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.msgs) == 0 {
+		return p2p.Msg{}, false
+	}
+	msg := q.msgs[0]
+	q.msgs = q.msgs[1:]
+	return msg, true
+}
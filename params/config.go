@@ -0,0 +1,48 @@
+package params
+
+import "math/big"
+
+// DefaultChainConfig holds the rules of the main network, which has no
+// protocol upgrades scheduled.
+var DefaultChainConfig = &ChainConfig{}
+
+// ChainConfig is the core config which determines the blockchain rules. Each
+// upgrade activates at the given block number and stays active for all later
+// blocks; a nil block number means the upgrade is never activated.
+type ChainConfig struct {
+	// HomesteadBlock switches to the homestead difficulty adjustment, which
+	// scales with the block time instead of stepping by a fixed amount, and
+	// enables the DELEGATECALL opcode.
+	HomesteadBlock *big.Int `json:"homesteadBlock"`
+
+	// GasRepriceBlock switches to GasTableRepriced, raising the cost of the
+	// state accessing operations.
+	GasRepriceBlock *big.Int `json:"gasRepriceBlock"`
+}
+
+// IsHomestead returns whether num is either equal to the homestead block or
+// greater.
+func (c *ChainConfig) IsHomestead(num *big.Int) bool {
+	return isForked(c.HomesteadBlock, num)
+}
+
+// IsGasReprice returns whether num is either equal to the gas reprice block or
+// greater.
+func (c *ChainConfig) IsGasReprice(num *big.Int) bool {
+	return isForked(c.GasRepriceBlock, num)
+}
+
+// GasTable returns the gas schedule in effect at block num.
+func (c *ChainConfig) GasTable(num *big.Int) GasTable {
+	if c.IsGasReprice(num) {
+		return GasTableRepriced
+	}
+	return GasTableFrontier
+}
+
+func isForked(fork, num *big.Int) bool {
+	if fork == nil || num == nil {
+		return false
+	}
+	return fork.Cmp(num) <= 0
+}
@@ -0,0 +1,38 @@
+package params
+
+import "math/big"
+
+// GasTable holds the gas prices of the operations whose cost changes between
+// protocol upgrades.
+type GasTable struct {
+	ExtcodeSize *big.Int // Once per EXTCODESIZE operation.
+	ExtcodeCopy *big.Int // Once per EXTCODECOPY operation, besides the copy costs.
+	Balance     *big.Int // Once per BALANCE operation.
+	SLoad       *big.Int // Once per SLOAD operation.
+	Calls       *big.Int // Once per CALL, CALLCODE and DELEGATECALL operation.
+	Suicide     *big.Int // Once per SUICIDE operation.
+}
+
+var (
+	// GasTableFrontier contains the gas prices of the initial release.
+	GasTableFrontier = GasTable{
+		ExtcodeSize: big.NewInt(20),
+		ExtcodeCopy: big.NewInt(20),
+		Balance:     big.NewInt(20),
+		SLoad:       SloadGas,
+		Calls:       CallGas,
+		Suicide:     big.NewInt(0),
+	}
+
+	// GasTableRepriced contains the gas prices after the repricing of the
+	// operations that access the state, which were too cheap for the IO load
+	// they cause.
+	GasTableRepriced = GasTable{
+		ExtcodeSize: big.NewInt(700),
+		ExtcodeCopy: big.NewInt(700),
+		Balance:     big.NewInt(400),
+		SLoad:       big.NewInt(200),
+		Calls:       big.NewInt(700),
+		Suicide:     big.NewInt(5000),
+	}
+)
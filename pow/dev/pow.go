@@ -0,0 +1,22 @@
+// Package dev implements a proof-of-work which requires no work at all. It is
+// meant for private development chains where blocks are sealed instantly.
+package dev
+
+import "github.com/ethereum/go-ethereum/pow"
+
+type DevPow struct{}
+
+func New() *DevPow {
+	return &DevPow{}
+}
+
+// Search returns immediately, any nonce is acceptable.
+func (pow *DevPow) Search(block pow.Block, stop <-chan struct{}) (uint64, []byte) {
+	return block.Nonce() + 1, make([]byte, 32)
+}
+
+// Verify accepts every block.
+func (pow *DevPow) Verify(block pow.Block) bool { return true }
+
+func (pow *DevPow) GetHashrate() int64 { return 0 }
+func (pow *DevPow) Turbo(bool)         {}
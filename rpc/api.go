@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
 	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/xeth"
 )
 
@@ -56,6 +58,46 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 		*reply = api.xeth().IsListening()
 	case "net_peerCount":
 		*reply = newHexNum(api.xeth().PeerCount())
+	case "admin_addPeer", "admin_removePeer", "admin_addTrustedPeer", "admin_removeTrustedPeer":
+		args := new(NodeURLArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		var err error
+		switch req.Method {
+		case "admin_addPeer":
+			err = api.xeth().AddPeer(args.URL)
+		case "admin_removePeer":
+			err = api.xeth().RemovePeer(args.URL)
+		case "admin_addTrustedPeer":
+			err = api.xeth().AddTrustedPeer(args.URL)
+		case "admin_removeTrustedPeer":
+			err = api.xeth().RemoveTrustedPeer(args.URL)
+		}
+		if err != nil {
+			return NewValidationError("url", err.Error())
+		}
+		*reply = true
+	case "admin_newPeerEventFilter":
+		args := new(PeerEventFilterArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = newHexNum(api.xeth().NewPeerEventFilter(args.Messages))
+	case "admin_getPeerEventChanges":
+		args := new(FilterIdArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.xeth().PeerEventsChanged(args.Id)
+	case "admin_uninstallPeerEventFilter":
+		args := new(FilterIdArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.xeth().UninstallPeerEventFilter(args.Id)
+	case "debug_metrics":
+		*reply = metrics.Snapshot(metrics.DefaultRegistry)
 	case "eth_protocolVersion":
 		*reply = api.xeth().EthVersion()
 	case "eth_coinbase":
@@ -184,6 +226,36 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 			return err
 		}
 		*reply = v
+	case "eth_sign":
+		args := new(SignArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		v, err := api.xeth().Sign(args.From, args.Data, false)
+		if err != nil {
+			return err
+		}
+		*reply = v
+	case "personal_sign":
+		args := new(PersonalSignArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		v, err := api.xeth().SignWithPassphrase(args.From, args.Data, args.Passphrase)
+		if err != nil {
+			return err
+		}
+		*reply = v
+	case "personal_ecRecover":
+		args := new(EcRecoverArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		v, err := api.xeth().EcRecover(args.Data, args.Signature)
+		if err != nil {
+			return err
+		}
+		*reply = v
 	case "eth_call":
 		args := new(CallArgs)
 		if err := json.Unmarshal(req.Params, &args); err != nil {
@@ -363,6 +435,13 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 			return err
 		}
 		*reply = api.xeth().RemoteMining().SubmitWork(args.Nonce, common.HexToHash(args.Digest), common.HexToHash(args.Header))
+	case "eth_submitHashrate":
+		args := new(SubmitHashRateArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		api.xeth().RemoteMining().SubmitHashrate(common.HexToHash(args.Id), args.Rate)
+		*reply = true
 	case "db_putString":
 		args := new(DbArgs)
 		if err := json.Unmarshal(req.Params, &args); err != nil {
@@ -413,66 +492,6 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 		res, _ := api.xeth().DbGet([]byte(args.Database + args.Key))
 		*reply = newHexData(res)
 
-	case "shh_version":
-		// Retrieves the currently running whisper protocol version
-		*reply = api.xeth().WhisperVersion()
-
-	case "shh_post":
-		// Injects a new message into the whisper network
-		args := new(WhisperMessageArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		err := api.xeth().Whisper().Post(args.Payload, args.To, args.From, args.Topics, args.Priority, args.Ttl)
-		if err != nil {
-			return err
-		}
-		*reply = true
-
-	case "shh_newIdentity":
-		// Creates a new whisper identity to use for sending/receiving messages
-		*reply = api.xeth().Whisper().NewIdentity()
-
-	case "shh_hasIdentity":
-		// Checks if an identity if owned or not
-		args := new(WhisperIdentityArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		*reply = api.xeth().Whisper().HasIdentity(args.Identity)
-
-	case "shh_newFilter":
-		// Create a new filter to watch and match messages with
-		args := new(WhisperFilterArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		id := api.xeth().NewWhisperFilter(args.To, args.From, args.Topics)
-		*reply = newHexNum(big.NewInt(int64(id)).Bytes())
-
-	case "shh_uninstallFilter":
-		// Remove an existing filter watching messages
-		args := new(FilterIdArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		*reply = api.xeth().UninstallWhisperFilter(args.Id)
-
-	case "shh_getFilterChanges":
-		// Retrieve all the new messages arrived since the last request
-		args := new(FilterIdArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		*reply = api.xeth().WhisperMessagesChanged(args.Id)
-
-	case "shh_getMessages":
-		// Retrieve all the cached messages matching a specific, existing filter
-		args := new(FilterIdArgs)
-		if err := json.Unmarshal(req.Params, &args); err != nil {
-			return err
-		}
-		*reply = api.xeth().WhisperMessages(args.Id)
 	case "eth_hashrate":
 		*reply = newHexNum(api.xeth().HashRate())
 
@@ -495,7 +514,12 @@ func (api *EthereumApi) GetRequestReply(req *RpcRequest, reply *interface{}) err
 	// 		return err
 	// 	}
 	// 	*reply = api.xeth().PullWatchTx(args.Hash)
+	case "shh_version":
+		*reply = api.xeth().WhisperVersion()
 	default:
+		if strings.HasPrefix(req.Method, "shh_") {
+			return NewWhisperApi(api.xeth().Whisper()).GetRequestReply(req, reply)
+		}
 		return NewNotImplementedError(req.Method)
 	}
 
@@ -6,6 +6,9 @@ import (
 	"testing"
 	// "time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
 	// "github.com/ethereum/go-ethereum/xeth"
 )
 
@@ -26,6 +29,53 @@ func TestWeb3Sha3(t *testing.T) {
 	}
 }
 
+func TestPersonalEcRecover(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	data := []byte("login nonce 42")
+	sig, err := crypto.Sign(crypto.MessageHash(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+	params, _ := json.Marshal([]string{common.ToHex(data), common.ToHex(sig)})
+
+	api := &EthereumApi{}
+	req := RpcRequest{Method: "personal_ecRecover", Params: params}
+	var response interface{}
+	if err := api.GetRequestReply(&req, &response); err != nil {
+		t.Fatal(err)
+	}
+	if expected := common.ToHex(crypto.PubkeyToAddress(key.PublicKey)); response != expected {
+		t.Errorf("Expected %s got %v", expected, response)
+	}
+
+	// A signature of the plain hash, like that of a transaction, recovers another address
+	sig, _ = crypto.Sign(crypto.Sha3(data), key)
+	params, _ = json.Marshal([]string{common.ToHex(data), common.ToHex(sig)})
+	req = RpcRequest{Method: "personal_ecRecover", Params: params}
+	if err := api.GetRequestReply(&req, &response); err == nil && response == common.ToHex(crypto.PubkeyToAddress(key.PublicKey)) {
+		t.Error("unprefixed signature recovered the signer")
+	}
+}
+
+func TestDebugMetrics(t *testing.T) {
+	metrics.NewCounter("rpc/test").Inc(2)
+
+	api := &EthereumApi{}
+	req := RpcRequest{Method: "debug_metrics"}
+	var response interface{}
+	if err := api.GetRequestReply(&req, &response); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, ok := response.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected response type %T", response)
+	}
+	if snapshot["rpc/test"] != int64(2) {
+		t.Errorf("counter mismatch: got %v, want 2", snapshot["rpc/test"])
+	}
+}
+
 // func TestDbStr(t *testing.T) {
 // 	jsonput := `{"jsonrpc":"2.0","method":"db_putString","params":["testDB","myKey","myString"],"id":64}`
 // 	jsonget := `{"jsonrpc":"2.0","method":"db_getString","params":["testDB","myKey"],"id":64}`
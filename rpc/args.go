@@ -629,6 +629,121 @@ func (args *Sha3Args) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+type SignArgs struct {
+	From string
+	Data string
+}
+
+func (args *SignArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return NewInsufficientParamsError(len(obj), 2)
+	}
+
+	from, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("from", "not a string")
+	}
+	if !common.IsHex(from) {
+		return NewValidationError("from", "not a hexstring")
+	}
+	args.From = from
+
+	data, ok := obj[1].(string)
+	if !ok {
+		return NewInvalidTypeError("data", "not a string")
+	}
+	if !common.IsHex(data) {
+		return NewValidationError("data", "not a hexstring")
+	}
+	args.Data = data
+
+	return nil
+}
+
+type PersonalSignArgs struct {
+	Data       string
+	From       string
+	Passphrase string
+}
+
+func (args *PersonalSignArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 3 {
+		return NewInsufficientParamsError(len(obj), 3)
+	}
+
+	data, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("data", "not a string")
+	}
+	if !common.IsHex(data) {
+		return NewValidationError("data", "not a hexstring")
+	}
+	args.Data = data
+
+	from, ok := obj[1].(string)
+	if !ok {
+		return NewInvalidTypeError("from", "not a string")
+	}
+	if !common.IsHex(from) {
+		return NewValidationError("from", "not a hexstring")
+	}
+	args.From = from
+
+	passphrase, ok := obj[2].(string)
+	if !ok {
+		return NewInvalidTypeError("passphrase", "not a string")
+	}
+	args.Passphrase = passphrase
+
+	return nil
+}
+
+type EcRecoverArgs struct {
+	Data      string
+	Signature string
+}
+
+func (args *EcRecoverArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return NewInsufficientParamsError(len(obj), 2)
+	}
+
+	data, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("data", "not a string")
+	}
+	if !common.IsHex(data) {
+		return NewValidationError("data", "not a hexstring")
+	}
+	args.Data = data
+
+	sig, ok := obj[1].(string)
+	if !ok {
+		return NewInvalidTypeError("signature", "not a string")
+	}
+	if !common.IsHex(sig) || len(common.FromHex(sig)) != 65 {
+		return NewValidationError("signature", "not a 65 byte hexstring")
+	}
+	args.Signature = sig
+
+	return nil
+}
+
 type BlockFilterArgs struct {
 	Earliest int64
 	Latest   int64
@@ -878,6 +993,7 @@ type WhisperMessageArgs struct {
 	Payload  string
 	To       string
 	From     string
+	SymKey   string
 	Topics   []string
 	Priority uint32
 	Ttl      uint32
@@ -888,6 +1004,7 @@ func (args *WhisperMessageArgs) UnmarshalJSON(b []byte) (err error) {
 		Payload  string
 		To       string
 		From     string
+		SymKey   string
 		Topics   []string
 		Priority interface{}
 		Ttl      interface{}
@@ -903,6 +1020,7 @@ func (args *WhisperMessageArgs) UnmarshalJSON(b []byte) (err error) {
 	args.Payload = obj[0].Payload
 	args.To = obj[0].To
 	args.From = obj[0].From
+	args.SymKey = obj[0].SymKey
 	args.Topics = obj[0].Topics
 
 	var num *big.Int
@@ -993,6 +1111,51 @@ func (args *FilterIdArgs) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+type NodeURLArgs struct {
+	URL string
+}
+
+func (args *NodeURLArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+
+	argstr, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("url", "not a string")
+	}
+	args.URL = argstr
+
+	return nil
+}
+
+type PeerEventFilterArgs struct {
+	Messages bool
+}
+
+func (args *PeerEventFilterArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	// the messages flag is optional and defaults to false
+	if len(obj) > 0 {
+		messages, ok := obj[0].(bool)
+		if !ok {
+			return NewInvalidTypeError("messages", "not a bool")
+		}
+		args.Messages = messages
+	}
+
+	return nil
+}
+
 type WhisperIdentityArgs struct {
 	Identity string
 }
@@ -1019,9 +1182,36 @@ func (args *WhisperIdentityArgs) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+type WhisperKeyArgs struct {
+	Key string
+}
+
+func (args *WhisperKeyArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+
+	argstr, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("key", "not a string")
+	}
+	if !common.IsHex(argstr) {
+		return NewValidationError("key", "not a hexstring")
+	}
+	args.Key = argstr
+
+	return nil
+}
+
 type WhisperFilterArgs struct {
 	To     string
 	From   string
+	SymKey string
 	Topics [][]string
 }
 
@@ -1032,6 +1222,7 @@ func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 	var obj []struct {
 		To     interface{} `json:"to"`
 		From   interface{} `json:"from"`
+		SymKey interface{} `json:"symKey"`
 		Topics interface{} `json:"topics"`
 	}
 	if err := json.Unmarshal(b, &obj); err != nil {
@@ -1059,6 +1250,13 @@ func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 		}
 		args.From = argstr
 	}
+	if obj[0].SymKey != nil {
+		argstr, ok := obj[0].SymKey.(string)
+		if !ok {
+			return NewInvalidTypeError("symKey", "is not a string")
+		}
+		args.SymKey = argstr
+	}
 	// Construct the nested topic array
 	if obj[0].Topics != nil {
 		// Make sure we have an actual topic array
@@ -1099,6 +1297,117 @@ func (args *WhisperFilterArgs) UnmarshalJSON(b []byte) (err error) {
 	return nil
 }
 
+// WhisperMailRequestArgs holds a request for the archived whisper messages
+// of a mail server peer.
+type WhisperMailRequestArgs struct {
+	Peer   string
+	From   uint32
+	To     uint32
+	Topics []string
+}
+
+func (args *WhisperMailRequestArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []struct {
+		Peer   string
+		From   interface{}
+		To     interface{}
+		Topics []string
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+	if len(obj[0].Peer) == 0 {
+		return NewValidationError("peer", "cannot be blank")
+	}
+	args.Peer = obj[0].Peer
+	args.Topics = obj[0].Topics
+
+	if obj[0].From != nil {
+		num, err := numString(obj[0].From)
+		if err != nil {
+			return err
+		}
+		args.From = uint32(num.Int64())
+	}
+	if obj[0].To != nil {
+		num, err := numString(obj[0].To)
+		if err != nil {
+			return err
+		}
+		args.To = uint32(num.Int64())
+	}
+	return nil
+}
+
+// WhisperSymKeyArgs holds the name of a whisper symmetric key.
+type WhisperSymKeyArgs struct {
+	Name string
+}
+
+func (args *WhisperSymKeyArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+	argstr, ok := obj[0].(string)
+	if !ok {
+		return NewInvalidTypeError("name", "not a string")
+	}
+	if len(argstr) == 0 {
+		return NewValidationError("name", "cannot be blank")
+	}
+	args.Name = argstr
+	return nil
+}
+
+// WhisperAddSymKeyArgs holds a whisper symmetric key to store under Name.
+// The key is given either directly or as a password or topic to derive it
+// from.
+type WhisperAddSymKeyArgs struct {
+	Name     string
+	Key      string
+	Password string
+	Topic    string
+}
+
+func (args *WhisperAddSymKeyArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []struct {
+		Name     string `json:"name"`
+		Key      string `json:"key"`
+		Password string `json:"password"`
+		Topic    string `json:"topic"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+	if len(obj) < 1 {
+		return NewInsufficientParamsError(len(obj), 1)
+	}
+	if len(obj[0].Name) == 0 {
+		return NewValidationError("name", "cannot be blank")
+	}
+	set := 0
+	for _, v := range []string{obj[0].Key, obj[0].Password, obj[0].Topic} {
+		if len(v) > 0 {
+			set++
+		}
+	}
+	if set != 1 {
+		return NewValidationError("key", "exactly one of key, password or topic must be given")
+	}
+	args.Name = obj[0].Name
+	args.Key = obj[0].Key
+	args.Password = obj[0].Password
+	args.Topic = obj[0].Topic
+	return nil
+}
+
 type SubmitWorkArgs struct {
 	Nonce  uint64
 	Header string
@@ -1136,3 +1445,34 @@ func (args *SubmitWorkArgs) UnmarshalJSON(b []byte) (err error) {
 
 	return nil
 }
+
+type SubmitHashRateArgs struct {
+	Rate uint64
+	Id   string
+}
+
+func (args *SubmitHashRateArgs) UnmarshalJSON(b []byte) (err error) {
+	var obj []interface{}
+	if err = json.Unmarshal(b, &obj); err != nil {
+		return NewDecodeParamError(err.Error())
+	}
+
+	if len(obj) < 2 {
+		return NewInsufficientParamsError(len(obj), 2)
+	}
+
+	var objstr string
+	var ok bool
+	if objstr, ok = obj[0].(string); !ok {
+		return NewInvalidTypeError("rate", "not a string")
+	}
+
+	args.Rate = common.String2Big(objstr).Uint64()
+	if objstr, ok = obj[1].(string); !ok {
+		return NewInvalidTypeError("id", "not a string")
+	}
+
+	args.Id = objstr
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -1959,6 +1960,19 @@ func TestWhisperFilterArgs(t *testing.T) {
 	// }
 }
 
+func TestWhisperFilterArgsSymKey(t *testing.T) {
+	input := `[{"topics": ["0x68656c6c6f20776f726c64"], "symKey": "group"}]`
+
+	args := new(WhisperFilterArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.SymKey != "group" {
+		t.Errorf("SymKey should be %#v but is %#v", "group", args.SymKey)
+	}
+}
+
 func TestWhisperFilterArgsInvalid(t *testing.T) {
 	input := `{}`
 
@@ -2169,6 +2183,41 @@ func TestWhisperIdentityArgsInt(t *testing.T) {
 	}
 }
 
+func TestWhisperKeyArgs(t *testing.T) {
+	input := `["0xc931d93e97ab07fe42d923478ba2465f283c931d93e97ab07fe42d923478ba24"]`
+	expected := new(WhisperKeyArgs)
+	expected.Key = "0xc931d93e97ab07fe42d923478ba2465f283c931d93e97ab07fe42d923478ba24"
+
+	args := new(WhisperKeyArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if expected.Key != args.Key {
+		t.Errorf("Key shoud be %#v but is %#v", expected.Key, args.Key)
+	}
+}
+
+func TestWhisperKeyArgsNotHex(t *testing.T) {
+	input := `["c931d93e97ab07fe42d923478ba2465f283"]`
+
+	args := new(WhisperKeyArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperKeyArgsEmpty(t *testing.T) {
+	input := `[]`
+
+	args := new(WhisperKeyArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
 func TestBlockNumArgs(t *testing.T) {
 	input := `["0x29a"]`
 	expected := new(BlockNumIndexArgs)
@@ -2486,6 +2535,46 @@ func TestSubmitWorkArgsDigestInt(t *testing.T) {
 	}
 }
 
+func TestSubmitHashRateArgs(t *testing.T) {
+	input := `["0x0000000000000000000000000000000000000000000000000000000000500000", "0x59daa26581d0acd1fce254fb7e85952f4c09d0915afd33d3886cd914bc7d283c"]`
+	expected := new(SubmitHashRateArgs)
+	expected.Rate = 0x500000
+	expected.Id = "0x59daa26581d0acd1fce254fb7e85952f4c09d0915afd33d3886cd914bc7d283c"
+
+	args := new(SubmitHashRateArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if expected.Rate != args.Rate {
+		t.Errorf("Rate shoud be %d but is %d", expected.Rate, args.Rate)
+	}
+
+	if expected.Id != args.Id {
+		t.Errorf("Id shoud be %#v but is %#v", expected.Id, args.Id)
+	}
+}
+
+func TestSubmitHashRateArgsEmpty(t *testing.T) {
+	input := `[]`
+
+	args := new(SubmitHashRateArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSubmitHashRateArgsRateInt(t *testing.T) {
+	input := `[1, "0x59daa26581d0acd1fce254fb7e85952f4c09d0915afd33d3886cd914bc7d283c"]`
+
+	args := new(SubmitHashRateArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
 func TestBlockHeightFromJsonInvalid(t *testing.T) {
 	var num int64
 	var msg json.RawMessage = []byte(`}{`)
@@ -2494,3 +2583,252 @@ func TestBlockHeightFromJsonInvalid(t *testing.T) {
 		t.Error(str)
 	}
 }
+
+func TestNodeURLArgs(t *testing.T) {
+	url := "enode://1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439@10.3.58.6:30303"
+	input := fmt.Sprintf(`["%s"]`, url)
+
+	args := new(NodeURLArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+	if args.URL != url {
+		t.Errorf("URL should be %#v but is %#v", url, args.URL)
+	}
+}
+
+func TestNodeURLArgsEmpty(t *testing.T) {
+	input := `[]`
+
+	args := new(NodeURLArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestNodeURLArgsInt(t *testing.T) {
+	input := `[7]`
+
+	args := new(NodeURLArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestPeerEventFilterArgs(t *testing.T) {
+	args := new(PeerEventFilterArgs)
+	if err := json.Unmarshal([]byte(`[]`), &args); err != nil {
+		t.Error(err)
+	}
+	if args.Messages {
+		t.Errorf("Messages should default to false")
+	}
+	if err := json.Unmarshal([]byte(`[true]`), &args); err != nil {
+		t.Error(err)
+	}
+	if !args.Messages {
+		t.Errorf("Messages should be true")
+	}
+}
+
+func TestPeerEventFilterArgsString(t *testing.T) {
+	input := `["true"]`
+
+	args := new(PeerEventFilterArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), &args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperAddSymKeyArgs(t *testing.T) {
+	input := `[{"name": "group", "password": "secret"}]`
+
+	args := new(WhisperAddSymKeyArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Name != "group" {
+		t.Errorf("Name should be %#v but is %#v", "group", args.Name)
+	}
+
+	if args.Password != "secret" {
+		t.Errorf("Password should be %#v but is %#v", "secret", args.Password)
+	}
+}
+
+func TestWhisperAddSymKeyArgsMultipleSources(t *testing.T) {
+	input := `[{"name": "group", "password": "secret", "topic": "chat"}]`
+
+	args := new(WhisperAddSymKeyArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperAddSymKeyArgsNoName(t *testing.T) {
+	input := `[{"key": "0x01"}]`
+
+	args := new(WhisperAddSymKeyArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperSymKeyArgs(t *testing.T) {
+	input := `["group"]`
+
+	args := new(WhisperSymKeyArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Name != "group" {
+		t.Errorf("Name should be %#v but is %#v", "group", args.Name)
+	}
+}
+
+func TestWhisperSymKeyArgsInt(t *testing.T) {
+	input := `[5]`
+
+	args := new(WhisperSymKeyArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSignArgs(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1", "0x68656c6c6f"]`
+
+	args := new(SignArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.From != "0x407d73d8a49eeb85d32cf465507dd71d507100c1" {
+		t.Errorf("From should be %#v but is %#v", "0x407d73d8a49eeb85d32cf465507dd71d507100c1", args.From)
+	}
+	if args.Data != "0x68656c6c6f" {
+		t.Errorf("Data should be %#v but is %#v", "0x68656c6c6f", args.Data)
+	}
+}
+
+func TestSignArgsEmpty(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1"]`
+
+	args := new(SignArgs)
+	str := ExpectInsufficientParamsError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestSignArgsDataNotHex(t *testing.T) {
+	input := `["0x407d73d8a49eeb85d32cf465507dd71d507100c1", "hello"]`
+
+	args := new(SignArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestPersonalSignArgs(t *testing.T) {
+	input := `["0x68656c6c6f", "0x407d73d8a49eeb85d32cf465507dd71d507100c1", "foo"]`
+
+	args := new(PersonalSignArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Data != "0x68656c6c6f" {
+		t.Errorf("Data should be %#v but is %#v", "0x68656c6c6f", args.Data)
+	}
+	if args.From != "0x407d73d8a49eeb85d32cf465507dd71d507100c1" {
+		t.Errorf("From should be %#v but is %#v", "0x407d73d8a49eeb85d32cf465507dd71d507100c1", args.From)
+	}
+	if args.Passphrase != "foo" {
+		t.Errorf("Passphrase should be %#v but is %#v", "foo", args.Passphrase)
+	}
+}
+
+func TestPersonalSignArgsPassphraseInt(t *testing.T) {
+	input := `["0x68656c6c6f", "0x407d73d8a49eeb85d32cf465507dd71d507100c1", 5]`
+
+	args := new(PersonalSignArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestEcRecoverArgs(t *testing.T) {
+	sig := "0x" + strings.Repeat("ab", 64) + "1b"
+	input := `["0x68656c6c6f", "` + sig + `"]`
+
+	args := new(EcRecoverArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+
+	if args.Data != "0x68656c6c6f" {
+		t.Errorf("Data should be %#v but is %#v", "0x68656c6c6f", args.Data)
+	}
+	if args.Signature != sig {
+		t.Errorf("Signature should be %#v but is %#v", sig, args.Signature)
+	}
+}
+
+func TestEcRecoverArgsShortSignature(t *testing.T) {
+	input := `["0x68656c6c6f", "0x` + strings.Repeat("ab", 64) + `"]`
+
+	args := new(EcRecoverArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperMailRequestArgs(t *testing.T) {
+	input := `[{"peer": "0x1234", "from": 100, "to": "0xc8", "topics": ["0x68656c6c6f"]}]`
+
+	args := new(WhisperMailRequestArgs)
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
+		t.Error(err)
+	}
+	if args.Peer != "0x1234" {
+		t.Errorf("Peer should be %#v but is %#v", "0x1234", args.Peer)
+	}
+	if args.From != 100 || args.To != 200 {
+		t.Errorf("time range should be 100-200 but is %d-%d", args.From, args.To)
+	}
+	if len(args.Topics) != 1 || args.Topics[0] != "0x68656c6c6f" {
+		t.Errorf("Topics should be %v but are %v", []string{"0x68656c6c6f"}, args.Topics)
+	}
+}
+
+func TestWhisperMailRequestArgsNoPeer(t *testing.T) {
+	input := `[{"from": 100}]`
+
+	args := new(WhisperMailRequestArgs)
+	str := ExpectValidationError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
+
+func TestWhisperMailRequestArgsFromBool(t *testing.T) {
+	input := `[{"peer": "0x1234", "from": true}]`
+
+	args := new(WhisperMailRequestArgs)
+	str := ExpectInvalidTypeError(json.Unmarshal([]byte(input), args))
+	if len(str) > 0 {
+		t.Error(str)
+	}
+}
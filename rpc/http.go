@@ -61,10 +61,24 @@ func Stop() error {
 	return nil
 }
 
+// RequestHandler is implemented by the JSON-RPC API backends.
+type RequestHandler interface {
+	GetRequestReply(req *RpcRequest, reply *interface{}) error
+}
+
 // JSONRPC returns a handler that implements the Ethereum JSON-RPC API.
 func JSONRPC(pipe *xeth.XEth) http.Handler {
-	api := NewEthereumApi(pipe)
+	return NewHandler(NewEthereumApi(pipe))
+}
+
+// WhisperJSONRPC returns a handler that implements the shh_* methods of the
+// JSON-RPC API.
+func WhisperJSONRPC(shh *xeth.Whisper) http.Handler {
+	return NewHandler(NewWhisperApi(shh))
+}
 
+// NewHandler returns a handler serving JSON-RPC requests with the given API.
+func NewHandler(api RequestHandler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -110,13 +124,13 @@ func JSONRPC(pipe *xeth.XEth) http.Handler {
 	})
 }
 
-func RpcResponse(api *EthereumApi, request *RpcRequest) *interface{} {
+func RpcResponse(api RequestHandler, request *RpcRequest) *interface{} {
 	var reply, response interface{}
 	reserr := api.GetRequestReply(request, &reply)
 	switch reserr.(type) {
 	case nil:
 		response = &RpcSuccessResponse{Jsonrpc: jsonrpcver, Id: request.Id, Result: reply}
-	case *NotImplementedError:
+	case *NotImplementedError, *NotAvailableError:
 		jsonerr := &RpcErrorObject{-32601, reserr.Error()}
 		response = &RpcErrorResponse{Jsonrpc: jsonrpcver, Id: request.Id, Error: jsonerr}
 	case *DecodeParamError, *InsufficientParamsError, *ValidationError, *InvalidTypeError:
@@ -37,6 +37,15 @@ func TestNotImplementedError(t *testing.T) {
 	}
 }
 
+func TestNotAvailableError(t *testing.T) {
+	err := NewNotAvailableError("foo", "bar")
+	expected := "foo method not available: bar"
+
+	if err.Error() != expected {
+		t.Error(err.Error())
+	}
+}
+
 func TestDecodeParamError(t *testing.T) {
 	err := NewDecodeParamError("foo")
 	expected := "could not decode, foo"
@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/xeth"
+)
+
+// WhisperApi serves the shh_* methods of the JSON-RPC API. It only depends on
+// the whisper client, so it can run on nodes without the eth protocol.
+type WhisperApi struct {
+	shh *xeth.Whisper
+}
+
+func NewWhisperApi(shh *xeth.Whisper) *WhisperApi {
+	return &WhisperApi{shh: shh}
+}
+
+func (api *WhisperApi) GetRequestReply(req *RpcRequest, reply *interface{}) error {
+	glog.V(logger.Debug).Infof("%s %s", req.Method, req.Params)
+
+	if api.shh == nil || api.shh.Whisper == nil {
+		return NewNotAvailableError(req.Method, "whisper is not enabled")
+	}
+	switch req.Method {
+	case "shh_version":
+		// Retrieves the currently running whisper protocol version
+		*reply = fmt.Sprintf("%d", api.shh.Version())
+
+	case "shh_post":
+		// Injects a new message into the whisper network
+		args := new(WhisperMessageArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		err := api.shh.Post(args.Payload, args.To, args.From, args.SymKey, args.Topics, args.Priority, args.Ttl)
+		if err != nil {
+			return err
+		}
+		*reply = true
+
+	case "shh_newIdentity":
+		// Creates a new whisper identity to use for sending/receiving messages
+		*reply = api.shh.NewIdentity()
+
+	case "shh_hasIdentity":
+		// Checks if an identity if owned or not
+		args := new(WhisperIdentityArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.shh.HasIdentity(args.Identity)
+
+	case "shh_identities":
+		// Lists the public keys of all the owned identities
+		*reply = api.shh.Identities()
+
+	case "shh_importIdentity":
+		// Injects an existing private key as a new identity
+		args := new(WhisperKeyArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		id, err := api.shh.ImportIdentity(args.Key)
+		if err != nil {
+			return err
+		}
+		*reply = id
+
+	case "shh_exportIdentity":
+		// Retrieves the private key of an owned identity
+		args := new(WhisperIdentityArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		key, err := api.shh.ExportIdentity(args.Identity)
+		if err != nil {
+			return err
+		}
+		*reply = key
+
+	case "shh_deleteIdentity":
+		// Removes an owned identity, including its persisted key
+		args := new(WhisperIdentityArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		if err := api.shh.DeleteIdentity(args.Identity); err != nil {
+			return err
+		}
+		*reply = true
+
+	case "shh_generateSymKey":
+		// Creates a random symmetric key and stores it under the given name
+		args := new(WhisperSymKeyArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		key, err := api.shh.GenerateSymKey(args.Name)
+		if err != nil {
+			return err
+		}
+		*reply = key
+
+	case "shh_addSymKey":
+		// Stores a given or derived symmetric key under the given name
+		args := new(WhisperAddSymKeyArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		if err := api.shh.AddSymKey(args.Name, args.Key, args.Password, args.Topic); err != nil {
+			return err
+		}
+		*reply = true
+
+	case "shh_hasSymKey":
+		// Checks if a symmetric key is stored under the given name
+		args := new(WhisperSymKeyArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.shh.HasSymKey(args.Name)
+
+	case "shh_deleteSymKey":
+		// Removes the symmetric key stored under the given name
+		args := new(WhisperSymKeyArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		api.shh.DeleteSymKey(args.Name)
+		*reply = true
+
+	case "shh_requestMessages":
+		// Requests archived messages from a mail server peer
+		args := new(WhisperMailRequestArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		if err := api.shh.RequestMessages(args.Peer, args.From, args.To, args.Topics); err != nil {
+			return err
+		}
+		*reply = true
+
+	case "shh_newFilter":
+		// Create a new filter to watch and match messages with
+		args := new(WhisperFilterArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		id, err := api.shh.NewFilter(args.To, args.From, args.SymKey, args.Topics)
+		if err != nil {
+			return err
+		}
+		*reply = newHexNum(big.NewInt(int64(id)).Bytes())
+
+	case "shh_uninstallFilter":
+		// Remove an existing filter watching messages
+		args := new(FilterIdArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.shh.UninstallFilter(args.Id)
+
+	case "shh_getFilterChanges":
+		// Retrieve all the new messages arrived since the last request
+		args := new(FilterIdArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.shh.FilterChanges(args.Id)
+
+	case "shh_getMessages":
+		// Retrieve all the cached messages matching a specific, existing filter
+		args := new(FilterIdArgs)
+		if err := json.Unmarshal(req.Params, &args); err != nil {
+			return err
+		}
+		*reply = api.shh.FilterMessages(args.Id)
+
+	default:
+		return NewNotImplementedError(req.Method)
+	}
+
+	glog.V(logger.Detail).Infof("Reply: %T %v", *reply, *reply)
+	return nil
+}
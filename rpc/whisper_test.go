@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/whisper"
+	"github.com/ethereum/go-ethereum/xeth"
+)
+
+func TestWhisperApi(t *testing.T) {
+	shh := whisper.New()
+	shh.Start()
+	defer shh.Stop()
+
+	api := NewWhisperApi(xeth.NewWhisper(shh))
+	call := func(method, params string) (interface{}, error) {
+		req := RpcRequest{Method: method, Params: json.RawMessage(params)}
+		var reply interface{}
+		err := api.GetRequestReply(&req, &reply)
+		return reply, err
+	}
+	// Non-whisper methods should be rejected
+	if _, err := call("eth_blockNumber", "[]"); err == nil {
+		t.Fatalf("eth_blockNumber succeeded without the eth protocol")
+	} else if _, ok := err.(*NotImplementedError); !ok {
+		t.Fatalf("error mismatch: have %v, want NotImplementedError", err)
+	}
+	// Post a message to a symmetric key filter and poll for it
+	if _, err := call("shh_addSymKey", `[{"name": "test", "password": "secret"}]`); err != nil {
+		t.Fatalf("failed to add symmetric key: %v", err)
+	}
+	reply, err := call("shh_newFilter", `[{"symKey": "test"}]`)
+	if err != nil {
+		t.Fatalf("failed to install filter: %v", err)
+	}
+	id := reply.(*hexnum).String()
+
+	if _, err := call("shh_post", `[{"payload": "0x68656c6c6f", "symKey": "test", "ttl": 50, "priority": 50}]`); err != nil {
+		t.Fatalf("failed to post message: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		reply, err := call("shh_getFilterChanges", `["`+id+`"]`)
+		if err != nil {
+			t.Fatalf("failed to poll filter: %v", err)
+		}
+		if messages := reply.([]xeth.WhisperMessage); len(messages) > 0 {
+			if payload := messages[0].Payload; payload != "0x68656c6c6f" {
+				t.Fatalf("payload mismatch: have %s, want 0x68656c6c6f", payload)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message not delivered in time")
+		}
+	}
+	// Uninstalled filters should be gone
+	if reply, _ := call("shh_uninstallFilter", `["`+id+`"]`); reply != true {
+		t.Fatalf("failed to uninstall filter")
+	}
+	if reply, _ := call("shh_uninstallFilter", `["`+id+`"]`); reply != false {
+		t.Fatalf("uninstalled filter twice")
+	}
+}
+
+func TestWhisperApiIdentities(t *testing.T) {
+	api := NewWhisperApi(xeth.NewWhisper(whisper.New()))
+	call := func(method, params string) (interface{}, error) {
+		req := RpcRequest{Method: method, Params: json.RawMessage(params)}
+		var reply interface{}
+		err := api.GetRequestReply(&req, &reply)
+		return reply, err
+	}
+	// Export a generated identity and import it again after deletion
+	reply, _ := call("shh_newIdentity", "[]")
+	id := reply.(string)
+
+	key, err := call("shh_exportIdentity", `["`+id+`"]`)
+	if err != nil {
+		t.Fatalf("failed to export identity: %v", err)
+	}
+	if _, err := call("shh_deleteIdentity", `["`+id+`"]`); err != nil {
+		t.Fatalf("failed to delete identity: %v", err)
+	}
+	if ids, _ := call("shh_identities", "[]"); len(ids.([]string)) != 0 {
+		t.Fatalf("deleted identity still listed: %v", ids)
+	}
+	if _, err := call("shh_exportIdentity", `["`+id+`"]`); err == nil {
+		t.Fatalf("exported deleted identity")
+	}
+	imported, err := call("shh_importIdentity", `["`+key.(string)+`"]`)
+	if err != nil {
+		t.Fatalf("failed to import identity: %v", err)
+	}
+	if imported != id {
+		t.Fatalf("imported identity mismatch: have %v, want %s", imported, id)
+	}
+	if ids, _ := call("shh_identities", "[]"); len(ids.([]string)) != 1 || ids.([]string)[0] != id {
+		t.Fatalf("identity list mismatch: have %v, want [%s]", ids, id)
+	}
+}
+
+func TestWhisperApiRequestMessages(t *testing.T) {
+	api := NewWhisperApi(xeth.NewWhisper(whisper.New()))
+	call := func(params string) error {
+		req := RpcRequest{Method: "shh_requestMessages", Params: json.RawMessage(params)}
+		var reply interface{}
+		return api.GetRequestReply(&req, &reply)
+	}
+	if err := call(`[{"peer": "0x1234"}]`); err == nil {
+		t.Errorf("requested messages from invalid peer id")
+	}
+	peer := discover.NodeID{1}.String()
+	if err := call(`[{"peer": "` + peer + `", "from": 100, "topics": ["0x6d61696c"]}]`); err == nil {
+		t.Errorf("requested messages from unconnected peer")
+	}
+}
+
+func TestWhisperApiDisabled(t *testing.T) {
+	// Nodes running without whisper have no whisper client to serve from
+	for _, api := range []*WhisperApi{NewWhisperApi(nil), NewWhisperApi(xeth.NewWhisper(nil))} {
+		for _, method := range []string{"shh_version", "shh_newIdentity", "shh_identities"} {
+			var reply interface{}
+			err := api.GetRequestReply(&RpcRequest{Method: method, Params: json.RawMessage("[]")}, &reply)
+			if _, ok := err.(*NotAvailableError); !ok {
+				t.Errorf("%s: expected NotAvailableError, got %T: %v", method, err, err)
+			}
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package trie
 
+import "github.com/ethereum/go-ethereum/metrics"
+
+var (
+	cacheHitMeter  = metrics.NewMeter("trie/cache/hits")
+	cacheMissMeter = metrics.NewMeter("trie/cache/misses")
+)
+
 type Backend interface {
 	Get([]byte) ([]byte, error)
 	Put([]byte, []byte)
@@ -17,7 +24,10 @@ func NewCache(backend Backend) *Cache {
 func (self *Cache) Get(key []byte) []byte {
 	data := self.store[string(key)]
 	if data == nil {
+		cacheMissMeter.Mark(1)
 		data, _ = self.backend.Get(key)
+	} else {
+		cacheHitMeter.Mark(1)
 	}
 
 	return data
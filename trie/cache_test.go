@@ -0,0 +1,36 @@
+package trie
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCacheMeters(t *testing.T) {
+	db := make(Db)
+	trie := New(nil, db)
+	for i := 0; i < 100; i++ {
+		trie.Update([]byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("a fairly long value to force hashing %03d", i)))
+	}
+	trie.Commit()
+
+	hits, misses := cacheHitMeter.Count(), cacheMissMeter.Count()
+
+	// A trie opened on the committed root finds no nodes in its own cache
+	reopened := New(trie.Root(), db)
+	if v := reopened.Get([]byte("key-042")); len(v) == 0 {
+		t.Fatalf("value missing from reopened trie")
+	}
+	if n := cacheMissMeter.Count() - misses; n == 0 {
+		t.Errorf("cache miss count mismatch: have %d, want > 0", n)
+	}
+	// while discarding changes to the original trie finds the committed root cached
+	trie.Update([]byte("key-042"), []byte("changed"))
+	trie.Hash()
+	trie.Reset()
+	if v := string(trie.Get([]byte("key-042"))); v != "a fairly long value to force hashing 042" {
+		t.Fatalf("value mismatch after reset: have %q", v)
+	}
+	if n := cacheHitMeter.Count() - hits; n == 0 {
+		t.Errorf("cache hit count mismatch: have %d, want > 0", n)
+	}
+}
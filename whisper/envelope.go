@@ -7,6 +7,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -25,6 +26,8 @@ type Envelope struct {
 	Nonce  uint32
 
 	hash common.Hash // Cached hash of the envelope to avoid rehashing every time
+	size int         // Cached size of the encoded envelope
+	pow  float64     // Cached proof of work, normalised by size and TTL
 }
 
 // NewEnvelope wraps a Whisper message with expiration and destination data
@@ -40,23 +43,67 @@ func NewEnvelope(ttl time.Duration, topics []Topic, msg *Message) *Envelope {
 }
 
 // Seal closes the envelope by spending the requested amount of time as a proof
-// of work on hashing the data.
-func (self *Envelope) Seal(pow time.Duration) {
-	d := make([]byte, 64)
-	copy(d[:32], self.rlpWithoutNonce())
-
-	finish, bestBit := time.Now().Add(pow).UnixNano(), 0
-	for nonce := uint32(0); time.Now().UnixNano() < finish; {
+// of work on hashing the data. If the proof of work is below target by then,
+// hashing continues until it is reached, but for at most maxSealTime more.
+func (self *Envelope) Seal(pow time.Duration, target float64) {
+	d := self.powInput()
+
+	finish := time.Now().Add(pow)
+	deadline := finish.Add(maxSealTime).UnixNano()
+	bestBit, reached := 0, target <= 0
+	for nonce := uint32(0); ; {
+		if now := time.Now().UnixNano(); now >= deadline || (reached && now >= finish.UnixNano()) {
+			break
+		}
 		for i := 0; i < 1024; i++ {
 			binary.BigEndian.PutUint32(d[60:], nonce)
 
 			firstBit := common.FirstBitSet(common.BigD(crypto.Sha3(d)))
 			if firstBit > bestBit {
 				self.Nonce, bestBit = nonce, firstBit
+				self.hash, self.size, self.pow = common.Hash{}, 0, 0
+				reached = self.PoW() >= target
 			}
 			nonce++
 		}
 	}
+	self.hash, self.size, self.pow = common.Hash{}, 0, 0
+}
+
+// powInput returns the buffer hashed by the proof of work, without the nonce.
+// It commits to the hash of the whole envelope so that a nonce can't be reused
+// for different contents.
+func (self *Envelope) powInput() []byte {
+	d := make([]byte, 64)
+	copy(d[:32], crypto.Sha3(self.rlpWithoutNonce()))
+	return d
+}
+
+// PoW returns the proof of work of the envelope. The work done while sealing
+// is normalised by the size and TTL of the envelope, so large and long-lived
+// envelopes need more work to reach the same value.
+func (self *Envelope) PoW() float64 {
+	if self.pow == 0 {
+		d := self.powInput()
+		binary.BigEndian.PutUint32(d[60:], self.Nonce)
+		bits := common.FirstBitSet(common.BigD(crypto.Sha3(d)))
+
+		ttl := self.TTL
+		if ttl == 0 {
+			ttl = 1
+		}
+		self.pow = math.Pow(2, float64(bits)) / (float64(self.Size()) * float64(ttl))
+	}
+	return self.pow
+}
+
+// Size returns the size of the RLP encoded envelope.
+func (self *Envelope) Size() int {
+	if self.size == 0 {
+		enc, _ := rlp.EncodeToBytes(self)
+		self.size = len(enc)
+	}
+	return self.size
 }
 
 // rlpWithoutNonce returns the RLP encoded envelope contents, except the nonce.
@@ -67,7 +114,44 @@ func (self *Envelope) rlpWithoutNonce() []byte {
 
 // Open extracts the message contained within a potentially encrypted envelope.
 func (self *Envelope) Open(key *ecdsa.PrivateKey) (msg *Message, err error) {
-	// Split open the payload into a message construct
+	message, err := self.split()
+	if err != nil {
+		return nil, err
+	}
+	// Decrypt the message, if requested
+	if key == nil {
+		return message, nil
+	}
+	err = message.decrypt(key)
+	switch err {
+	case nil:
+		return message, nil
+
+	case ecies.ErrInvalidPublicKey: // Payload isn't encrypted
+		return message, err
+
+	default:
+		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
+	}
+}
+
+// OpenSymmetric extracts the message contained within an envelope encrypted
+// with a symmetric key.
+func (self *Envelope) OpenSymmetric(key []byte) (*Message, error) {
+	message, err := self.split()
+	if err != nil {
+		return nil, err
+	}
+	if err := message.decryptSymmetric(key); err != nil {
+		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
+	}
+	message.SymKey = key
+	return message, nil
+}
+
+// split decodes the message construct contained in the envelope without
+// decrypting the payload.
+func (self *Envelope) split() (*Message, error) {
 	data := self.Data
 
 	message := &Message{
@@ -85,22 +169,7 @@ func (self *Envelope) Open(key *ecdsa.PrivateKey) (msg *Message, err error) {
 		message.Signature, data = data[:signatureLength], data[signatureLength:]
 	}
 	message.Payload = data
-
-	// Decrypt the message, if requested
-	if key == nil {
-		return message, nil
-	}
-	err = message.decrypt(key)
-	switch err {
-	case nil:
-		return message, nil
-
-	case ecies.ErrInvalidPublicKey: // Payload isn't encrypted
-		return message, err
-
-	default:
-		return nil, fmt.Errorf("unable to open envelope, decrypt failed: %v", err)
-	}
+	return message, nil
 }
 
 // Hash returns the SHA3 hash of the envelope, calculating it if not yet done.
@@ -127,5 +196,6 @@ func (self *Envelope) DecodeRLP(s *rlp.Stream) error {
 		return err
 	}
 	self.hash = crypto.Sha3Hash(raw)
+	self.size = len(raw)
 	return nil
 }
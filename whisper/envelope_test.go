@@ -7,6 +7,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestEnvelopeOpen(t *testing.T) {
@@ -140,3 +141,35 @@ func TestEnvelopeIdentifiedOpenTargeted(t *testing.T) {
 		t.Fatalf("payload mismatch: have 0x%x, want 0x%x", opened.Payload, payload)
 	}
 }
+
+func TestEnvelopePoW(t *testing.T) {
+	message := NewMessage([]byte("hello world"))
+	envelope, err := message.Wrap(DefaultPoW, Options{})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if pow := envelope.PoW(); pow < DefaultMinimumPoW {
+		t.Fatalf("sealed envelope PoW too low: have %f, want at least %f", pow, DefaultMinimumPoW)
+	}
+	// The PoW must survive the network round trip
+	enc, err := rlp.EncodeToBytes(envelope)
+	if err != nil {
+		t.Fatalf("failed to encode envelope: %v", err)
+	}
+	decoded := new(Envelope)
+	if err := rlp.DecodeBytes(enc, decoded); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	if decoded.Size() != len(enc) {
+		t.Fatalf("size mismatch: have %d, want %d", decoded.Size(), len(enc))
+	}
+	if decoded.PoW() != envelope.PoW() {
+		t.Fatalf("PoW mismatch: have %f, want %f", decoded.PoW(), envelope.PoW())
+	}
+	// Changing the contents invalidates the work
+	decoded.Data = append(decoded.Data, 0)
+	decoded.pow, decoded.size = 0, 0
+	if decoded.PoW() == envelope.PoW() {
+		t.Fatalf("PoW unchanged after modifying the contents")
+	}
+}
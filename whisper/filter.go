@@ -12,6 +12,7 @@ import (
 type Filter struct {
 	To     *ecdsa.PublicKey   // Recipient of the message
 	From   *ecdsa.PublicKey   // Sender of the message
+	SymKey []byte             // Symmetric key the message is encrypted with
 	Topics [][]Topic          // Topics to filter messages with
 	Fn     func(msg *Message) // Handler in case of a match
 }
@@ -81,6 +82,7 @@ func NewFilterTopicsFromStringsFlat(data ...string) [][]Topic {
 type filterer struct {
 	to      string                 // Recipient of the message
 	from    string                 // Sender of the message
+	symKey  string                 // Symmetric key the message is encrypted with
 	matcher *topicMatcher          // Topics to filter messages with
 	fn      func(data interface{}) // Handler in case of a match
 }
@@ -96,6 +98,9 @@ func (self filterer) Compare(f filter.Filter) bool {
 	if len(self.from) > 0 && self.from != filter.from {
 		return false
 	}
+	if len(self.symKey) > 0 && self.symKey != filter.symKey {
+		return false
+	}
 	// Check the topic filtering
 	topics := make([]Topic, len(filter.matcher.conditions))
 	for i, group := range filter.matcher.conditions {
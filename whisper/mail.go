@@ -0,0 +1,174 @@
+// Contains the protocol support for mail servers, which archive envelopes and
+// deliver them to clients that were offline when they were sent.
+
+package whisper
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// maxMailBatchSize is the maximum total size of the envelopes in a single
+// mail delivery message.
+const maxMailBatchSize = 16 * MaxEnvelopeSize
+
+var errUnknownPeer = errors.New("unknown whisper peer")
+
+// MailServer archives envelopes received by the node and delivers them to
+// clients on request. Implementations must be safe for concurrent use.
+type MailServer interface {
+	// Archive stores an envelope.
+	Archive(envelope *Envelope)
+
+	// Request returns the archived envelopes matching a request made by the
+	// given node, or an error if the node may not make requests.
+	Request(from discover.NodeID, req *MailRequest) ([]*Envelope, error)
+}
+
+// MailRequest asks a mail server for archived envelopes.
+type MailRequest struct {
+	From   uint32  // Earliest send time of the envelopes (Unix time)
+	To     uint32  // Latest send time of the envelopes (Unix time)
+	Topics []Topic // Topics of the envelopes, any topic if empty
+}
+
+// Matches checks if an envelope was sent within the time range of the request
+// and carries at least one of the requested topics.
+func (self *MailRequest) Matches(envelope *Envelope) bool {
+	sent := envelope.Expiry - envelope.TTL
+	if sent < self.From || sent > self.To {
+		return false
+	}
+	if len(self.Topics) == 0 {
+		return true
+	}
+	for _, want := range self.Topics {
+		for _, have := range envelope.Topics {
+			if want == have {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RegisterMailServer turns the node into a mail server. All envelopes entering
+// the pool are archived with server, and peers can request them. It must be
+// called before the node is started.
+func (self *Whisper) RegisterMailServer(server MailServer) {
+	self.mailServer = server
+}
+
+// RequestMessages asks the mail server at the given peer for archived envelopes.
+// Delivered messages are passed to the installed filters like live ones.
+func (self *Whisper) RequestMessages(id discover.NodeID, req MailRequest) error {
+	self.peerMu.RLock()
+	var target *peer
+	for p := range self.peers {
+		if p.peer.ID() == id && atomic.LoadInt32(&p.started) == 1 {
+			target = p
+			break
+		}
+	}
+	self.peerMu.RUnlock()
+
+	if target == nil {
+		return errUnknownPeer
+	}
+	target.expectMail()
+	return p2p.Send(target.ws, mailRequestCode, &req)
+}
+
+// archive stores an envelope with the mail server, if any, in the background.
+// The caller must hold poolMu.
+func (self *Whisper) archive(envelope *Envelope) {
+	if self.mailServer == nil {
+		return
+	}
+	select {
+	case <-self.quit:
+		return
+	default:
+	}
+	self.archiving.Add(1)
+	go func() {
+		defer self.archiving.Done()
+		self.mailServer.Archive(envelope)
+	}()
+}
+
+// handleMailRequest answers a request for archived envelopes, delivering them
+// in batches. An empty delivery marks the end of the answer, it is also sent
+// if the request can't be served.
+func (self *Whisper) handleMailRequest(p *peer, packet p2p.Msg) error {
+	var req MailRequest
+	if err := packet.Decode(&req); err != nil {
+		return err
+	}
+	var envelopes []*Envelope
+	if self.mailServer != nil {
+		var err error
+		if envelopes, err = self.mailServer.Request(p.peer.ID(), &req); err != nil {
+			glog.V(logger.Debug).Infof("%v: mail request denied: %v", p.peer, err)
+		}
+	}
+	glog.V(logger.Detail).Infof("%v: delivering %d archived envelope(s)", p.peer, len(envelopes))
+
+	var (
+		batch []*Envelope
+		size  int
+	)
+	for _, envelope := range envelopes {
+		if size+envelope.Size() > maxMailBatchSize && len(batch) > 0 {
+			if err := p2p.Send(p.ws, mailDeliveryCode, batch); err != nil {
+				return err
+			}
+			batch, size = nil, 0
+		}
+		batch = append(batch, envelope)
+		size += envelope.Size()
+	}
+	if len(batch) > 0 {
+		if err := p2p.Send(p.ws, mailDeliveryCode, batch); err != nil {
+			return err
+		}
+	}
+	return p2p.Send(p.ws, mailDeliveryCode, []*Envelope{})
+}
+
+// handleMailDelivery passes archived envelopes sent by a mail server to the
+// installed filters. The envelopes are not pooled as they may have expired.
+// Once a request was answered completely, further deliveries are ignored
+// unless requested again.
+func (self *Whisper) handleMailDelivery(p *peer, packet p2p.Msg) error {
+	if !p.mailExpected() {
+		glog.V(logger.Debug).Infof("%v: ignoring unrequested mail delivery", p.peer)
+		return nil
+	}
+	var envelopes []*Envelope
+	if err := packet.Decode(&envelopes); err != nil {
+		return err
+	}
+	if len(envelopes) == 0 {
+		p.mailDelivered()
+		return nil
+	}
+	for _, envelope := range envelopes {
+		if envelope.Size() > MaxEnvelopeSize {
+			continue
+		}
+		self.poolMu.RLock()
+		_, pooled := self.messages[envelope.Hash()]
+		self.poolMu.RUnlock()
+
+		if !pooled {
+			self.postEvent(envelope)
+		}
+	}
+	return nil
+}
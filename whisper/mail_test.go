@@ -0,0 +1,184 @@
+package whisper
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// testMailServer is an in-memory mail server.
+type testMailServer struct {
+	mu        sync.Mutex
+	envelopes []*Envelope
+}
+
+func (s *testMailServer) Archive(envelope *Envelope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.envelopes = append(s.envelopes, envelope)
+}
+
+func (s *testMailServer) Request(from discover.NodeID, req *MailRequest) ([]*Envelope, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*Envelope
+	for _, envelope := range s.envelopes {
+		if req.Matches(envelope) {
+			result = append(result, envelope)
+		}
+	}
+	return result, nil
+}
+
+func (s *testMailServer) archived() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.envelopes)
+}
+
+func TestMailRequestMatches(t *testing.T) {
+	envelope := &Envelope{Expiry: 150, TTL: 50, Topics: NewTopicsFromStrings("chat")}
+
+	tests := []struct {
+		req  MailRequest
+		want bool
+	}{
+		{MailRequest{From: 0, To: 200}, true},
+		{MailRequest{From: 100, To: 100}, true},
+		{MailRequest{From: 101, To: 200}, false},
+		{MailRequest{From: 0, To: 99}, false},
+		{MailRequest{From: 0, To: 200, Topics: NewTopicsFromStrings("chat")}, true},
+		{MailRequest{From: 0, To: 200, Topics: NewTopicsFromStrings("news", "chat")}, true},
+		{MailRequest{From: 0, To: 200, Topics: NewTopicsFromStrings("news")}, false},
+	}
+	for i, test := range tests {
+		if have := test.req.Matches(envelope); have != test.want {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, test.want)
+		}
+	}
+}
+
+func TestMailDelivery(t *testing.T) {
+	// Start a mail server and archive a message sent while the client is offline
+	server, archive := New(), new(testMailServer)
+	server.RegisterMailServer(archive)
+	server.Start()
+	defer server.Stop()
+
+	envelope, err := NewMessage([]byte("offline whisper")).Wrap(DefaultPoW, Options{
+		TTL:    DefaultTTL,
+		Topics: NewTopicsFromStrings("mail"),
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := server.Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	for start := time.Now(); archive.archived() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("envelope not archived")
+		}
+	}
+	// Remove the message from the live pool so only the archive has it
+	server.poolMu.Lock()
+	delete(server.messages, envelope.Hash())
+	server.poolMu.Unlock()
+
+	// Connect a client and request the archived messages
+	client := New()
+	client.Start()
+	defer client.Stop()
+
+	arrived := make(chan *Message, 1)
+	client.Watch(Filter{
+		Topics: NewFilterTopicsFromStrings([]string{"mail"}),
+		Fn:     func(msg *Message) { arrived <- msg },
+	})
+	serverID, clientID := discover.NodeID{1}, discover.NodeID{2}
+	src, dst := p2p.MsgPipe()
+	defer src.Close()
+	go server.handlePeer(p2p.NewPeer(clientID, "", nil), src)
+	go client.handlePeer(p2p.NewPeer(serverID, "", nil), dst)
+
+	req := MailRequest{From: 0, To: uint32(time.Now().Unix()), Topics: NewTopicsFromStrings("mail")}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if err := client.RequestMessages(serverID, req); err == nil {
+			break
+		} else if err != errUnknownPeer || time.Since(start) > time.Second {
+			t.Fatalf("failed to request messages: %v", err)
+		}
+	}
+	select {
+	case msg := <-arrived:
+		if string(msg.Payload) != "offline whisper" {
+			t.Fatalf("payload mismatch: have %q, want %q", msg.Payload, "offline whisper")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("archived message not delivered")
+	}
+	// Deliveries are unexpected again once the request was answered
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		client.peerMu.RLock()
+		expected := false
+		for p := range client.peers {
+			expected = expected || p.mailExpected()
+		}
+		client.peerMu.RUnlock()
+
+		if !expected {
+			break
+		}
+		if time.Since(start) > time.Second {
+			t.Fatalf("mail still expected after delivery")
+		}
+	}
+}
+
+// slowMailServer is a mail server taking a while to archive envelopes.
+type slowMailServer struct {
+	testMailServer
+	closed bool
+}
+
+func (s *slowMailServer) Archive(envelope *Envelope) {
+	time.Sleep(50 * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		panic("envelope archived after close")
+	}
+	s.envelopes = append(s.envelopes, envelope)
+}
+
+func TestMailArchiveStop(t *testing.T) {
+	node, archive := New(), new(slowMailServer)
+	node.RegisterMailServer(archive)
+	node.Start()
+
+	envelope, err := NewMessage([]byte("archived whisper")).Wrap(DefaultPoW, Options{TTL: DefaultTTL})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := node.Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	// Stopping waits for the pending archival, later envelopes aren't archived
+	node.Stop()
+	if n := archive.archived(); n != 1 {
+		t.Fatalf("archived envelope count mismatch: have %d, want 1", n)
+	}
+	archive.mu.Lock()
+	archive.closed = true
+	archive.mu.Unlock()
+
+	late, err := NewMessage([]byte("late whisper")).Wrap(DefaultPoW, Options{TTL: DefaultTTL})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	node.Send(late)
+	time.Sleep(100 * time.Millisecond)
+}
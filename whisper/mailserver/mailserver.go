@@ -0,0 +1,198 @@
+// Package mailserver implements a whisper mail server, which archives the
+// envelopes received by the node in a database and delivers them to trusted
+// clients on request.
+package mailserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/whisper"
+	"github.com/syndtr/goleveldb/leveldb"
+	dberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// maxResults is the maximum number of envelopes returned for a request.
+// Clients can fetch more by requesting the rest of the time range.
+const maxResults = 1000
+
+var errUntrusted = errors.New("peer is not trusted")
+
+// Schema layout of the archive. Envelopes are stored under their send time
+// and hash, and indexed by each of their topics.
+var (
+	envelopePrefix = []byte("e") // envelopePrefix + sent (uint32 BE) + hash -> RLP(envelope)
+	topicPrefix    = []byte("t") // topicPrefix + topic + sent (uint32 BE) + hash -> nil
+)
+
+// MailServer archives whisper envelopes in a LevelDB database.
+type MailServer struct {
+	db      *leveldb.DB
+	trusted map[discover.NodeID]bool
+}
+
+// New opens the archive at path and creates a mail server which serves
+// requests from the given trusted nodes. If no path is given, a temporary
+// in-memory archive is used.
+func New(path string, trusted []discover.NodeID) (*MailServer, error) {
+	var (
+		db  *leveldb.DB
+		err error
+	)
+	if path == "" {
+		db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	} else {
+		db, err = leveldb.OpenFile(path, nil)
+		if _, iscorrupted := err.(*dberrors.ErrCorrupted); iscorrupted {
+			db, err = leveldb.RecoverFile(path, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	server := &MailServer{db: db, trusted: make(map[discover.NodeID]bool)}
+	for _, id := range trusted {
+		server.trusted[id] = true
+	}
+	return server, nil
+}
+
+// Close closes the archive.
+func (self *MailServer) Close() {
+	self.db.Close()
+}
+
+// Archive implements whisper.MailServer, storing an envelope and its
+// topic index entries.
+func (self *MailServer) Archive(envelope *whisper.Envelope) {
+	blob, err := rlp.EncodeToBytes(envelope)
+	if err != nil {
+		glog.V(logger.Error).Infof("failed to encode envelope: %v", err)
+		return
+	}
+	sent, hash := envelope.Expiry-envelope.TTL, envelope.Hash()
+
+	batch := new(leveldb.Batch)
+	batch.Put(envelopeKey(sent, hash), blob)
+	for _, topic := range envelope.Topics {
+		batch.Put(topicKey(topic, sent, hash), nil)
+	}
+	if err := self.db.Write(batch, nil); err != nil {
+		glog.V(logger.Error).Infof("failed to archive envelope: %v", err)
+	}
+}
+
+// Request implements whisper.MailServer, returning the archived envelopes
+// matching the request, ordered by send time.
+func (self *MailServer) Request(from discover.NodeID, req *whisper.MailRequest) ([]*whisper.Envelope, error) {
+	if !self.trusted[from] {
+		return nil, errUntrusted
+	}
+	if req.To < req.From {
+		return nil, nil
+	}
+	if len(req.Topics) == 0 {
+		return self.scan(req)
+	}
+	// Collect the envelope keys from the topic index first. The earliest
+	// results are among the earliest ones of each topic, so those are merged
+	// before the limit is applied.
+	seen := make(map[string]bool)
+	var keys [][]byte
+	for _, topic := range req.Topics {
+		prefix := append(common.CopyBytes(topicPrefix), topic[:]...)
+		it := self.db.NewIterator(timeRange(prefix, req.From, req.To), nil)
+		for n := 0; n < maxResults && it.Next(); n++ {
+			key := append(common.CopyBytes(envelopePrefix), it.Key()[len(prefix):]...)
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				keys = append(keys, key)
+			}
+		}
+		it.Release()
+	}
+	sort.Sort(byteSlices(keys))
+	if len(keys) > maxResults {
+		keys = keys[:maxResults]
+	}
+
+	envelopes := make([]*whisper.Envelope, 0, len(keys))
+	for _, key := range keys {
+		blob, err := self.db.Get(key, nil)
+		if err != nil {
+			continue
+		}
+		if envelope := decodeEnvelope(blob); envelope != nil {
+			envelopes = append(envelopes, envelope)
+		}
+	}
+	return envelopes, nil
+}
+
+// scan returns the envelopes sent within the time range of a request.
+func (self *MailServer) scan(req *whisper.MailRequest) ([]*whisper.Envelope, error) {
+	it := self.db.NewIterator(timeRange(envelopePrefix, req.From, req.To), nil)
+	defer it.Release()
+
+	var envelopes []*whisper.Envelope
+	for it.Next() && len(envelopes) < maxResults {
+		if envelope := decodeEnvelope(it.Value()); envelope != nil {
+			envelopes = append(envelopes, envelope)
+		}
+	}
+	return envelopes, it.Error()
+}
+
+func decodeEnvelope(blob []byte) *whisper.Envelope {
+	envelope := new(whisper.Envelope)
+	if err := rlp.DecodeBytes(blob, envelope); err != nil {
+		glog.V(logger.Error).Infof("failed to decode archived envelope: %v", err)
+		return nil
+	}
+	return envelope
+}
+
+func envelopeKey(sent uint32, hash common.Hash) []byte {
+	key := make([]byte, 0, len(envelopePrefix)+4+len(hash))
+	key = append(key, envelopePrefix...)
+	key = appendTime(key, sent)
+	return append(key, hash[:]...)
+}
+
+func topicKey(topic whisper.Topic, sent uint32, hash common.Hash) []byte {
+	key := make([]byte, 0, len(topicPrefix)+len(topic)+4+len(hash))
+	key = append(key, topicPrefix...)
+	key = append(key, topic[:]...)
+	key = appendTime(key, sent)
+	return append(key, hash[:]...)
+}
+
+// timeRange returns the key range of entries under prefix sent between from
+// and to, inclusive.
+func timeRange(prefix []byte, from, to uint32) *util.Range {
+	start := appendTime(common.CopyBytes(prefix), from)
+	if to == ^uint32(0) {
+		return &util.Range{Start: start, Limit: util.BytesPrefix(prefix).Limit}
+	}
+	return &util.Range{Start: start, Limit: appendTime(common.CopyBytes(prefix), to+1)}
+}
+
+func appendTime(key []byte, t uint32) []byte {
+	var enc [4]byte
+	binary.BigEndian.PutUint32(enc[:], t)
+	return append(key, enc[:]...)
+}
+
+type byteSlices [][]byte
+
+func (s byteSlices) Len() int           { return len(s) }
+func (s byteSlices) Less(i, j int) bool { return string(s[i]) < string(s[j]) }
+func (s byteSlices) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
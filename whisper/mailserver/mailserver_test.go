@@ -0,0 +1,115 @@
+package mailserver
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/whisper"
+)
+
+func testEnvelope(sent uint32, payload byte, topics ...string) *whisper.Envelope {
+	return &whisper.Envelope{
+		Expiry: sent + 50,
+		TTL:    50,
+		Topics: whisper.NewTopicsFromStrings(topics...),
+		Data:   []byte{0, payload},
+	}
+}
+
+func TestMailServerRequest(t *testing.T) {
+	trusted := discover.NodeID{1}
+	server, err := New("", []discover.NodeID{trusted})
+	if err != nil {
+		t.Fatalf("failed to create mail server: %v", err)
+	}
+	defer server.Close()
+
+	archived := []*whisper.Envelope{
+		testEnvelope(100, 1, "chat"),
+		testEnvelope(200, 2, "news"),
+		testEnvelope(300, 3, "chat", "news"),
+		testEnvelope(400, 4),
+	}
+	for _, envelope := range archived {
+		server.Archive(envelope)
+	}
+
+	tests := []struct {
+		req  whisper.MailRequest
+		want []int // indices into archived
+	}{
+		{whisper.MailRequest{From: 0, To: ^uint32(0)}, []int{0, 1, 2, 3}},
+		{whisper.MailRequest{From: 200, To: 300}, []int{1, 2}},
+		{whisper.MailRequest{From: 0, To: 1000, Topics: whisper.NewTopicsFromStrings("chat")}, []int{0, 2}},
+		{whisper.MailRequest{From: 0, To: 1000, Topics: whisper.NewTopicsFromStrings("chat", "news")}, []int{0, 1, 2}},
+		{whisper.MailRequest{From: 150, To: ^uint32(0), Topics: whisper.NewTopicsFromStrings("chat")}, []int{2}},
+		{whisper.MailRequest{From: 500, To: 600}, nil},
+		{whisper.MailRequest{From: 300, To: 100}, nil},
+	}
+	for i, test := range tests {
+		envelopes, err := server.Request(trusted, &test.req)
+		if err != nil {
+			t.Errorf("test %d: request failed: %v", i, err)
+			continue
+		}
+		if len(envelopes) != len(test.want) {
+			t.Errorf("test %d: envelope count mismatch: have %d, want %d", i, len(envelopes), len(test.want))
+			continue
+		}
+		for j, envelope := range envelopes {
+			if want := archived[test.want[j]]; envelope.Hash() != want.Hash() {
+				t.Errorf("test %d: envelope %d mismatch: have %x, want %x", i, j, envelope.Hash(), want.Hash())
+			}
+		}
+	}
+
+	if _, err := server.Request(discover.NodeID{2}, &tests[0].req); err != errUntrusted {
+		t.Errorf("error mismatch for untrusted peer: have %v, want %v", err, errUntrusted)
+	}
+}
+
+// Tests that requests for several topics return the earliest envelopes of all
+// topics if there are more than fit into a response, so that clients can page
+// through them by send time.
+func TestMailServerRequestLimit(t *testing.T) {
+	trusted := discover.NodeID{1}
+	server, err := New("", []discover.NodeID{trusted})
+	if err != nil {
+		t.Fatalf("failed to create mail server: %v", err)
+	}
+	defer server.Close()
+
+	var archived []*whisper.Envelope
+	for i := 0; i < 10; i++ {
+		archived = append(archived, testEnvelope(uint32(100+i), 0, "early"))
+	}
+	for i := 0; i < maxResults; i++ {
+		archived = append(archived, testEnvelope(uint32(10000+i), 0, "late"))
+	}
+	for _, envelope := range archived {
+		server.Archive(envelope)
+	}
+
+	req := whisper.MailRequest{From: 0, To: ^uint32(0), Topics: whisper.NewTopicsFromStrings("late", "early")}
+	var received []*whisper.Envelope
+	for page := 0; page < 2; page++ {
+		envelopes, err := server.Request(trusted, &req)
+		if err != nil {
+			t.Fatalf("page %d: request failed: %v", page, err)
+		}
+		if len(envelopes) == 0 || len(envelopes) > maxResults {
+			t.Fatalf("page %d: envelope count %d out of range (0, %d]", page, len(envelopes), maxResults)
+		}
+		received = append(received, envelopes...)
+		last := envelopes[len(envelopes)-1]
+		req.From = last.Expiry - last.TTL + 1
+	}
+	if len(received) != len(archived) {
+		t.Fatalf("envelope count mismatch: have %d, want %d", len(received), len(archived))
+	}
+	for i, envelope := range received {
+		if envelope.Hash() != archived[i].Hash() {
+			t.Fatalf("envelope %d mismatch: have %x, want %x", i, envelope.Hash(), archived[i].Hash())
+		}
+	}
+}
@@ -4,7 +4,11 @@
 package whisper
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
 	"math/rand"
 	"time"
 
@@ -25,18 +29,23 @@ type Message struct {
 	Sent time.Time     // Time when the message was posted into the network
 	TTL  time.Duration // Maximum time to live allowed for the message
 
-	To   *ecdsa.PublicKey // Message recipient (identity used to decode the message)
-	Hash common.Hash      // Message envelope hash to act as a unique id
+	To     *ecdsa.PublicKey // Message recipient (identity used to decode the message)
+	SymKey []byte           // Symmetric key used to decode the message
+	Hash   common.Hash      // Message envelope hash to act as a unique id
 }
 
 // Options specifies the exact way a message should be wrapped into an Envelope.
 type Options struct {
 	From   *ecdsa.PrivateKey
 	To     *ecdsa.PublicKey
+	SymKey []byte // Symmetric encryption key, mutually exclusive with To
 	TTL    time.Duration
 	Topics []Topic
+	PoW    float64 // Proof of work to reach when sealing, DefaultMinimumPoW if zero
 }
 
+var errRecipients = errors.New("message can't be encrypted to both a public and a symmetric key")
+
 // NewMessage creates and initializes a non-signed, non-encrypted Whisper message.
 func NewMessage(payload []byte) *Message {
 	// Construct an initial flag set: no signature, rest random
@@ -55,7 +64,8 @@ func NewMessage(payload []byte) *Message {
 //
 // pow (Proof Of Work) controls how much time to spend on hashing the message,
 // inherently controlling its priority through the network (smaller hash, bigger
-// priority).
+// priority). Hashing continues past that time until the envelope reaches the
+// proof of work in options.PoW, so that nodes accept it.
 //
 // The user can control the amount of identity, privacy and encryption through
 // the options parameter as follows:
@@ -63,11 +73,20 @@ func NewMessage(payload []byte) *Message {
 //   - options.From != nil && options.To == nil: signed broadcast (known sender)
 //   - options.From == nil && options.To != nil: encrypted anonymous message
 //   - options.From != nil && options.To != nil: encrypted signed message
+//
+// Setting options.SymKey instead of options.To encrypts the message with the
+// symmetric key, making it readable by everyone who holds the key.
 func (self *Message) Wrap(pow time.Duration, options Options) (*Envelope, error) {
+	if options.To != nil && options.SymKey != nil {
+		return nil, errRecipients
+	}
 	// Use the default TTL if non was specified
 	if options.TTL == 0 {
 		options.TTL = DefaultTTL
 	}
+	if options.PoW == 0 {
+		options.PoW = DefaultMinimumPoW
+	}
 	self.TTL = options.TTL
 
 	// Sign and encrypt the message if requested
@@ -81,9 +100,14 @@ func (self *Message) Wrap(pow time.Duration, options Options) (*Envelope, error)
 			return nil, err
 		}
 	}
+	if options.SymKey != nil {
+		if err := self.encryptSymmetric(options.SymKey); err != nil {
+			return nil, err
+		}
+	}
 	// Wrap the processed message, seal it and return
 	envelope := NewEnvelope(options.TTL, options.Topics, self)
-	envelope.Seal(pow)
+	envelope.Seal(pow, options.PoW)
 
 	return envelope, nil
 }
@@ -128,6 +152,50 @@ func (self *Message) decrypt(key *ecdsa.PrivateKey) error {
 	return err
 }
 
+// encryptSymmetric encrypts a message payload with a symmetric key using
+// AES-GCM. The random nonce is prepended to the ciphertext.
+func (self *Message) encryptSymmetric(key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := crand.Read(nonce); err != nil {
+		return err
+	}
+	self.Payload = gcm.Seal(nonce, nonce, self.Payload, nil)
+	return nil
+}
+
+// decryptSymmetric decrypts a payload encrypted with encryptSymmetric.
+// It fails if the payload wasn't encrypted with the given key.
+func (self *Message) decryptSymmetric(key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if len(self.Payload) < gcm.NonceSize() {
+		return errors.New("payload too short")
+	}
+	nonce, ciphertext := self.Payload[:gcm.NonceSize()], self.Payload[gcm.NonceSize():]
+	cleartext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err == nil {
+		self.Payload = cleartext
+	}
+	return err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != SymKeyLength {
+		return nil, errInvalidSymKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // hash calculates the SHA3 checksum of the message flags and payload.
 func (self *Message) hash() []byte {
 	return crypto.Sha3(append([]byte{self.Flags}, self.Payload...))
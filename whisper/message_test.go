@@ -140,3 +140,53 @@ func TestMessageFullCrypto(t *testing.T) {
 		t.Fatalf("public key mismatch: have 0x%x, want 0x%x", p2, p1)
 	}
 }
+
+// Tests whether a message can be encrypted and decrypted with a symmetric key.
+func TestMessageSymmetricEncryptDecrypt(t *testing.T) {
+	key, err := GenerateSymKey()
+	if err != nil {
+		t.Fatalf("failed to create symmetric key: %v", err)
+	}
+	payload := []byte("hello world")
+
+	msg := NewMessage(payload)
+	envelope, err := msg.Wrap(DefaultPoW, Options{
+		SymKey: key,
+	})
+	if err != nil {
+		t.Fatalf("failed to encrypt message: %v", err)
+	}
+	if bytes.Contains(envelope.Data, payload) {
+		t.Fatalf("payload found in encrypted envelope: 0x%x", envelope.Data)
+	}
+	out, err := envelope.OpenSymmetric(key)
+	if err != nil {
+		t.Fatalf("failed to open encrypted message: %v", err)
+	}
+	if !bytes.Equal(out.Payload, payload) {
+		t.Fatalf("payload mismatch: have 0x%x, want 0x%x", out.Payload, payload)
+	}
+	if !bytes.Equal(out.SymKey, key) {
+		t.Fatalf("symmetric key mismatch: have 0x%x, want 0x%x", out.SymKey, key)
+	}
+
+	other, _ := GenerateSymKey()
+	if _, err := envelope.OpenSymmetric(other); err == nil {
+		t.Fatalf("opened message with wrong symmetric key")
+	}
+}
+
+// Tests that a message can't be encrypted to a public and a symmetric key.
+func TestMessageSymmetricAndPublicKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to create recipient crypto key: %v", err)
+	}
+	msg := NewMessage([]byte("hello world"))
+	if _, err := msg.Wrap(DefaultPoW, Options{
+		To:     &key.PublicKey,
+		SymKey: make([]byte, SymKeyLength),
+	}); err != errRecipients {
+		t.Fatalf("error mismatch: have %v, want %v", err, errRecipients)
+	}
+}
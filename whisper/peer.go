@@ -2,6 +2,9 @@ package whisper
 
 import (
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -20,6 +23,17 @@ type peer struct {
 
 	known *set.Set // Messages already known by the peer to avoid wasting bandwidth
 
+	powRequirement uint64  // Minimum PoW requested by the peer (float64 bits, atomic)
+	powTolerated   uint64  // Minimum PoW of envelopes the peer may send (float64 bits, atomic)
+	powAdvertised  float64 // Minimum PoW last advertised to the peer
+
+	bloom           TopicBloom   // Topics the peer is interested in
+	bloomMu         sync.RWMutex // Mutex to sync the peer's topic bloom
+	bloomAdvertised TopicBloom   // Topic bloom last advertised to the peer
+
+	started       int32 // Set once the handshake is done (atomic)
+	mailRequested int32 // Number of mail requests awaiting delivery by the peer (atomic)
+
 	quit chan struct{}
 }
 
@@ -37,6 +51,7 @@ func newPeer(host *Whisper, remote *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 // start initiates the peer updater, periodically broadcasting the whisper packets
 // into the network.
 func (self *peer) start() {
+	atomic.StoreInt32(&self.started, 1)
 	go self.update()
 	glog.V(logger.Debug).Infof("%v: whisper started", self.peer)
 }
@@ -51,9 +66,15 @@ func (self *peer) stop() {
 // verifies the remote status too.
 func (self *peer) handshake() error {
 	// Send the handshake status message asynchronously
+	pow := self.host.MinimumPoW()
+	self.powAdvertised = pow
+	self.setToleratedPoW(pow)
+	bloom := self.host.TopicBloom()
+	self.bloomAdvertised = bloom
+
 	errc := make(chan error, 1)
 	go func() {
-		errc <- p2p.SendItems(self.ws, statusCode, protocolVersion)
+		errc <- p2p.SendItems(self.ws, statusCode, protocolVersion, math.Float64bits(pow), bloom[:])
 	}()
 	// Fetch the remote status packet and verify protocol match
 	packet, err := self.ws.ReadMsg()
@@ -74,6 +95,22 @@ func (self *peer) handshake() error {
 	if peerVersion != protocolVersion {
 		return fmt.Errorf("protocol version mismatch %d != %d", peerVersion, protocolVersion)
 	}
+	peerPoW, err := s.Uint()
+	if err != nil {
+		return fmt.Errorf("bad status message: %v", err)
+	}
+	self.setPoWRequirement(math.Float64frombits(peerPoW))
+
+	blob, err := s.Bytes()
+	if err != nil {
+		return fmt.Errorf("bad status message: %v", err)
+	}
+	if len(blob) != TopicBloomSize {
+		return fmt.Errorf("bad status message: invalid topic bloom size %d", len(blob))
+	}
+	var peerBloom TopicBloom
+	copy(peerBloom[:], blob)
+	self.setTopicBloom(peerBloom)
 	// Wait until out own status is consumed too
 	if err := <-errc; err != nil {
 		return fmt.Errorf("failed to send status packet: %v", err)
@@ -95,6 +132,14 @@ func (self *peer) update() {
 			self.expire()
 
 		case <-transmit.C:
+			if err := self.advertisePoW(); err != nil {
+				glog.V(logger.Info).Infof("%v: PoW advertisement failed: %v", self.peer, err)
+				return
+			}
+			if err := self.advertiseBloom(); err != nil {
+				glog.V(logger.Info).Infof("%v: topic bloom advertisement failed: %v", self.peer, err)
+				return
+			}
 			if err := self.broadcast(); err != nil {
 				glog.V(logger.Info).Infof("%v: broadcast failed: %v", self.peer, err)
 				return
@@ -106,6 +151,86 @@ func (self *peer) update() {
 	}
 }
 
+// advertisePoW sends the minimum PoW of the host to the peer if it changed
+// since it was last advertised. Envelopes meeting the previous requirement
+// are tolerated until the next cycle, as the peer may have sent them before
+// receiving the update.
+func (self *peer) advertisePoW() error {
+	pow := self.host.MinimumPoW()
+	self.setToleratedPoW(math.Min(pow, self.powAdvertised))
+	if pow == self.powAdvertised {
+		return nil
+	}
+	self.powAdvertised = pow
+	return p2p.Send(self.ws, powRequirementCode, math.Float64bits(pow))
+}
+
+// advertiseBloom sends the topic bloom of the host to the peer if it changed
+// since it was last advertised.
+func (self *peer) advertiseBloom() error {
+	bloom := self.host.TopicBloom()
+	if bloom == self.bloomAdvertised {
+		return nil
+	}
+	self.bloomAdvertised = bloom
+	return p2p.Send(self.ws, topicBloomCode, bloom[:])
+}
+
+func (self *peer) setTopicBloom(bloom TopicBloom) {
+	self.bloomMu.Lock()
+	defer self.bloomMu.Unlock()
+
+	self.bloom = bloom
+}
+
+// topicBloom returns the bloom filter of topics the peer is interested in.
+func (self *peer) topicBloom() TopicBloom {
+	self.bloomMu.RLock()
+	defer self.bloomMu.RUnlock()
+
+	return self.bloom
+}
+
+func (self *peer) setPoWRequirement(pow float64) {
+	atomic.StoreUint64(&self.powRequirement, math.Float64bits(pow))
+}
+
+// powRequired returns the minimum PoW of envelopes the peer accepts.
+func (self *peer) powRequired() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&self.powRequirement))
+}
+
+func (self *peer) setToleratedPoW(pow float64) {
+	atomic.StoreUint64(&self.powTolerated, math.Float64bits(pow))
+}
+
+// toleratedPoW returns the minimum PoW of envelopes the peer knows we accept.
+func (self *peer) toleratedPoW() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&self.powTolerated))
+}
+
+// expectMail records that archived messages were requested from the peer.
+func (self *peer) expectMail() {
+	atomic.AddInt32(&self.mailRequested, 1)
+}
+
+// mailDelivered records that the peer finished delivering the archived messages
+// of a request.
+func (self *peer) mailDelivered() {
+	for {
+		pending := atomic.LoadInt32(&self.mailRequested)
+		if pending == 0 || atomic.CompareAndSwapInt32(&self.mailRequested, pending, pending-1) {
+			return
+		}
+	}
+}
+
+// mailExpected checks whether archived messages were requested from the peer
+// and not all of them were delivered yet.
+func (self *peer) mailExpected() bool {
+	return atomic.LoadInt32(&self.mailRequested) > 0
+}
+
 // mark marks an envelope known to the peer so that it won't be sent back.
 func (self *peer) mark(envelope *Envelope) {
 	self.known.Add(envelope.Hash())
@@ -139,13 +264,15 @@ func (self *peer) expire() {
 }
 
 // broadcast iterates over the collection of envelopes and transmits yet unknown
-// ones over the network.
+// ones the peer is interested in over the network.
 func (self *peer) broadcast() error {
 	// Fetch the envelopes and collect the unknown ones
 	envelopes := self.host.envelopes()
 	transmit := make([]*Envelope, 0, len(envelopes))
+	required := self.powRequired()
+	bloom := self.topicBloom()
 	for _, envelope := range envelopes {
-		if !self.marked(envelope) {
+		if !self.marked(envelope) && envelope.PoW() >= required && bloom.Matches(envelope.Topics) {
 			transmit = append(transmit, envelope)
 			self.mark(envelope)
 		}
@@ -1,6 +1,7 @@
 package whisper
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -8,6 +9,11 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 )
 
+// testStatus is the handshake status sent by a node with default settings.
+var testStatus = []interface{}{protocolVersion, math.Float64bits(DefaultMinimumPoW), testFullBloom[:]}
+
+var testFullBloom = FullTopicBloom()
+
 type testPeer struct {
 	client *Whisper
 	stream *p2p.MsgPipeRW
@@ -42,11 +48,11 @@ func startTestPeer() *testPeer {
 func startTestPeerInited() (*testPeer, error) {
 	peer := startTestPeer()
 
-	if err := p2p.ExpectMsg(peer.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(peer.stream, statusCode, testStatus); err != nil {
 		peer.stream.Close()
 		return nil, err
 	}
-	if err := p2p.SendItems(peer.stream, statusCode, protocolVersion); err != nil {
+	if err := p2p.Send(peer.stream, statusCode, testStatus); err != nil {
 		peer.stream.Close()
 		return nil, err
 	}
@@ -57,7 +63,7 @@ func TestPeerStatusMessage(t *testing.T) {
 	tester := startTestPeer()
 
 	// Wait for the handshake status message and check it
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, testStatus); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Terminate the node
@@ -74,7 +80,7 @@ func TestPeerHandshakeFail(t *testing.T) {
 	tester := startTestPeer()
 
 	// Wait for and check the handshake
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, testStatus); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Send an invalid handshake status and verify disconnect
@@ -88,15 +94,32 @@ func TestPeerHandshakeFail(t *testing.T) {
 	}
 }
 
+func TestPeerHandshakeIncomplete(t *testing.T) {
+	tester := startTestPeer()
+
+	if err := p2p.ExpectMsg(tester.stream, statusCode, testStatus); err != nil {
+		t.Fatalf("status message mismatch: %v", err)
+	}
+	// Send a status without PoW requirement and topic bloom and verify disconnect
+	if err := p2p.SendItems(tester.stream, statusCode, protocolVersion); err != nil {
+		t.Fatalf("failed to send incomplete status: %v", err)
+	}
+	select {
+	case <-tester.termed:
+	case <-time.After(time.Second):
+		t.Fatalf("remote close timed out")
+	}
+}
+
 func TestPeerHandshakeSuccess(t *testing.T) {
 	tester := startTestPeer()
 
 	// Wait for and check the handshake
-	if err := p2p.ExpectMsg(tester.stream, statusCode, []uint64{protocolVersion}); err != nil {
+	if err := p2p.ExpectMsg(tester.stream, statusCode, testStatus); err != nil {
 		t.Fatalf("status message mismatch: %v", err)
 	}
 	// Send a valid handshake status and make sure connection stays live
-	if err := p2p.SendItems(tester.stream, statusCode, protocolVersion); err != nil {
+	if err := p2p.Send(tester.stream, statusCode, testStatus); err != nil {
 		t.Fatalf("failed to send status: %v", err)
 	}
 	select {
@@ -148,6 +171,94 @@ func TestPeerSend(t *testing.T) {
 	}
 }
 
+func TestPeerPoWRequirement(t *testing.T) {
+	// Start a tester and execute the handshake
+	tester, err := startTestPeerInited()
+	if err != nil {
+		t.Fatalf("failed to start initialized peer: %v", err)
+	}
+	defer tester.stream.Close()
+
+	// Changes of the local requirement should be advertised
+	tester.client.SetMinimumPoW(1)
+	if err := p2p.ExpectMsg(tester.stream, powRequirementCode, math.Float64bits(1)); err != nil {
+		t.Fatalf("PoW requirement mismatch: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+	// Envelopes below the remote requirement should not be forwarded
+	if err := p2p.Send(tester.stream, powRequirementCode, math.Float64bits(math.Inf(1))); err != nil {
+		t.Fatalf("failed to send PoW requirement: %v", err)
+	}
+	envelope, err := NewMessage([]byte("peer PoW test message")).Wrap(DefaultPoW, Options{
+		TTL: DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	tester.client.SetMinimumPoW(0)
+	if err := tester.client.Send(envelope); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, powRequirementCode, math.Float64bits(0)); err != nil {
+		t.Fatalf("PoW requirement mismatch: %v", err)
+	}
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+}
+
+func TestPeerTopicBloom(t *testing.T) {
+	tester := startTestPeer()
+	defer tester.stream.Close()
+
+	// Watch a topic and pool envelopes before the handshake completes
+	local, remote := NewTopicFromString("local"), NewTopicFromString("remote")
+	tester.client.SetTopicFiltering(true)
+	tester.client.Watch(Filter{
+		Topics: [][]Topic{{local}},
+		Fn:     func(*Message) {},
+	})
+	wanted, err := NewMessage([]byte("wanted")).Wrap(DefaultPoW, Options{TTL: DefaultTTL, Topics: []Topic{remote}})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	unwanted, err := NewMessage([]byte("unwanted")).Wrap(DefaultPoW, Options{TTL: DefaultTTL, Topics: []Topic{local}})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	for _, envelope := range []*Envelope{wanted, unwanted} {
+		if err := tester.client.Send(envelope); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+	// Execute the handshake, exchanging the topics of interest
+	localBloom := local.Bloom()
+	status := []interface{}{protocolVersion, math.Float64bits(DefaultMinimumPoW), localBloom[:]}
+	if err := p2p.ExpectMsg(tester.stream, statusCode, status); err != nil {
+		t.Fatalf("status message mismatch: %v", err)
+	}
+	remoteBloom := remote.Bloom()
+	if err := p2p.SendItems(tester.stream, statusCode, protocolVersion, uint64(0), remoteBloom[:]); err != nil {
+		t.Fatalf("failed to send status: %v", err)
+	}
+	// Only envelopes matching the remote bloom should be forwarded
+	if err := p2p.ExpectMsg(tester.stream, messagesCode, []interface{}{wanted}); err != nil {
+		t.Fatalf("message mismatch: %v", err)
+	}
+	// Changes of the watched topics should be advertised
+	other := NewTopicFromString("other")
+	tester.client.Watch(Filter{
+		Topics: [][]Topic{{other}},
+		Fn:     func(*Message) {},
+	})
+	localBloom.Add(other)
+	if err := p2p.ExpectMsg(tester.stream, topicBloomCode, localBloom[:]); err != nil {
+		t.Fatalf("topic bloom mismatch: %v", err)
+	}
+}
+
 func TestPeerDeliver(t *testing.T) {
 	// Start a tester and execute the handshake
 	tester, err := startTestPeerInited()
@@ -0,0 +1,48 @@
+// Contains the generation and derivation of symmetric message keys.
+
+package whisper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// SymKeyLength is the length of symmetric keys (AES-256).
+	SymKeyLength = 32
+
+	symKeyIterations = 65536 // PBKDF2 iterations for password-derived keys
+)
+
+var errInvalidSymKey = errors.New("invalid symmetric key length")
+
+// Salts separating the key spaces of passwords and topics.
+var (
+	passwordKeySalt = []byte("whisper-password-key")
+	topicKeySalt    = []byte("whisper-topic-key")
+)
+
+// GenerateSymKey creates a new random symmetric key.
+func GenerateSymKey() ([]byte, error) {
+	key := make([]byte, SymKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeriveSymKey derives a symmetric key from a password. All members of a
+// group who know the password derive the same key.
+func DeriveSymKey(password string) []byte {
+	return pbkdf2.Key([]byte(password), passwordKeySalt, symKeyIterations, SymKeyLength, sha256.New)
+}
+
+// TopicSymKey derives a symmetric key from a topic string. Envelopes only
+// carry a short hash of the topic, so messages of public channels stay
+// unreadable to nodes which don't know the full topic name.
+func TopicSymKey(topic string) []byte {
+	return pbkdf2.Key([]byte(topic), topicKeySalt, 1, SymKeyLength, sha256.New)
+}
@@ -0,0 +1,40 @@
+package whisper
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeriveSymKey(t *testing.T) {
+	key := DeriveSymKey("secret")
+	if len(key) != SymKeyLength {
+		t.Fatalf("key length mismatch: have %d, want %d", len(key), SymKeyLength)
+	}
+	if !bytes.Equal(key, DeriveSymKey("secret")) {
+		t.Errorf("derivation not deterministic")
+	}
+	if bytes.Equal(key, DeriveSymKey("other secret")) {
+		t.Errorf("same key for different passwords")
+	}
+	if bytes.Equal(key, TopicSymKey("secret")) {
+		t.Errorf("same key for password and topic")
+	}
+}
+
+func TestSymKeyStore(t *testing.T) {
+	w := New()
+	if err := w.AddSymKey("short", []byte{1, 2, 3}); err != errInvalidSymKey {
+		t.Fatalf("error mismatch for short key: have %v, want %v", err, errInvalidSymKey)
+	}
+	key := TopicSymKey("chat")
+	if err := w.AddSymKey("chat", key); err != nil {
+		t.Fatalf("failed to add key: %v", err)
+	}
+	if !w.HasSymKey("chat") || !bytes.Equal(w.GetSymKey("chat"), key) {
+		t.Fatalf("stored key mismatch")
+	}
+	w.DeleteSymKey("chat")
+	if w.HasSymKey("chat") {
+		t.Fatalf("key still present after deletion")
+	}
+}
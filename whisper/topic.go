@@ -50,6 +50,78 @@ func (self *Topic) String() string {
 	return string(self[:])
 }
 
+// TopicBloomSize is the size in bytes of a topic bloom filter.
+const TopicBloomSize = 64
+
+// TopicBloom is a bloom filter over a set of topics, used by nodes to advertise
+// the topics they are interested in to their peers. Each topic sets three of the
+// 512 bits, each indexed by one of its first three bytes extended with a bit of
+// the fourth one.
+type TopicBloom [TopicBloomSize]byte
+
+// FullTopicBloom returns a bloom filter matching all topics, advertised by nodes
+// that relay every envelope.
+func FullTopicBloom() TopicBloom {
+	var bloom TopicBloom
+	for i := range bloom {
+		bloom[i] = 0xff
+	}
+	return bloom
+}
+
+// Bloom returns the bloom filter containing only this topic.
+func (self Topic) Bloom() TopicBloom {
+	var bloom TopicBloom
+	bloom.Add(self)
+	return bloom
+}
+
+// bloomBits returns the indices of the bloom filter bits set by the topic.
+func (self Topic) bloomBits() [3]int {
+	var bits [3]int
+	for i := range bits {
+		bits[i] = int(self[i]) + 256*int((self[3]>>uint(i))&1)
+	}
+	return bits
+}
+
+// Add inserts a topic into the bloom filter.
+func (self *TopicBloom) Add(topic Topic) {
+	for _, bit := range topic.bloomBits() {
+		self[bit/8] |= 1 << uint(bit%8)
+	}
+}
+
+// Contains checks if a topic is (probabilistically) part of the bloom filter.
+func (self *TopicBloom) Contains(topic Topic) bool {
+	for _, bit := range topic.bloomBits() {
+		if self[bit/8]&(1<<uint(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Full checks whether the bloom filter matches all topics.
+func (self *TopicBloom) Full() bool {
+	return *self == FullTopicBloom()
+}
+
+// Matches checks whether an envelope with the given topics may be of interest
+// to the owner of the bloom filter, that is if any of the topics is contained.
+// Envelopes without topics only match a full bloom filter.
+func (self *TopicBloom) Matches(topics []Topic) bool {
+	if self.Full() {
+		return true
+	}
+	for _, topic := range topics {
+		if self.Contains(topic) {
+			return true
+		}
+	}
+	return false
+}
+
 // topicMatcher is a filter expression to verify if a list of topics contained
 // in an arriving message matches some topic conditions. The topic matcher is
 // built up of a list of conditions, each of which must be satisfied by the
@@ -197,3 +197,40 @@ func TestTopicMatcher(t *testing.T) {
 		}
 	}
 }
+
+func TestTopicBloom(t *testing.T) {
+	topics := NewTopicsFromStrings("a", "b", "c")
+
+	// An empty bloom should match nothing
+	var bloom TopicBloom
+	if bloom.Matches(topics) {
+		t.Fatalf("empty bloom matches %v", topics)
+	}
+	// Added topics should be contained, others not
+	bloom.Add(topics[0])
+	if !bloom.Contains(topics[0]) {
+		t.Fatalf("bloom doesn't contain added topic %x", topics[0])
+	}
+	for _, topic := range topics[1:] {
+		if bloom.Contains(topic) {
+			t.Fatalf("bloom contains non-added topic %x", topic)
+		}
+	}
+	if !bloom.Matches(topics) {
+		t.Fatalf("bloom doesn't match %v", topics)
+	}
+	if bloom.Matches(topics[1:]) {
+		t.Fatalf("bloom matches %v", topics[1:])
+	}
+	if bloom != topics[0].Bloom() {
+		t.Fatalf("bloom mismatch: have %x, want %x", bloom, topics[0].Bloom())
+	}
+	// A full bloom should match everything, even envelopes without topics
+	full := FullTopicBloom()
+	if !full.Matches(nil) {
+		t.Fatalf("full bloom doesn't match topicless envelope")
+	}
+	if bloom.Matches(nil) {
+		t.Fatalf("partial bloom matches topicless envelope")
+	}
+}
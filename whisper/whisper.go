@@ -2,7 +2,12 @@ package whisper
 
 import (
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -16,10 +21,14 @@ import (
 )
 
 const (
-	statusCode   = 0x00
-	messagesCode = 0x01
-
-	protocolVersion uint64 = 0x02
+	statusCode         = 0x00
+	messagesCode       = 0x01
+	powRequirementCode = 0x02
+	mailRequestCode    = 0x03
+	mailDeliveryCode   = 0x04
+	topicBloomCode     = 0x05
+
+	protocolVersion uint64 = 0x03
 	protocolName           = "shh"
 
 	signatureFlag   = byte(1 << 7)
@@ -27,11 +36,25 @@ const (
 
 	expirationCycle   = 800 * time.Millisecond
 	transmissionCycle = 300 * time.Millisecond
+
+	maxSealTime = 10 * time.Second // Maximum extra time spent sealing to reach the target PoW
 )
 
 const (
-	DefaultTTL = 50 * time.Second
-	DefaultPoW = 50 * time.Millisecond
+	DefaultTTL        = 50 * time.Second
+	DefaultPoW        = 50 * time.Millisecond
+	DefaultMinimumPoW = 0.2 // Minimum proof of work of accepted envelopes
+
+	MaxEnvelopeSize = 64 * 1024        // Maximum size of an encoded envelope
+	maxPoolSize     = 32 * 1024 * 1024 // Maximum total size of pooled envelopes
+)
+
+var (
+	errOversized = errors.New("envelope too large")
+	errLowPoW    = errors.New("envelope proof of work below minimum")
+	errPoolFull  = errors.New("message pool full")
+
+	errUnknownIdentity = errors.New("unknown identity")
 )
 
 type MessageEvent struct {
@@ -46,12 +69,28 @@ type Whisper struct {
 	protocol p2p.Protocol
 	filters  *filter.Filters
 
-	keys map[string]*ecdsa.PrivateKey
+	watched        map[int][][]Topic // Topic conditions of the installed filters
+	watchMu        sync.RWMutex      // Mutex to sync the watched topics
+	topicFiltering int32             // Set if only watched topics are requested from peers (atomic)
+
+	keys     map[string]*ecdsa.PrivateKey // Identities by public key
+	keyStore crypto.KeyStore2             // Persistent storage of the identities, if any
+	keyAuth  string                       // Passphrase of the identity key store
+	keyMu    sync.RWMutex                 // Mutex to sync the identities
+
+	symKeys  map[string][]byte // Symmetric keys by name
+	symKeyMu sync.RWMutex      // Mutex to sync the symmetric key set
 
 	messages    map[common.Hash]*Envelope // Pool of messages currently tracked by this node
 	expirations map[uint32]*set.SetNonTS  // Message expiration pool (TODO: something lighter)
+	poolSize    int                       // Total size of the pooled envelopes
 	poolMu      sync.RWMutex              // Mutex to sync the message and expiration pools
 
+	minPoW uint64 // Minimum PoW of accepted envelopes (float64 bits, atomic)
+
+	mailServer MailServer     // Archive of received envelopes, if running as a mail server
+	archiving  sync.WaitGroup // Envelopes being stored with the mail server
+
 	peers  map[*peer]struct{} // Set of currently active peers
 	peerMu sync.RWMutex       // Mutex to sync the active peer set
 
@@ -63,10 +102,13 @@ type Whisper struct {
 func New() *Whisper {
 	whisper := &Whisper{
 		filters:     filter.New(),
+		watched:     make(map[int][][]Topic),
 		keys:        make(map[string]*ecdsa.PrivateKey),
+		symKeys:     make(map[string][]byte),
 		messages:    make(map[common.Hash]*Envelope),
 		expirations: make(map[uint32]*set.SetNonTS),
 		peers:       make(map[*peer]struct{}),
+		minPoW:      math.Float64bits(DefaultMinimumPoW),
 		quit:        make(chan struct{}),
 	}
 	whisper.filters.Start()
@@ -75,7 +117,7 @@ func New() *Whisper {
 	whisper.protocol = p2p.Protocol{
 		Name:    protocolName,
 		Version: uint(protocolVersion),
-		Length:  2,
+		Length:  6,
 		Run:     whisper.handlePeer,
 	}
 
@@ -92,46 +134,224 @@ func (self *Whisper) Version() uint {
 	return self.protocol.Version
 }
 
+// MinimumPoW returns the minimum proof of work of envelopes accepted by the
+// node. See Envelope.PoW for how it is calculated.
+func (self *Whisper) MinimumPoW() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&self.minPoW))
+}
+
+// SetMinimumPoW sets the minimum proof of work of accepted envelopes. The new
+// requirement is advertised to all connected peers.
+func (self *Whisper) SetMinimumPoW(pow float64) {
+	atomic.StoreUint64(&self.minPoW, math.Float64bits(pow))
+}
+
 // NewIdentity generates a new cryptographic identity for the client, and injects
 // it into the known identities for message decryption.
+//
+// If a key store is set, the identity is also persisted; failures to do so are
+// only logged, the identity remains usable until the node is restarted.
 func (self *Whisper) NewIdentity() *ecdsa.PrivateKey {
 	key, err := crypto.GenerateKey()
 	if err != nil {
 		panic(err)
 	}
-	self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+	if err := self.ImportIdentity(key); err != nil {
+		glog.V(logger.Error).Infof("Failed to store whisper identity: %v", err)
 
+		self.keyMu.Lock()
+		self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+		self.keyMu.Unlock()
+	}
 	return key
 }
 
+// ImportIdentity injects an existing private key into the known identities, and
+// persists it if a key store is set. The key is only injected if it could be
+// persisted.
+func (self *Whisper) ImportIdentity(key *ecdsa.PrivateKey) error {
+	self.keyMu.Lock()
+	defer self.keyMu.Unlock()
+
+	if self.keyStore != nil {
+		if err := self.keyStore.StoreKey(crypto.NewKeyFromECDSA(key), self.keyAuth); err != nil {
+			return err
+		}
+	}
+	self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+	return nil
+}
+
+// DeleteIdentity removes an identity, including from the key store if set.
+func (self *Whisper) DeleteIdentity(key *ecdsa.PublicKey) error {
+	self.keyMu.Lock()
+	defer self.keyMu.Unlock()
+
+	id := string(crypto.FromECDSAPub(key))
+	if _, ok := self.keys[id]; !ok {
+		return errUnknownIdentity
+	}
+	if self.keyStore != nil {
+		if err := self.keyStore.DeleteKey(crypto.PubkeyToAddress(*key), self.keyAuth); err != nil {
+			return err
+		}
+	}
+	delete(self.keys, id)
+	return nil
+}
+
 // HasIdentity checks if the the whisper node is configured with the private key
 // of the specified public pair.
 func (self *Whisper) HasIdentity(key *ecdsa.PublicKey) bool {
-	return self.keys[string(crypto.FromECDSAPub(key))] != nil
+	return self.GetIdentity(key) != nil
 }
 
 // GetIdentity retrieves the private key of the specified public identity.
 func (self *Whisper) GetIdentity(key *ecdsa.PublicKey) *ecdsa.PrivateKey {
+	self.keyMu.RLock()
+	defer self.keyMu.RUnlock()
+
 	return self.keys[string(crypto.FromECDSAPub(key))]
 }
 
+// Identities returns the public keys of all the known identities.
+func (self *Whisper) Identities() []*ecdsa.PublicKey {
+	self.keyMu.RLock()
+	defer self.keyMu.RUnlock()
+
+	ids := make([]*ecdsa.PublicKey, 0, len(self.keys))
+	for _, key := range self.keys {
+		ids = append(ids, &key.PublicKey)
+	}
+	return ids
+}
+
+// SetKeyStore sets the persistent storage of the identities, encrypted with the
+// given passphrase, and loads all the identities already stored in it.
+func (self *Whisper) SetKeyStore(ks crypto.KeyStore2, auth string) error {
+	addresses, err := ks.GetKeyAddresses()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	keys := make([]*ecdsa.PrivateKey, len(addresses))
+	for i, addr := range addresses {
+		key, err := ks.GetKey(addr, auth)
+		if err != nil {
+			return fmt.Errorf("failed to load identity %x: %v", addr, err)
+		}
+		keys[i] = key.PrivateKey
+	}
+	self.keyMu.Lock()
+	defer self.keyMu.Unlock()
+
+	self.keyStore, self.keyAuth = ks, auth
+	for _, key := range keys {
+		self.keys[string(crypto.FromECDSAPub(&key.PublicKey))] = key
+	}
+	glog.V(logger.Info).Infof("Loaded %d whisper identities", len(keys))
+	return nil
+}
+
+// AddSymKey stores a symmetric key under the given name, replacing any
+// previous key of that name. Messages encrypted with stored keys are
+// decrypted automatically.
+func (self *Whisper) AddSymKey(name string, key []byte) error {
+	if len(key) != SymKeyLength {
+		return errInvalidSymKey
+	}
+	self.symKeyMu.Lock()
+	defer self.symKeyMu.Unlock()
+
+	self.symKeys[name] = common.CopyBytes(key)
+	return nil
+}
+
+// HasSymKey checks if a symmetric key is stored under the given name.
+func (self *Whisper) HasSymKey(name string) bool {
+	return self.GetSymKey(name) != nil
+}
+
+// GetSymKey retrieves the symmetric key stored under the given name.
+func (self *Whisper) GetSymKey(name string) []byte {
+	self.symKeyMu.RLock()
+	defer self.symKeyMu.RUnlock()
+
+	return self.symKeys[name]
+}
+
+// DeleteSymKey removes the symmetric key stored under the given name.
+func (self *Whisper) DeleteSymKey(name string) {
+	self.symKeyMu.Lock()
+	defer self.symKeyMu.Unlock()
+
+	delete(self.symKeys, name)
+}
+
 // Watch installs a new message handler to run in case a matching packet arrives
 // from the whisper network.
 func (self *Whisper) Watch(options Filter) int {
 	filter := filterer{
 		to:      string(crypto.FromECDSAPub(options.To)),
 		from:    string(crypto.FromECDSAPub(options.From)),
+		symKey:  string(options.SymKey),
 		matcher: newTopicMatcher(options.Topics...),
 		fn: func(data interface{}) {
 			options.Fn(data.(*Message))
 		},
 	}
-	return self.filters.Install(filter)
+	id := self.filters.Install(filter)
+
+	self.watchMu.Lock()
+	self.watched[id] = options.Topics
+	self.watchMu.Unlock()
+
+	return id
 }
 
 // Unwatch removes an installed message handler.
 func (self *Whisper) Unwatch(id int) {
 	self.filters.Uninstall(id)
+
+	self.watchMu.Lock()
+	delete(self.watched, id)
+	self.watchMu.Unlock()
+}
+
+// SetTopicFiltering sets whether the node only requests envelopes matching the
+// topics of its installed filters from its peers. Nodes filtering by topic save
+// bandwidth, but don't relay envelopes they are not interested in.
+func (self *Whisper) SetTopicFiltering(enabled bool) {
+	var flag int32
+	if enabled {
+		flag = 1
+	}
+	atomic.StoreInt32(&self.topicFiltering, flag)
+}
+
+// TopicBloom returns the bloom filter of topics advertised to peers. Unless
+// topic filtering is enabled, or if any filter accepts all topics, it matches
+// every envelope.
+func (self *Whisper) TopicBloom() TopicBloom {
+	if atomic.LoadInt32(&self.topicFiltering) == 0 {
+		return FullTopicBloom()
+	}
+	self.watchMu.RLock()
+	defer self.watchMu.RUnlock()
+
+	var bloom TopicBloom
+	for _, conditions := range self.watched {
+		wildcard := true
+		for _, condition := range conditions {
+			for _, topic := range condition {
+				bloom.Add(topic)
+				wildcard = false
+			}
+		}
+		if wildcard {
+			return FullTopicBloom()
+		}
+	}
+	return bloom
 }
 
 // Send injects a message into the whisper send queue, to be distributed in the
@@ -146,7 +366,12 @@ func (self *Whisper) Start() {
 }
 
 func (self *Whisper) Stop() {
+	// Envelopes are archived under the pool lock, so none are stored with the
+	// mail server once the pending ones are done, and it can be closed.
+	self.poolMu.Lock()
 	close(self.quit)
+	self.poolMu.Unlock()
+	self.archiving.Wait()
 	glog.V(logger.Info).Infoln("Whisper stopped")
 }
 
@@ -195,19 +420,63 @@ func (self *Whisper) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 		if err != nil {
 			return err
 		}
+		switch packet.Code {
+		case powRequirementCode:
+			var pow uint64
+			if err := packet.Decode(&pow); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to decode PoW requirement: %v", peer, err)
+				continue
+			}
+			whisperPeer.setPoWRequirement(math.Float64frombits(pow))
+			continue
+
+		case topicBloomCode:
+			var blob []byte
+			if err := packet.Decode(&blob); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to decode topic bloom: %v", peer, err)
+				continue
+			}
+			if len(blob) != TopicBloomSize {
+				glog.V(logger.Info).Infof("%v: invalid topic bloom size %d", peer, len(blob))
+				continue
+			}
+			var bloom TopicBloom
+			copy(bloom[:], blob)
+			whisperPeer.setTopicBloom(bloom)
+			continue
+
+		case mailRequestCode:
+			if err := self.handleMailRequest(whisperPeer, packet); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to handle mail request: %v", peer, err)
+			}
+			continue
+
+		case mailDeliveryCode:
+			if err := self.handleMailDelivery(whisperPeer, packet); err != nil {
+				glog.V(logger.Info).Infof("%v: failed to handle mail delivery: %v", peer, err)
+			}
+			continue
+		}
 		var envelopes []*Envelope
 		if err := packet.Decode(&envelopes); err != nil {
 			glog.V(logger.Info).Infof("%v: failed to decode envelope: %v", peer, err)
 			continue
 		}
 		// Inject all envelopes into the internal pool
+		invalid := false
 		for _, envelope := range envelopes {
 			if err := self.add(envelope); err != nil {
-				// TODO Punish peer here. Invalid envelope.
 				glog.V(logger.Debug).Infof("%v: failed to pool envelope: %v", peer, err)
+				// Only punish the peer for envelopes it knew we don't accept
+				if err == errOversized || (err == errLowPoW && envelope.PoW() < whisperPeer.toleratedPoW()) {
+					invalid = true
+				}
 			}
 			whisperPeer.mark(envelope)
 		}
+		if invalid {
+			peer.Report(p2p.ProtocolError)
+		}
 	}
 }
 
@@ -215,6 +484,13 @@ func (self *Whisper) handlePeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 // whisper network. It also inserts the envelope into the expiration pool at the
 // appropriate time-stamp.
 func (self *Whisper) add(envelope *Envelope) error {
+	// Reject envelopes which are too large or don't carry enough work
+	if envelope.Size() > MaxEnvelopeSize {
+		return errOversized
+	}
+	if envelope.PoW() < self.MinimumPoW() {
+		return errLowPoW
+	}
 	self.poolMu.Lock()
 	defer self.poolMu.Unlock()
 
@@ -224,7 +500,11 @@ func (self *Whisper) add(envelope *Envelope) error {
 		glog.V(logger.Detail).Infof("whisper envelope already cached: %x\n", envelope)
 		return nil
 	}
+	if self.poolSize+envelope.Size() > maxPoolSize {
+		return errPoolFull
+	}
 	self.messages[hash] = envelope
+	self.poolSize += envelope.Size()
 
 	// Insert the message into the expiration pool for later removal
 	if self.expirations[envelope.Expiry] == nil {
@@ -233,8 +513,9 @@ func (self *Whisper) add(envelope *Envelope) error {
 	if !self.expirations[envelope.Expiry].Has(hash) {
 		self.expirations[envelope.Expiry].Add(hash)
 
-		// Notify the local node of a message arrival
+		// Notify the local node of a message arrival and archive it
 		go self.postEvent(envelope)
+		self.archive(envelope)
 	}
 	glog.V(logger.Detail).Infof("cached whisper envelope %x\n", envelope)
 
@@ -253,6 +534,13 @@ func (self *Whisper) postEvent(envelope *Envelope) {
 // returning the decrypted message and the key used to achieve it. If not keys
 // are configured, open will return the payload as if non encrypted.
 func (self *Whisper) open(envelope *Envelope) *Message {
+	// Try the symmetric keys first, decryption with a wrong key fails
+	if message := self.openSymmetric(envelope); message != nil {
+		return message
+	}
+	self.keyMu.RLock()
+	defer self.keyMu.RUnlock()
+
 	// Short circuit if no identity is set, and assume clear-text
 	if len(self.keys) == 0 {
 		if message, err := envelope.Open(nil); err == nil {
@@ -273,6 +561,20 @@ func (self *Whisper) open(envelope *Envelope) *Message {
 	return nil
 }
 
+// openSymmetric tries to decrypt a whisper envelope with all the stored
+// symmetric keys.
+func (self *Whisper) openSymmetric(envelope *Envelope) *Message {
+	self.symKeyMu.RLock()
+	defer self.symKeyMu.RUnlock()
+
+	for _, key := range self.symKeys {
+		if message, err := envelope.OpenSymmetric(key); err == nil {
+			return message
+		}
+	}
+	return nil
+}
+
 // createFilter creates a message filter to check against installed handlers.
 func createFilter(message *Message, topics []Topic) filter.Filter {
 	matcher := make([][]Topic, len(topics))
@@ -282,6 +584,7 @@ func createFilter(message *Message, topics []Topic) filter.Filter {
 	return filterer{
 		to:      string(crypto.FromECDSAPub(message.To)),
 		from:    string(crypto.FromECDSAPub(message.Recover())),
+		symKey:  string(message.SymKey),
 		matcher: newTopicMatcher(matcher...),
 	}
 }
@@ -318,7 +621,10 @@ func (self *Whisper) expire() {
 		}
 		// Dump all expired messages and remove timestamp
 		hashSet.Each(func(v interface{}) bool {
-			delete(self.messages, v.(common.Hash))
+			if envelope, ok := self.messages[v.(common.Hash)]; ok {
+				self.poolSize -= envelope.Size()
+				delete(self.messages, v.(common.Hash))
+			}
 			return true
 		})
 		self.expirations[then].Clear()
@@ -1,9 +1,14 @@
 package whisper
 
 import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 )
@@ -103,6 +108,44 @@ func TestDirectMessage(t *testing.T) {
 	}
 }
 
+func TestGroupMessage(t *testing.T) {
+	// Start a cluster with a sender and two group members
+	cluster := startTestCluster(3)
+	key := DeriveSymKey("group password")
+
+	done := make(chan struct{}, 2)
+	for _, member := range cluster[1:] {
+		if err := member.AddSymKey("group", key); err != nil {
+			t.Fatalf("failed to add group key: %v", err)
+		}
+		member.Watch(Filter{
+			SymKey: key,
+			Fn: func(msg *Message) {
+				done <- struct{}{}
+			},
+		})
+	}
+	// Send a single message to the whole group
+	msg := NewMessage([]byte("group whisper"))
+	envelope, err := msg.Wrap(DefaultPoW, Options{
+		SymKey: key,
+		TTL:    DefaultTTL,
+	})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := cluster[0].Send(envelope); err != nil {
+		t.Fatalf("failed to send group message: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("group message receive timeout")
+		}
+	}
+}
+
 func TestAnonymousBroadcast(t *testing.T) {
 	testBroadcast(true, t)
 }
@@ -158,6 +201,51 @@ func testBroadcast(anonymous bool, t *testing.T) {
 	}
 }
 
+func TestEnvelopeAdmission(t *testing.T) {
+	node := New()
+
+	envelope, err := NewMessage([]byte("admission test")).Wrap(DefaultPoW, Options{TTL: DefaultTTL})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	node.SetMinimumPoW(envelope.PoW() * 2)
+	if err := node.Send(envelope); err != errLowPoW {
+		t.Fatalf("error mismatch for low PoW: have %v, want %v", err, errLowPoW)
+	}
+	node.SetMinimumPoW(envelope.PoW())
+	if err := node.Send(envelope); err != nil {
+		t.Fatalf("failed to send envelope: %v", err)
+	}
+	if size := node.poolSize; size != envelope.Size() {
+		t.Fatalf("pool size mismatch: have %d, want %d", size, envelope.Size())
+	}
+
+	node.SetMinimumPoW(0)
+	// The size is checked before the work, so hardly any work is needed
+	oversized, err := NewMessage(make([]byte, MaxEnvelopeSize)).Wrap(0, Options{TTL: DefaultTTL, PoW: 1e-9})
+	if err != nil {
+		t.Fatalf("failed to wrap message: %v", err)
+	}
+	if err := node.Send(oversized); err != errOversized {
+		t.Fatalf("error mismatch for oversized envelope: have %v, want %v", err, errOversized)
+	}
+}
+
+// Tests that envelopes with realistic payloads are sealed with enough work
+// for the sending node to accept them.
+func TestSendPayloadSizes(t *testing.T) {
+	node := New()
+	for _, size := range []int{4 * 1024, 8 * 1024, 16 * 1024} {
+		envelope, err := NewMessage(make([]byte, size)).Wrap(DefaultPoW, Options{TTL: DefaultTTL})
+		if err != nil {
+			t.Fatalf("size %d: failed to wrap message: %v", size, err)
+		}
+		if err := node.Send(envelope); err != nil {
+			t.Errorf("size %d: failed to send envelope with PoW %f: %v", size, envelope.PoW(), err)
+		}
+	}
+}
+
 func TestMessageExpiration(t *testing.T) {
 	// Start the single node cluster and inject a dummy message
 	node := startTestCluster(1)[0]
@@ -183,3 +271,83 @@ func TestMessageExpiration(t *testing.T) {
 		t.Fatalf("message not expired from cache")
 	}
 }
+
+func TestIdentityPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-keys")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Generate and import identities into a persisted client
+	client := New()
+	if err := client.SetKeyStore(crypto.NewKeyStorePlain(dir), ""); err != nil {
+		t.Fatalf("failed to set key store: %v", err)
+	}
+	generated := client.NewIdentity()
+	imported, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := client.ImportIdentity(imported); err != nil {
+		t.Fatalf("failed to import identity: %v", err)
+	}
+	// A new client on the same key store should know both
+	restarted := New()
+	if err := restarted.SetKeyStore(crypto.NewKeyStorePlain(dir), ""); err != nil {
+		t.Fatalf("failed to set key store: %v", err)
+	}
+	if ids := restarted.Identities(); len(ids) != 2 {
+		t.Fatalf("identity count mismatch: have %d, want 2", len(ids))
+	}
+	for _, key := range []*ecdsa.PrivateKey{generated, imported} {
+		if have := restarted.GetIdentity(&key.PublicKey); have == nil || have.D.Cmp(key.D) != 0 {
+			t.Fatalf("identity %x not restored", crypto.FromECDSAPub(&key.PublicKey))
+		}
+	}
+	// Deleted identities should be gone after a restart too
+	if err := restarted.DeleteIdentity(&generated.PublicKey); err != nil {
+		t.Fatalf("failed to delete identity: %v", err)
+	}
+	if err := restarted.DeleteIdentity(&generated.PublicKey); err != errUnknownIdentity {
+		t.Fatalf("double delete error mismatch: have %v, want %v", err, errUnknownIdentity)
+	}
+	restarted = New()
+	if err := restarted.SetKeyStore(crypto.NewKeyStorePlain(dir), ""); err != nil {
+		t.Fatalf("failed to set key store: %v", err)
+	}
+	if restarted.HasIdentity(&generated.PublicKey) || !restarted.HasIdentity(&imported.PublicKey) {
+		t.Fatalf("identity deletion not persisted")
+	}
+}
+
+func TestIdentityImportFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "whisper-keys")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	client := New()
+	if err := client.SetKeyStore(crypto.NewKeyStorePlain(filepath.Join(dir, "keys")), ""); err != nil {
+		t.Fatalf("failed to set key store: %v", err)
+	}
+	// Make the key store unwritable by putting a file in place of its directory
+	if err := ioutil.WriteFile(filepath.Join(dir, "keys"), nil, 0600); err != nil {
+		t.Fatalf("failed to block key store: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := client.ImportIdentity(key); err == nil {
+		t.Fatalf("import into unwritable key store succeeded")
+	}
+	if client.HasIdentity(&key.PublicKey) {
+		t.Fatalf("identity known despite failed import")
+	}
+	// Generated identities remain usable until a restart
+	if generated := client.NewIdentity(); !client.HasIdentity(&generated.PublicKey) {
+		t.Fatalf("generated identity unknown")
+	}
+}
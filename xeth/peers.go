@@ -0,0 +1,152 @@
+package xeth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// PeerEvent is the flattened form of the p2p peer events, as returned to
+// the RPC and console clients.
+type PeerEvent struct {
+	Type     string `json:"type"` // "add", "drop" or "msg"
+	Peer     string `json:"peer"`
+	Reason   string `json:"reason,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Code     uint64 `json:"code"`
+	Size     uint32 `json:"size"`
+	Incoming bool   `json:"incoming"`
+}
+
+func newPeerEvent(ev interface{}) *PeerEvent {
+	switch ev := ev.(type) {
+	case p2p.PeerAddEvent:
+		return &PeerEvent{Type: "add", Peer: ev.Peer.ID().String()}
+	case p2p.PeerDropEvent:
+		return &PeerEvent{Type: "drop", Peer: ev.Peer.ID().String(), Reason: ev.Reason.String()}
+	case p2p.PeerMsgEvent:
+		return &PeerEvent{
+			Type:     "msg",
+			Peer:     ev.Peer.ID().String(),
+			Protocol: ev.Protocol,
+			Code:     ev.Code,
+			Size:     ev.Size,
+			Incoming: ev.Incoming,
+		}
+	}
+	return nil
+}
+
+// peerFilter collects the peer events of a single subscription until they
+// are polled.
+type peerFilter struct {
+	mu      sync.Mutex
+	sub     event.Subscription
+	events  []*PeerEvent
+	timeout time.Time
+}
+
+func (f *peerFilter) loop() {
+	for ev := range f.sub.Chan() {
+		if pev := newPeerEvent(ev); pev != nil {
+			f.mu.Lock()
+			f.events = append(f.events, pev)
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *peerFilter) get() []*PeerEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.timeout = time.Now()
+	events := f.events
+	f.events = nil
+	return events
+}
+
+func (f *peerFilter) activity() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.timeout
+}
+
+// NewPeerEventFilter subscribes to peers being added and dropped and, if
+// messages is set, to the subprotocol messages exchanged with them.
+func (self *XEth) NewPeerEventFilter(messages bool) int {
+	types := []interface{}{p2p.PeerAddEvent{}, p2p.PeerDropEvent{}}
+	if messages {
+		types = append(types, p2p.PeerMsgEvent{})
+	}
+	filter := &peerFilter{
+		sub:     self.backend.EventMux().Subscribe(types...),
+		timeout: time.Now(),
+	}
+	go filter.loop()
+
+	self.peerMut.Lock()
+	defer self.peerMut.Unlock()
+
+	self.peerFilterId++
+	self.peerFilters[self.peerFilterId] = filter
+	return self.peerFilterId
+}
+
+// PeerEventsChanged returns the peer events collected since the last poll.
+func (self *XEth) PeerEventsChanged(id int) []*PeerEvent {
+	self.peerMut.Lock()
+	filter := self.peerFilters[id]
+	self.peerMut.Unlock()
+
+	if filter == nil {
+		return nil
+	}
+	return filter.get()
+}
+
+// UninstallPeerEventFilter stops and removes a peer event subscription.
+func (self *XEth) UninstallPeerEventFilter(id int) bool {
+	self.peerMut.Lock()
+	defer self.peerMut.Unlock()
+
+	if filter, ok := self.peerFilters[id]; ok {
+		filter.sub.Unsubscribe()
+		delete(self.peerFilters, id)
+		return true
+	}
+	return false
+}
+
+// expirePeerFilters uninstalls the peer event filters that were not polled
+// within filterTickerTime.
+func (self *XEth) expirePeerFilters() {
+	self.peerMut.Lock()
+	defer self.peerMut.Unlock()
+
+	for id, filter := range self.peerFilters {
+		if time.Since(filter.activity()) > filterTickerTime {
+			filter.sub.Unsubscribe()
+			delete(self.peerFilters, id)
+		}
+	}
+}
+
+func (self *XEth) AddPeer(nodeURL string) error {
+	return self.backend.AddPeer(nodeURL)
+}
+
+func (self *XEth) RemovePeer(nodeURL string) error {
+	return self.backend.RemovePeer(nodeURL)
+}
+
+func (self *XEth) AddTrustedPeer(nodeURL string) error {
+	return self.backend.AddTrustedPeer(nodeURL)
+}
+
+func (self *XEth) RemoveTrustedPeer(nodeURL string) error {
+	return self.backend.RemoveTrustedPeer(nodeURL)
+}
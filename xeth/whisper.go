@@ -4,11 +4,13 @@ package xeth
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/whisper"
 )
 
@@ -17,11 +19,17 @@ var qlogger = logger.NewLogger("XSHH")
 // Whisper represents the API wrapper around the internal whisper implementation.
 type Whisper struct {
 	*whisper.Whisper
+
+	filters  map[int]*whisperFilter // Poll based message filters installed by clients
+	filterMu sync.RWMutex           // Mutex to sync the filter pool
 }
 
 // NewWhisper wraps an internal whisper client into an external API version.
 func NewWhisper(w *whisper.Whisper) *Whisper {
-	return &Whisper{w}
+	return &Whisper{
+		Whisper: w,
+		filters: make(map[int]*whisperFilter),
+	}
 }
 
 // NewIdentity generates a new cryptographic identity for the client, and injects
@@ -37,8 +45,90 @@ func (self *Whisper) HasIdentity(key string) bool {
 	return self.Whisper.HasIdentity(crypto.ToECDSAPub(common.FromHex(key)))
 }
 
-// Post injects a message into the whisper network for distribution.
-func (self *Whisper) Post(payload string, to, from string, topics []string, priority, ttl uint32) error {
+// Identities returns the public keys of all the known identities.
+func (self *Whisper) Identities() []string {
+	keys := self.Whisper.Identities()
+
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = common.ToHex(crypto.FromECDSAPub(key))
+	}
+	return ids
+}
+
+// ImportIdentity injects a HEX encoded private key into the known identities,
+// returning the public key of the new identity.
+func (self *Whisper) ImportIdentity(key string) (string, error) {
+	raw := common.FromHex(key)
+	if len(raw) != 32 {
+		return "", fmt.Errorf("invalid private key length %d", len(raw))
+	}
+	priv := crypto.ToECDSA(raw)
+	if err := self.Whisper.ImportIdentity(priv); err != nil {
+		return "", err
+	}
+	return common.ToHex(crypto.FromECDSAPub(&priv.PublicKey)), nil
+}
+
+// ExportIdentity returns the HEX encoded private key of an identity.
+func (self *Whisper) ExportIdentity(identity string) (string, error) {
+	key := self.Whisper.GetIdentity(crypto.ToECDSAPub(common.FromHex(identity)))
+	if key == nil {
+		return "", fmt.Errorf("unknown identity: %s", identity)
+	}
+	return common.ToHex(crypto.FromECDSA(key)), nil
+}
+
+// DeleteIdentity removes an identity, making messages sent to it unreadable.
+func (self *Whisper) DeleteIdentity(identity string) error {
+	return self.Whisper.DeleteIdentity(crypto.ToECDSAPub(common.FromHex(identity)))
+}
+
+// GenerateSymKey creates a random symmetric key, stores it under the given
+// name and returns it.
+func (self *Whisper) GenerateSymKey(name string) (string, error) {
+	key, err := whisper.GenerateSymKey()
+	if err != nil {
+		return "", err
+	}
+	if err := self.Whisper.AddSymKey(name, key); err != nil {
+		return "", err
+	}
+	return common.ToHex(key), nil
+}
+
+// AddSymKey stores a symmetric key under the given name. The key is either
+// given directly or derived from a password or a topic, exactly one of which
+// must be set.
+func (self *Whisper) AddSymKey(name, key, password, topic string) error {
+	var raw []byte
+	switch {
+	case key != "" && password == "" && topic == "":
+		raw = common.FromHex(key)
+	case key == "" && password != "" && topic == "":
+		raw = whisper.DeriveSymKey(password)
+	case key == "" && password == "" && topic != "":
+		raw = whisper.TopicSymKey(topic)
+	default:
+		return fmt.Errorf("exactly one of key, password or topic must be given")
+	}
+	return self.Whisper.AddSymKey(name, raw)
+}
+
+// symKey retrieves the symmetric key stored under the given name, if any.
+func (self *Whisper) symKey(name string) ([]byte, error) {
+	if len(name) == 0 {
+		return nil, nil
+	}
+	if key := self.Whisper.GetSymKey(name); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown symmetric key: %s", name)
+}
+
+// Post injects a message into the whisper network for distribution. If symKey
+// is non-empty, the message is encrypted with the symmetric key of that name.
+func (self *Whisper) Post(payload string, to, from, symKey string, topics []string, priority, ttl uint32) error {
 	// Decode the topic strings
 	topicsDecoded := make([][]byte, len(topics))
 	for i, topic := range topics {
@@ -50,6 +140,7 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 		To:     crypto.ToECDSAPub(common.FromHex(to)),
 		TTL:    time.Duration(ttl) * time.Second,
 		Topics: whisper.NewTopics(topicsDecoded...),
+		PoW:    self.Whisper.MinimumPoW(),
 	}
 	if len(from) != 0 {
 		if key := self.Whisper.GetIdentity(crypto.ToECDSAPub(common.FromHex(from))); key != nil {
@@ -58,6 +149,11 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 			return fmt.Errorf("unknown identity to send from: %s", from)
 		}
 	}
+	key, err := self.symKey(symKey)
+	if err != nil {
+		return err
+	}
+	options.SymKey = key
 	// Wrap and send the message
 	pow := time.Duration(priority) * time.Millisecond
 	envelope, err := message.Wrap(pow, options)
@@ -70,9 +166,37 @@ func (self *Whisper) Post(payload string, to, from string, topics []string, prio
 	return nil
 }
 
+// RequestMessages asks the mail server at the given peer for the archived
+// messages sent between from and to (Unix time, now if zero) with any of the
+// given topics, or any topic if none are given. Delivered messages are passed
+// to the installed filters and watchers.
+func (self *Whisper) RequestMessages(peer string, from, to uint32, topics []string) error {
+	id, err := discover.HexID(peer)
+	if err != nil {
+		return fmt.Errorf("invalid peer id %q: %v", peer, err)
+	}
+	if to == 0 {
+		to = uint32(time.Now().Unix())
+	}
+	topicsDecoded := make([][]byte, len(topics))
+	for i, topic := range topics {
+		topicsDecoded[i] = common.FromHex(topic)
+	}
+	return self.Whisper.RequestMessages(id, whisper.MailRequest{
+		From:   from,
+		To:     to,
+		Topics: whisper.NewTopics(topicsDecoded...),
+	})
+}
+
 // Watch installs a new message handler to run in case a matching packet arrives
-// from the whisper network.
-func (self *Whisper) Watch(to, from string, topics [][]string, fn func(WhisperMessage)) int {
+// from the whisper network. If symKey is non-empty, only messages encrypted
+// with the symmetric key of that name match.
+func (self *Whisper) Watch(to, from, symKey string, topics [][]string, fn func(WhisperMessage)) (int, error) {
+	key, err := self.symKey(symKey)
+	if err != nil {
+		return 0, err
+	}
 	// Decode the topic strings
 	topicsDecoded := make([][][]byte, len(topics))
 	for i, condition := range topics {
@@ -85,12 +209,13 @@ func (self *Whisper) Watch(to, from string, topics [][]string, fn func(WhisperMe
 	filter := whisper.Filter{
 		To:     crypto.ToECDSAPub(common.FromHex(to)),
 		From:   crypto.ToECDSAPub(common.FromHex(from)),
+		SymKey: key,
 		Topics: whisper.NewFilterTopics(topicsDecoded...),
 	}
 	filter.Fn = func(message *whisper.Message) {
 		fn(NewWhisperMessage(message))
 	}
-	return self.Whisper.Watch(filter)
+	return self.Whisper.Watch(filter), nil
 }
 
 // Messages retrieves all the currently pooled messages matching a filter id.
@@ -103,3 +228,81 @@ func (self *Whisper) Messages(id int) []WhisperMessage {
 	}
 	return messages
 }
+
+// NewFilter creates and registers a new poll based message filter to watch for
+// inbound whisper messages. All parameters at this point are assumed to be HEX
+// encoded, except symKey which is the name of a stored symmetric key.
+func (self *Whisper) NewFilter(to, from, symKey string, topics [][]string) (int, error) {
+	// Pre-define the id to be filled later
+	var id int
+
+	// Callback to delegate core whisper messages to this xeth filter
+	callback := func(msg WhisperMessage) {
+		self.filterMu.RLock() // Only read lock to the filter pool
+		defer self.filterMu.RUnlock()
+
+		if filter := self.filters[id]; filter != nil {
+			filter.insert(msg)
+		}
+	}
+	// Initialize the core whisper filter and wrap into xeth
+	self.filterMu.Lock()
+	defer self.filterMu.Unlock()
+
+	id, err := self.Watch(to, from, symKey, topics, callback)
+	if err != nil {
+		return 0, err
+	}
+	self.filters[id] = newWhisperFilter(id, self)
+
+	return id, nil
+}
+
+// UninstallFilter disables and removes an existing filter.
+func (self *Whisper) UninstallFilter(id int) bool {
+	self.filterMu.Lock()
+	defer self.filterMu.Unlock()
+
+	if _, ok := self.filters[id]; ok {
+		self.Unwatch(id)
+		delete(self.filters, id)
+		return true
+	}
+	return false
+}
+
+// FilterMessages retrieves all the known messages that match a specific filter.
+func (self *Whisper) FilterMessages(id int) []WhisperMessage {
+	self.filterMu.RLock()
+	defer self.filterMu.RUnlock()
+
+	if filter := self.filters[id]; filter != nil {
+		return filter.messages()
+	}
+	return nil
+}
+
+// FilterChanges retrieves all the new messages matched by a filter since the
+// last retrieval.
+func (self *Whisper) FilterChanges(id int) []WhisperMessage {
+	self.filterMu.RLock()
+	defer self.filterMu.RUnlock()
+
+	if filter := self.filters[id]; filter != nil {
+		return filter.retrieve()
+	}
+	return nil
+}
+
+// ExpireFilters removes all the filters not polled for longer than timeout.
+func (self *Whisper) ExpireFilters(timeout time.Duration) {
+	self.filterMu.Lock()
+	defer self.filterMu.Unlock()
+
+	for id, filter := range self.filters {
+		if time.Since(filter.activity()) > timeout {
+			self.Unwatch(id)
+			delete(self.filters, id)
+		}
+	}
+}
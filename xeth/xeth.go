@@ -45,8 +45,9 @@ type XEth struct {
 	logMut sync.RWMutex
 	logs   map[int]*logFilter
 
-	messagesMut sync.RWMutex
-	messages    map[int]*whisperFilter
+	peerMut      sync.Mutex
+	peerFilters  map[int]*peerFilter
+	peerFilterId int
 
 	// regmut   sync.Mutex
 	// register map[string][]*interface{} // TODO improve return type
@@ -65,7 +66,7 @@ func New(eth *eth.Ethereum, frontend Frontend) *XEth {
 		quit:          make(chan struct{}),
 		filterManager: filter.NewFilterManager(eth.EventMux()),
 		logs:          make(map[int]*logFilter),
-		messages:      make(map[int]*whisperFilter),
+		peerFilters:   make(map[int]*peerFilter),
 		agent:         miner.NewRemoteAgent(),
 	}
 	eth.Miner().Register(xeth.agent)
@@ -88,22 +89,17 @@ done:
 		select {
 		case <-timer.C:
 			self.logMut.Lock()
-			self.messagesMut.Lock()
 			for id, filter := range self.logs {
 				if time.Since(filter.timeout) > filterTickerTime {
 					self.filterManager.UninstallFilter(id)
 					delete(self.logs, id)
 				}
 			}
-
-			for id, filter := range self.messages {
-				if time.Since(filter.activity()) > filterTickerTime {
-					self.Whisper().Unwatch(id)
-					delete(self.messages, id)
-				}
-			}
-			self.messagesMut.Unlock()
 			self.logMut.Unlock()
+
+			self.whisper.ExpireFilters(filterTickerTime)
+
+			self.expirePeerFilters()
 		case <-self.quit:
 			break done
 		}
@@ -458,60 +454,25 @@ func (self *XEth) AllLogs(earliest, latest int64, skip, max int, address []strin
 
 // NewWhisperFilter creates and registers a new message filter to watch for
 // inbound whisper messages. All parameters at this point are assumed to be
-// HEX encoded.
-func (p *XEth) NewWhisperFilter(to, from string, topics [][]string) int {
-	// Pre-define the id to be filled later
-	var id int
-
-	// Callback to delegate core whisper messages to this xeth filter
-	callback := func(msg WhisperMessage) {
-		p.messagesMut.RLock() // Only read lock to the filter pool
-		defer p.messagesMut.RUnlock()
-		p.messages[id].insert(msg)
-	}
-	// Initialize the core whisper filter and wrap into xeth
-	id = p.Whisper().Watch(to, from, topics, callback)
-
-	p.messagesMut.Lock()
-	p.messages[id] = newWhisperFilter(id, p.Whisper())
-	p.messagesMut.Unlock()
-
-	return id
+// HEX encoded, except symKey which is the name of a stored symmetric key.
+func (p *XEth) NewWhisperFilter(to, from, symKey string, topics [][]string) (int, error) {
+	return p.Whisper().NewFilter(to, from, symKey, topics)
 }
 
 // UninstallWhisperFilter disables and removes an existing filter.
 func (p *XEth) UninstallWhisperFilter(id int) bool {
-	p.messagesMut.Lock()
-	defer p.messagesMut.Unlock()
-
-	if _, ok := p.messages[id]; ok {
-		delete(p.messages, id)
-		return true
-	}
-	return false
+	return p.Whisper().UninstallFilter(id)
 }
 
 // WhisperMessages retrieves all the known messages that match a specific filter.
 func (self *XEth) WhisperMessages(id int) []WhisperMessage {
-	self.messagesMut.RLock()
-	defer self.messagesMut.RUnlock()
-
-	if self.messages[id] != nil {
-		return self.messages[id].messages()
-	}
-	return nil
+	return self.Whisper().FilterMessages(id)
 }
 
 // WhisperMessagesChanged retrieves all the new messages matched by a filter
 // since the last retrieval
 func (self *XEth) WhisperMessagesChanged(id int) []WhisperMessage {
-	self.messagesMut.RLock()
-	defer self.messagesMut.RUnlock()
-
-	if self.messages[id] != nil {
-		return self.messages[id].retrieve()
-	}
-	return nil
+	return self.Whisper().FilterChanges(id)
 }
 
 // func (self *XEth) Register(args string) bool {
@@ -749,6 +710,61 @@ func (self *XEth) sign(tx *types.Transaction, from common.Address, didUnlock boo
 	return nil
 }
 
+// Sign signs the message hash of data with the key of the given account,
+// unlocking it through the frontend if necessary. The signature is returned
+// as [R || S || V], where V is 27 or 28.
+func (self *XEth) Sign(fromStr, dataStr string, didUnlock bool) (string, error) {
+	from := common.HexToAddress(fromStr)
+	sig, err := self.backend.AccountManager().Sign(accounts.Account{Address: from.Bytes()}, crypto.MessageHash(common.FromHex(dataStr)))
+	if err == accounts.ErrLocked {
+		if didUnlock {
+			return "", fmt.Errorf("signer account still locked after successful unlock")
+		}
+		if !self.frontend.UnlockAccount(from.Bytes()) {
+			return "", fmt.Errorf("could not unlock signer account")
+		}
+		// retry signing, the account should now be unlocked.
+		return self.Sign(fromStr, dataStr, true)
+	} else if err != nil {
+		return "", err
+	}
+	sig[64] += 27
+	return common.ToHex(sig), nil
+}
+
+// SignWithPassphrase is like Sign, but unlocks the account with the given
+// passphrase for this signature only.
+func (self *XEth) SignWithPassphrase(fromStr, dataStr, passphrase string) (string, error) {
+	from := common.HexToAddress(fromStr)
+	sig, err := self.backend.AccountManager().SignWithPassphrase(accounts.Account{Address: from.Bytes()}, passphrase, crypto.MessageHash(common.FromHex(dataStr)))
+	if err != nil {
+		return "", err
+	}
+	sig[64] += 27
+	return common.ToHex(sig), nil
+}
+
+// EcRecover returns the address of the account that signed data with Sign or
+// SignWithPassphrase. V may be given as 0 or 1 as well.
+func (self *XEth) EcRecover(dataStr, sigStr string) (string, error) {
+	sig := common.FromHex(sigStr)
+	if len(sig) != 65 {
+		return "", fmt.Errorf("signature must be 65 bytes long")
+	}
+	sig = common.CopyBytes(sig)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return "", fmt.Errorf("invalid signature recovery id %d", sig[64])
+	}
+	pub, err := crypto.SigToPub(crypto.MessageHash(common.FromHex(dataStr)), sig)
+	if err != nil {
+		return "", err
+	}
+	return common.ToHex(crypto.PubkeyToAddress(*pub)), nil
+}
+
 // callmsg is the message type used for call transations.
 type callmsg struct {
 	from          *state.StateObject